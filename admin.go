@@ -0,0 +1,42 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	postgresConfig "github.com/nihal-ramaswamy/GoChat/internal/postgres"
+	"go.uber.org/zap"
+)
+
+// admin runs the admin subcommand, which bootstraps the first admin:
+//
+//	go run . admin promote <email>
+func admin(args []string, log *zap.Logger) error {
+	if len(args) != 2 || args[0] != "promote" {
+		return fmt.Errorf("Usage: admin promote <email>")
+	}
+
+	pdb := db.GetPostgresDbInstanceWithConfig(postgresConfig.GetPsqlInfoDefault(), log)
+	defer pdb.Close()
+
+	user, err := db.GetUserFromEmail(pdb, args[1])
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("User %s does not exist", args[1])
+	}
+	if err != nil {
+		return err
+	}
+	if user.Type == constants.USER_TYPE_BOT {
+		return fmt.Errorf("User %s is a bot", args[1])
+	}
+
+	if err := db.SetRole(pdb, user.Id, constants.ROLE_ADMIN); err != nil {
+		return err
+	}
+	// Tokens issued before carry the old role until the user signs in again
+	log.Info("Promoted user to admin", zap.String("id", user.Id), zap.String("email", user.Email))
+	return nil
+}
@@ -1,25 +1,120 @@
 package amqpConfig
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/nihal-ramaswamy/GoChat/internal/constants"
 	"github.com/nihal-ramaswamy/GoChat/internal/utils"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
 )
 
+var (
+	// ErrUnroutable is returned for persistent messages no queue was bound for
+	ErrUnroutable = errors.New("Message was not routed to any queue")
+	// ErrNotConfirmed is returned for persistent messages RabbitMQ did not accept
+	ErrNotConfirmed = errors.New("Message was not confirmed")
+	// ErrNotConnected is returned until the first connection to RabbitMQ is made
+	ErrNotConnected = errors.New("Not connected to RabbitMQ")
+)
+
+// AmqpConfig manages the connection to RabbitMQ. When the connection or its
+// channel closes unexpectedly it reconnects with exponential backoff and
+// redeclares the chat exchange. User queues are durable and redeclared by the
+// next publish or consumer.
 type AmqpConfig struct {
-	Host    string
-	Conn    *amqp.Connection
-	Channel *amqp.Channel
-	Queue   amqp.Queue
+	Host string
+	log  *zap.Logger
+
+	mu      sync.RWMutex
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	state   string
+	// Closed once connected, replaced while reconnecting
+	ready chan struct{}
+	// Idle channels for persistent publishes, replaced with the connection
+	confirms chan *confirmChannel
+	// Ids of users whose queue was declared on the connection, replaced with it
+	declared *sync.Map
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// confirmChannel is a channel in confirm mode taken by one persistent publish
+// at a time, so the returns on it belong to that publish. Publishes on
+// different channels wait for their confirmations concurrently.
+type confirmChannel struct {
+	ch *amqp.Channel
+	// Mandatory messages of the channel that reached no queue
+	returns chan amqp.Return
+}
+
+// NewAmqpConfig connects to host. If RabbitMQ is not reachable yet it starts
+// in the reconnecting state and keeps dialing in the background, so the
+// process can start before RabbitMQ does.
+func NewAmqpConfig(host string, log *zap.Logger) *AmqpConfig {
+	a := &AmqpConfig{
+		Host:  host,
+		log:   log,
+		state: constants.BROKER_STATE_RECONNECTING,
+		ready: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+
+	conn, ch, confirms, err := dial(host)
+	if err != nil {
+		log.Warn("Error connecting to RabbitMQ, retrying in the background", zap.Error(err))
+		go func() {
+			if a.reconnect() {
+				a.watch()
+			}
+		}()
+		return a
+	}
+	a.setConnection(conn, ch, confirms)
+	go a.watch()
+	return a
+}
+
+func DefaultAmqpConfig(log *zap.Logger) *AmqpConfig {
+	return NewAmqpConfig(utils.GetDotEnvVariable(constants.RABBITMQ_HOST), log)
 }
 
-func NewAmqpConfig(host string) (*AmqpConfig, error) {
+// dial connects to host and opens the shared channel and the channels for
+// persistent publishes
+func dial(host string) (*amqp.Connection, *amqp.Channel, chan *confirmChannel, error) {
 	conn, err := amqp.Dial(host)
 	if err != nil {
-		panic(fmt.Errorf("Failed to connect to RabbitMQ: %s", err))
+		return nil, nil, nil, fmt.Errorf("Failed to connect to RabbitMQ: %s", err)
+	}
+	ch, err := openChannel(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+
+	confirms := make(chan *confirmChannel, constants.AMQP_CONFIRM_CHANNELS)
+	for range constants.AMQP_CONFIRM_CHANNELS {
+		c, err := openConfirmChannel(conn)
+		if err != nil {
+			conn.Close()
+			return nil, nil, nil, err
+		}
+		confirms <- c
 	}
+	return conn, ch, confirms, nil
+}
+
+// openChannel opens a channel and declares the chat exchange on it
+func openChannel(conn *amqp.Connection) (*amqp.Channel, error) {
 	ch, err := conn.Channel()
 	if err != nil {
 		return nil, fmt.Errorf("Failed to open channel: %s", err)
@@ -34,34 +129,470 @@ func NewAmqpConfig(host string) (*AmqpConfig, error) {
 		false,
 		nil)
 	if err != nil {
+		ch.Close()
 		return nil, fmt.Errorf("Failed to declare exchange: %s", err)
 	}
+	return ch, nil
+}
+
+// openConfirmChannel opens a channel in confirm mode for persistent publishes.
+// The exchange is declared by the shared channel of the same connection.
+func openConfirmChannel(conn *amqp.Connection) (*confirmChannel, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open channel: %s", err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("Failed to enable publisher confirms: %s", err)
+	}
+	return &confirmChannel{ch: ch, returns: ch.NotifyReturn(make(chan amqp.Return, 16))}, nil
+}
+
+func (a *AmqpConfig) setConnection(conn *amqp.Connection, ch *amqp.Channel, confirms chan *confirmChannel) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.conn, a.channel, a.confirms = conn, ch, confirms
+	// Queues may be gone if RabbitMQ restarted, so declare them again
+	a.declared = &sync.Map{}
+	a.state = constants.BROKER_STATE_CONNECTED
+	close(a.ready)
+}
+
+func (a *AmqpConfig) setChannel(ch *amqp.Channel) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.channel = ch
+}
+
+// setReconnecting blocks Ready until setConnection is called
+func (a *AmqpConfig) setReconnecting() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.state == constants.BROKER_STATE_CLOSED {
+		return
+	}
+	if a.state == constants.BROKER_STATE_CONNECTED {
+		a.ready = make(chan struct{})
+	}
+	a.state = constants.BROKER_STATE_RECONNECTING
+}
+
+// Channel returns the channel shared by publishers. It is replaced when the
+// connection is recovered, so callers should not keep it.
+func (a *AmqpConfig) Channel() *amqp.Channel {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.channel
+}
+
+// publisher returns the shared channel, or ErrNotConnected if RabbitMQ was
+// never reached
+func (a *AmqpConfig) publisher() (*amqp.Channel, error) {
+	if ch := a.Channel(); ch != nil {
+		return ch, nil
+	}
+	return nil, ErrNotConnected
+}
+
+func (a *AmqpConfig) connection() *amqp.Connection {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.conn
+}
+
+// State returns constants.BROKER_STATE_CONNECTED, BROKER_STATE_RECONNECTING
+// or BROKER_STATE_CLOSED
+func (a *AmqpConfig) State() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.state
+}
+
+// Ready returns a channel that is closed while connected
+func (a *AmqpConfig) Ready() <-chan struct{} {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.ready
+}
+
+// Done returns a channel that is closed once Close is called
+func (a *AmqpConfig) Done() <-chan struct{} {
+	return a.done
+}
 
+// watch recovers the connection and the shared channel until Close is called
+func (a *AmqpConfig) watch() {
+	for {
+		conn, ch := a.connection(), a.Channel()
+		connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		chClosed := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+		select {
+		case <-a.done:
+			return
+		case err := <-chClosed:
+			if a.closing() {
+				return
+			}
+			// Channel errors, such as unbinding a missing queue, close only
+			// the channel
+			if !conn.IsClosed() {
+				a.log.Warn("RabbitMQ channel closed, reopening", zap.Any("reason", err))
+				if ch, err := openChannel(conn); err == nil {
+					a.setChannel(ch)
+					continue
+				}
+				conn.Close()
+			}
+		case err := <-connClosed:
+			if a.closing() {
+				return
+			}
+			a.log.Warn("RabbitMQ connection closed", zap.Any("reason", err))
+		}
+
+		if !a.reconnect() {
+			return
+		}
+	}
+}
+
+// reconnect dials until it succeeds or Close is called. It returns false if
+// Close was called.
+func (a *AmqpConfig) reconnect() bool {
+	a.setReconnecting()
+	delay := constants.AMQP_RECONNECT_BASE
+	for {
+		conn, ch, confirms, err := dial(a.Host)
+		if err == nil {
+			if a.closing() {
+				conn.Close()
+				return false
+			}
+			a.setConnection(conn, ch, confirms)
+			a.log.Info("Reconnected to RabbitMQ")
+			return true
+		}
+
+		a.log.Warn("Error reconnecting to RabbitMQ", zap.Duration("retry_after", delay), zap.Error(err))
+		select {
+		case <-a.done:
+			return false
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, constants.AMQP_RECONNECT_MAX)
+	}
+}
+
+func (a *AmqpConfig) closing() bool {
+	select {
+	case <-a.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops recovering the connection and closes it
+func (a *AmqpConfig) Close() error {
+	var err error
+	a.closeOnce.Do(func() {
+		close(a.done)
+
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.state = constants.BROKER_STATE_CLOSED
+		// Either may already be closed by a broker restart, or never opened
+		if a.channel == nil {
+			return
+		}
+		if closeErr := a.channel.Close(); closeErr != nil && !errors.Is(closeErr, amqp.ErrClosed) {
+			err = closeErr
+		}
+		if closeErr := a.conn.Close(); closeErr != nil && !errors.Is(closeErr, amqp.ErrClosed) {
+			err = closeErr
+		}
+	})
+	return err
+}
+
+// UserQueueName returns the name of the durable delivery queue owned by a user
+func UserQueueName(id string) string {
+	return constants.USER_QUEUE_PREFIX + id
+}
+
+// DeclareUserQueue declares the durable queue for a user and binds it to the
+// chat exchange using the user id as the routing key. Declaring is idempotent,
+// so the queue is created on first use and reused afterwards.
+func DeclareUserQueue(ch *amqp.Channel, id string) (amqp.Queue, error) {
 	queue, err := ch.QueueDeclare(
-		"",    // name
-		false, // durable
-		false, // delete when unused
-		true,  // exclusive
-		false, // no-wait
-		nil,   // arguments
+		UserQueueName(id), // name
+		true,              // durable
+		false,             // delete when unused
+		false,             // exclusive
+		false,             // no-wait
+		nil,               // arguments
 	)
 	if err != nil {
-		panic(fmt.Errorf("Failed to declare queue: %s", err))
+		return queue, fmt.Errorf("Failed to declare queue: %s", err)
 	}
 
-	return &AmqpConfig{
-		Host:    host,
-		Conn:    conn,
-		Channel: ch,
-		Queue:   queue,
-	}, nil
+	err = ch.QueueBind(
+		queue.Name,
+		id,                      // routing key
+		constants.EXCHANGE_NAME, // exchange
+		false,
+		nil)
+	if err != nil {
+		return queue, fmt.Errorf("Failed to bind queue: %s", err)
+	}
+
+	return queue, nil
+}
+
+// declareUserQueue declares the queue of a user unless it was already declared
+// on the current connection, and returns the shared channel
+func (a *AmqpConfig) declareUserQueue(id string) (*amqp.Channel, error) {
+	a.mu.RLock()
+	ch, declared := a.channel, a.declared
+	a.mu.RUnlock()
+	if ch == nil {
+		return nil, ErrNotConnected
+	}
+
+	if _, ok := declared.Load(id); ok {
+		return ch, nil
+	}
+	if _, err := DeclareUserQueue(ch, id); err != nil {
+		return nil, err
+	}
+	declared.Store(id, struct{}{})
+	return ch, nil
+}
+
+// forgetUserQueue makes the next use of the queue of a user declare it again,
+// for when it turned out to be missing
+func (a *AmqpConfig) forgetUserQueue(id string) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.declared != nil {
+		a.declared.Delete(id)
+	}
+}
+
+// Publish sends a persistent message to the queue of the given user. The
+// queue is declared by the first publish on a connection so messages for
+// users that never connected are kept until they do.
+func (a *AmqpConfig) Publish(ctx context.Context, id string, body []byte) error {
+	if _, err := a.declareUserQueue(id); err != nil {
+		return err
+	}
+	err := a.publishConfirmed(ctx, id, body)
+	if errors.Is(err, ErrUnroutable) {
+		// The queue was deleted since it was declared
+		a.forgetUserQueue(id)
+	}
+	return err
+}
+
+// publishConfirmed publishes a persistent, mandatory message and waits until
+// RabbitMQ confirms it. A message that reached no queue is returned by
+// RabbitMQ before it is confirmed and fails with ErrUnroutable, so callers
+// retry it instead of losing it silently.
+func (a *AmqpConfig) publishConfirmed(ctx context.Context, routingKey string, body []byte) error {
+	a.mu.RLock()
+	confirms := a.confirms
+	a.mu.RUnlock()
+	if confirms == nil {
+		return ErrNotConnected
+	}
+
+	var c *confirmChannel
+	select {
+	case c = <-confirms:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer a.releaseConfirmChannel(confirms, c)
+	// Returns of publishes that gave up waiting for their confirmation
+	drainReturns(c.returns, "")
+
+	id := newMessageId()
+	confirmation, err := c.ch.PublishWithDeferredConfirmWithContext(
+		ctx,
+		constants.EXCHANGE_NAME, // Exchange
+		routingKey,              // Routing key
+		true,                    // mandatory
+		false,                   // immediate
+		amqp.Publishing{
+			ContentType:  "text/plain",
+			DeliveryMode: amqp.Persistent,
+			MessageId:    id,
+			Body:         body,
+		})
+	if err != nil {
+		return err
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return ErrNotConfirmed
+	}
+	if drainReturns(c.returns, id) {
+		return ErrUnroutable
+	}
+	return nil
+}
+
+// releaseConfirmChannel puts c back into confirms. A channel closed by a
+// channel error is reopened while its connection is still the current one;
+// otherwise the pool is replaced with the connection.
+func (a *AmqpConfig) releaseConfirmChannel(confirms chan *confirmChannel, c *confirmChannel) {
+	if c.ch.IsClosed() {
+		a.mu.RLock()
+		conn, current := a.conn, a.confirms == confirms
+		a.mu.RUnlock()
+		if current && !conn.IsClosed() {
+			if reopened, err := openConfirmChannel(conn); err == nil {
+				c = reopened
+			}
+		}
+	}
+	confirms <- c
+}
+
+// drainReturns empties returns and reports whether the message with id is among them
+func drainReturns(returns <-chan amqp.Return, id string) bool {
+	returned := false
+	for {
+		select {
+		case r, ok := <-returns:
+			if !ok {
+				return returned
+			}
+			if id != "" && r.MessageId == id {
+				returned = true
+			}
+		default:
+			return returned
+		}
+	}
+}
+
+func newMessageId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Consume opens a dedicated channel and starts consuming the queue of the
+// given user. Deliveries must be acknowledged by the caller; closing the
+// returned channel requeues everything that was not acknowledged.
+func (a *AmqpConfig) Consume(id string) (*amqp.Channel, <-chan amqp.Delivery, error) {
+	conn := a.connection()
+	if conn == nil {
+		return nil, nil, ErrNotConnected
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to open channel: %s", err)
+	}
+
+	queue, err := DeclareUserQueue(ch, id)
+	if err != nil {
+		ch.Close()
+		return nil, nil, err
+	}
+
+	msgs, err := ch.Consume(
+		queue.Name,
+		"",    // consumer
+		false, // auto ack
+		false, // exclusive
+		false, // no local
+		false, // no wait
+		nil,   // args
+	)
+	if err != nil {
+		ch.Close()
+		return nil, nil, fmt.Errorf("Failed to consume queue: %s", err)
+	}
+
+	return ch, msgs, nil
+}
+
+// Bind binds the queue of a user to a routing key, such as a conversation so
+// a single publish to the conversation reaches every member once
+func (a *AmqpConfig) Bind(userId, routingKey string) error {
+	ch, err := a.declareUserQueue(userId)
+	if err != nil {
+		return err
+	}
+
+	err = ch.QueueBind(
+		UserQueueName(userId),
+		routingKey,              // routing key
+		constants.EXCHANGE_NAME, // exchange
+		false,
+		nil)
+	if err != nil {
+		a.forgetUserQueue(userId)
+		return fmt.Errorf("Failed to bind queue: %s", err)
+	}
+	return nil
+}
+
+// Unbind stops delivering messages published to a routing key to a user
+func (a *AmqpConfig) Unbind(userId, routingKey string) error {
+	// Unbinding a missing queue closes the channel, so make sure it exists
+	ch, err := a.declareUserQueue(userId)
+	if err != nil {
+		return err
+	}
+
+	err = ch.QueueUnbind(
+		UserQueueName(userId),
+		routingKey,              // routing key
+		constants.EXCHANGE_NAME, // exchange
+		nil)
+	if err != nil {
+		a.forgetUserQueue(userId)
+		return fmt.Errorf("Failed to unbind queue: %s", err)
+	}
+	return nil
+}
+
+// PublishToBinding sends a persistent message to every queue bound to a
+// routing key. It fails with ErrUnroutable if no queue is bound to it, which
+// happens when RabbitMQ lost its bindings.
+func (a *AmqpConfig) PublishToBinding(ctx context.Context, routingKey string, body []byte) error {
+	return a.publishConfirmed(ctx, routingKey, body)
 }
 
-func DefaultAmqpConfig() *AmqpConfig {
-	// return NewAmqpConfig(utils.GetDotEnvVariable(constants.RABBITMQ_HOST))
-	config, err := NewAmqpConfig(utils.GetDotEnvVariable(constants.RABBITMQ_HOST))
+// PublishEphemeral sends a transient message to a routing key, either a user
+// id or a bound routing key. It is never written to disk and is dropped by the
+// broker if it is not consumed within ttl.
+func (a *AmqpConfig) PublishEphemeral(ctx context.Context, routingKey string, body []byte, ttl time.Duration) error {
+	ch, err := a.publisher()
 	if err != nil {
-		panic(fmt.Errorf("Failed to get amqp config: %s", err))
+		return err
 	}
-	return config
+	return ch.PublishWithContext(
+		ctx,
+		constants.EXCHANGE_NAME, // Exchange
+		routingKey,              // Routing key
+		false,                   // mandatory
+		false,                   // immediate
+		amqp.Publishing{
+			ContentType:  "text/plain",
+			DeliveryMode: amqp.Transient,
+			Expiration:   strconv.FormatInt(ttl.Milliseconds(), 10),
+			Body:         body,
+		})
 }
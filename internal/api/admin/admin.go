@@ -0,0 +1,49 @@
+package admin_api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+// managedUser returns the user in the :id path parameter if the caller may
+// act on them. Nobody can act on themselves, and moderators can only act on
+// users without a role.
+func managedUser(c *gin.Context, pdb *sql.DB, log *zap.Logger) (dto.AdminUser, bool) {
+	target, err := db.GetAdminUser(pdb, c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "User does not exist"})
+		return target, false
+	}
+	if err != nil {
+		log.Error("Error getting user", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return target, false
+	}
+
+	if target.Id == c.GetString("user_id") {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Cannot manage your own account"})
+		return target, false
+	}
+	if c.GetString("role") != constants.ROLE_ADMIN && target.Role != constants.ROLE_USER {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return target, false
+	}
+	return target, true
+}
+
+// audit records an action taken on another account
+func audit(c *gin.Context, log *zap.Logger, action string, target dto.AdminUser, fields ...zap.Field) {
+	fields = append([]zap.Field{
+		zap.String("action", action),
+		zap.String("actor_id", c.GetString("user_id")),
+		zap.String("target_id", target.Id),
+		zap.String("ip", c.ClientIP()),
+	}, fields...)
+	log.Info("Admin action", fields...)
+}
@@ -0,0 +1,184 @@
+package admin_api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/routes"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+)
+
+// Test /admin/users
+// Tests that roles limit who can manage accounts and that disabled accounts
+// are signed out and cannot sign in again
+func TestAdmin(t *testing.T) {
+	ctx := context.Background()
+
+	testConfig, err := testUtils.SetUpRouter(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up server for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := testConfig.PostgresContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+		testConfig.Db.Close()
+	})
+
+	t.Cleanup(func() {
+		if err := testConfig.RabbitmqContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	t.Cleanup(func() {
+		if err := testConfig.RedisContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	routes.NewRoutes(
+		testConfig.Server,
+		testConfig.Db,
+		testConfig.Users,
+		testConfig.Chats,
+		testConfig.Rdb,
+		testConfig.Rdb,
+		ctx,
+		testConfig.Log,
+		testConfig.Broker,
+		testConfig.Relay,
+		testConfig.Upgrader,
+		testConfig.WebsocketMap,
+		testConfig.Storage,
+		testConfig.Mailer,
+		testConfig.OidcProvider,
+		testConfig.Keys,
+	)
+
+	serve := func(method, target, token string, payload any, result any) int {
+		var body bytes.Buffer
+		if payload != nil {
+			if err := json.NewEncoder(&body).Encode(payload); err != nil {
+				t.Fatalf("Error converting payload to json: %s", err)
+			}
+		}
+		req, err := http.NewRequest(method, target, &body)
+		if err != nil {
+			t.Fatalf("Error creating request: %s", err)
+		}
+		if token != "" {
+			req.Header.Set("Token", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		testConfig.Server.ServeHTTP(w, req)
+		if result != nil {
+			if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+				t.Fatalf("Error unmarshalling response: %s", err)
+			}
+		}
+		return w.Code
+	}
+
+	admin := dto.User{Name: "admin", Email: "admin@test", Password: "test"}
+	moderator := dto.User{Name: "moderator", Email: "moderator@test", Password: "test"}
+	user := dto.User{Name: "user", Email: "user@test", Password: "test"}
+	ids := map[string]string{}
+	for _, u := range []dto.User{admin, moderator, user} {
+		if code := serve("POST", "/auth/register", "", u, nil); code != http.StatusAccepted {
+			t.Fatalf("Expected status code: 202, got %d", code)
+		}
+		account, err := db.GetUserFromEmail(testConfig.Db, u.Email)
+		if err != nil {
+			t.Fatalf("Error getting user: %s", err)
+		}
+		ids[u.Email] = account.Id
+	}
+	// The first admin is promoted as `go run . admin promote` does
+	if err := db.SetRole(testConfig.Db, ids[admin.Email], constants.ROLE_ADMIN); err != nil {
+		t.Fatalf("Error setting role: %s", err)
+	}
+
+	signIn := func(u dto.User) string {
+		var tokens dto.TokenPair
+		if code := serve("POST", "/auth/signin", "", u, &tokens); code != http.StatusAccepted {
+			t.Fatalf("Expected status code: 202, got %d", code)
+		}
+		return tokens.Token
+	}
+	adminToken := signIn(admin)
+	moderatorToken := signIn(moderator)
+	userToken := signIn(user)
+
+	if code := serve("GET", "/admin/users", userToken, nil, nil); code != http.StatusForbidden {
+		t.Errorf("Expected status code: 403, got %d", code)
+	}
+
+	var users []dto.AdminUser
+	if code := serve("GET", "/admin/users?q=test", adminToken, nil, &users); code != http.StatusOK || len(users) != 3 {
+		t.Fatalf("Expected three users, got %d %v", code, users)
+	}
+	// Wildcards in the search term match literally
+	for _, q := range []string{"%25", "_", "%5C"} {
+		if code := serve("GET", "/admin/users?q="+q, adminToken, nil, &users); code != http.StatusOK || len(users) != 0 {
+			t.Errorf("Expected no users for %s, got %d %v", q, code, users)
+		}
+	}
+
+	// A role change signs the user out so the new role applies on the next sign in
+	rolePath := "/admin/users/" + ids[moderator.Email] + "/role"
+	role := dto.UpdateRoleRequest{Role: constants.ROLE_MODERATOR}
+	if code := serve("PATCH", rolePath, adminToken, role, nil); code != http.StatusOK {
+		t.Fatalf("Expected status code: 200, got %d", code)
+	}
+	if code := serve("GET", "/admin/users", moderatorToken, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected status code: 401, got %d", code)
+	}
+	moderatorToken = signIn(moderator)
+	if code := serve("GET", "/admin/users", moderatorToken, nil, nil); code != http.StatusOK {
+		t.Errorf("Expected status code: 200, got %d", code)
+	}
+
+	// Moderators cannot change roles or act on admins
+	role = dto.UpdateRoleRequest{Role: constants.ROLE_ADMIN}
+	if code := serve("PATCH", "/admin/users/"+ids[user.Email]+"/role", moderatorToken, role, nil); code != http.StatusForbidden {
+		t.Errorf("Expected status code: 403, got %d", code)
+	}
+	if code := serve("POST", "/admin/users/"+ids[admin.Email]+"/disable", moderatorToken, nil, nil); code != http.StatusForbidden {
+		t.Errorf("Expected status code: 403, got %d", code)
+	}
+	if code := serve("POST", "/admin/users/"+ids[admin.Email]+"/disable", adminToken, nil, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected status code: 400, got %d", code)
+	}
+
+	if code := serve("POST", "/admin/users/"+ids[user.Email]+"/disable", moderatorToken, nil, nil); code != http.StatusAccepted {
+		t.Fatalf("Expected status code: 202, got %d", code)
+	}
+	if code := serve("GET", "/auth/sessions", userToken, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected status code: 401, got %d", code)
+	}
+	if code := serve("POST", "/auth/signin", "", user, nil); code != http.StatusForbidden {
+		t.Errorf("Expected status code: 403, got %d", code)
+	}
+
+	if code := serve("POST", "/admin/users/"+ids[user.Email]+"/enable", moderatorToken, nil, nil); code != http.StatusAccepted {
+		t.Fatalf("Expected status code: 202, got %d", code)
+	}
+	userToken = signIn(user)
+
+	if code := serve("DELETE", "/admin/users/"+ids[user.Email]+"/sessions", moderatorToken, nil, nil); code != http.StatusAccepted {
+		t.Fatalf("Expected status code: 202, got %d", code)
+	}
+	if code := serve("GET", "/auth/sessions", userToken, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected status code: 401, got %d", code)
+	}
+}
@@ -0,0 +1,96 @@
+package admin_api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"github.com/nihal-ramaswamy/GoChat/internal/session"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type DisableUserHandler struct {
+	dto.HandlerInterface
+	pdb         *sql.DB
+	rdb         *redis.Client
+	ctx         context.Context
+	log         *zap.Logger
+	middlewares []gin.HandlerFunc
+}
+
+func NewDisableUserHandler(
+	pdb *sql.DB,
+	rdb *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+) *DisableUserHandler {
+	return &DisableUserHandler{
+		pdb:         pdb,
+		rdb:         rdb,
+		ctx:         ctx,
+		log:         log,
+		middlewares: []gin.HandlerFunc{middlewares.RequirePermission(constants.PERMISSION_USERS_DISABLE)},
+	}
+}
+
+func (*DisableUserHandler) Pattern() string {
+	return "/users/:id/disable"
+}
+
+func (*DisableUserHandler) RequestMethod() string {
+	return constants.POST
+}
+
+// Handler to disable an account. The user is signed out everywhere, cannot
+// sign in again and the API keys of their bots stop working.
+// POST /admin/users/:id/disable
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	  Response:
+//	  202 Accepted: {
+//	  "message": ok
+//	  }
+//	  400 Bad Request: {
+//	  "error": "Cannot manage your own account"
+//	  }
+//	  403 Forbidden: {
+//	  "error": "Insufficient permissions"
+//	  }
+//	  404 Not Found: {
+//	  "error": "User does not exist"
+//	  }
+func (h *DisableUserHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		target, ok := managedUser(c, h.pdb, h.log)
+		if !ok {
+			return
+		}
+
+		if err := db.SetDisabled(h.pdb, target.Id, true); err != nil {
+			h.log.Error("Error disabling user", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if err := session.RevokeAll(h.ctx, h.rdb, target.Id, ""); err != nil {
+			h.log.Error("Error revoking sessions", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		audit(c, h.log, "disable_user", target)
+
+		c.JSON(http.StatusAccepted, gin.H{"message": "ok"})
+	}
+}
+
+func (h *DisableUserHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
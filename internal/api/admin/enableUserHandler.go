@@ -0,0 +1,78 @@
+package admin_api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"go.uber.org/zap"
+)
+
+type EnableUserHandler struct {
+	dto.HandlerInterface
+	pdb         *sql.DB
+	log         *zap.Logger
+	middlewares []gin.HandlerFunc
+}
+
+func NewEnableUserHandler(pdb *sql.DB, log *zap.Logger) *EnableUserHandler {
+	return &EnableUserHandler{
+		pdb:         pdb,
+		log:         log,
+		middlewares: []gin.HandlerFunc{middlewares.RequirePermission(constants.PERMISSION_USERS_DISABLE)},
+	}
+}
+
+func (*EnableUserHandler) Pattern() string {
+	return "/users/:id/enable"
+}
+
+func (*EnableUserHandler) RequestMethod() string {
+	return constants.POST
+}
+
+// Handler to re-enable a disabled account
+// POST /admin/users/:id/enable
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	  Response:
+//	  202 Accepted: {
+//	  "message": ok
+//	  }
+//	  400 Bad Request: {
+//	  "error": "Cannot manage your own account"
+//	  }
+//	  403 Forbidden: {
+//	  "error": "Insufficient permissions"
+//	  }
+//	  404 Not Found: {
+//	  "error": "User does not exist"
+//	  }
+func (h *EnableUserHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		target, ok := managedUser(c, h.pdb, h.log)
+		if !ok {
+			return
+		}
+
+		if err := db.SetDisabled(h.pdb, target.Id, false); err != nil {
+			h.log.Error("Error enabling user", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		audit(c, h.log, "enable_user", target)
+
+		c.JSON(http.StatusAccepted, gin.H{"message": "ok"})
+	}
+}
+
+func (h *EnableUserHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
@@ -0,0 +1,52 @@
+package admin_api
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type AdminGroup struct {
+	dto.ServerGroupInterface
+	routeHandlers []dto.HandlerInterface
+	middlewares   []gin.HandlerFunc
+}
+
+func NewAdminGroup(
+	pdb *sql.DB,
+	rdb_auth *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+	keys *jwtkeys.KeySet,
+) *AdminGroup {
+	handlers := []dto.HandlerInterface{
+		NewListUsersHandler(pdb, log),
+		NewUpdateRoleHandler(pdb, rdb_auth, ctx, log),
+		NewDisableUserHandler(pdb, rdb_auth, ctx, log),
+		NewEnableUserHandler(pdb, log),
+		NewRevokeUserSessionsHandler(pdb, rdb_auth, ctx, log),
+	}
+
+	return &AdminGroup{
+		routeHandlers: handlers,
+		middlewares:   []gin.HandlerFunc{middlewares.AuthMiddleware(pdb, rdb_auth, ctx, log, keys)},
+	}
+}
+
+func (ag *AdminGroup) Group() string {
+	return "/admin"
+}
+
+func (ag *AdminGroup) RouteHandlers() []dto.HandlerInterface {
+	return ag.routeHandlers
+}
+
+func (ag *AdminGroup) Middlewares() []gin.HandlerFunc {
+	return ag.middlewares
+}
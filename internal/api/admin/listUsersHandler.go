@@ -0,0 +1,100 @@
+package admin_api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"go.uber.org/zap"
+)
+
+type ListUsersHandler struct {
+	dto.HandlerInterface
+	pdb         *sql.DB
+	log         *zap.Logger
+	middlewares []gin.HandlerFunc
+}
+
+func NewListUsersHandler(pdb *sql.DB, log *zap.Logger) *ListUsersHandler {
+	return &ListUsersHandler{
+		pdb:         pdb,
+		log:         log,
+		middlewares: []gin.HandlerFunc{middlewares.RequirePermission(constants.PERMISSION_USERS_READ)},
+	}
+}
+
+func (*ListUsersHandler) Pattern() string {
+	return "/users"
+}
+
+func (*ListUsersHandler) RequestMethod() string {
+	return constants.GET
+}
+
+// Handler to list users, oldest first, optionally filtered by a substring of
+// their name or email
+// GET /admin/users?q=query&limit=limit&offset=offset
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	  Response:
+//	  200 OK: [{
+//	  "id": id,
+//	  "name": name,
+//	  "email": email,
+//	  "type": "human" | "bot",
+//	  "role": "user" | "moderator" | "admin",
+//	  "created_at": createdAt,
+//	  "disabled_at": disabledAt
+//	  }]
+//	  400 Bad Request: {
+//	  "error": "Invalid limit or offset"
+//	  }
+//	  403 Forbidden: {
+//	  "error": "Insufficient permissions"
+//	  }
+func (h *ListUsersHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page := dto.UserPageQuery{
+			Query: strings.TrimSpace(c.Query("q")),
+			Limit: constants.USER_PAGE_DEFAULT_LIMIT,
+		}
+
+		var err error
+		if limit := c.Query("limit"); limit != "" {
+			page.Limit, err = strconv.Atoi(limit)
+			if err != nil || page.Limit < 1 || page.Limit > constants.USER_PAGE_MAX_LIMIT {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid limit or offset"})
+				return
+			}
+		}
+		if offset := c.Query("offset"); offset != "" {
+			page.Offset, err = strconv.Atoi(offset)
+			if err != nil || page.Offset < 0 {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid limit or offset"})
+				return
+			}
+		}
+
+		users, err := db.GetUsers(h.pdb, &page)
+		if err != nil {
+			h.log.Error("Error listing users", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusOK, users)
+	}
+}
+
+func (h *ListUsersHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
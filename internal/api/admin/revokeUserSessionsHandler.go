@@ -0,0 +1,89 @@
+package admin_api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"github.com/nihal-ramaswamy/GoChat/internal/session"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type RevokeUserSessionsHandler struct {
+	dto.HandlerInterface
+	pdb         *sql.DB
+	rdb         *redis.Client
+	ctx         context.Context
+	log         *zap.Logger
+	middlewares []gin.HandlerFunc
+}
+
+func NewRevokeUserSessionsHandler(
+	pdb *sql.DB,
+	rdb *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+) *RevokeUserSessionsHandler {
+	return &RevokeUserSessionsHandler{
+		pdb:         pdb,
+		rdb:         rdb,
+		ctx:         ctx,
+		log:         log,
+		middlewares: []gin.HandlerFunc{middlewares.RequirePermission(constants.PERMISSION_SESSIONS_REVOKE)},
+	}
+}
+
+func (*RevokeUserSessionsHandler) Pattern() string {
+	return "/users/:id/sessions"
+}
+
+func (*RevokeUserSessionsHandler) RequestMethod() string {
+	return constants.DELETE
+}
+
+// Handler to sign a user out of every device. The account stays usable.
+// DELETE /admin/users/:id/sessions
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	  Response:
+//	  202 Accepted: {
+//	  "message": ok
+//	  }
+//	  400 Bad Request: {
+//	  "error": "Cannot manage your own account"
+//	  }
+//	  403 Forbidden: {
+//	  "error": "Insufficient permissions"
+//	  }
+//	  404 Not Found: {
+//	  "error": "User does not exist"
+//	  }
+func (h *RevokeUserSessionsHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		target, ok := managedUser(c, h.pdb, h.log)
+		if !ok {
+			return
+		}
+
+		if err := session.RevokeAll(h.ctx, h.rdb, target.Id, ""); err != nil {
+			h.log.Error("Error revoking sessions", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		audit(c, h.log, "revoke_sessions", target)
+
+		c.JSON(http.StatusAccepted, gin.H{"message": "ok"})
+	}
+}
+
+func (h *RevokeUserSessionsHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
@@ -0,0 +1,121 @@
+package admin_api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"github.com/nihal-ramaswamy/GoChat/internal/session"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type UpdateRoleHandler struct {
+	dto.HandlerInterface
+	pdb         *sql.DB
+	rdb         *redis.Client
+	ctx         context.Context
+	log         *zap.Logger
+	middlewares []gin.HandlerFunc
+}
+
+func NewUpdateRoleHandler(
+	pdb *sql.DB,
+	rdb *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+) *UpdateRoleHandler {
+	return &UpdateRoleHandler{
+		pdb:         pdb,
+		rdb:         rdb,
+		ctx:         ctx,
+		log:         log,
+		middlewares: []gin.HandlerFunc{middlewares.RequirePermission(constants.PERMISSION_ROLES_WRITE)},
+	}
+}
+
+func (*UpdateRoleHandler) Pattern() string {
+	return "/users/:id/role"
+}
+
+func (*UpdateRoleHandler) RequestMethod() string {
+	return constants.PATCH
+}
+
+// Handler to change the role of a user. Roles are carried in access tokens,
+// so the user is signed out everywhere for the new role to apply.
+// PATCH /admin/users/:id/role
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	Request Body: {
+//	  "role": "user" | "moderator" | "admin"
+//	  }
+//
+//	  Response:
+//	  200 OK: {
+//	  "id": id,
+//	  "name": name,
+//	  "email": email,
+//	  "type": "human" | "bot",
+//	  "role": role,
+//	  "created_at": createdAt,
+//	  "disabled_at": disabledAt
+//	  }
+//	  400 Bad Request: {
+//	  "error": "Invalid role"
+//	  }
+//	  400 Bad Request: {
+//	  "error": "Cannot manage your own account"
+//	  }
+//	  403 Forbidden: {
+//	  "error": "Insufficient permissions"
+//	  }
+//	  404 Not Found: {
+//	  "error": "User does not exist"
+//	  }
+func (h *UpdateRoleHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request dto.UpdateRoleRequest
+		if err := c.ShouldBindJSON(&request); err != nil || !slices.Contains(constants.ROLES, request.Role) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+			return
+		}
+
+		target, ok := managedUser(c, h.pdb, h.log)
+		if !ok {
+			return
+		}
+		if target.Type == constants.USER_TYPE_BOT && request.Role != constants.ROLE_USER {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+			return
+		}
+
+		if err := db.SetRole(h.pdb, target.Id, request.Role); err != nil {
+			h.log.Error("Error setting role", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if err := session.RevokeAll(h.ctx, h.rdb, target.Id, ""); err != nil {
+			h.log.Error("Error revoking sessions", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		audit(c, h.log, "update_role", target, zap.String("from", target.Role), zap.String("to", request.Role))
+
+		target.Role = request.Role
+		c.JSON(http.StatusOK, target)
+	}
+}
+
+func (h *UpdateRoleHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
@@ -0,0 +1,145 @@
+package auth_api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/mfa"
+	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type ConfirmTotpHandler struct {
+	dto.HandlerInterface
+	users       db.UserRepository
+	rdb         *redis.Client
+	ctx         context.Context
+	log         *zap.Logger
+	middlewares []gin.HandlerFunc
+}
+
+func NewConfirmTotpHandler(
+	pdb *sql.DB,
+	users db.UserRepository,
+	rdb *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+	keys *jwtkeys.KeySet,
+) *ConfirmTotpHandler {
+	return &ConfirmTotpHandler{
+		users:       users,
+		rdb:         rdb,
+		ctx:         ctx,
+		log:         log,
+		middlewares: []gin.HandlerFunc{middlewares.AuthMiddleware(pdb, rdb, ctx, log, keys)},
+	}
+}
+
+func (*ConfirmTotpHandler) Pattern() string {
+	return "/2fa/confirm"
+}
+
+func (*ConfirmTotpHandler) RequestMethod() string {
+	return constants.POST
+}
+
+// Handler to finish enrolling an authenticator app with a code it generated.
+// Two-factor authentication is enabled from the next sign in. The recovery
+// codes are only returned here; each one can replace a code once. Wrong codes
+// count towards the same limit as POST /auth/2fa/disable.
+// POST /auth/2fa/confirm
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	Request Body: {
+//	  "code": code
+//	  }
+//
+//	  Response:
+//	  200 OK: {
+//	  "recovery_codes": [recoveryCode]
+//	  }
+//	  400 Bad Request: {
+//	  "error": "Error reading payload"
+//	  }
+//	  400 Bad Request: {
+//	  "error": "Invalid code"
+//	  }
+//	  429 Too Many Requests: {
+//	  "error": "Too many failed attempts, try again later"
+//	  }
+//	  409 Conflict: {
+//	  "error": "Two-factor authentication is not being enrolled"
+//	  }
+func (h *ConfirmTotpHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request dto.TotpCodeRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Error reading payload"})
+			return
+		}
+
+		current, err := currentSession(c, h.ctx, h.rdb)
+		if err != nil {
+			h.log.Error("Error getting current session", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if codeLocked(c, h.ctx, h.rdb, h.log, current.UserId) {
+			return
+		}
+
+		secret, enabled, err := h.users.GetTotp(current.UserId)
+		if err != nil {
+			h.log.Error("Error getting totp secret", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if enabled || secret == "" {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not being enrolled"})
+			return
+		}
+
+		valid, err := mfa.Check(h.ctx, h.rdb, current.UserId, secret, request.Code)
+		if err != nil {
+			h.log.Error("Error checking totp code", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if !valid {
+			rejectCode(c, h.ctx, h.rdb, h.log, current.UserId)
+			return
+		}
+		if err := mfa.ResetUser(h.ctx, h.rdb, current.UserId); err != nil {
+			h.log.Error("Error resetting failed codes", zap.Error(err))
+		}
+
+		recoveryCodes := mfa.GenerateRecoveryCodes()
+		err = h.users.EnableTotp(current.UserId, recoveryCodes)
+		if err == sql.ErrNoRows {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not being enrolled"})
+			return
+		}
+		if err != nil {
+			h.log.Error("Error enabling totp", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusOK, dto.RecoveryCodes{RecoveryCodes: recoveryCodes})
+	}
+}
+
+func (h *ConfirmTotpHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
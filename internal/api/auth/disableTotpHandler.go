@@ -0,0 +1,126 @@
+package auth_api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/mfa"
+	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type DisableTotpHandler struct {
+	dto.HandlerInterface
+	users       db.UserRepository
+	rdb         *redis.Client
+	ctx         context.Context
+	log         *zap.Logger
+	middlewares []gin.HandlerFunc
+}
+
+func NewDisableTotpHandler(
+	pdb *sql.DB,
+	users db.UserRepository,
+	rdb *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+	keys *jwtkeys.KeySet,
+) *DisableTotpHandler {
+	return &DisableTotpHandler{
+		users:       users,
+		rdb:         rdb,
+		ctx:         ctx,
+		log:         log,
+		middlewares: []gin.HandlerFunc{middlewares.AuthMiddleware(pdb, rdb, ctx, log, keys)},
+	}
+}
+
+func (*DisableTotpHandler) Pattern() string {
+	return "/2fa/disable"
+}
+
+func (*DisableTotpHandler) RequestMethod() string {
+	return constants.POST
+}
+
+// Handler to turn off two-factor authentication. A current code or a recovery
+// code is required so a stolen access token alone cannot remove the second
+// factor. After MFA_MAX_ATTEMPTS wrong codes, here or in POST /auth/2fa/confirm,
+// the user is blocked for the rest of a 15 minute window.
+// POST /auth/2fa/disable
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	Request Body: {
+//	  "code": code,
+//	  "recovery_code": recoveryCode
+//	  }
+//
+//	  Response:
+//	  202 Accepted: {
+//	  "message": ok
+//	  }
+//	  400 Bad Request: {
+//	  "error": "Error reading payload"
+//	  }
+//	  400 Bad Request: {
+//	  "error": "Invalid code"
+//	  }
+//	  429 Too Many Requests: {
+//	  "error": "Too many failed attempts, try again later"
+//	  }
+func (h *DisableTotpHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request dto.SecondFactor
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Error reading payload"})
+			return
+		}
+
+		current, err := currentSession(c, h.ctx, h.rdb)
+		if err != nil {
+			h.log.Error("Error getting current session", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if codeLocked(c, h.ctx, h.rdb, h.log, current.UserId) {
+			return
+		}
+
+		valid, err := checkSecondFactor(h.ctx, h.users, h.rdb, current.UserId, request)
+		if err != nil {
+			h.log.Error("Error checking second factor", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if !valid {
+			rejectCode(c, h.ctx, h.rdb, h.log, current.UserId)
+			return
+		}
+		if err := mfa.ResetUser(h.ctx, h.rdb, current.UserId); err != nil {
+			h.log.Error("Error resetting failed codes", zap.Error(err))
+		}
+
+		if err := h.users.DisableTotp(current.UserId); err != nil {
+			h.log.Error("Error disabling totp", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"message": "ok"})
+	}
+}
+
+func (h *DisableTotpHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
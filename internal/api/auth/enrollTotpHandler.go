@@ -0,0 +1,100 @@
+package auth_api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/mfa"
+	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type EnrollTotpHandler struct {
+	dto.HandlerInterface
+	users       db.UserRepository
+	rdb         *redis.Client
+	ctx         context.Context
+	log         *zap.Logger
+	middlewares []gin.HandlerFunc
+}
+
+func NewEnrollTotpHandler(
+	pdb *sql.DB,
+	users db.UserRepository,
+	rdb *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+	keys *jwtkeys.KeySet,
+) *EnrollTotpHandler {
+	return &EnrollTotpHandler{
+		users:       users,
+		rdb:         rdb,
+		ctx:         ctx,
+		log:         log,
+		middlewares: []gin.HandlerFunc{middlewares.AuthMiddleware(pdb, rdb, ctx, log, keys)},
+	}
+}
+
+func (*EnrollTotpHandler) Pattern() string {
+	return "/2fa/enroll"
+}
+
+func (*EnrollTotpHandler) RequestMethod() string {
+	return constants.POST
+}
+
+// Handler to start enrolling an authenticator app. The secret only takes
+// effect once POST /auth/2fa/confirm receives a code generated from it;
+// enrolling again before that replaces it.
+// POST /auth/2fa/enroll
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	  Response:
+//	  200 OK: {
+//	  "secret": secret,
+//	  "uri": otpauthUri
+//	  }
+//	  409 Conflict: {
+//	  "error": "Two-factor authentication is already enabled"
+//	  }
+func (e *EnrollTotpHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		current, err := currentSession(c, e.ctx, e.rdb)
+		if err != nil {
+			e.log.Error("Error getting current session", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		secret := mfa.GenerateSecret()
+		err = e.users.SetPendingTotpSecret(current.UserId, secret)
+		if err == sql.ErrNoRows {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+			return
+		}
+		if err != nil {
+			e.log.Error("Error saving totp secret", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusOK, dto.TotpEnrollment{
+			Secret: secret,
+			Uri:    mfa.URI(current.Email, secret),
+		})
+	}
+}
+
+func (e *EnrollTotpHandler) Middlewares() []gin.HandlerFunc {
+	return e.middlewares
+}
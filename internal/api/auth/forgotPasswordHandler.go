@@ -0,0 +1,102 @@
+package auth_api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/mailer"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type ForgotPasswordHandler struct {
+	dto.HandlerInterface
+	users       db.UserRepository
+	rdb         *redis.Client
+	ctx         context.Context
+	log         *zap.Logger
+	mailer      mailer.Mailer
+	links       *mailLinks
+	middlewares []gin.HandlerFunc
+}
+
+func NewForgotPasswordHandler(
+	users db.UserRepository,
+	rdb *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+	mailer mailer.Mailer,
+) *ForgotPasswordHandler {
+	return &ForgotPasswordHandler{
+		users:       users,
+		rdb:         rdb,
+		ctx:         ctx,
+		log:         log,
+		mailer:      mailer,
+		links:       newMailLinks(),
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (*ForgotPasswordHandler) Pattern() string {
+	return "/forgot"
+}
+
+func (*ForgotPasswordHandler) RequestMethod() string {
+	return constants.POST
+}
+
+// Handler to request a password reset token by mail. The response is the
+// same whether or not the email is registered, so it cannot be used to find
+// out who has an account. The mail is sent in the background so the response
+// time does not tell either.
+// POST /auth/forgot
+//
+//	Request Body: {
+//	  "email": email
+//	  }
+//
+//	  Response:
+//	  202 Accepted: {
+//	  "message": ok
+//	  }
+//	  400 Bad Request: {
+//	  "error": "Error reading payload"
+//	  }
+func (f *ForgotPasswordHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request dto.ForgotPasswordRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Error reading payload"})
+			return
+		}
+
+		go f.sendPasswordResetMail(request.Email)
+
+		c.JSON(http.StatusAccepted, gin.H{"message": "ok"})
+	}
+}
+
+// sendPasswordResetMail mails a password reset token if email belongs to a user
+func (f *ForgotPasswordHandler) sendPasswordResetMail(email string) {
+	ctx, cancel := context.WithTimeout(f.ctx, constants.MAIL_SEND_TIMEOUT)
+	defer cancel()
+
+	user, err := f.users.GetUserFromEmail(email)
+	if err == nil && user.Type != constants.USER_TYPE_BOT {
+		if err := f.links.sendPasswordResetMail(ctx, f.rdb, f.mailer, &user); err != nil {
+			f.log.Error("Error sending password reset mail", zap.Error(err))
+		}
+	} else if err != nil && err != sql.ErrNoRows {
+		f.log.Error("Error getting user from email", zap.Error(err))
+	}
+}
+
+func (f *ForgotPasswordHandler) Middlewares() []gin.HandlerFunc {
+	return f.middlewares
+}
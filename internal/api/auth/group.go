@@ -5,7 +5,11 @@ import (
 	"database/sql"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/mailer"
+	"github.com/nihal-ramaswamy/GoChat/internal/sso"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
@@ -25,15 +29,38 @@ func (h *AuthGroup) RouteHandlers() []dto.HandlerInterface {
 }
 
 func NewAuthGroup(
-	db *sql.DB,
+	pdb *sql.DB,
+	users db.UserRepository,
 	rdb *redis.Client,
 	ctx context.Context,
 	log *zap.Logger,
+	mailer mailer.Mailer,
+	oidcProvider *sso.OidcProvider,
+	keys *jwtkeys.KeySet,
 ) *AuthGroup {
 	handlers := []dto.HandlerInterface{
-		NewNewUserHandler(db, log),
-		NewLoginUserHandler(db, rdb, ctx, log),
-		NewLogoutUserHandler(db, rdb, ctx, log),
+		NewNewUserHandler(users, rdb, ctx, log, mailer),
+		NewLoginUserHandler(users, rdb, ctx, log, keys),
+		NewLogoutUserHandler(pdb, rdb, ctx, log, keys),
+		NewRefreshTokenHandler(rdb, ctx, log, keys),
+		NewListSessionsHandler(pdb, rdb, ctx, log, keys),
+		NewRevokeSessionHandler(pdb, rdb, ctx, log, keys),
+		NewRevokeOtherSessionsHandler(pdb, rdb, ctx, log, keys),
+		NewForgotPasswordHandler(users, rdb, ctx, log, mailer),
+		NewResetPasswordHandler(users, rdb, ctx, log),
+		NewVerifyEmailHandler(users, rdb, ctx, log),
+		NewEnrollTotpHandler(pdb, users, rdb, ctx, log, keys),
+		NewConfirmTotpHandler(pdb, users, rdb, ctx, log, keys),
+		NewDisableTotpHandler(pdb, users, rdb, ctx, log, keys),
+		NewVerifyTotpHandler(users, rdb, ctx, log, keys),
+	}
+
+	// Single sign-on is only offered when an identity provider is configured
+	if oidcProvider != nil {
+		handlers = append(handlers,
+			NewOidcLoginHandler(rdb, ctx, log, oidcProvider),
+			NewOidcCallbackHandler(users, rdb, ctx, log, oidcProvider, keys),
+		)
 	}
 
 	return &AuthGroup{
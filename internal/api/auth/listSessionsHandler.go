@@ -0,0 +1,91 @@
+package auth_api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"github.com/nihal-ramaswamy/GoChat/internal/session"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type ListSessionsHandler struct {
+	dto.HandlerInterface
+	ctx         context.Context
+	rdb         *redis.Client
+	log         *zap.Logger
+	middlewares []gin.HandlerFunc
+}
+
+func NewListSessionsHandler(
+	pdb *sql.DB,
+	rdb *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+	keys *jwtkeys.KeySet,
+) *ListSessionsHandler {
+	return &ListSessionsHandler{
+		rdb:         rdb,
+		ctx:         ctx,
+		log:         log,
+		middlewares: []gin.HandlerFunc{middlewares.AuthMiddleware(pdb, rdb, ctx, log, keys)},
+	}
+}
+
+func (*ListSessionsHandler) Pattern() string {
+	return "/sessions"
+}
+
+func (*ListSessionsHandler) RequestMethod() string {
+	return constants.GET
+}
+
+// Handler to list the active sessions of a user, most recently used first
+// GET /auth/sessions
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	  Response:
+//	  200 OK: [{
+//	  "id": id,
+//	  "user_id": userId,
+//	  "user_agent": userAgent,
+//	  "ip": ip,
+//	  "created_at": createdAt,
+//	  "last_used_at": lastUsedAt,
+//	  "current": current
+//	  }]
+func (l *ListSessionsHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		current, err := currentSession(c, l.ctx, l.rdb)
+		if err != nil {
+			l.log.Error("Error getting current session", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		sessions, err := session.List(l.ctx, l.rdb, current.UserId)
+		if err != nil {
+			l.log.Error("Error listing sessions", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		for i := range sessions {
+			sessions[i].Current = sessions[i].Id == current.Id
+		}
+
+		c.JSON(http.StatusOK, sessions)
+	}
+}
+
+func (l *ListSessionsHandler) Middlewares() []gin.HandlerFunc {
+	return l.middlewares
+}
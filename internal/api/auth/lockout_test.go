@@ -0,0 +1,124 @@
+package auth_api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/routes"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+)
+
+// Test /auth/signin
+// Tests that unknown emails and wrong passwords get the same response and that
+// repeated failures lock the account, even for the right password
+func TestLoginLockout(t *testing.T) {
+	ctx := context.Background()
+
+	testConfig, err := testUtils.SetUpRouter(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up server for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := testConfig.PostgresContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+		testConfig.Db.Close()
+	})
+
+	t.Cleanup(func() {
+		if err := testConfig.RabbitmqContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	t.Cleanup(func() {
+		if err := testConfig.RedisContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	routes.NewRoutes(
+		testConfig.Server,
+		testConfig.Db,
+		testConfig.Users,
+		testConfig.Chats,
+		testConfig.Rdb,
+		testConfig.Rdb,
+		ctx,
+		testConfig.Log,
+		testConfig.Broker,
+		testConfig.Relay,
+		testConfig.Upgrader,
+		testConfig.WebsocketMap,
+		testConfig.Storage,
+		testConfig.Mailer,
+		testConfig.OidcProvider,
+		testConfig.Keys,
+	)
+
+	serve := func(method, target, token string, payload any, result any) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		if payload != nil {
+			if err := json.NewEncoder(&body).Encode(payload); err != nil {
+				t.Fatalf("Error converting payload to json: %s", err)
+			}
+		}
+		req, err := http.NewRequest(method, target, &body)
+		if err != nil {
+			t.Fatalf("Error creating request: %s", err)
+		}
+		if token != "" {
+			req.Header.Set("Token", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		testConfig.Server.ServeHTTP(w, req)
+		if result != nil {
+			if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+				t.Fatalf("Error unmarshalling response: %s", err)
+			}
+		}
+		return w
+	}
+
+	user := dto.User{Name: "test", Email: "lockout@test", Password: "test"}
+	if w := serve("POST", "/auth/register", "", user, nil); w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status code: 202, got %d", w.Code)
+	}
+
+	var unknown, wrong map[string]string
+	if w := serve("POST", "/auth/signin", "", dto.User{Email: "unknown@test", Password: "test"}, &unknown); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status code: 401, got %d", w.Code)
+	}
+	if w := serve("POST", "/auth/signin", "", dto.User{Email: user.Email, Password: "wrong"}, &wrong); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status code: 401, got %d", w.Code)
+	}
+	if unknown["error"] != wrong["error"] {
+		t.Errorf("Expected the same error for unknown emails and wrong passwords, got %q and %q", unknown["error"], wrong["error"])
+	}
+
+	for range constants.LOGIN_ACCOUNT_MAX_FAILURES - 2 {
+		if w := serve("POST", "/auth/signin", "", dto.User{Email: user.Email, Password: "wrong"}, nil); w.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected status code: 401, got %d", w.Code)
+		}
+	}
+	w := serve("POST", "/auth/signin", "", dto.User{Email: user.Email, Password: "wrong"}, nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status code: 429, got %d", w.Code)
+	}
+	if retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || retryAfter <= 0 {
+		t.Errorf("Expected a Retry-After header, got %q", w.Header().Get("Retry-After"))
+	}
+
+	if w := serve("POST", "/auth/signin", "", user, nil); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status code: 429, got %d", w.Code)
+	}
+}
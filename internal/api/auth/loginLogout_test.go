@@ -8,8 +8,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
-	"os"
-	"path/filepath"
+	"strings"
 	"testing"
 
 	_ "github.com/lib/pq"
@@ -23,13 +22,7 @@ import (
 func TestLoginLogout(t *testing.T) {
 	ctx := context.Background()
 
-	wd, err := os.Getwd()
-	if err != nil {
-		t.Fatalf("Error getting working directory: %s", err)
-	}
-	rootDir := filepath.Join(wd, "..", "..", "..")
-
-	testConfig, err := testUtils.SetUpRouter(rootDir, ctx)
+	testConfig, err := testUtils.SetUpRouter(ctx)
 	if err != nil {
 		t.Fatalf("Error setting up server for testing: %s", err)
 	}
@@ -56,12 +49,20 @@ func TestLoginLogout(t *testing.T) {
 	routes.NewRoutes(
 		testConfig.Server,
 		testConfig.Db,
+		testConfig.Users,
+		testConfig.Chats,
+		testConfig.Rdb,
 		testConfig.Rdb,
 		ctx,
 		testConfig.Log,
-		testConfig.AmqpConfig,
+		testConfig.Broker,
+		testConfig.Relay,
 		testConfig.Upgrader,
 		testConfig.WebsocketMap,
+		testConfig.Storage,
+		testConfig.Mailer,
+		testConfig.OidcProvider,
+		testConfig.Keys,
 	)
 
 	w := httptest.NewRecorder()
@@ -119,12 +120,13 @@ func TestLoginLogout(t *testing.T) {
 		t.Errorf("Expected token, got empty string")
 	}
 
-	exists, rdb_val, err := testUtils.ReadFromRedis(testConfig.Rdb, userDto.Email)
+	sessionKey := "session:" + strings.Split(token.RefreshToken, ".")[0]
+	exists, err := testConfig.Rdb.Exists(ctx, sessionKey).Result()
 	if err != nil {
 		t.Fatalf("Error reading from redis: %s", err)
 	}
-	if !exists || rdb_val == "" {
-		t.Errorf("Expected token, got empty string")
+	if exists != 1 {
+		t.Errorf("Expected session to be stored")
 	}
 
 	w = httptest.NewRecorder()
@@ -138,8 +140,11 @@ func TestLoginLogout(t *testing.T) {
 		t.Errorf("Expected status code: 202, got %d", w.Code)
 	}
 
-	exists, rdb_val, err = testUtils.ReadFromRedis(testConfig.Rdb, userDto.Email)
-	if exists {
-		t.Errorf("Expected token to be deleted, got %s", rdb_val)
+	exists, err = testConfig.Rdb.Exists(ctx, sessionKey).Result()
+	if err != nil {
+		t.Fatalf("Error reading from redis: %s", err)
+	}
+	if exists != 0 {
+		t.Errorf("Expected session to be deleted")
 	}
 }
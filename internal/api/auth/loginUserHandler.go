@@ -2,15 +2,17 @@ package auth_api
 
 import (
 	"context"
-	"database/sql"
-	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/nihal-ramaswamy/GoChat/internal/constants"
 	"github.com/nihal-ramaswamy/GoChat/internal/db"
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
-	"github.com/nihal-ramaswamy/GoChat/internal/utils"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/lockout"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
@@ -18,21 +20,24 @@ import (
 type LoginUserHandler struct {
 	dto.HandlerInterface
 	log         *zap.Logger
-	db          *sql.DB
+	keys        *jwtkeys.KeySet
+	users       db.UserRepository
 	rdb         *redis.Client
 	ctx         context.Context
 	middlewares []gin.HandlerFunc
 }
 
 func NewLoginUserHandler(
-	db *sql.DB,
+	users db.UserRepository,
 	rdb *redis.Client,
 	ctx context.Context,
 	log *zap.Logger,
+	keys *jwtkeys.KeySet,
 ) *LoginUserHandler {
 	return &LoginUserHandler{
 		log:         log,
-		db:          db,
+		keys:        keys,
+		users:       users,
 		rdb:         rdb,
 		ctx:         ctx,
 		middlewares: []gin.HandlerFunc{},
@@ -47,7 +52,11 @@ func (*LoginUserHandler) RequestMethod() string {
 	return constants.POST
 }
 
-// Hanlder to authenticate a user
+// Hanlder to authenticate a user. Every sign in starts a new session, so
+// signing in on another device does not sign out the existing ones. Users with
+// two-factor authentication get an mfa token instead, which POST /auth/2fa/verify
+// exchanges for the tokens. Repeated failures lock sign in for the email
+// address and for the client address, for longer with every further failure.
 // POST /auth/signin
 //
 //	Request Body: {
@@ -57,13 +66,23 @@ func (*LoginUserHandler) RequestMethod() string {
 //
 //	  Response:
 //	  202 Accepted: {
-//	  "token": token
+//	  "token": token,
+//	  "refresh_token": refreshToken,
+//	  "expires_in": seconds
+//	  }
+//	  202 Accepted: {
+//	  "mfa_required": true,
+//	  "mfa_token": mfaToken,
+//	  "expires_in": seconds
 //	  }
 //	  401 Unauthorized: {
 //	  "error": "Invalid credentials"
 //	  }
-//	  401 Unauthorized: {
-//	  "error": "User with email %s does not exist"
+//	  403 Forbidden: {
+//	  "error": "Account is disabled"
+//	  }
+//	  429 Too Many Requests: {
+//	  "error": "Too many failed attempts, try again later"
 //	  }
 //	  500 Internal Server Error: {
 //	  "error": "Internal Server Error"
@@ -77,21 +96,59 @@ func (l *LoginUserHandler) Handler() gin.HandlerFunc {
 			err := c.Error(err)
 			l.log.Info("Responding with error", zap.Error(err))
 			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		locked, err := lockout.Check(l.ctx, l.rdb, user.Email, c.ClientIP())
+		if err != nil {
+			l.log.Error("Error checking lockout", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
 		}
-		if !db.DoesEmailExist(l.db, user.Email) {
-			c.JSON(http.StatusUnauthorized,
-				gin.H{
-					"error": fmt.Sprintf("User with email %s does not exist", user.Email),
-				})
+		if locked > 0 {
+			tooManyAttempts(c, locked)
 			return
 		}
 
-		if !db.DoesPasswordMatch(l.db, user, l.log) {
+		// Unknown emails, wrong passwords and bots get the same answer so
+		// responses do not reveal which accounts exist
+		var account dto.User
+		valid := l.users.DoesPasswordMatch(user)
+		if valid {
+			account, err = l.users.GetUserFromEmail(user.Email)
+			if nil != err {
+				err := c.Error(err)
+				l.log.Info("Responding with error", zap.Error(err))
+
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			// Bots only authenticate with API keys
+			valid = account.Type != constants.USER_TYPE_BOT
+		}
+		if !valid {
+			locked, err := lockout.Fail(l.ctx, l.rdb, user.Email, c.ClientIP())
+			if err != nil {
+				l.log.Error("Error recording failed sign in", zap.Error(err))
+			}
+			if locked > 0 {
+				l.log.Warn("Sign in locked",
+					zap.String("event", "login_lockout"),
+					zap.String("email", user.Email),
+					zap.String("ip", c.ClientIP()),
+					zap.Duration("duration", locked))
+				tooManyAttempts(c, locked)
+				return
+			}
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 			return
 		}
 
-		token, err := utils.GenerateToken(user)
+		response, err := signIn(c, l.ctx, l.users, l.rdb, l.keys, &account)
+		if err == db.ErrAccountDisabled {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		if nil != err {
 			err := c.Error(err)
 			l.log.Info("Responding with error", zap.Error(err))
@@ -100,12 +157,24 @@ func (l *LoginUserHandler) Handler() gin.HandlerFunc {
 			return
 		}
 
-		l.rdb.Set(l.ctx, user.Email, token, constants.TOKEN_EXPIRY_TIME)
+		// With two-factor authentication the failures are reset once the
+		// second factor is verified, so wrong codes count towards the lock
+		if _, mfaRequired := response.(dto.MfaChallenge); !mfaRequired {
+			if err := lockout.Reset(l.ctx, l.rdb, account.Email); err != nil {
+				l.log.Error("Error resetting failed sign ins", zap.Error(err))
+			}
+		}
 
-		c.JSON(http.StatusAccepted, gin.H{"token": token})
+		c.JSON(http.StatusAccepted, response)
 	}
 }
 
+// tooManyAttempts tells the client how long sign in is locked
+func tooManyAttempts(c *gin.Context, locked time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.Seconds()))))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, try again later"})
+}
+
 func (l *LoginUserHandler) Middlewares() []gin.HandlerFunc {
 	return l.middlewares
 }
@@ -8,7 +8,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/nihal-ramaswamy/GoChat/internal/constants"
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
 	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"github.com/nihal-ramaswamy/GoChat/internal/session"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
@@ -26,12 +28,13 @@ func NewLogoutUserHandler(
 	rdb *redis.Client,
 	ctx context.Context,
 	log *zap.Logger,
+	keys *jwtkeys.KeySet,
 ) *LogoutUserHandler {
 	return &LogoutUserHandler{
 		rdb:         rdb,
 		ctx:         ctx,
 		log:         log,
-		middlewares: []gin.HandlerFunc{middlewares.AuthMiddleware(pdb, rdb, ctx, log)},
+		middlewares: []gin.HandlerFunc{middlewares.AuthMiddleware(pdb, rdb, ctx, log, keys)},
 	}
 }
 
@@ -43,7 +46,7 @@ func (*LogoutUserHandler) RequestMethod() string {
 	return constants.POST
 }
 
-// Handler to logout a user
+// Handler to logout a user. Only the session of the calling device is ended.
 // POST /auth/signout
 //
 //	Request Header: {
@@ -56,10 +59,8 @@ func (*LogoutUserHandler) RequestMethod() string {
 //	  }
 func (l *LogoutUserHandler) Handler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		email := c.GetString("email")
-		_, err := l.rdb.Del(l.ctx, email).Result()
-		if err != nil {
-			l.log.Error("Error deleting token from rdb")
+		if err := session.Revoke(l.ctx, l.rdb, c.GetString("session_id")); err != nil {
+			l.log.Error("Error revoking session", zap.Error(err))
 		}
 
 		c.JSON(http.StatusAccepted, gin.H{"message": "ok"})
@@ -0,0 +1,70 @@
+package auth_api
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/mailer"
+	"github.com/nihal-ramaswamy/GoChat/internal/mailtoken"
+	"github.com/nihal-ramaswamy/GoChat/internal/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// mailLinks builds the links sent in account emails and signs their tokens
+type mailLinks struct {
+	baseUrl string
+	secret  []byte
+}
+
+func newMailLinks() *mailLinks {
+	return &mailLinks{
+		baseUrl: utils.GetDotEnvVariable(constants.SERVER_HOST) + utils.GetDotEnvVariable(constants.SERVER_PORT),
+		secret:  []byte(utils.GetDotEnvVariable("SECRET_KEY")),
+	}
+}
+
+// sendVerificationMail mails the user a link that confirms their email address
+func (m *mailLinks) sendVerificationMail(
+	ctx context.Context,
+	rdb *redis.Client,
+	sender mailer.Mailer,
+	user *dto.User,
+) error {
+	token, err := mailtoken.Issue(ctx, rdb, m.secret, constants.MAIL_TOKEN_VERIFY_EMAIL, user.Id, constants.VERIFY_EMAIL_TOKEN_TTL)
+	if err != nil {
+		return err
+	}
+
+	link := m.baseUrl + "/auth/verify?token=" + url.QueryEscape(token)
+	return sender.Send(ctx, dto.Mail{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening the link below:\n\n%s\n\n"+
+			"The link expires in %s.\n", user.Name, link, constants.VERIFY_EMAIL_TOKEN_TTL),
+	})
+}
+
+// sendPasswordResetMail mails the user a token to choose a new password
+func (m *mailLinks) sendPasswordResetMail(
+	ctx context.Context,
+	rdb *redis.Client,
+	sender mailer.Mailer,
+	user *dto.User,
+) error {
+	token, err := mailtoken.Issue(ctx, rdb, m.secret, constants.MAIL_TOKEN_RESET_PASSWORD, user.Id, constants.RESET_PASSWORD_TOKEN_TTL)
+	if err != nil {
+		return err
+	}
+
+	return sender.Send(ctx, dto.Mail{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. "+
+			"If it was you, send the token below to POST %s/auth/reset along with your new password:\n\n%s\n\n"+
+			"The token expires in %s. If it was not you, you can ignore this mail.\n",
+			user.Name, m.baseUrl, token, constants.RESET_PASSWORD_TOKEN_TTL),
+	})
+}
@@ -0,0 +1,186 @@
+package auth_api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/mfa"
+	"github.com/nihal-ramaswamy/GoChat/internal/routes"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+)
+
+// Tests registering, signing in and two-factor authentication against the
+// in-memory repository, without any containers
+func TestAuthWithMemoryRepository(t *testing.T) {
+	ctx := context.Background()
+
+	testConfig, err := testUtils.SetUpMemoryRouter()
+	if err != nil {
+		t.Fatalf("Error setting up server for testing: %s", err)
+	}
+	t.Cleanup(testConfig.MiniRedis.Close)
+
+	routes.NewRoutes(
+		testConfig.Server,
+		testConfig.Db,
+		testConfig.Users,
+		testConfig.Chats,
+		testConfig.Rdb,
+		testConfig.Rdb,
+		ctx,
+		testConfig.Log,
+		testConfig.Broker,
+		testConfig.Relay,
+		testConfig.Upgrader,
+		testConfig.WebsocketMap,
+		testConfig.Storage,
+		testConfig.Mailer,
+		testConfig.OidcProvider,
+		testConfig.Keys,
+	)
+
+	serve := func(method, target, token string, payload any, result any) int {
+		var body bytes.Buffer
+		if payload != nil {
+			if err := json.NewEncoder(&body).Encode(payload); err != nil {
+				t.Fatalf("Error converting payload to json: %s", err)
+			}
+		}
+		req, err := http.NewRequest(method, target, &body)
+		if err != nil {
+			t.Fatalf("Error creating request: %s", err)
+		}
+		if token != "" {
+			req.Header.Set("Token", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		testConfig.Server.ServeHTTP(w, req)
+		if result != nil {
+			if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+				t.Fatalf("Error unmarshalling response: %s", err)
+			}
+		}
+		return w.Code
+	}
+
+	user := dto.User{Name: "test", Email: "memory@test", Password: "test"}
+	var registered testUtils.IdDto
+	if code := serve("POST", "/auth/register", "", user, &registered); code != http.StatusAccepted || registered.Id == "" {
+		t.Fatalf("Expected status code: 202 with an id, got %d", code)
+	}
+	if code := serve("POST", "/auth/register", "", user, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected duplicate email to be rejected, got %d", code)
+	}
+
+	var result testUtils.ErrorDto
+	wrong := dto.User{Email: user.Email, Password: "wrong"}
+	if serve("POST", "/auth/signin", "", wrong, &result); result.Error != "Invalid credentials" {
+		t.Errorf("Expected %q, got %q", "Invalid credentials", result.Error)
+	}
+	unknown := dto.User{Email: "unknown@test", Password: "test"}
+	if serve("POST", "/auth/signin", "", unknown, &result); result.Error != "Invalid credentials" {
+		t.Errorf("Expected %q, got %q", "Invalid credentials", result.Error)
+	}
+
+	var tokens dto.TokenPair
+	if code := serve("POST", "/auth/signin", "", user, &tokens); code != http.StatusAccepted || tokens.Token == "" {
+		t.Fatalf("Expected tokens, got %d", code)
+	}
+
+	var enrollment dto.TotpEnrollment
+	if code := serve("POST", "/auth/2fa/enroll", tokens.Token, nil, &enrollment); code != http.StatusOK {
+		t.Fatalf("Expected status code: 200, got %d", code)
+	}
+	now := time.Now()
+	totp, err := mfa.Code(enrollment.Secret, now)
+	if err != nil {
+		t.Fatalf("Error generating code: %s", err)
+	}
+	var recovery dto.RecoveryCodes
+	if code := serve("POST", "/auth/2fa/confirm", tokens.Token, dto.TotpCodeRequest{Code: totp}, &recovery); code != http.StatusOK {
+		t.Fatalf("Expected status code: 200, got %d", code)
+	}
+	if len(recovery.RecoveryCodes) != constants.RECOVERY_CODE_COUNT {
+		t.Errorf("Expected %d recovery codes, got %d", constants.RECOVERY_CODE_COUNT, len(recovery.RecoveryCodes))
+	}
+
+	var challenge dto.MfaChallenge
+	if code := serve("POST", "/auth/signin", "", user, &challenge); code != http.StatusAccepted || !challenge.MfaRequired {
+		t.Fatalf("Expected mfa challenge, got %d %+v", code, challenge)
+	}
+	verify := dto.MfaVerifyRequest{MfaToken: challenge.MfaToken, SecondFactor: dto.SecondFactor{RecoveryCode: recovery.RecoveryCodes[0]}}
+	tokens = dto.TokenPair{}
+	if code := serve("POST", "/auth/2fa/verify", "", verify, &tokens); code != http.StatusAccepted || tokens.Token == "" {
+		t.Fatalf("Expected tokens, got %d", code)
+	}
+
+	// Recovery codes work once
+	challenge = dto.MfaChallenge{}
+	serve("POST", "/auth/signin", "", user, &challenge)
+	verify.MfaToken = challenge.MfaToken
+	if code := serve("POST", "/auth/2fa/verify", "", verify, nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected used recovery code to be rejected, got %d", code)
+	}
+
+	// Wrong codes count as failed sign ins, so signing in again does not
+	// allow more guesses
+	for range constants.LOGIN_ACCOUNT_MAX_FAILURES - 2 {
+		challenge = dto.MfaChallenge{}
+		serve("POST", "/auth/signin", "", user, &challenge)
+		verify := dto.MfaVerifyRequest{MfaToken: challenge.MfaToken, SecondFactor: dto.SecondFactor{Code: "wrong"}}
+		if code := serve("POST", "/auth/2fa/verify", "", verify, nil); code != http.StatusUnauthorized {
+			t.Fatalf("Expected status code: 401, got %d", code)
+		}
+	}
+	challenge = dto.MfaChallenge{}
+	serve("POST", "/auth/signin", "", user, &challenge)
+	verify = dto.MfaVerifyRequest{MfaToken: challenge.MfaToken, SecondFactor: dto.SecondFactor{Code: "wrong"}}
+	if code := serve("POST", "/auth/2fa/verify", "", verify, nil); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status code: 429, got %d", code)
+	}
+	if code := serve("POST", "/auth/signin", "", user, nil); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected locked sign in to reject the right password, got %d", code)
+	}
+	testConfig.MiniRedis.FastForward(constants.LOGIN_FAILURE_WINDOW)
+
+	// Wrong codes block disabling, even with a valid recovery code
+	for range constants.MFA_MAX_ATTEMPTS - 1 {
+		if code := serve("POST", "/auth/2fa/disable", tokens.Token, dto.SecondFactor{Code: "wrong"}, nil); code != http.StatusBadRequest {
+			t.Fatalf("Expected status code: 400, got %d", code)
+		}
+	}
+	if code := serve("POST", "/auth/2fa/disable", tokens.Token, dto.SecondFactor{Code: "wrong"}, nil); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status code: 429, got %d", code)
+	}
+	disable := dto.SecondFactor{RecoveryCode: recovery.RecoveryCodes[1]}
+	if code := serve("POST", "/auth/2fa/disable", tokens.Token, disable, nil); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected blocked user to be rejected, got %d", code)
+	}
+	testConfig.MiniRedis.FastForward(constants.MFA_FAILURE_WINDOW)
+
+	if code := serve("POST", "/auth/2fa/disable", tokens.Token, disable, nil); code != http.StatusAccepted {
+		t.Fatalf("Expected status code: 202, got %d", code)
+	}
+	challenge = dto.MfaChallenge{}
+	if serve("POST", "/auth/signin", "", user, &challenge); challenge.MfaRequired {
+		t.Errorf("Expected sign in without second factor once disabled")
+	}
+
+	// Repeated failures lock sign in
+	for range constants.LOGIN_ACCOUNT_MAX_FAILURES - 1 {
+		serve("POST", "/auth/signin", "", wrong, nil)
+	}
+	if code := serve("POST", "/auth/signin", "", wrong, nil); code != http.StatusTooManyRequests {
+		t.Errorf("Expected status code: 429, got %d", code)
+	}
+	if code := serve("POST", "/auth/signin", "", user, nil); code != http.StatusTooManyRequests {
+		t.Errorf("Expected locked sign in to reject the right password, got %d", code)
+	}
+}
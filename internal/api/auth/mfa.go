@@ -0,0 +1,72 @@
+package auth_api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/mfa"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// checkSecondFactor reports whether factor holds a valid TOTP code or an
+// unused recovery code of a user with TOTP enabled. Recovery codes are
+// consumed.
+func checkSecondFactor(
+	ctx context.Context,
+	users db.UserRepository,
+	rdb *redis.Client,
+	userId string,
+	factor dto.SecondFactor,
+) (bool, error) {
+	secret, enabled, err := users.GetTotp(userId)
+	if err != nil || !enabled {
+		return false, err
+	}
+
+	if factor.Code != "" {
+		return mfa.Check(ctx, rdb, userId, secret, factor.Code)
+	}
+	if factor.RecoveryCode != "" {
+		return users.UseRecoveryCode(userId, mfa.NormalizeRecoveryCode(factor.RecoveryCode))
+	}
+	return false, nil
+}
+
+// codeLocked responds with 429 and returns true if a signed in user sent too
+// many wrong codes recently
+func codeLocked(c *gin.Context, ctx context.Context, rdb *redis.Client, log *zap.Logger, userId string) bool {
+	locked, err := mfa.Locked(ctx, rdb, userId)
+	if err != nil {
+		log.Error("Error checking failed codes", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return true
+	}
+	if locked > 0 {
+		tooManyAttempts(c, locked)
+		return true
+	}
+	return false
+}
+
+// rejectCode counts a wrong code sent by a signed in user and responds with
+// 400, or 429 once it blocks them
+func rejectCode(c *gin.Context, ctx context.Context, rdb *redis.Client, log *zap.Logger, userId string) {
+	locked, err := mfa.FailUser(ctx, rdb, userId)
+	if err != nil {
+		log.Error("Error recording failed code", zap.Error(err))
+	}
+	if locked > 0 {
+		log.Warn("Second factor locked",
+			zap.String("event", "mfa_lockout"),
+			zap.String("user_id", userId),
+			zap.String("ip", c.ClientIP()),
+			zap.Duration("duration", locked))
+		tooManyAttempts(c, locked)
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+}
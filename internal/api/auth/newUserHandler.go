@@ -1,7 +1,7 @@
 package auth_api
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"net/http"
 
@@ -9,20 +9,36 @@ import (
 	"github.com/nihal-ramaswamy/GoChat/internal/constants"
 	"github.com/nihal-ramaswamy/GoChat/internal/db"
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/mailer"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
 type NewUserHandler struct {
 	dto.HandlerInterface
-	db          *sql.DB
+	users       db.UserRepository
+	rdb         *redis.Client
+	ctx         context.Context
 	log         *zap.Logger
+	mailer      mailer.Mailer
+	links       *mailLinks
 	middlewares []gin.HandlerFunc
 }
 
-func NewNewUserHandler(db *sql.DB, log *zap.Logger) *NewUserHandler {
+func NewNewUserHandler(
+	users db.UserRepository,
+	rdb *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+	mailer mailer.Mailer,
+) *NewUserHandler {
 	return &NewUserHandler{
-		db:  db,
-		log: log,
+		users:  users,
+		rdb:    rdb,
+		ctx:    ctx,
+		log:    log,
+		mailer: mailer,
+		links:  newMailLinks(),
 	}
 }
 
@@ -30,7 +46,7 @@ func (*NewUserHandler) Pattern() string {
 	return "/register"
 }
 
-// Handler creates a new user in the database
+// Handler creates a new user in the database and mails them a link to verify their email address
 // POST /auth/register
 //
 //	Request Body: {
@@ -47,6 +63,7 @@ func (*NewUserHandler) Pattern() string {
 //	 400 Bad Request: {
 //	 "error": "User with email %s already exists"
 //	 }
+//	 500 Internal Server Error
 func (n *NewUserHandler) Handler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := dto.NewUser()
@@ -58,7 +75,7 @@ func (n *NewUserHandler) Handler() gin.HandlerFunc {
 			return
 		}
 
-		if db.DoesEmailExist(n.db, user.Email) {
+		if n.users.DoesEmailExist(user.Email) {
 			c.JSON(http.StatusBadRequest,
 				gin.H{
 					"error": fmt.Sprintf("User with email %s already exists", user.Email),
@@ -66,7 +83,17 @@ func (n *NewUserHandler) Handler() gin.HandlerFunc {
 			return
 		}
 
-		id := db.RegisterNewUser(n.db, user, n.log)
+		id, err := n.users.RegisterNewUser(user)
+		if err != nil {
+			n.log.Error("Error registering user", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		user.Id = id
+		if err := n.links.sendVerificationMail(n.ctx, n.rdb, n.mailer, user); err != nil {
+			n.log.Error("Error sending verification mail", zap.Error(err))
+		}
 
 		c.JSON(http.StatusAccepted, gin.H{"id": id})
 	}
@@ -7,8 +7,6 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
-	"os"
-	"path/filepath"
 	"testing"
 
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
@@ -24,13 +22,7 @@ import (
 func TestAuthRegister(t *testing.T) {
 	ctx := context.Background()
 
-	wd, err := os.Getwd()
-	if err != nil {
-		t.Fatalf("Error getting working directory: %s", err)
-	}
-	rootDir := filepath.Join(wd, "..", "..", "..")
-
-	testConfig, err := testUtils.SetUpRouter(rootDir, ctx)
+	testConfig, err := testUtils.SetUpRouter(ctx)
 	if err != nil {
 		t.Fatalf("Error setting up server for testing: %s", err)
 	}
@@ -57,12 +49,20 @@ func TestAuthRegister(t *testing.T) {
 	routes.NewRoutes(
 		testConfig.Server,
 		testConfig.Db,
+		testConfig.Users,
+		testConfig.Chats,
+		testConfig.Rdb,
 		testConfig.Rdb,
 		ctx,
 		testConfig.Log,
-		testConfig.AmqpConfig,
+		testConfig.Broker,
+		testConfig.Relay,
 		testConfig.Upgrader,
 		testConfig.WebsocketMap,
+		testConfig.Storage,
+		testConfig.Mailer,
+		testConfig.OidcProvider,
+		testConfig.Keys,
 	)
 
 	w := httptest.NewRecorder()
@@ -0,0 +1,157 @@
+package auth_api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/sso"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type OidcCallbackHandler struct {
+	dto.HandlerInterface
+	users       db.UserRepository
+	rdb         *redis.Client
+	ctx         context.Context
+	log         *zap.Logger
+	keys        *jwtkeys.KeySet
+	provider    *sso.OidcProvider
+	middlewares []gin.HandlerFunc
+}
+
+func NewOidcCallbackHandler(
+	users db.UserRepository,
+	rdb *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+	provider *sso.OidcProvider,
+	keys *jwtkeys.KeySet,
+) *OidcCallbackHandler {
+	return &OidcCallbackHandler{
+		users:       users,
+		rdb:         rdb,
+		ctx:         ctx,
+		log:         log,
+		keys:        keys,
+		provider:    provider,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (*OidcCallbackHandler) Pattern() string {
+	return "/oidc/callback"
+}
+
+func (*OidcCallbackHandler) RequestMethod() string {
+	return constants.GET
+}
+
+// Handler the identity provider redirects to after the user logged in. On
+// first login the identity is linked to the account with the same verified
+// email address, or a new account is created. The response is the same as
+// POST /auth/signin. The state has to match the oidc_state cookie set by
+// GET /auth/oidc/login in the same browser.
+// GET /auth/oidc/callback?code=code&state=state
+//
+//	Response:
+//	202 Accepted: {
+//	"token": token,
+//	"refresh_token": refreshToken,
+//	"expires_in": seconds
+//	}
+//	202 Accepted: {
+//	"mfa_required": true,
+//	"mfa_token": mfaToken,
+//	"expires_in": seconds
+//	}
+//	400 Bad Request: {
+//	"error": "Invalid or expired login state"
+//	}
+//	401 Unauthorized: {
+//	"error": "Login was rejected by the identity provider"
+//	}
+//	403 Forbidden: {
+//	"error": "Identity provider did not share an email address"
+//	}
+//	403 Forbidden: {
+//	"error": "Account is disabled"
+//	}
+//	409 Conflict: {
+//	"error": "Email address belongs to another account"
+//	}
+func (h *OidcCallbackHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cookie, _ := c.Cookie(constants.OIDC_STATE_COOKIE)
+		setStateCookie(c, h.provider, "", -1)
+		if !sso.MatchesStateCookie(c.Query("state"), cookie) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": sso.ErrInvalidState.Error()})
+			return
+		}
+
+		login, err := sso.Finish(h.ctx, h.rdb, c.Query("state"))
+		if err == sso.ErrInvalidState {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			h.log.Error("Error finishing oidc login", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if c.Query("error") != "" || c.Query("code") == "" {
+			h.log.Info("Identity provider rejected login",
+				zap.String("error", c.Query("error")), zap.String("description", c.Query("error_description")))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Login was rejected by the identity provider"})
+			return
+		}
+
+		identity, err := h.provider.Exchange(c.Request.Context(), c.Query("code"), login)
+		if err != nil {
+			h.log.Warn("Error exchanging oidc code", zap.Error(err), zap.String("ip", c.ClientIP()))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Login was rejected by the identity provider"})
+			return
+		}
+		if identity.Email == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Identity provider did not share an email address"})
+			return
+		}
+		if identity.Name == "" {
+			identity.Name = identity.Email
+		}
+
+		user, err := h.users.GetOrProvisionOidcUser(&identity)
+		if err == db.ErrIdentityEmailTaken {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			h.log.Error("Error provisioning oidc user", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		response, err := signIn(c, h.ctx, h.users, h.rdb, h.keys, &user)
+		if err == db.ErrAccountDisabled {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			h.log.Error("Error signing in oidc user", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, response)
+	}
+}
+
+func (h *OidcCallbackHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
@@ -0,0 +1,92 @@
+package auth_api
+
+import (
+	"context"
+	"net/http"
+	"path"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/sso"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type OidcLoginHandler struct {
+	dto.HandlerInterface
+	rdb         *redis.Client
+	ctx         context.Context
+	log         *zap.Logger
+	provider    *sso.OidcProvider
+	middlewares []gin.HandlerFunc
+}
+
+func NewOidcLoginHandler(
+	rdb *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+	provider *sso.OidcProvider,
+) *OidcLoginHandler {
+	return &OidcLoginHandler{
+		rdb:         rdb,
+		ctx:         ctx,
+		log:         log,
+		provider:    provider,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (*OidcLoginHandler) Pattern() string {
+	return "/oidc/login"
+}
+
+func (*OidcLoginHandler) RequestMethod() string {
+	return constants.GET
+}
+
+// Handler to start signing in with the identity provider. The user is
+// redirected to the provider, which sends them back to GET /auth/oidc/callback.
+// The login can only be finished in the same browser, which gets the
+// oidc_state cookie.
+// GET /auth/oidc/login
+//
+//	Response:
+//	302 Found: Location of the identity provider
+//	Set-Cookie: oidc_state
+//	502 Bad Gateway: {
+//	"error": "Identity provider is unavailable"
+//	}
+func (h *OidcLoginHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state, login, err := sso.Begin(h.ctx, h.rdb)
+		if err != nil {
+			h.log.Error("Error starting oidc login", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		url, err := h.provider.AuthCodeURL(c.Request.Context(), state, login)
+		if err != nil {
+			h.log.Error("Error building oidc redirect", zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+			return
+		}
+
+		setStateCookie(c, h.provider, sso.StateCookie(state), int(constants.OIDC_STATE_TTL.Seconds()))
+		c.Redirect(http.StatusFound, url)
+	}
+}
+
+// setStateCookie sets the cookie tying a login to the browser, or removes it
+// when maxAge is negative. It is only sent to the callback, including when
+// the identity provider redirects there from another site.
+func setStateCookie(c *gin.Context, provider *sso.OidcProvider, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(constants.OIDC_STATE_COOKIE, value, maxAge,
+		path.Dir(constants.OIDC_CALLBACK_PATH), "", provider.SecureCallback(), true)
+}
+
+func (h *OidcLoginHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
@@ -0,0 +1,198 @@
+package auth_api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/routes"
+	"github.com/nihal-ramaswamy/GoChat/internal/sso"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+)
+
+// Test /auth/oidc/login and /auth/oidc/callback
+// Tests that the first login provisions or links a user and later logins
+// return the same user
+func TestOidcLogin(t *testing.T) {
+	ctx := context.Background()
+
+	testConfig, err := testUtils.SetUpRouter(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up server for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := testConfig.PostgresContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+		testConfig.Db.Close()
+	})
+
+	t.Cleanup(func() {
+		if err := testConfig.RabbitmqContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	t.Cleanup(func() {
+		if err := testConfig.RedisContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	mock, err := testUtils.NewMockOidcServer("client", "secret")
+	if err != nil {
+		t.Fatalf("Error starting mock oidc server: %s", err)
+	}
+	t.Cleanup(mock.Close)
+
+	testConfig.OidcProvider = sso.NewOidcProvider(&sso.OidcConfig{
+		Issuer:       mock.Issuer(),
+		ClientId:     "client",
+		ClientSecret: "secret",
+		RedirectUrl:  "http://localhost" + constants.OIDC_CALLBACK_PATH,
+		Scopes:       []string{"openid", "email", "profile"},
+	})
+
+	routes.NewRoutes(
+		testConfig.Server,
+		testConfig.Db,
+		testConfig.Users,
+		testConfig.Chats,
+		testConfig.Rdb,
+		testConfig.Rdb,
+		ctx,
+		testConfig.Log,
+		testConfig.Broker,
+		testConfig.Relay,
+		testConfig.Upgrader,
+		testConfig.WebsocketMap,
+		testConfig.Storage,
+		testConfig.Mailer,
+		testConfig.OidcProvider,
+		testConfig.Keys,
+	)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	// start logs in at the identity provider and returns the callback it
+	// redirects to along with the cookies of the browser
+	start := func() (string, []*http.Cookie) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/auth/oidc/login", nil)
+		if err != nil {
+			t.Fatalf("Error creating request: %s", err)
+		}
+		testConfig.Server.ServeHTTP(w, req)
+		if w.Code != http.StatusFound {
+			t.Fatalf("Expected status code: 302, got %d", w.Code)
+		}
+
+		resp, err := client.Get(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatalf("Error calling identity provider: %s", err)
+		}
+		resp.Body.Close()
+		callback, err := url.Parse(resp.Header.Get("Location"))
+		if err != nil {
+			t.Fatalf("Error parsing callback: %s", err)
+		}
+		return callback.RequestURI(), w.Result().Cookies()
+	}
+
+	finish := func(callback string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", callback, nil)
+		if err != nil {
+			t.Fatalf("Error creating request: %s", err)
+		}
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		testConfig.Server.ServeHTTP(w, req)
+		return w
+	}
+
+	// login runs the browser side of the flow and returns the callback response
+	login := func() *httptest.ResponseRecorder {
+		return finish(start())
+	}
+
+	// First login creates the user
+	mock.User = testUtils.MockOidcUser{Subject: "new", Email: "new@sso", EmailVerified: true, Name: "new"}
+	w := login()
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status code: 202, got %d", w.Code)
+	}
+	var tokens dto.TokenPair
+	if err := json.Unmarshal(w.Body.Bytes(), &tokens); err != nil || tokens.Token == "" {
+		t.Fatalf("Expected tokens, got %s", w.Body.String())
+	}
+	created, err := db.GetUserFromEmail(testConfig.Db, "new@sso")
+	if err != nil {
+		t.Fatalf("Expected user to be provisioned: %s", err)
+	}
+
+	// The identity keeps pointing to the same user even if the email changes
+	mock.User.Email = "renamed@sso"
+	if w := login(); w.Code != http.StatusAccepted {
+		t.Errorf("Expected status code: 202, got %d", w.Code)
+	}
+	if db.DoesEmailExist(testConfig.Db, "renamed@sso") {
+		t.Errorf("Expected no second user for the same identity")
+	}
+
+	// Existing password users are linked when the email is verified
+	existing := dto.User{Name: "existing", Email: "existing@sso", Password: "test"}
+	body, _ := json.Marshal(existing)
+	req, err := http.NewRequest("POST", "/auth/register", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatalf("Error creating request: %s", err)
+	}
+	testConfig.Server.ServeHTTP(httptest.NewRecorder(), req)
+
+	mock.User = testUtils.MockOidcUser{Subject: "unverified", Email: existing.Email, EmailVerified: false}
+	if w := login(); w.Code != http.StatusConflict {
+		t.Errorf("Expected status code: 409, got %d", w.Code)
+	}
+	mock.User = testUtils.MockOidcUser{Subject: "verified", Email: existing.Email, EmailVerified: true}
+	if w := login(); w.Code != http.StatusAccepted {
+		t.Errorf("Expected status code: 202, got %d", w.Code)
+	}
+	linked, err := db.GetUserFromEmail(testConfig.Db, existing.Email)
+	if err != nil {
+		t.Fatalf("Error getting user: %s", err)
+	}
+	if linked.Id == created.Id || linked.Name != existing.Name {
+		t.Errorf("Expected identity to be linked to the existing user")
+	}
+
+	// A callback only finishes the login in the browser that started it, so a
+	// callback URL of someone else's login cannot sign a victim in
+	callback, cookies := start()
+	if w := finish(callback, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code: 400 without the state cookie, got %d", w.Code)
+	}
+	_, otherCookies := start()
+	if w := finish(callback, otherCookies); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code: 400 with the cookie of another login, got %d", w.Code)
+	}
+	if w := finish(callback, cookies); w.Code != http.StatusAccepted {
+		t.Errorf("Expected status code: 202, got %d", w.Code)
+	}
+
+	// A state can only be used once
+	if w := finish(callback, cookies); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code: 400, got %d", w.Code)
+	}
+}
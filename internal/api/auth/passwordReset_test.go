@@ -0,0 +1,172 @@
+package auth_api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/routes"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+)
+
+var (
+	verifyLinkRegexp = regexp.MustCompile(`/auth/verify\?token=(\S+)`)
+	resetTokenRegexp = regexp.MustCompile(`(?m)^([0-9a-f]+\.[0-9a-f]+)\r?$`)
+)
+
+// readMail returns the content of the only mail written since the last call.
+// Some mails are sent in the background, so it waits for one to arrive.
+func readMail(t *testing.T, dir string) string {
+	t.Helper()
+
+	var mails []string
+	for deadline := time.Now().Add(5 * time.Second); len(mails) == 0 && time.Now().Before(deadline); {
+		var err error
+		if mails, err = filepath.Glob(filepath.Join(dir, "*.eml")); err != nil {
+			t.Fatalf("Error reading mail directory: %s", err)
+		}
+		if len(mails) == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if len(mails) != 1 {
+		t.Fatalf("Expected 1 mail, got %d", len(mails))
+	}
+	path := mails[0]
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error reading mail: %s", err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Error removing mail: %s", err)
+	}
+	return string(content)
+}
+
+// Test /auth/verify, /auth/forgot and /auth/reset
+// Tests that mailed tokens verify the address and change the password once
+func TestVerifyAndResetPassword(t *testing.T) {
+	ctx := context.Background()
+
+	testConfig, err := testUtils.SetUpRouter(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up server for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := testConfig.PostgresContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+		testConfig.Db.Close()
+	})
+
+	t.Cleanup(func() {
+		if err := testConfig.RabbitmqContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	t.Cleanup(func() {
+		if err := testConfig.RedisContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	routes.NewRoutes(
+		testConfig.Server,
+		testConfig.Db,
+		testConfig.Users,
+		testConfig.Chats,
+		testConfig.Rdb,
+		testConfig.Rdb,
+		ctx,
+		testConfig.Log,
+		testConfig.Broker,
+		testConfig.Relay,
+		testConfig.Upgrader,
+		testConfig.WebsocketMap,
+		testConfig.Storage,
+		testConfig.Mailer,
+		testConfig.OidcProvider,
+		testConfig.Keys,
+	)
+
+	serve := func(method, target string, payload any) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		if payload != nil {
+			if err := json.NewEncoder(&body).Encode(payload); err != nil {
+				t.Fatalf("Error converting payload to json: %s", err)
+			}
+		}
+		req, err := http.NewRequest(method, target, &body)
+		if err != nil {
+			t.Fatalf("Error creating request: %s", err)
+		}
+		w := httptest.NewRecorder()
+		testConfig.Server.ServeHTTP(w, req)
+		return w
+	}
+
+	user := dto.User{Name: "test", Email: "reset@test", Password: "old"}
+	if w := serve("POST", "/auth/register", user); w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status code: 202, got %d", w.Code)
+	}
+	stored, err := db.GetUserFromEmail(testConfig.Db, user.Email)
+	if err != nil {
+		t.Fatalf("Error getting user: %s", err)
+	}
+	if verified, err := db.IsEmailVerified(testConfig.Db, stored.Id); err != nil || verified {
+		t.Errorf("Expected email to be unverified after registration")
+	}
+
+	match := verifyLinkRegexp.FindStringSubmatch(readMail(t, testConfig.Mailer.Dir))
+	if match == nil {
+		t.Fatalf("Expected verification link in mail")
+	}
+	if w := serve("GET", "/auth/verify?token="+match[1], nil); w.Code != http.StatusOK {
+		t.Errorf("Expected status code: 200, got %d", w.Code)
+	}
+	if verified, err := db.IsEmailVerified(testConfig.Db, stored.Id); err != nil || !verified {
+		t.Errorf("Expected email to be verified")
+	}
+	if w := serve("GET", "/auth/verify?token="+match[1], nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected used token to be rejected, got %d", w.Code)
+	}
+
+	// Unknown addresses get the same response and no mail
+	if w := serve("POST", "/auth/forgot", dto.ForgotPasswordRequest{Email: "unknown@test"}); w.Code != http.StatusAccepted {
+		t.Errorf("Expected status code: 202, got %d", w.Code)
+	}
+	if w := serve("POST", "/auth/forgot", dto.ForgotPasswordRequest{Email: user.Email}); w.Code != http.StatusAccepted {
+		t.Errorf("Expected status code: 202, got %d", w.Code)
+	}
+	match = resetTokenRegexp.FindStringSubmatch(readMail(t, testConfig.Mailer.Dir))
+	if match == nil {
+		t.Fatalf("Expected reset token in mail")
+	}
+	reset := dto.ResetPasswordRequest{Token: match[1], Password: "new"}
+	if w := serve("POST", "/auth/reset", reset); w.Code != http.StatusAccepted {
+		t.Errorf("Expected status code: 202, got %d", w.Code)
+	}
+	if w := serve("POST", "/auth/reset", reset); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected used token to be rejected, got %d", w.Code)
+	}
+
+	if w := serve("POST", "/auth/signin", user); w.Code == http.StatusAccepted {
+		t.Errorf("Expected old password to be rejected")
+	}
+	user.Password = "new"
+	if w := serve("POST", "/auth/signin", user); w.Code != http.StatusAccepted {
+		t.Errorf("Expected status code: 202, got %d", w.Code)
+	}
+}
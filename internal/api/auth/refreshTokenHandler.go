@@ -0,0 +1,111 @@
+package auth_api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/session"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type RefreshTokenHandler struct {
+	dto.HandlerInterface
+	log         *zap.Logger
+	keys        *jwtkeys.KeySet
+	rdb         *redis.Client
+	ctx         context.Context
+	middlewares []gin.HandlerFunc
+}
+
+func NewRefreshTokenHandler(
+	rdb *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+	keys *jwtkeys.KeySet,
+) *RefreshTokenHandler {
+	return &RefreshTokenHandler{
+		log:         log,
+		keys:        keys,
+		rdb:         rdb,
+		ctx:         ctx,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (*RefreshTokenHandler) Pattern() string {
+	return "/refresh"
+}
+
+func (*RefreshTokenHandler) RequestMethod() string {
+	return constants.POST
+}
+
+// Handler to exchange a refresh token for a new access token. The refresh
+// token is rotated: the one sent is no longer valid and a new one is returned.
+// Sending an already exchanged refresh token again signs the device out.
+// POST /auth/refresh
+//
+//	Request Body: {
+//	  "refresh_token": refreshToken
+//	  }
+//
+//	  Response:
+//	  202 Accepted: {
+//	  "token": token,
+//	  "refresh_token": refreshToken,
+//	  "expires_in": seconds
+//	  }
+//	  400 Bad Request: {
+//	  "error": "Error reading payload"
+//	  }
+//	  401 Unauthorized: {
+//	  "error": "Invalid refresh token"
+//	  }
+//	  401 Unauthorized: {
+//	  "error": "Refresh token reuse detected"
+//	  }
+func (r *RefreshTokenHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request dto.RefreshRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Error reading payload"})
+			return
+		}
+
+		userSession, refreshToken, err := session.Rotate(r.ctx, r.rdb, request.RefreshToken)
+		if err == session.ErrRefreshTokenReused {
+			r.log.Warn("Refresh token reused, session revoked",
+				zap.String("session_id", userSession.Id), zap.String("ip", c.ClientIP()))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if err == session.ErrInvalidRefreshToken {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			r.log.Error("Error rotating refresh token", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		user := &dto.User{Id: userSession.UserId, Email: userSession.Email, Role: userSession.Role}
+		tokens, err := newTokenPair(r.keys, user, userSession.Id, refreshToken)
+		if err != nil {
+			r.log.Error("Error generating token", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, tokens)
+	}
+}
+
+func (r *RefreshTokenHandler) Middlewares() []gin.HandlerFunc {
+	return r.middlewares
+}
@@ -0,0 +1,108 @@
+package auth_api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/mailtoken"
+	"github.com/nihal-ramaswamy/GoChat/internal/session"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type ResetPasswordHandler struct {
+	dto.HandlerInterface
+	users       db.UserRepository
+	rdb         *redis.Client
+	ctx         context.Context
+	log         *zap.Logger
+	links       *mailLinks
+	middlewares []gin.HandlerFunc
+}
+
+func NewResetPasswordHandler(
+	users db.UserRepository,
+	rdb *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+) *ResetPasswordHandler {
+	return &ResetPasswordHandler{
+		users:       users,
+		rdb:         rdb,
+		ctx:         ctx,
+		log:         log,
+		links:       newMailLinks(),
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (*ResetPasswordHandler) Pattern() string {
+	return "/reset"
+}
+
+func (*ResetPasswordHandler) RequestMethod() string {
+	return constants.POST
+}
+
+// Handler to choose a new password with a token from POST /auth/forgot.
+// Every session of the user is signed out. Since the token was delivered by
+// mail, the email address is marked as verified as well.
+// POST /auth/reset
+//
+//	Request Body: {
+//	  "token": token,
+//	  "password": password
+//	  }
+//
+//	  Response:
+//	  202 Accepted: {
+//	  "message": ok
+//	  }
+//	  400 Bad Request: {
+//	  "error": "Error reading payload"
+//	  }
+//	  400 Bad Request: {
+//	  "error": "Invalid or expired token"
+//	  }
+func (r *ResetPasswordHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request dto.ResetPasswordRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Error reading payload"})
+			return
+		}
+
+		userId, err := mailtoken.Consume(r.ctx, r.rdb, r.links.secret, constants.MAIL_TOKEN_RESET_PASSWORD, request.Token)
+		if err == mailtoken.ErrInvalidToken {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			r.log.Error("Error consuming reset token", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if err := r.users.ResetPassword(userId, request.Password); err != nil {
+			r.log.Error("Error resetting password", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if err := r.users.MarkEmailVerified(userId); err != nil {
+			r.log.Error("Error marking email verified", zap.Error(err))
+		}
+		if err := session.RevokeAll(r.ctx, r.rdb, userId, ""); err != nil {
+			r.log.Error("Error revoking sessions", zap.Error(err))
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"message": "ok"})
+	}
+}
+
+func (r *ResetPasswordHandler) Middlewares() []gin.HandlerFunc {
+	return r.middlewares
+}
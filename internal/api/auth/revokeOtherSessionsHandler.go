@@ -0,0 +1,81 @@
+package auth_api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"github.com/nihal-ramaswamy/GoChat/internal/session"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type RevokeOtherSessionsHandler struct {
+	dto.HandlerInterface
+	ctx         context.Context
+	rdb         *redis.Client
+	log         *zap.Logger
+	middlewares []gin.HandlerFunc
+}
+
+func NewRevokeOtherSessionsHandler(
+	pdb *sql.DB,
+	rdb *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+	keys *jwtkeys.KeySet,
+) *RevokeOtherSessionsHandler {
+	return &RevokeOtherSessionsHandler{
+		rdb:         rdb,
+		ctx:         ctx,
+		log:         log,
+		middlewares: []gin.HandlerFunc{middlewares.AuthMiddleware(pdb, rdb, ctx, log, keys)},
+	}
+}
+
+func (*RevokeOtherSessionsHandler) Pattern() string {
+	return "/sessions"
+}
+
+func (*RevokeOtherSessionsHandler) RequestMethod() string {
+	return constants.DELETE
+}
+
+// Handler to sign out every session of the user except the calling one
+// DELETE /auth/sessions
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	  Response:
+//	  202 Accepted: {
+//	  "message": ok
+//	  }
+func (r *RevokeOtherSessionsHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		current, err := currentSession(c, r.ctx, r.rdb)
+		if err != nil {
+			r.log.Error("Error getting current session", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if err := session.RevokeAll(r.ctx, r.rdb, current.UserId, current.Id); err != nil {
+			r.log.Error("Error revoking sessions", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"message": "ok"})
+	}
+}
+
+func (r *RevokeOtherSessionsHandler) Middlewares() []gin.HandlerFunc {
+	return r.middlewares
+}
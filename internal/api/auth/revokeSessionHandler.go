@@ -0,0 +1,96 @@
+package auth_api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"github.com/nihal-ramaswamy/GoChat/internal/session"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type RevokeSessionHandler struct {
+	dto.HandlerInterface
+	ctx         context.Context
+	rdb         *redis.Client
+	log         *zap.Logger
+	middlewares []gin.HandlerFunc
+}
+
+func NewRevokeSessionHandler(
+	pdb *sql.DB,
+	rdb *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+	keys *jwtkeys.KeySet,
+) *RevokeSessionHandler {
+	return &RevokeSessionHandler{
+		rdb:         rdb,
+		ctx:         ctx,
+		log:         log,
+		middlewares: []gin.HandlerFunc{middlewares.AuthMiddleware(pdb, rdb, ctx, log, keys)},
+	}
+}
+
+func (*RevokeSessionHandler) Pattern() string {
+	return "/sessions/:id"
+}
+
+func (*RevokeSessionHandler) RequestMethod() string {
+	return constants.DELETE
+}
+
+// Handler to sign out one session of the user, for example a lost device.
+// Its access and refresh tokens stop working immediately.
+// DELETE /auth/sessions/:id
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	  Response:
+//	  202 Accepted: {
+//	  "message": ok
+//	  }
+//	  404 Not Found: {
+//	  "error": "Session does not exist"
+//	  }
+func (r *RevokeSessionHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		current, err := currentSession(c, r.ctx, r.rdb)
+		if err != nil {
+			r.log.Error("Error getting current session", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		target, err := session.Get(r.ctx, r.rdb, c.Param("id"))
+		if err == redis.Nil || (err == nil && target.UserId != current.UserId) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Session does not exist"})
+			return
+		}
+		if err != nil {
+			r.log.Error("Error getting session", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if err := session.Revoke(r.ctx, r.rdb, target.Id); err != nil {
+			r.log.Error("Error revoking session", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"message": "ok"})
+	}
+}
+
+func (r *RevokeSessionHandler) Middlewares() []gin.HandlerFunc {
+	return r.middlewares
+}
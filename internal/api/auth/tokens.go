@@ -0,0 +1,94 @@
+package auth_api
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/mfa"
+	"github.com/nihal-ramaswamy/GoChat/internal/session"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTokenPair signs an access token for a session and pairs it with the session's refresh token
+func newTokenPair(keys *jwtkeys.KeySet, user *dto.User, sessionId, refreshToken string) (dto.TokenPair, error) {
+	token, err := keys.Issue(user, sessionId)
+	if err != nil {
+		return dto.TokenPair{}, err
+	}
+
+	return dto.TokenPair{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(constants.ACCESS_TOKEN_EXPIRY_TIME.Seconds()),
+	}, nil
+}
+
+// loadRole sets the role of a user who is about to sign in. It returns
+// db.ErrAccountDisabled if the account was disabled.
+func loadRole(users db.UserRepository, user *dto.User) error {
+	role, err := users.GetActiveRole(user.Id)
+	if err != nil {
+		return err
+	}
+	user.Role = role
+	return nil
+}
+
+// startSession signs the user in on the device making the request
+func startSession(
+	c *gin.Context,
+	ctx context.Context,
+	rdb *redis.Client,
+	keys *jwtkeys.KeySet,
+	user *dto.User,
+) (dto.TokenPair, error) {
+	userSession, refreshToken, err := session.Create(ctx, rdb, user, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		return dto.TokenPair{}, err
+	}
+	return newTokenPair(keys, user, userSession.Id, refreshToken)
+}
+
+// signIn starts a session for a user whose password or identity provider was
+// checked, or returns an MfaChallenge if the user has two-factor
+// authentication enabled. It returns db.ErrAccountDisabled if the account
+// was disabled.
+func signIn(
+	c *gin.Context,
+	ctx context.Context,
+	users db.UserRepository,
+	rdb *redis.Client,
+	keys *jwtkeys.KeySet,
+	user *dto.User,
+) (any, error) {
+	if err := loadRole(users, user); err != nil {
+		return nil, err
+	}
+
+	_, totpEnabled, err := users.GetTotp(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	if !totpEnabled {
+		return startSession(c, ctx, rdb, keys, user)
+	}
+
+	mfaToken, err := mfa.IssuePending(ctx, rdb, user)
+	if err != nil {
+		return nil, err
+	}
+	return dto.MfaChallenge{
+		MfaRequired: true,
+		MfaToken:    mfaToken,
+		ExpiresIn:   int64(constants.MFA_PENDING_TTL.Seconds()),
+	}, nil
+}
+
+// currentSession returns the session of the access token AuthMiddleware accepted
+func currentSession(c *gin.Context, ctx context.Context, rdb *redis.Client) (dto.Session, error) {
+	return session.Get(ctx, rdb, c.GetString("session_id"))
+}
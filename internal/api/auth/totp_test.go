@@ -0,0 +1,181 @@
+package auth_api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/mfa"
+	"github.com/nihal-ramaswamy/GoChat/internal/routes"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+)
+
+// Test /auth/2fa/enroll, /auth/2fa/confirm and /auth/2fa/verify
+// Tests that sign in requires a second factor once enrolled and that recovery
+// codes and wrong codes are handled
+func TestTotpLogin(t *testing.T) {
+	ctx := context.Background()
+
+	testConfig, err := testUtils.SetUpRouter(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up server for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := testConfig.PostgresContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+		testConfig.Db.Close()
+	})
+
+	t.Cleanup(func() {
+		if err := testConfig.RabbitmqContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	t.Cleanup(func() {
+		if err := testConfig.RedisContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	routes.NewRoutes(
+		testConfig.Server,
+		testConfig.Db,
+		testConfig.Users,
+		testConfig.Chats,
+		testConfig.Rdb,
+		testConfig.Rdb,
+		ctx,
+		testConfig.Log,
+		testConfig.Broker,
+		testConfig.Relay,
+		testConfig.Upgrader,
+		testConfig.WebsocketMap,
+		testConfig.Storage,
+		testConfig.Mailer,
+		testConfig.OidcProvider,
+		testConfig.Keys,
+	)
+
+	serve := func(method, target, token string, payload any, result any) int {
+		var body bytes.Buffer
+		if payload != nil {
+			if err := json.NewEncoder(&body).Encode(payload); err != nil {
+				t.Fatalf("Error converting payload to json: %s", err)
+			}
+		}
+		req, err := http.NewRequest(method, target, &body)
+		if err != nil {
+			t.Fatalf("Error creating request: %s", err)
+		}
+		if token != "" {
+			req.Header.Set("Token", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		testConfig.Server.ServeHTTP(w, req)
+		if result != nil {
+			if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+				t.Fatalf("Error unmarshalling response: %s", err)
+			}
+		}
+		return w.Code
+	}
+
+	user := dto.User{Name: "test", Email: "totp@test", Password: "test"}
+	if code := serve("POST", "/auth/register", "", user, nil); code != http.StatusAccepted {
+		t.Fatalf("Expected status code: 202, got %d", code)
+	}
+	var tokens dto.TokenPair
+	if code := serve("POST", "/auth/signin", "", user, &tokens); code != http.StatusAccepted {
+		t.Fatalf("Expected status code: 202, got %d", code)
+	}
+
+	var enrollment dto.TotpEnrollment
+	if code := serve("POST", "/auth/2fa/enroll", tokens.Token, nil, &enrollment); code != http.StatusOK {
+		t.Fatalf("Expected status code: 200, got %d", code)
+	}
+	if enrollment.Secret == "" || enrollment.Uri == "" {
+		t.Fatalf("Expected secret and uri, got %+v", enrollment)
+	}
+
+	// Enrolling is not finished, so sign in still returns tokens directly
+	var challenge dto.MfaChallenge
+	if serve("POST", "/auth/signin", "", user, &challenge); challenge.MfaRequired {
+		t.Errorf("Expected sign in without second factor before confirming")
+	}
+
+	if code := serve("POST", "/auth/2fa/confirm", tokens.Token, dto.TotpCodeRequest{Code: "000000"}, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected status code: 400, got %d", code)
+	}
+	now := time.Now()
+	totp, err := mfa.Code(enrollment.Secret, now)
+	if err != nil {
+		t.Fatalf("Error generating code: %s", err)
+	}
+	var recovery dto.RecoveryCodes
+	if code := serve("POST", "/auth/2fa/confirm", tokens.Token, dto.TotpCodeRequest{Code: totp}, &recovery); code != http.StatusOK {
+		t.Fatalf("Expected status code: 200, got %d", code)
+	}
+	if len(recovery.RecoveryCodes) != constants.RECOVERY_CODE_COUNT {
+		t.Errorf("Expected %d recovery codes, got %d", constants.RECOVERY_CODE_COUNT, len(recovery.RecoveryCodes))
+	}
+
+	// Password alone is no longer enough
+	challenge = dto.MfaChallenge{}
+	if code := serve("POST", "/auth/signin", "", user, &challenge); code != http.StatusAccepted || !challenge.MfaRequired {
+		t.Fatalf("Expected mfa challenge, got %d %+v", code, challenge)
+	}
+
+	// The code used to confirm cannot be replayed
+	verify := dto.MfaVerifyRequest{MfaToken: challenge.MfaToken, SecondFactor: dto.SecondFactor{Code: totp}}
+	if code := serve("POST", "/auth/2fa/verify", "", verify, nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected replayed code to be rejected, got %d", code)
+	}
+
+	verify.Code, err = mfa.Code(enrollment.Secret, now.Add(constants.TOTP_PERIOD))
+	if err != nil {
+		t.Fatalf("Error generating code: %s", err)
+	}
+	tokens = dto.TokenPair{}
+	if code := serve("POST", "/auth/2fa/verify", "", verify, &tokens); code != http.StatusAccepted || tokens.Token == "" {
+		t.Fatalf("Expected tokens, got %d", code)
+	}
+	if code := serve("POST", "/auth/2fa/verify", "", verify, nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected used mfa token to be rejected, got %d", code)
+	}
+
+	// Recovery codes work once each
+	challenge = dto.MfaChallenge{}
+	serve("POST", "/auth/signin", "", user, &challenge)
+	verify = dto.MfaVerifyRequest{MfaToken: challenge.MfaToken, SecondFactor: dto.SecondFactor{RecoveryCode: recovery.RecoveryCodes[0]}}
+	if code := serve("POST", "/auth/2fa/verify", "", verify, nil); code != http.StatusAccepted {
+		t.Errorf("Expected status code: 202, got %d", code)
+	}
+	challenge = dto.MfaChallenge{}
+	serve("POST", "/auth/signin", "", user, &challenge)
+	verify.MfaToken = challenge.MfaToken
+	if code := serve("POST", "/auth/2fa/verify", "", verify, nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected used recovery code to be rejected, got %d", code)
+	}
+
+	// The pending login is dropped after too many wrong codes
+	challenge = dto.MfaChallenge{}
+	serve("POST", "/auth/signin", "", user, &challenge)
+	verify = dto.MfaVerifyRequest{MfaToken: challenge.MfaToken, SecondFactor: dto.SecondFactor{Code: "000000"}}
+	var result testUtils.ErrorDto
+	for range constants.MFA_MAX_ATTEMPTS - 1 {
+		serve("POST", "/auth/2fa/verify", "", verify, nil)
+	}
+	if serve("POST", "/auth/2fa/verify", "", verify, &result); result.Error != mfa.ErrTooManyAttempts.Error() {
+		t.Errorf("Expected %q, got %q", mfa.ErrTooManyAttempts.Error(), result.Error)
+	}
+}
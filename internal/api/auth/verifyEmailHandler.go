@@ -0,0 +1,85 @@
+package auth_api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/mailtoken"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type VerifyEmailHandler struct {
+	dto.HandlerInterface
+	users       db.UserRepository
+	rdb         *redis.Client
+	ctx         context.Context
+	log         *zap.Logger
+	links       *mailLinks
+	middlewares []gin.HandlerFunc
+}
+
+func NewVerifyEmailHandler(
+	users db.UserRepository,
+	rdb *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+) *VerifyEmailHandler {
+	return &VerifyEmailHandler{
+		users:       users,
+		rdb:         rdb,
+		ctx:         ctx,
+		log:         log,
+		links:       newMailLinks(),
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (*VerifyEmailHandler) Pattern() string {
+	return "/verify"
+}
+
+func (*VerifyEmailHandler) RequestMethod() string {
+	return constants.GET
+}
+
+// Handler to confirm an email address with the link mailed on registration
+// GET /auth/verify?token=token
+//
+//	Response:
+//	200 OK: {
+//	"message": "Email verified"
+//	}
+//	400 Bad Request: {
+//	"error": "Invalid or expired token"
+//	}
+func (v *VerifyEmailHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := mailtoken.Consume(v.ctx, v.rdb, v.links.secret, constants.MAIL_TOKEN_VERIFY_EMAIL, c.Query("token"))
+		if err == mailtoken.ErrInvalidToken {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			v.log.Error("Error consuming verification token", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if err := v.users.MarkEmailVerified(userId); err != nil {
+			v.log.Error("Error marking email verified", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+	}
+}
+
+func (v *VerifyEmailHandler) Middlewares() []gin.HandlerFunc {
+	return v.middlewares
+}
@@ -0,0 +1,190 @@
+package auth_api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/lockout"
+	"github.com/nihal-ramaswamy/GoChat/internal/mfa"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type VerifyTotpHandler struct {
+	dto.HandlerInterface
+	users       db.UserRepository
+	rdb         *redis.Client
+	ctx         context.Context
+	log         *zap.Logger
+	keys        *jwtkeys.KeySet
+	middlewares []gin.HandlerFunc
+}
+
+func NewVerifyTotpHandler(
+	users db.UserRepository,
+	rdb *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+	keys *jwtkeys.KeySet,
+) *VerifyTotpHandler {
+	return &VerifyTotpHandler{
+		users:       users,
+		rdb:         rdb,
+		ctx:         ctx,
+		log:         log,
+		keys:        keys,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (*VerifyTotpHandler) Pattern() string {
+	return "/2fa/verify"
+}
+
+func (*VerifyTotpHandler) RequestMethod() string {
+	return constants.POST
+}
+
+// Handler to finish signing in a user with two-factor authentication. The
+// mfa token from POST /auth/signin is exchanged for a session once a current
+// code or a recovery code is sent. After too many wrong codes the user has to
+// sign in with their password again. Wrong codes also count as failed sign ins
+// of the account, so signing in again does not allow more guesses once it is
+// locked.
+// POST /auth/2fa/verify
+//
+//	Request Body: {
+//	  "mfa_token": mfaToken,
+//	  "code": code,
+//	  "recovery_code": recoveryCode
+//	  }
+//
+//	  Response:
+//	  202 Accepted: {
+//	  "token": token,
+//	  "refresh_token": refreshToken,
+//	  "expires_in": seconds
+//	  }
+//	  400 Bad Request: {
+//	  "error": "Error reading payload"
+//	  }
+//	  401 Unauthorized: {
+//	  "error": "Invalid or expired mfa token"
+//	  }
+//	  401 Unauthorized: {
+//	  "error": "Invalid code"
+//	  }
+//	  401 Unauthorized: {
+//	  "error": "Too many attempts"
+//	  }
+//	  403 Forbidden: {
+//	  "error": "Account is disabled"
+//	  }
+//	  429 Too Many Requests: {
+//	  "error": "Too many failed attempts, try again later"
+//	  }
+func (h *VerifyTotpHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request dto.MfaVerifyRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Error reading payload"})
+			return
+		}
+
+		user, err := mfa.Pending(h.ctx, h.rdb, request.MfaToken)
+		if err == mfa.ErrInvalidMfaToken {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			h.log.Error("Error getting pending login", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		locked, err := lockout.Check(h.ctx, h.rdb, user.Email, c.ClientIP())
+		if err != nil {
+			h.log.Error("Error checking lockout", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if locked > 0 {
+			tooManyAttempts(c, locked)
+			return
+		}
+
+		valid, err := checkSecondFactor(h.ctx, h.users, h.rdb, user.Id, request.SecondFactor)
+		if err != nil {
+			h.log.Error("Error checking second factor", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if !valid {
+			locked, err := lockout.Fail(h.ctx, h.rdb, user.Email, c.ClientIP())
+			if err != nil {
+				h.log.Error("Error recording failed sign in", zap.Error(err))
+			}
+			if locked > 0 {
+				h.log.Warn("Sign in locked",
+					zap.String("event", "login_lockout"),
+					zap.String("email", user.Email),
+					zap.String("ip", c.ClientIP()),
+					zap.Duration("duration", locked))
+				tooManyAttempts(c, locked)
+				return
+			}
+			if err := mfa.Fail(h.ctx, h.rdb, request.MfaToken); err == mfa.ErrTooManyAttempts || err == mfa.ErrInvalidMfaToken {
+				h.log.Warn("Second factor rejected", zap.String("user_id", user.Id), zap.String("ip", c.ClientIP()), zap.Error(err))
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			} else if err != nil {
+				h.log.Error("Error recording failed attempt", zap.Error(err))
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+			return
+		}
+
+		completed, err := mfa.Complete(h.ctx, h.rdb, request.MfaToken)
+		if err != nil {
+			h.log.Error("Error completing pending login", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if !completed {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": mfa.ErrInvalidMfaToken.Error()})
+			return
+		}
+
+		// The account may have been disabled since the password was checked
+		if err := loadRole(h.users, &user); err == db.ErrAccountDisabled {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		} else if err != nil {
+			h.log.Error("Error getting role", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		tokens, err := startSession(c, h.ctx, h.rdb, h.keys, &user)
+		if err != nil {
+			h.log.Error("Error starting session", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if err := lockout.Reset(h.ctx, h.rdb, user.Email); err != nil {
+			h.log.Error("Error resetting failed sign ins", zap.Error(err))
+		}
+
+		c.JSON(http.StatusAccepted, tokens)
+	}
+}
+
+func (h *VerifyTotpHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
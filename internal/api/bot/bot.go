@@ -0,0 +1,27 @@
+package bot_api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+// ownedBot returns the bot in the :id path parameter if it belongs to the
+// caller. Bots of other users are reported as missing.
+func ownedBot(c *gin.Context, pdb *sql.DB, log *zap.Logger) (dto.Bot, bool) {
+	bot, err := db.GetBot(pdb, c.Param("id"))
+	if err == sql.ErrNoRows || (err == nil && bot.OwnerId != c.GetString("user_id")) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Bot does not exist"})
+		return bot, false
+	}
+	if err != nil {
+		log.Error("Error getting bot", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return bot, false
+	}
+	return bot, true
+}
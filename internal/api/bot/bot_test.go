@@ -0,0 +1,189 @@
+package bot_api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/routes"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+)
+
+// Test /bots and /bots/:id/keys
+// Tests that API keys can only call routes their scopes allow and stop
+// working once revoked
+func TestApiKeys(t *testing.T) {
+	ctx := context.Background()
+
+	testConfig, err := testUtils.SetUpRouter(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up server for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := testConfig.PostgresContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+		testConfig.Db.Close()
+	})
+
+	t.Cleanup(func() {
+		if err := testConfig.RabbitmqContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	t.Cleanup(func() {
+		if err := testConfig.RedisContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	routes.NewRoutes(
+		testConfig.Server,
+		testConfig.Db,
+		testConfig.Users,
+		testConfig.Chats,
+		testConfig.Rdb,
+		testConfig.Rdb,
+		ctx,
+		testConfig.Log,
+		testConfig.Broker,
+		testConfig.Relay,
+		testConfig.Upgrader,
+		testConfig.WebsocketMap,
+		testConfig.Storage,
+		testConfig.Mailer,
+		testConfig.OidcProvider,
+		testConfig.Keys,
+	)
+
+	serve := func(method, target, token string, payload any, result any) int {
+		var body bytes.Buffer
+		if payload != nil {
+			if err := json.NewEncoder(&body).Encode(payload); err != nil {
+				t.Fatalf("Error converting payload to json: %s", err)
+			}
+		}
+		req, err := http.NewRequest(method, target, &body)
+		if err != nil {
+			t.Fatalf("Error creating request: %s", err)
+		}
+		if token != "" {
+			req.Header.Set("Token", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		testConfig.Server.ServeHTTP(w, req)
+		if result != nil {
+			if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+				t.Fatalf("Error unmarshalling response: %s", err)
+			}
+		}
+		return w.Code
+	}
+
+	owner := dto.User{Name: "owner", Email: "owner@test", Password: "test"}
+	receiver := dto.User{Name: "receiver", Email: "receiver@test", Password: "test"}
+	for _, user := range []dto.User{owner, receiver} {
+		if code := serve("POST", "/auth/register", "", user, nil); code != http.StatusAccepted {
+			t.Fatalf("Expected status code: 202, got %d", code)
+		}
+	}
+	receiverAccount, err := db.GetUserFromEmail(testConfig.Db, receiver.Email)
+	if err != nil {
+		t.Fatalf("Error getting receiver: %s", err)
+	}
+
+	var tokens dto.TokenPair
+	if code := serve("POST", "/auth/signin", "", owner, &tokens); code != http.StatusAccepted {
+		t.Fatalf("Expected status code: 202, got %d", code)
+	}
+
+	var bot dto.Bot
+	if code := serve("POST", "/bots", tokens.Token, dto.CreateBotRequest{Name: "helper"}, &bot); code != http.StatusCreated {
+		t.Fatalf("Expected status code: 201, got %d", code)
+	}
+	var bots []dto.Bot
+	if code := serve("GET", "/bots", tokens.Token, nil, &bots); code != http.StatusOK || len(bots) != 1 || bots[0].Id != bot.Id {
+		t.Fatalf("Expected the new bot to be listed, got %d %v", code, bots)
+	}
+
+	// Bots cannot sign in with a password
+	botLogin := dto.User{Email: bot.Email, Password: "test"}
+	if code := serve("POST", "/auth/signin", "", botLogin, nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected status code: 401, got %d", code)
+	}
+
+	keysPath := "/bots/" + bot.Id + "/keys"
+	invalid := dto.CreateApiKeyRequest{Name: "admin", Scopes: []string{"admin"}}
+	if code := serve("POST", keysPath, tokens.Token, invalid, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected status code: 400, got %d", code)
+	}
+
+	var sender, reader dto.NewApiKey
+	request := dto.CreateApiKeyRequest{Name: "sender", Scopes: []string{constants.SCOPE_CHAT_SEND}}
+	if code := serve("POST", keysPath, tokens.Token, request, &sender); code != http.StatusCreated {
+		t.Fatalf("Expected status code: 201, got %d", code)
+	}
+	request = dto.CreateApiKeyRequest{Name: "reader", Scopes: []string{constants.SCOPE_CHAT_READ}}
+	if code := serve("POST", keysPath, tokens.Token, request, &reader); code != http.StatusCreated {
+		t.Fatalf("Expected status code: 201, got %d", code)
+	}
+
+	chat := dto.Chat{ReceiverId: receiverAccount.Id, Message: "beep"}
+	if code := serve("POST", "/chat/chat", sender.Key, chat, nil); code != http.StatusOK {
+		t.Errorf("Expected status code: 200, got %d", code)
+	}
+	if code := serve("POST", "/chat/chat", reader.Key, chat, nil); code != http.StatusForbidden {
+		t.Errorf("Expected status code: 403, got %d", code)
+	}
+	if code := serve("GET", "/chat/read", reader.Key, nil, nil); code != http.StatusOK {
+		t.Errorf("Expected status code: 200, got %d", code)
+	}
+
+	// Routes without a scope are closed to API keys
+	if code := serve("GET", "/auth/sessions", sender.Key, nil, nil); code != http.StatusForbidden {
+		t.Errorf("Expected status code: 403, got %d", code)
+	}
+	if code := serve("GET", "/bots", sender.Key, nil, nil); code != http.StatusForbidden {
+		t.Errorf("Expected status code: 403, got %d", code)
+	}
+
+	// A wrong secret for a valid key id is rejected
+	if code := serve("POST", "/chat/chat", sender.Key+"0", chat, nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected status code: 401, got %d", code)
+	}
+
+	if code := serve("DELETE", keysPath+"/"+sender.Id, tokens.Token, nil, nil); code != http.StatusAccepted {
+		t.Fatalf("Expected status code: 202, got %d", code)
+	}
+	if code := serve("POST", "/chat/chat", sender.Key, chat, nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected status code: 401, got %d", code)
+	}
+
+	var keys []dto.ApiKey
+	if code := serve("GET", keysPath, tokens.Token, nil, &keys); code != http.StatusOK || len(keys) != 2 {
+		t.Fatalf("Expected two keys, got %d %v", code, keys)
+	}
+	for _, key := range keys {
+		if (key.Id == sender.Id) != (key.RevokedAt != nil) {
+			t.Errorf("Expected only the sender key to be revoked, got %v", key)
+		}
+	}
+
+	// Bots of other users are not visible
+	var receiverTokens dto.TokenPair
+	if code := serve("POST", "/auth/signin", "", receiver, &receiverTokens); code != http.StatusAccepted {
+		t.Fatalf("Expected status code: 202, got %d", code)
+	}
+	if code := serve("GET", keysPath, receiverTokens.Token, nil, nil); code != http.StatusNotFound {
+		t.Errorf("Expected status code: 404, got %d", code)
+	}
+}
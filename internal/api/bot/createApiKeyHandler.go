@@ -0,0 +1,120 @@
+package bot_api
+
+import (
+	"database/sql"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+type CreateApiKeyHandler struct {
+	dto.HandlerInterface
+	pdb         *sql.DB
+	log         *zap.Logger
+	middlewares []gin.HandlerFunc
+}
+
+func NewCreateApiKeyHandler(pdb *sql.DB, log *zap.Logger) *CreateApiKeyHandler {
+	return &CreateApiKeyHandler{
+		pdb:         pdb,
+		log:         log,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (*CreateApiKeyHandler) Pattern() string {
+	return "/:id/keys"
+}
+
+func (*CreateApiKeyHandler) RequestMethod() string {
+	return constants.POST
+}
+
+// Handler to create an API key for a bot of the user. The key is only
+// returned by this response and cannot be recovered later.
+// POST /bots/:id/keys
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	Request Body: {
+//	  "name": name,
+//	  "scopes": ["chat:send" | "chat:read"],
+//	  "expires_in_days": expiresInDays
+//	  }
+//
+//	  Response:
+//	  201 Created: {
+//	  "id": id,
+//	  "user_id": botId,
+//	  "name": name,
+//	  "scopes": [scope],
+//	  "created_at": createdAt,
+//	  "last_used_at": null,
+//	  "expires_at": expiresAt,
+//	  "revoked_at": null,
+//	  "key": key
+//	  }
+//	  400 Bad Request: {
+//	  "error": "Error reading payload"
+//	  }
+//	  400 Bad Request: {
+//	  "error": "Invalid scope"
+//	  }
+//	  404 Not Found: {
+//	  "error": "Bot does not exist"
+//	  }
+func (h *CreateApiKeyHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bot, ok := ownedBot(c, h.pdb, h.log)
+		if !ok {
+			return
+		}
+
+		var request dto.CreateApiKeyRequest
+		if err := c.ShouldBindJSON(&request); err != nil || request.ExpiresInDays < 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Error reading payload"})
+			return
+		}
+		if len(request.Scopes) == 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid scope"})
+			return
+		}
+		for _, scope := range request.Scopes {
+			if !slices.Contains(constants.API_KEY_SCOPES, scope) {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid scope"})
+				return
+			}
+		}
+
+		key := dto.ApiKey{
+			UserId: bot.Id,
+			Name:   request.Name,
+			Scopes: slices.Compact(slices.Sorted(slices.Values(request.Scopes))),
+		}
+		if request.ExpiresInDays > 0 {
+			expiresAt := time.Now().AddDate(0, 0, request.ExpiresInDays)
+			key.ExpiresAt = &expiresAt
+		}
+
+		secret, err := db.CreateApiKey(h.pdb, &key)
+		if err != nil {
+			h.log.Error("Error creating API key", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusCreated, dto.NewApiKey{ApiKey: key, Key: secret})
+	}
+}
+
+func (h *CreateApiKeyHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
@@ -0,0 +1,79 @@
+package bot_api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+type CreateBotHandler struct {
+	dto.HandlerInterface
+	pdb         *sql.DB
+	log         *zap.Logger
+	middlewares []gin.HandlerFunc
+}
+
+func NewCreateBotHandler(pdb *sql.DB, log *zap.Logger) *CreateBotHandler {
+	return &CreateBotHandler{
+		pdb:         pdb,
+		log:         log,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (*CreateBotHandler) Pattern() string {
+	return ""
+}
+
+func (*CreateBotHandler) RequestMethod() string {
+	return constants.POST
+}
+
+// Handler to create a bot owned by the user
+// POST /bots
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	Request Body: {
+//	  "name": name
+//	  }
+//
+//	  Response:
+//	  201 Created: {
+//	  "id": id,
+//	  "name": name,
+//	  "email": email,
+//	  "owner_id": ownerId
+//	  }
+//	  400 Bad Request: {
+//	  "error": "Error reading payload"
+//	  }
+func (h *CreateBotHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request dto.CreateBotRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Error reading payload"})
+			return
+		}
+
+		bot := dto.Bot{Name: request.Name, OwnerId: c.GetString("user_id")}
+		if err := db.CreateBot(h.pdb, &bot); err != nil {
+			h.log.Error("Error creating bot", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusCreated, bot)
+	}
+}
+
+func (h *CreateBotHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
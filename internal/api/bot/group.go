@@ -0,0 +1,52 @@
+package bot_api
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type BotGroup struct {
+	dto.ServerGroupInterface
+	routeHandlers []dto.HandlerInterface
+	middlewares   []gin.HandlerFunc
+}
+
+func NewBotGroup(
+	pdb *sql.DB,
+	rdb_auth *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+	keys *jwtkeys.KeySet,
+) *BotGroup {
+	handlers := []dto.HandlerInterface{
+		NewCreateBotHandler(pdb, log),
+		NewListBotsHandler(pdb, log),
+		NewCreateApiKeyHandler(pdb, log),
+		NewListApiKeysHandler(pdb, log),
+		NewRevokeApiKeyHandler(pdb, log),
+	}
+
+	return &BotGroup{
+		routeHandlers: handlers,
+		middlewares:   []gin.HandlerFunc{middlewares.AuthMiddleware(pdb, rdb_auth, ctx, log, keys)},
+	}
+}
+
+func (bg *BotGroup) Group() string {
+	return "/bots"
+}
+
+func (bg *BotGroup) RouteHandlers() []dto.HandlerInterface {
+	return bg.routeHandlers
+}
+
+func (bg *BotGroup) Middlewares() []gin.HandlerFunc {
+	return bg.middlewares
+}
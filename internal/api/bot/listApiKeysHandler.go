@@ -0,0 +1,79 @@
+package bot_api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+type ListApiKeysHandler struct {
+	dto.HandlerInterface
+	pdb         *sql.DB
+	log         *zap.Logger
+	middlewares []gin.HandlerFunc
+}
+
+func NewListApiKeysHandler(pdb *sql.DB, log *zap.Logger) *ListApiKeysHandler {
+	return &ListApiKeysHandler{
+		pdb:         pdb,
+		log:         log,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (*ListApiKeysHandler) Pattern() string {
+	return "/:id/keys"
+}
+
+func (*ListApiKeysHandler) RequestMethod() string {
+	return constants.GET
+}
+
+// Handler to list the API keys of a bot of the user, including revoked and
+// expired keys. The keys themselves are never returned.
+// GET /bots/:id/keys
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	  Response:
+//	  200 OK: [{
+//	  "id": id,
+//	  "user_id": botId,
+//	  "name": name,
+//	  "scopes": [scope],
+//	  "created_at": createdAt,
+//	  "last_used_at": lastUsedAt,
+//	  "expires_at": expiresAt,
+//	  "revoked_at": revokedAt
+//	  }]
+//	  404 Not Found: {
+//	  "error": "Bot does not exist"
+//	  }
+func (h *ListApiKeysHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bot, ok := ownedBot(c, h.pdb, h.log)
+		if !ok {
+			return
+		}
+
+		keys, err := db.GetApiKeys(h.pdb, bot.Id)
+		if err != nil {
+			h.log.Error("Error listing API keys", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusOK, keys)
+	}
+}
+
+func (h *ListApiKeysHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
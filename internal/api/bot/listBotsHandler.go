@@ -0,0 +1,66 @@
+package bot_api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+type ListBotsHandler struct {
+	dto.HandlerInterface
+	pdb         *sql.DB
+	log         *zap.Logger
+	middlewares []gin.HandlerFunc
+}
+
+func NewListBotsHandler(pdb *sql.DB, log *zap.Logger) *ListBotsHandler {
+	return &ListBotsHandler{
+		pdb:         pdb,
+		log:         log,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (*ListBotsHandler) Pattern() string {
+	return ""
+}
+
+func (*ListBotsHandler) RequestMethod() string {
+	return constants.GET
+}
+
+// Handler to list the bots owned by the user
+// GET /bots
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	  Response:
+//	  200 OK: [{
+//	  "id": id,
+//	  "name": name,
+//	  "email": email,
+//	  "owner_id": ownerId
+//	  }]
+func (h *ListBotsHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bots, err := db.GetBotsForOwner(h.pdb, c.GetString("user_id"))
+		if err != nil {
+			h.log.Error("Error listing bots", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusOK, bots)
+	}
+}
+
+func (h *ListBotsHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
@@ -0,0 +1,79 @@
+package bot_api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+type RevokeApiKeyHandler struct {
+	dto.HandlerInterface
+	pdb         *sql.DB
+	log         *zap.Logger
+	middlewares []gin.HandlerFunc
+}
+
+func NewRevokeApiKeyHandler(pdb *sql.DB, log *zap.Logger) *RevokeApiKeyHandler {
+	return &RevokeApiKeyHandler{
+		pdb:         pdb,
+		log:         log,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (*RevokeApiKeyHandler) Pattern() string {
+	return "/:id/keys/:keyId"
+}
+
+func (*RevokeApiKeyHandler) RequestMethod() string {
+	return constants.DELETE
+}
+
+// Handler to revoke an API key of a bot of the user. The key stops working
+// immediately.
+// DELETE /bots/:id/keys/:keyId
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	  Response:
+//	  202 Accepted: {
+//	  "message": ok
+//	  }
+//	  404 Not Found: {
+//	  "error": "Bot does not exist"
+//	  }
+//	  404 Not Found: {
+//	  "error": "API key does not exist"
+//	  }
+func (h *RevokeApiKeyHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bot, ok := ownedBot(c, h.pdb, h.log)
+		if !ok {
+			return
+		}
+
+		err := db.RevokeApiKey(h.pdb, c.Param("keyId"), bot.Id)
+		if err == sql.ErrNoRows {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "API key does not exist"})
+			return
+		}
+		if err != nil {
+			h.log.Error("Error revoking API key", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"message": "ok"})
+	}
+}
+
+func (h *RevokeApiKeyHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
@@ -0,0 +1,132 @@
+package chat_api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+type AddConversationMemberHandler struct {
+	dto.HandlerInterface
+	log         *zap.Logger
+	users       db.UserRepository
+	chats       db.ChatRepository
+	middlewares []gin.HandlerFunc
+	broker      broker.Broker
+}
+
+func NewAddConversationMemberHandler(
+	users db.UserRepository,
+	chats db.ChatRepository,
+	log *zap.Logger,
+	broker broker.Broker,
+) *AddConversationMemberHandler {
+	return &AddConversationMemberHandler{
+		log:         log,
+		users:       users,
+		chats:       chats,
+		broker:      broker,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (h *AddConversationMemberHandler) Pattern() string {
+	return "/conversation/:id/members"
+}
+
+func (h *AddConversationMemberHandler) RequestMethod() string {
+	return constants.POST
+}
+
+func (h *AddConversationMemberHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
+
+// Handler to add a member to a group conversation. Only members can add members.
+// POST /chat/conversation/:id/members
+//
+//	Request Body: {
+//	 "user_id": userId
+//	 }
+//	 Response:
+//	 202 Accepted: {
+//	 "message": "Member added"
+//	 }
+//	 400 Bad Request: {
+//	 "error": "Error reading payload"
+//	 }
+//	 400 Bad Request: {
+//	 "error": "Members of a direct conversation cannot be changed"
+//	 }
+//	 403 Forbidden: {
+//	 "error": "Not a member of the conversation"
+//	 }
+//	 404 Not Found: {
+//	 "error": "Conversation does not exist"
+//	 }
+//	 404 Not Found: {
+//	 "error": "User with id %s does not exist"
+//	 }
+func (h *AddConversationMemberHandler) Handler() gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		email := ginCtx.GetString("email")
+		user, err := h.users.GetUserFromEmail(email)
+		if err != nil {
+			h.log.Error("Error getting user from email")
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error getting user from email",
+			})
+			return
+		}
+
+		conversation, status, err := getConversationForMember(h.chats, ginCtx.Param("id"), user.Id)
+		if err != nil {
+			ginCtx.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		if conversation.Type == constants.CONVERSATION_DIRECT {
+			ginCtx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Members of a direct conversation cannot be changed",
+			})
+			return
+		}
+
+		var member dto.ConversationMember
+		if err := ginCtx.ShouldBindJSON(&member); err != nil || member.UserId == "" {
+			h.log.Error("Error binding json", zap.Error(err))
+			ginCtx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Error reading payload",
+			})
+			return
+		}
+
+		if !h.users.DoesUserExist(member.UserId) {
+			ginCtx.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "User with id " + member.UserId + " does not exist",
+			})
+			return
+		}
+
+		if err := h.chats.AddConversationMember(conversation.Id, member.UserId); err != nil {
+			h.log.Error("Error adding member", zap.Error(err))
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error adding member",
+			})
+			return
+		}
+
+		if err := h.broker.BindConversation(member.UserId, conversation.Id); err != nil {
+			h.log.Error("Error binding conversation", zap.Error(err))
+		}
+
+		ginCtx.JSON(http.StatusAccepted, gin.H{
+			"message": "Member added",
+		})
+	}
+}
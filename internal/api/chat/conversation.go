@@ -0,0 +1,26 @@
+package chat_api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+)
+
+// getConversationForMember loads a conversation and checks the user is a member.
+// On failure it returns the status code and error message to respond with.
+func getConversationForMember(chats db.ChatRepository, conversationId, userId string) (dto.Conversation, int, error) {
+	conversation, err := chats.GetConversation(conversationId)
+	if err == sql.ErrNoRows {
+		return conversation, http.StatusNotFound, fmt.Errorf("Conversation does not exist")
+	}
+	if err != nil {
+		return conversation, http.StatusInternalServerError, fmt.Errorf("Error getting conversation")
+	}
+	if !conversation.HasMember(userId) {
+		return conversation, http.StatusForbidden, fmt.Errorf("Not a member of the conversation")
+	}
+	return conversation, http.StatusOK, nil
+}
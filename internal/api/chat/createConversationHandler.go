@@ -0,0 +1,190 @@
+package chat_api
+
+import (
+	"database/sql"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+type CreateConversationHandler struct {
+	dto.HandlerInterface
+	log         *zap.Logger
+	users       db.UserRepository
+	chats       db.ChatRepository
+	middlewares []gin.HandlerFunc
+	broker      broker.Broker
+}
+
+func NewCreateConversationHandler(
+	users db.UserRepository,
+	chats db.ChatRepository,
+	log *zap.Logger,
+	broker broker.Broker,
+) *CreateConversationHandler {
+	return &CreateConversationHandler{
+		log:         log,
+		users:       users,
+		chats:       chats,
+		broker:      broker,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (h *CreateConversationHandler) Pattern() string {
+	return "/conversation"
+}
+
+func (h *CreateConversationHandler) RequestMethod() string {
+	return constants.POST
+}
+
+func (h *CreateConversationHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
+
+// Handler to create a conversation. The caller is always a member.
+// A direct conversation has exactly one other member and is reused if it already exists.
+// POST /chat/conversation
+//
+//	Request Body: {
+//	 "type": "direct" | "group",
+//	 "name": name,
+//	 "member_ids": [memberId]
+//	 }
+//	 Response:
+//	 201 Created: {
+//	 "id": id,
+//	 "type": type,
+//	 "name": name,
+//	 "created_by": createdBy,
+//	 "created_at": createdAt,
+//	 "member_ids": [memberId]
+//	 }
+//	 200 OK: existing direct conversation
+//	 400 Bad Request: {
+//	 "error": "Error reading payload"
+//	 }
+//	 400 Bad Request: {
+//	 "error": "Invalid conversation type"
+//	 }
+//	 400 Bad Request: {
+//	 "error": "Direct conversation requires exactly one other member"
+//	 }
+//	 404 Not Found: {
+//	 "error": "User with id %s does not exist"
+//	 }
+//	 500 Internal Server Error: {
+//	 "error": "Error creating conversation"
+//	 }
+func (h *CreateConversationHandler) Handler() gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		email := ginCtx.GetString("email")
+		user, err := h.users.GetUserFromEmail(email)
+		if err != nil {
+			h.log.Error("Error getting user from email")
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error getting user from email",
+			})
+			return
+		}
+
+		var conversation dto.Conversation
+		if err := ginCtx.ShouldBindJSON(&conversation); err != nil {
+			h.log.Error("Error binding json", zap.Error(err))
+			ginCtx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Error reading payload",
+			})
+			return
+		}
+
+		memberIds := []string{user.Id}
+		for _, memberId := range conversation.MemberIds {
+			if slices.Contains(memberIds, memberId) {
+				continue
+			}
+			if !h.users.DoesUserExist(memberId) {
+				ginCtx.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+					"error": "User with id " + memberId + " does not exist",
+				})
+				return
+			}
+			memberIds = append(memberIds, memberId)
+		}
+		conversation.MemberIds = memberIds
+		conversation.CreatedBy = user.Id
+
+		switch conversation.Type {
+		case constants.CONVERSATION_DIRECT:
+			if len(memberIds) != 2 {
+				ginCtx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": "Direct conversation requires exactly one other member",
+				})
+				return
+			}
+			if h.respondWithExisting(ginCtx, memberIds) {
+				return
+			}
+		case constants.CONVERSATION_GROUP:
+		default:
+			ginCtx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid conversation type",
+			})
+			return
+		}
+
+		err = h.chats.CreateConversation(&conversation)
+		if err == db.ErrConversationExists && h.respondWithExisting(ginCtx, memberIds) {
+			// Created by the other member in the meantime
+			return
+		}
+		if err != nil {
+			h.log.Error("Error creating conversation", zap.Error(err))
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error creating conversation",
+			})
+			return
+		}
+
+		for _, memberId := range conversation.MemberIds {
+			if err := h.broker.BindConversation(memberId, conversation.Id); err != nil {
+				h.log.Error("Error binding conversation", zap.Error(err))
+			}
+		}
+
+		ginCtx.JSON(http.StatusCreated, conversation)
+	}
+}
+
+// respondWithExisting responds with the direct conversation between the
+// members if they already have one. It returns false if they do not.
+func (h *CreateConversationHandler) respondWithExisting(ginCtx *gin.Context, memberIds []string) bool {
+	id, err := h.chats.GetDirectConversationId(memberIds[0], memberIds[1])
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		h.log.Error("Error getting direct conversation", zap.Error(err))
+		ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Error creating conversation",
+		})
+		return true
+	}
+
+	existing, err := h.chats.GetConversation(id)
+	if err != nil {
+		h.log.Error("Error getting conversation", zap.Error(err))
+		ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Error creating conversation",
+		})
+		return true
+	}
+	ginCtx.JSON(http.StatusOK, existing)
+	return true
+}
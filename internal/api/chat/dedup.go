@@ -0,0 +1,33 @@
+package chat_api
+
+// dedupWindow remembers the last size dedup keys seen, so events published
+// more than once are forwarded once
+type dedupWindow struct {
+	size int
+	keys map[string]bool
+	// Keys in the order they were seen, oldest first
+	order []string
+}
+
+func newDedupWindow(size int) *dedupWindow {
+	return &dedupWindow{size: size, keys: map[string]bool{}}
+}
+
+// seen reports whether key was seen before and remembers it. Empty keys are
+// never duplicates.
+func (w *dedupWindow) seen(key string) bool {
+	if key == "" {
+		return false
+	}
+	if w.keys[key] {
+		return true
+	}
+
+	if len(w.order) == w.size {
+		delete(w.keys, w.order[0])
+		w.order = w.order[1:]
+	}
+	w.keys[key] = true
+	w.order = append(w.order, key)
+	return false
+}
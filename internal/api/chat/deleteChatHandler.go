@@ -0,0 +1,151 @@
+package chat_api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/storage"
+	"go.uber.org/zap"
+)
+
+type DeleteChatHandler struct {
+	dto.HandlerInterface
+	log         *zap.Logger
+	users       db.UserRepository
+	chats       db.ChatRepository
+	middlewares []gin.HandlerFunc
+	broker      broker.Broker
+	storage     storage.Storage
+}
+
+func NewDeleteChatHandler(
+	users db.UserRepository,
+	chats db.ChatRepository,
+	log *zap.Logger,
+	broker broker.Broker,
+	storage storage.Storage,
+) *DeleteChatHandler {
+	return &DeleteChatHandler{
+		log:         log,
+		users:       users,
+		chats:       chats,
+		broker:      broker,
+		storage:     storage,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (h *DeleteChatHandler) Pattern() string {
+	return "/message/:id"
+}
+
+func (h *DeleteChatHandler) RequestMethod() string {
+	return constants.DELETE
+}
+
+func (h *DeleteChatHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
+
+// Handler to delete a message.
+// scope=me hides the message from the user's own history and works for any
+// message they can see. scope=everyone clears a message the user sent, drops
+// its edit history and attachments and sends recipients a delete event.
+// DELETE /chat/message/:id?scope=me|everyone
+//
+//	Response:
+//	202 Accepted: {
+//	"message": "Message deleted"
+//	}
+//	400 Bad Request: {
+//	"error": "Invalid scope"
+//	}
+//	404 Not Found: {
+//	"error": "Message does not exist"
+//	}
+func (h *DeleteChatHandler) Handler() gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		email := ginCtx.GetString("email")
+		user, err := h.users.GetUserFromEmail(email)
+		if err != nil {
+			h.log.Error("Error getting user from email")
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error getting user from email",
+			})
+			return
+		}
+
+		id := ginCtx.Param("id")
+
+		switch ginCtx.DefaultQuery("scope", constants.DELETE_FOR_ME) {
+		case constants.DELETE_FOR_ME:
+			chat, err := h.chats.GetChat(id)
+			if err != nil || !canSeeChat(h.chats, &chat, user.Id) {
+				ginCtx.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+					"error": "Message does not exist",
+				})
+				return
+			}
+
+			if err := h.chats.DeleteChatForUser(id, user.Id); err != nil {
+				h.log.Error("Error deleting chat", zap.Error(err))
+				ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error": "Error deleting chat",
+				})
+				return
+			}
+		case constants.DELETE_FOR_EVERYONE:
+			chat, err := h.chats.DeleteChatForEveryone(id, user.Id)
+			if err == sql.ErrNoRows {
+				ginCtx.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+					"error": "Message does not exist",
+				})
+				return
+			}
+			if err != nil {
+				h.log.Error("Error deleting chat", zap.Error(err))
+				ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error": "Error deleting chat",
+				})
+				return
+			}
+
+			h.deleteAttachments(ginCtx.Request.Context(), chat.Id)
+
+			publishToRecipients(h.broker, h.log, &chat, constants.WS_DELETE, dto.ChatDeleteEvent{
+				Id:             chat.Id,
+				ConversationId: chat.ConversationId,
+				DeletedAt:      *chat.DeletedAt,
+			})
+		default:
+			ginCtx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid scope",
+			})
+			return
+		}
+
+		ginCtx.JSON(http.StatusAccepted, gin.H{
+			"message": "Message deleted",
+		})
+	}
+}
+
+// deleteAttachments removes the attachments of a chat and their blobs. Failures are logged.
+func (h *DeleteChatHandler) deleteAttachments(ctx context.Context, chatId string) {
+	attachments, err := h.chats.DeleteChatAttachments(chatId)
+	if err != nil {
+		h.log.Error("Error deleting attachments", zap.Error(err))
+		return
+	}
+	for _, attachment := range attachments {
+		if err := h.storage.Delete(ctx, attachment.StorageKey); err != nil {
+			h.log.Error("Error removing stored attachment", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package chat_api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+type EditChatHandler struct {
+	dto.HandlerInterface
+	log         *zap.Logger
+	users       db.UserRepository
+	chats       db.ChatRepository
+	middlewares []gin.HandlerFunc
+	broker      broker.Broker
+}
+
+func NewEditChatHandler(
+	users db.UserRepository,
+	chats db.ChatRepository,
+	log *zap.Logger,
+	broker broker.Broker,
+) *EditChatHandler {
+	return &EditChatHandler{
+		log:         log,
+		users:       users,
+		chats:       chats,
+		broker:      broker,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (h *EditChatHandler) Pattern() string {
+	return "/message/:id"
+}
+
+func (h *EditChatHandler) RequestMethod() string {
+	return constants.PATCH
+}
+
+func (h *EditChatHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
+
+// Handler to edit a message sent by the user. The previous version is kept in
+// the edit history and recipients get an edit event with the new version.
+// PATCH /chat/message/:id
+//
+//	Request Body: {
+//	 "message": message
+//	 }
+//	 Response:
+//	 200 OK: {
+//	 "id": id,
+//	 "sender_id": senderId,
+//	 "receiver_id": receiverId,
+//	 "conversation_id": conversationId,
+//	 "message": message,
+//	 "created_at": createdAt,
+//	 "edited_at": editedAt
+//	 }
+//	 400 Bad Request: {
+//	 "error": "Error reading payload"
+//	 }
+//	 404 Not Found: {
+//	 "error": "Message does not exist"
+//	 }
+func (h *EditChatHandler) Handler() gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		email := ginCtx.GetString("email")
+		user, err := h.users.GetUserFromEmail(email)
+		if err != nil {
+			h.log.Error("Error getting user from email")
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error getting user from email",
+			})
+			return
+		}
+
+		var edit dto.Chat
+		if err := ginCtx.ShouldBindJSON(&edit); err != nil || edit.Message == "" {
+			h.log.Error("Error binding json", zap.Error(err))
+			ginCtx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Error reading payload",
+			})
+			return
+		}
+
+		chat, err := h.chats.EditChat(ginCtx.Param("id"), user.Id, edit.Message)
+		if err == sql.ErrNoRows {
+			ginCtx.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Message does not exist",
+			})
+			return
+		}
+		if err != nil {
+			h.log.Error("Error editing chat", zap.Error(err))
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error editing chat",
+			})
+			return
+		}
+
+		publishToRecipients(h.broker, h.log, &chat, constants.WS_EDIT, chat)
+
+		ginCtx.JSON(http.StatusOK, chat)
+	}
+}
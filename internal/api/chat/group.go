@@ -6,9 +6,16 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
-	amqpConfig "github.com/nihal-ramaswamy/GoChat/internal/amqp"
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
 	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"github.com/nihal-ramaswamy/GoChat/internal/outbox"
+	"github.com/nihal-ramaswamy/GoChat/internal/ratelimit"
+	"github.com/nihal-ramaswamy/GoChat/internal/storage"
+	"github.com/nihal-ramaswamy/GoChat/internal/utils"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
@@ -21,22 +28,43 @@ type ChatGroup struct {
 
 func NewChatGroup(
 	pdb *sql.DB,
+	users db.UserRepository,
+	chats db.ChatRepository,
 	rdb_auth *redis.Client,
+	rdb_presence *redis.Client,
 	ctx context.Context,
 	log *zap.Logger,
-	amqpConfig *amqpConfig.AmqpConfig,
+	broker broker.Broker,
+	relay *outbox.Relay,
 	upgrader *websocket.Upgrader,
 	websocketMap *dto.WebsocketConnectionMap,
+	storage storage.Storage,
+	limiter *ratelimit.Limiter,
+	keys *jwtkeys.KeySet,
 ) *ChatGroup {
+	requireVerifiedEmail := utils.GetDotEnvVariable(constants.REQUIRE_EMAIL_VERIFICATION) == "true"
+
 	handlers := []dto.HandlerInterface{
-		NewSendChatHandler(pdb, log, amqpConfig),
-		NewReadDbChatHandler(pdb, log),
-		NewReadChatWsHandler(pdb, log, upgrader, websocketMap, amqpConfig),
+		NewSendChatHandler(users, chats, log, relay, requireVerifiedEmail, limiter),
+		NewReadDbChatHandler(users, chats, log),
+		NewSearchChatHandler(users, chats, log),
+		NewReadChatWsHandler(pdb, users, chats, rdb_presence, ctx, log, upgrader, websocketMap, broker, relay, requireVerifiedEmail, limiter),
+		NewCreateConversationHandler(users, chats, log, broker),
+		NewAddConversationMemberHandler(users, chats, log, broker),
+		NewRemoveConversationMemberHandler(users, chats, log, broker),
+		NewSendConversationChatHandler(users, chats, log, relay, requireVerifiedEmail, limiter),
+		NewMarkReadHandler(users, chats, log, broker),
+		NewReadReceiptsHandler(users, chats, log),
+		NewEditChatHandler(users, chats, log, broker),
+		NewDeleteChatHandler(users, chats, log, broker, storage),
+		NewReadChatEditsHandler(users, chats, log),
+		NewUploadAttachmentHandler(users, chats, log, storage),
+		NewReadAttachmentHandler(users, chats, log, storage),
 	}
 
 	return &ChatGroup{
 		routeHandlers: handlers,
-		middlewares:   []gin.HandlerFunc{middlewares.AuthMiddleware(pdb, rdb_auth, ctx, log)},
+		middlewares:   []gin.HandlerFunc{middlewares.AuthMiddleware(pdb, rdb_auth, ctx, log, keys)},
 	}
 }
 
@@ -0,0 +1,105 @@
+package chat_api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+type MarkReadHandler struct {
+	dto.HandlerInterface
+	log         *zap.Logger
+	users       db.UserRepository
+	chats       db.ChatRepository
+	middlewares []gin.HandlerFunc
+	broker      broker.Broker
+}
+
+func NewMarkReadHandler(
+	users db.UserRepository,
+	chats db.ChatRepository,
+	log *zap.Logger,
+	broker broker.Broker,
+) *MarkReadHandler {
+	return &MarkReadHandler{
+		log:         log,
+		users:       users,
+		chats:       chats,
+		broker:      broker,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (h *MarkReadHandler) Pattern() string {
+	return "/receipts/read"
+}
+
+func (h *MarkReadHandler) RequestMethod() string {
+	return constants.POST
+}
+
+func (h *MarkReadHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
+
+// Handler to mark every message received in a conversation, or from a peer,
+// up to and including a message as read. Senders get a receipt event.
+// POST /chat/receipts/read
+//
+//	Request Body: {
+//	 "conversation_id": conversationId,
+//	 "peer_id": peerId,
+//	 "up_to": messageId
+//	 }
+//	 Response:
+//	 200 OK: {
+//	 "message_ids": [messageId]
+//	 }
+//	 400 Bad Request: {
+//	 "error": "up_to and one of conversation_id and peer_id are required"
+//	 }
+//	 403 Forbidden: {
+//	 "error": "Not a member of the conversation"
+//	 }
+//	 404 Not Found: {
+//	 "error": "Message does not exist in the thread"
+//	 }
+func (h *MarkReadHandler) Handler() gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		email := ginCtx.GetString("email")
+		user, err := h.users.GetUserFromEmail(email)
+		if err != nil {
+			h.log.Error("Error getting user from email")
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error getting user from email",
+			})
+			return
+		}
+
+		var request dto.ReadReceiptRequest
+		if err := ginCtx.ShouldBindJSON(&request); err != nil {
+			h.log.Error("Error binding json", zap.Error(err))
+			ginCtx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Error reading payload",
+			})
+			return
+		}
+
+		ids, status, err := markRead(h.chats, h.broker, h.log, user.Id, &request)
+		if err != nil {
+			ginCtx.AbortWithStatusJSON(status, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		ginCtx.JSON(http.StatusOK, gin.H{
+			"message_ids": ids,
+		})
+	}
+}
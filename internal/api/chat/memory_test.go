@@ -0,0 +1,790 @@
+package chat_api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/routes"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+)
+
+// Tests sending, reading, searching and editing chats against the in-memory
+// repository, without any containers
+func TestChatWithMemoryRepository(t *testing.T) {
+	ctx := context.Background()
+
+	testConfig, err := testUtils.SetUpMemoryRouter()
+	if err != nil {
+		t.Fatalf("Error setting up server for testing: %s", err)
+	}
+	t.Cleanup(testConfig.MiniRedis.Close)
+
+	routes.NewRoutes(
+		testConfig.Server,
+		testConfig.Db,
+		testConfig.Users,
+		testConfig.Chats,
+		testConfig.Rdb,
+		testConfig.Rdb,
+		ctx,
+		testConfig.Log,
+		testConfig.Broker,
+		testConfig.Relay,
+		testConfig.Upgrader,
+		testConfig.WebsocketMap,
+		testConfig.Storage,
+		testConfig.Mailer,
+		testConfig.OidcProvider,
+		testConfig.Keys,
+	)
+
+	serve := func(method, target, token string, payload any, result any) int {
+		var body bytes.Buffer
+		if payload != nil {
+			if err := json.NewEncoder(&body).Encode(payload); err != nil {
+				t.Fatalf("Error converting payload to json: %s", err)
+			}
+		}
+		req, err := http.NewRequest(method, target, &body)
+		if err != nil {
+			t.Fatalf("Error creating request: %s", err)
+		}
+		if token != "" {
+			req.Header.Set("Token", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		testConfig.Server.ServeHTTP(w, req)
+		if result != nil {
+			if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+				t.Fatalf("Error unmarshalling response: %s", err)
+			}
+		}
+		return w.Code
+	}
+
+	signUp := func(email string) (string, string) {
+		user := dto.User{Name: "test", Email: email, Password: "test"}
+		var registered testUtils.IdDto
+		if code := serve("POST", "/auth/register", "", user, &registered); code != http.StatusAccepted {
+			t.Fatalf("Expected status code: 202, got %d", code)
+		}
+		var tokens dto.TokenPair
+		if code := serve("POST", "/auth/signin", "", user, &tokens); code != http.StatusAccepted || tokens.Token == "" {
+			t.Fatalf("Expected tokens, got %d", code)
+		}
+		return registered.Id, tokens.Token
+	}
+	senderId, senderToken := signUp("sender@test")
+	receiverId, receiverToken := signUp("receiver@test")
+
+	subscription, err := testConfig.Broker.Subscribe(receiverId)
+	if err != nil {
+		t.Fatalf("Error subscribing: %s", err)
+	}
+	defer subscription.Close()
+
+	// Send
+	var result testUtils.ErrorDto
+	if code := serve("POST", "/chat/chat", senderToken, dto.Chat{Message: "hello"}, &result); code != http.StatusBadRequest {
+		t.Errorf("Expected status code: 400, got %d %q", code, result.Error)
+	}
+	messages := []string{"Lunch tomorrow?", "<b>Lunch</b> at noon", "See you"}
+	ids := []string{}
+	for _, message := range messages {
+		var sent testUtils.IdDto
+		chat := dto.Chat{ReceiverId: receiverId, Message: message}
+		if code := serve("POST", "/chat/chat", senderToken, chat, &sent); code != http.StatusOK || sent.Id == "" {
+			t.Fatalf("Expected status code: 200 with an id, got %d", code)
+		}
+		ids = append(ids, sent.Id)
+	}
+
+	if _, err := testConfig.Relay.RelayPending(ctx); err != nil {
+		t.Fatalf("Error relaying chats: %s", err)
+	}
+	for _, id := range ids {
+		select {
+		case d := <-subscription.Deliveries():
+			var envelope dto.WsEnvelope
+			if err := json.Unmarshal(d.Body, &envelope); err != nil {
+				t.Fatalf("Error reading envelope: %s", err)
+			}
+			if envelope.Type != constants.WS_MESSAGE || envelope.DedupKey != constants.WS_MESSAGE+":"+id {
+				t.Errorf("Expected message %s, got %s", id, d.Body)
+			}
+			d.Ack()
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for chat %s", id)
+		}
+	}
+
+	// Read
+	var page dto.ChatPage
+	if code := serve("GET", "/chat/read?limit=2", receiverToken, nil, &page); code != http.StatusOK {
+		t.Fatalf("Expected status code: 200, got %d", code)
+	}
+	if len(page.Messages) != 2 || page.Messages[0].Id != ids[2] || page.NextCursor == "" {
+		t.Fatalf("Expected the newest 2 chats and a cursor, got %+v", page)
+	}
+	next := page.NextCursor
+	page = dto.ChatPage{}
+	serve("GET", "/chat/read?limit=2&before="+url.QueryEscape(next), receiverToken, nil, &page)
+	if len(page.Messages) != 1 || page.Messages[0].Id != ids[0] || page.NextCursor != "" {
+		t.Errorf("Expected the oldest chat on the last page, got %+v", page)
+	}
+	if code := serve("GET", "/chat/read?before=invalid", receiverToken, nil, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected status code: 400, got %d", code)
+	}
+
+	// Search
+	var search dto.ChatSearchPage
+	if code := serve("GET", "/chat/search?q=lunch", receiverToken, nil, &search); code != http.StatusOK {
+		t.Fatalf("Expected status code: 200, got %d", code)
+	}
+	if len(search.Results) != 2 || search.Results[0].Id != ids[1] {
+		t.Fatalf("Expected 2 matches, newest first, got %+v", search.Results)
+	}
+	if search.Results[0].Snippet != "&lt;b&gt;<mark>Lunch</mark>&lt;/b&gt; at noon" {
+		t.Errorf("Expected an escaped, highlighted snippet, got %s", search.Results[0].Snippet)
+	}
+	if code := serve("GET", "/chat/search", receiverToken, nil, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected status code: 400, got %d", code)
+	}
+
+	// Edit
+	edit := dto.Chat{Message: "Dinner tomorrow?"}
+	if code := serve("PATCH", "/chat/message/"+ids[0], receiverToken, edit, nil); code != http.StatusNotFound {
+		t.Errorf("Expected only the sender to edit, got %d", code)
+	}
+	if code := serve("PATCH", "/chat/message/"+ids[0], senderToken, dto.Chat{}, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected status code: 400, got %d", code)
+	}
+	var edited dto.Chat
+	if code := serve("PATCH", "/chat/message/"+ids[0], senderToken, edit, &edited); code != http.StatusOK {
+		t.Fatalf("Expected status code: 200, got %d", code)
+	}
+	if edited.Message != edit.Message || edited.EditedAt == nil || edited.SenderId != senderId {
+		t.Errorf("Expected the edited chat, got %+v", edited)
+	}
+
+	select {
+	case d := <-subscription.Deliveries():
+		var envelope dto.WsEnvelope
+		if err := json.Unmarshal(d.Body, &envelope); err != nil {
+			t.Fatalf("Error reading envelope: %s", err)
+		}
+		if envelope.Type != constants.WS_EDIT || !strings.Contains(string(envelope.Data), edit.Message) {
+			t.Errorf("Expected an edit event, got %s", d.Body)
+		}
+		d.Ack()
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the edit event")
+	}
+
+	var edits []dto.ChatEdit
+	if code := serve("GET", "/chat/message/"+ids[0]+"/edits", receiverToken, nil, &edits); code != http.StatusOK {
+		t.Fatalf("Expected status code: 200, got %d", code)
+	}
+	if len(edits) != 1 || edits[0].Message != messages[0] {
+		t.Errorf("Expected the previous version, got %+v", edits)
+	}
+
+	search = dto.ChatSearchPage{}
+	serve("GET", "/chat/search?q=dinner", receiverToken, nil, &search)
+	if len(search.Results) != 1 || search.Results[0].Id != ids[0] {
+		t.Errorf("Expected the edited chat to be found by its new text, got %+v", search.Results)
+	}
+}
+
+// Tests direct and group conversations against the in-memory repository
+func TestConversationWithMemoryRepository(t *testing.T) {
+	ctx := context.Background()
+
+	testConfig, err := testUtils.SetUpMemoryRouter()
+	if err != nil {
+		t.Fatalf("Error setting up server for testing: %s", err)
+	}
+	t.Cleanup(testConfig.MiniRedis.Close)
+
+	routes.NewRoutes(
+		testConfig.Server,
+		testConfig.Db,
+		testConfig.Users,
+		testConfig.Chats,
+		testConfig.Rdb,
+		testConfig.Rdb,
+		ctx,
+		testConfig.Log,
+		testConfig.Broker,
+		testConfig.Relay,
+		testConfig.Upgrader,
+		testConfig.WebsocketMap,
+		testConfig.Storage,
+		testConfig.Mailer,
+		testConfig.OidcProvider,
+		testConfig.Keys,
+	)
+
+	serve := func(method, target, token string, payload any, result any) int {
+		var body bytes.Buffer
+		if payload != nil {
+			if err := json.NewEncoder(&body).Encode(payload); err != nil {
+				t.Fatalf("Error converting payload to json: %s", err)
+			}
+		}
+		req, err := http.NewRequest(method, target, &body)
+		if err != nil {
+			t.Fatalf("Error creating request: %s", err)
+		}
+		req.Header.Set("Token", "Bearer "+token)
+		w := httptest.NewRecorder()
+		testConfig.Server.ServeHTTP(w, req)
+		if result != nil {
+			if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+				t.Fatalf("Error unmarshalling response: %s", err)
+			}
+		}
+		return w.Code
+	}
+
+	signUp := func(email string) (string, string) {
+		user := dto.User{Name: "test", Email: email, Password: "test"}
+		var registered testUtils.IdDto
+		if code := serve("POST", "/auth/register", "", user, &registered); code != http.StatusAccepted {
+			t.Fatalf("Expected status code: 202, got %d", code)
+		}
+		var tokens dto.TokenPair
+		if code := serve("POST", "/auth/signin", "", user, &tokens); code != http.StatusAccepted || tokens.Token == "" {
+			t.Fatalf("Expected tokens, got %d", code)
+		}
+		return registered.Id, tokens.Token
+	}
+	ownerId, ownerToken := signUp("owner@test")
+	memberId, memberToken := signUp("member@test")
+	outsiderId, _ := signUp("outsider@test")
+
+	// Direct conversations are reused whichever member creates them
+	var direct, reused dto.Conversation
+	request := dto.Conversation{Type: constants.CONVERSATION_DIRECT, MemberIds: []string{memberId}}
+	if code := serve("POST", "/chat/conversation", ownerToken, request, &direct); code != http.StatusCreated {
+		t.Fatalf("Expected status code: 201, got %d", code)
+	}
+	request = dto.Conversation{Type: constants.CONVERSATION_DIRECT, MemberIds: []string{ownerId}}
+	if code := serve("POST", "/chat/conversation", memberToken, request, &reused); code != http.StatusOK || reused.Id != direct.Id {
+		t.Errorf("Expected the existing conversation %s, got %d %s", direct.Id, code, reused.Id)
+	}
+
+	// Group chats show up in the history of every member
+	var group dto.Conversation
+	request = dto.Conversation{Type: constants.CONVERSATION_GROUP, Name: "group", MemberIds: []string{memberId}}
+	if code := serve("POST", "/chat/conversation", ownerToken, request, &group); code != http.StatusCreated {
+		t.Fatalf("Expected status code: 201, got %d", code)
+	}
+	var sent testUtils.IdDto
+	target := "/chat/conversation/" + group.Id + "/chat"
+	if code := serve("POST", target, ownerToken, dto.Chat{Message: "hello group"}, &sent); code != http.StatusOK {
+		t.Fatalf("Expected status code: 200, got %d", code)
+	}
+	var page dto.ChatPage
+	if code := serve("GET", "/chat/read", memberToken, nil, &page); code != http.StatusOK {
+		t.Fatalf("Expected status code: 200, got %d", code)
+	}
+	if len(page.Messages) != 1 || page.Messages[0].Id != sent.Id {
+		t.Errorf("Expected the group chat in the history, got %+v", page.Messages)
+	}
+
+	// up_to has to be a message of the thread being marked read
+	read := dto.ReadReceiptRequest{PeerId: ownerId, UpTo: sent.Id}
+	if code := serve("POST", "/chat/receipts/read", memberToken, read, nil); code != http.StatusNotFound {
+		t.Errorf("Expected status code: 404 for a message of another thread, got %d", code)
+	}
+	read = dto.ReadReceiptRequest{ConversationId: group.Id, UpTo: sent.Id}
+	if code := serve("POST", "/chat/receipts/read", memberToken, read, nil); code != http.StatusOK {
+		t.Errorf("Expected status code: 200, got %d", code)
+	}
+
+	var result testUtils.ErrorDto
+	target = "/chat/conversation/" + group.Id + "/members/" + outsiderId
+	if code := serve("DELETE", target, ownerToken, nil, &result); code != http.StatusNotFound {
+		t.Errorf("Expected status code: 404 removing a non-member, got %d %q", code, result.Error)
+	}
+	target = "/chat/conversation/" + group.Id + "/members/" + memberId
+	if code := serve("DELETE", target, ownerToken, nil, nil); code != http.StatusAccepted {
+		t.Errorf("Expected status code: 202, got %d", code)
+	}
+}
+
+// wsClient is a websocket connection of a test user to /chat/ws
+type wsClient struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+func dialWs(t *testing.T, server *httptest.Server, token string) *wsClient {
+	target := "ws" + strings.TrimPrefix(server.URL, "http") + "/chat/ws"
+	conn, response, err := websocket.DefaultDialer.Dial(target, http.Header{"Token": {"Bearer " + token}})
+	if err != nil {
+		t.Fatalf("Error opening websocket: %s %v", err, response)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &wsClient{t: t, conn: conn}
+}
+
+func (c *wsClient) send(envelopeType, requestId string, data any) {
+	envelope, err := dto.NewWsEnvelope(envelopeType, requestId, data)
+	if err != nil {
+		c.t.Fatalf("Error creating envelope: %s", err)
+	}
+	if err := c.conn.WriteJSON(envelope); err != nil {
+		c.t.Fatalf("Error writing envelope: %s", err)
+	}
+}
+
+// read returns the next envelope of the given type and its data, failing on
+// any other envelope
+func (c *wsClient) read(envelopeType string, data any) dto.WsEnvelope {
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var envelope dto.WsEnvelope
+	if err := c.conn.ReadJSON(&envelope); err != nil {
+		c.t.Fatalf("Error reading envelope: %s", err)
+	}
+	if envelope.Type != envelopeType {
+		c.t.Fatalf("Expected a %s envelope, got %s %s", envelopeType, envelope.Type, envelope.Data)
+	}
+	if data != nil {
+		if err := json.Unmarshal(envelope.Data, data); err != nil {
+			c.t.Fatalf("Error reading envelope data: %s", err)
+		}
+	}
+	return envelope
+}
+
+// readError returns the error sent in answer to requestId
+func (c *wsClient) readError(requestId string) string {
+	var wsError dto.WsError
+	if envelope := c.read(constants.WS_ERROR, &wsError); envelope.RequestId != requestId {
+		c.t.Errorf("Expected an error for request %s, got %s", requestId, envelope.RequestId)
+	}
+	return wsError.Error
+}
+
+// Tests typing and recording signals over the websocket against the in-memory
+// repository and broker
+func TestSignalsWithMemoryRepository(t *testing.T) {
+	ctx := context.Background()
+
+	testConfig, err := testUtils.SetUpMemoryRouter()
+	if err != nil {
+		t.Fatalf("Error setting up server for testing: %s", err)
+	}
+	t.Cleanup(testConfig.MiniRedis.Close)
+
+	routes.NewRoutes(
+		testConfig.Server,
+		testConfig.Db,
+		testConfig.Users,
+		testConfig.Chats,
+		testConfig.Rdb,
+		testConfig.Rdb,
+		ctx,
+		testConfig.Log,
+		testConfig.Broker,
+		testConfig.Relay,
+		testConfig.Upgrader,
+		testConfig.WebsocketMap,
+		testConfig.Storage,
+		testConfig.Mailer,
+		testConfig.OidcProvider,
+		testConfig.Keys,
+	)
+	server := httptest.NewServer(testConfig.Server)
+	t.Cleanup(server.Close)
+
+	serve := func(method, target, token string, payload any, result any) int {
+		var body bytes.Buffer
+		if payload != nil {
+			if err := json.NewEncoder(&body).Encode(payload); err != nil {
+				t.Fatalf("Error converting payload to json: %s", err)
+			}
+		}
+		req, err := http.NewRequest(method, target, &body)
+		if err != nil {
+			t.Fatalf("Error creating request: %s", err)
+		}
+		req.Header.Set("Token", "Bearer "+token)
+		w := httptest.NewRecorder()
+		testConfig.Server.ServeHTTP(w, req)
+		if result != nil {
+			if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+				t.Fatalf("Error unmarshalling response: %s", err)
+			}
+		}
+		return w.Code
+	}
+
+	signUp := func(email string) (string, string) {
+		user := dto.User{Name: "test", Email: email, Password: "test"}
+		var registered testUtils.IdDto
+		if code := serve("POST", "/auth/register", "", user, &registered); code != http.StatusAccepted {
+			t.Fatalf("Expected status code: 202, got %d", code)
+		}
+		var tokens dto.TokenPair
+		if code := serve("POST", "/auth/signin", "", user, &tokens); code != http.StatusAccepted || tokens.Token == "" {
+			t.Fatalf("Expected tokens, got %d", code)
+		}
+		return registered.Id, tokens.Token
+	}
+	senderId, senderToken := signUp("sender@test")
+	receiverId, receiverToken := signUp("receiver@test")
+	_, outsiderToken := signUp("outsider@test")
+
+	var group dto.Conversation
+	request := dto.Conversation{Type: constants.CONVERSATION_GROUP, Name: "group", MemberIds: []string{receiverId}}
+	if code := serve("POST", "/chat/conversation", senderToken, request, &group); code != http.StatusCreated {
+		t.Fatalf("Expected status code: 201, got %d", code)
+	}
+
+	sender := dialWs(t, server, senderToken)
+	receiver := dialWs(t, server, receiverToken)
+	outsider := dialWs(t, server, outsiderToken)
+
+	sender.send(constants.WS_SIGNAL, "unknown", dto.Signal{ReceiverId: receiverId, Kind: "dancing", Active: true})
+	if message := sender.readError("unknown"); message != "Unknown signal kind" {
+		t.Errorf("Expected unknown kinds to be rejected, got %q", message)
+	}
+
+	// Non-members cannot signal into a conversation, neither by its id nor by
+	// passing a routing key as the receiver
+	outsider.send(constants.WS_SIGNAL, "member", dto.Signal{ConversationId: group.Id, Kind: constants.SIGNAL_TYPING, Active: true})
+	if message := outsider.readError("member"); message != "Not a member of the conversation" {
+		t.Errorf("Expected non-members to be rejected, got %q", message)
+	}
+	for _, routingKey := range []string{broker.ConversationRoutingKey(group.Id), broker.PresenceRoutingKey(receiverId)} {
+		outsider.send(constants.WS_SIGNAL, routingKey, dto.Signal{ReceiverId: routingKey, Kind: constants.SIGNAL_TYPING, Active: true})
+		if message := outsider.readError(routingKey); !strings.Contains(message, "does not exist") {
+			t.Errorf("Expected receiver %s to be rejected, got %q", routingKey, message)
+		}
+	}
+
+	// The rejected signals never reached the receiver, the direct one is first
+	sender.send(constants.WS_SIGNAL, "", dto.Signal{ReceiverId: receiverId, Kind: constants.SIGNAL_TYPING, Active: true})
+	var signal dto.Signal
+	receiver.read(constants.WS_SIGNAL, &signal)
+	if signal.SenderId != senderId || signal.ReceiverId != receiverId || signal.Kind != constants.SIGNAL_TYPING || !signal.Active {
+		t.Errorf("Expected the typing signal, got %+v", signal)
+	}
+	if until := time.Until(signal.ExpiresAt); until <= 0 || until > constants.SIGNAL_TTL {
+		t.Errorf("Expected the signal to expire within %s, got %s", constants.SIGNAL_TTL, signal.ExpiresAt)
+	}
+
+	// Conversation signals reach the other members but not the sender
+	sender.send(constants.WS_SIGNAL, "", dto.Signal{ConversationId: group.Id, Kind: constants.SIGNAL_RECORDING, Active: true})
+	signal = dto.Signal{}
+	receiver.read(constants.WS_SIGNAL, &signal)
+	if signal.SenderId != senderId || signal.ConversationId != group.Id || signal.Kind != constants.SIGNAL_RECORDING {
+		t.Errorf("Expected the recording signal, got %+v", signal)
+	}
+	sender.send(constants.WS_SIGNAL, "own", dto.Signal{Kind: constants.SIGNAL_TYPING})
+	if message := sender.readError("own"); message != "Receiver id is required" {
+		t.Errorf("Expected the sender not to get its own signal, got %q", message)
+	}
+
+	// Signals not consumed within SIGNAL_TTL are dropped
+	receiver.conn.Close()
+	sender.send(constants.WS_SIGNAL, "", dto.Signal{ReceiverId: receiverId, Kind: constants.SIGNAL_TYPING, Active: false})
+	time.Sleep(constants.SIGNAL_TTL)
+
+	receiver = dialWs(t, server, receiverToken)
+	sender.send(constants.WS_SIGNAL, "", dto.Signal{ReceiverId: receiverId, Kind: constants.SIGNAL_RECORDING, Active: true})
+	signal = dto.Signal{}
+	receiver.read(constants.WS_SIGNAL, &signal)
+	if signal.Kind != constants.SIGNAL_RECORDING {
+		t.Errorf("Expected the expired signal to be dropped, got %+v", signal)
+	}
+}
+
+// Tests sending chats over the websocket against the in-memory repository and
+// broker. Sends go through the same checks as POST /chat/chat and are
+// answered with an ack carrying the id of the saved chat, or an error.
+func TestWebsocketSendWithMemoryRepository(t *testing.T) {
+	ctx := context.Background()
+
+	testConfig, err := testUtils.SetUpMemoryRouter()
+	if err != nil {
+		t.Fatalf("Error setting up server for testing: %s", err)
+	}
+	t.Cleanup(testConfig.MiniRedis.Close)
+
+	routes.NewRoutes(
+		testConfig.Server,
+		testConfig.Db,
+		testConfig.Users,
+		testConfig.Chats,
+		testConfig.Rdb,
+		testConfig.Rdb,
+		ctx,
+		testConfig.Log,
+		testConfig.Broker,
+		testConfig.Relay,
+		testConfig.Upgrader,
+		testConfig.WebsocketMap,
+		testConfig.Storage,
+		testConfig.Mailer,
+		testConfig.OidcProvider,
+		testConfig.Keys,
+	)
+	server := httptest.NewServer(testConfig.Server)
+	t.Cleanup(server.Close)
+
+	serve := func(method, target, token string, payload any, result any) int {
+		var body bytes.Buffer
+		if payload != nil {
+			if err := json.NewEncoder(&body).Encode(payload); err != nil {
+				t.Fatalf("Error converting payload to json: %s", err)
+			}
+		}
+		req, err := http.NewRequest(method, target, &body)
+		if err != nil {
+			t.Fatalf("Error creating request: %s", err)
+		}
+		req.Header.Set("Token", "Bearer "+token)
+		w := httptest.NewRecorder()
+		testConfig.Server.ServeHTTP(w, req)
+		if result != nil {
+			if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+				t.Fatalf("Error unmarshalling response: %s", err)
+			}
+		}
+		return w.Code
+	}
+
+	signUp := func(email string) (string, string) {
+		user := dto.User{Name: "test", Email: email, Password: "test"}
+		var registered testUtils.IdDto
+		if code := serve("POST", "/auth/register", "", user, &registered); code != http.StatusAccepted {
+			t.Fatalf("Expected status code: 202, got %d", code)
+		}
+		var tokens dto.TokenPair
+		if code := serve("POST", "/auth/signin", "", user, &tokens); code != http.StatusAccepted || tokens.Token == "" {
+			t.Fatalf("Expected tokens, got %d", code)
+		}
+		return registered.Id, tokens.Token
+	}
+	senderId, senderToken := signUp("sender@test")
+	receiverId, receiverToken := signUp("receiver@test")
+	_, outsiderToken := signUp("outsider@test")
+
+	var group dto.Conversation
+	request := dto.Conversation{Type: constants.CONVERSATION_GROUP, Name: "group", MemberIds: []string{receiverId}}
+	if code := serve("POST", "/chat/conversation", senderToken, request, &group); code != http.StatusCreated {
+		t.Fatalf("Expected status code: 201, got %d", code)
+	}
+
+	sender := dialWs(t, server, senderToken)
+	receiver := dialWs(t, server, receiverToken)
+	outsider := dialWs(t, server, outsiderToken)
+
+	// Malformed frames are answered with an error and keep the socket open
+	if err := sender.conn.WriteMessage(websocket.TextMessage, []byte("not json")); err != nil {
+		t.Fatalf("Error writing frame: %s", err)
+	}
+	if message := sender.readError(""); message != "Invalid envelope" {
+		t.Errorf("Expected an invalid envelope error, got %q", message)
+	}
+	sender.send("unknown", "unknown", nil)
+	if message := sender.readError("unknown"); message != "Unknown envelope type" {
+		t.Errorf("Expected an unknown type error, got %q", message)
+	}
+	sender.send(constants.WS_SEND, "payload", "hello")
+	if message := sender.readError("payload"); message != "Error reading payload" {
+		t.Errorf("Expected a payload error, got %q", message)
+	}
+
+	// The checks of POST /chat/chat apply
+	sender.send(constants.WS_SEND, "receiver", dto.Chat{Message: "hello"})
+	if message := sender.readError("receiver"); message != "Receiver id is required" {
+		t.Errorf("Expected a missing receiver error, got %q", message)
+	}
+	routingKey := broker.ConversationRoutingKey(group.Id)
+	sender.send(constants.WS_SEND, "routing", dto.Chat{ReceiverId: routingKey, Message: "hello"})
+	if message := sender.readError("routing"); !strings.Contains(message, "does not exist") {
+		t.Errorf("Expected a routing key receiver to be rejected, got %q", message)
+	}
+	outsider.send(constants.WS_SEND, "member", dto.Chat{ConversationId: group.Id, Message: "hello"})
+	if message := outsider.readError("member"); message != "Not a member of the conversation" {
+		t.Errorf("Expected non-members to be rejected, got %q", message)
+	}
+
+	// Acks carry the id of the saved chat and echo the request id
+	ids := map[string]bool{}
+	for requestId, chat := range map[string]dto.Chat{
+		"direct": {ReceiverId: receiverId, Message: "hello direct"},
+		"group":  {ConversationId: group.Id, Message: "hello group"},
+	} {
+		sender.send(constants.WS_SEND, requestId, chat)
+		var ack dto.WsAck
+		if envelope := sender.read(constants.WS_ACK, &ack); envelope.RequestId != requestId || ack.Id == "" || ack.CreatedAt.IsZero() {
+			t.Fatalf("Expected an ack with the chat id for %s, got %s %+v", requestId, envelope.RequestId, ack)
+		}
+		saved, err := testConfig.Chats.GetChat(ack.Id)
+		if err != nil || saved.SenderId != senderId || saved.Message != chat.Message {
+			t.Errorf("Expected the chat to be saved, got %+v %v", saved, err)
+		}
+		ids[ack.Id] = true
+	}
+
+	if _, err := testConfig.Relay.RelayPending(ctx); err != nil {
+		t.Fatalf("Error relaying chats: %s", err)
+	}
+	for len(ids) > 0 {
+		var chat dto.Chat
+		envelope := receiver.read(constants.WS_MESSAGE, &chat)
+		if !ids[chat.Id] || envelope.DedupKey != constants.WS_MESSAGE+":"+chat.Id {
+			t.Errorf("Expected one of the sent chats, got %s", envelope.Data)
+		}
+		delete(ids, chat.Id)
+	}
+}
+
+// Tests a user keeping several websocket connections open against the
+// in-memory repository and broker
+func TestWebsocketConnectionsWithMemoryRepository(t *testing.T) {
+	ctx := context.Background()
+
+	testConfig, err := testUtils.SetUpMemoryRouter()
+	if err != nil {
+		t.Fatalf("Error setting up server for testing: %s", err)
+	}
+	t.Cleanup(testConfig.MiniRedis.Close)
+
+	routes.NewRoutes(
+		testConfig.Server,
+		testConfig.Db,
+		testConfig.Users,
+		testConfig.Chats,
+		testConfig.Rdb,
+		testConfig.Rdb,
+		ctx,
+		testConfig.Log,
+		testConfig.Broker,
+		testConfig.Relay,
+		testConfig.Upgrader,
+		testConfig.WebsocketMap,
+		testConfig.Storage,
+		testConfig.Mailer,
+		testConfig.OidcProvider,
+		testConfig.Keys,
+	)
+	server := httptest.NewServer(testConfig.Server)
+	t.Cleanup(server.Close)
+
+	serve := func(method, target, token string, payload any, result any) int {
+		var body bytes.Buffer
+		if payload != nil {
+			if err := json.NewEncoder(&body).Encode(payload); err != nil {
+				t.Fatalf("Error converting payload to json: %s", err)
+			}
+		}
+		req, err := http.NewRequest(method, target, &body)
+		if err != nil {
+			t.Fatalf("Error creating request: %s", err)
+		}
+		req.Header.Set("Token", "Bearer "+token)
+		w := httptest.NewRecorder()
+		testConfig.Server.ServeHTTP(w, req)
+		if result != nil {
+			if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+				t.Fatalf("Error unmarshalling response: %s", err)
+			}
+		}
+		return w.Code
+	}
+
+	signUp := func(email string) (string, string) {
+		user := dto.User{Name: "test", Email: email, Password: "test"}
+		var registered testUtils.IdDto
+		if code := serve("POST", "/auth/register", "", user, &registered); code != http.StatusAccepted {
+			t.Fatalf("Expected status code: 202, got %d", code)
+		}
+		var tokens dto.TokenPair
+		if code := serve("POST", "/auth/signin", "", user, &tokens); code != http.StatusAccepted || tokens.Token == "" {
+			t.Fatalf("Expected tokens, got %d", code)
+		}
+		return registered.Id, tokens.Token
+	}
+	senderId, senderToken := signUp("sender@test")
+	receiverId, receiverToken := signUp("receiver@test")
+
+	// Connections are removed once their handler returned
+	waitForConnections := func(count int) {
+		deadline := time.Now().Add(5 * time.Second)
+		for testConfig.WebsocketMap.Len() != count {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected %d open connections, got %d", count, testConfig.WebsocketMap.Len())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	isOnline := func(userId string) bool {
+		var p dto.Presence
+		if code := serve("GET", "/presence/"+userId, senderToken, nil, &p); code != http.StatusOK {
+			t.Fatalf("Expected status code: 200, got %d", code)
+		}
+		return p.Online
+	}
+
+	phone := dialWs(t, server, receiverToken)
+	laptop := dialWs(t, server, receiverToken)
+	waitForConnections(2)
+
+	// Both follow the sender, closing one keeps the subscription of the other
+	for requestId, client := range map[string]*wsClient{"phone": phone, "laptop": laptop} {
+		client.send(constants.WS_PRESENCE_SUBSCRIBE, requestId, dto.PresenceSubscription{UserIds: []string{senderId}})
+		var presences []dto.Presence
+		if envelope := client.read(constants.WS_ACK, &presences); envelope.RequestId != requestId || len(presences) != 1 || presences[0].Online {
+			t.Fatalf("Expected the sender offline, got %s", envelope.Data)
+		}
+	}
+
+	phone.conn.Close()
+	waitForConnections(1)
+	if !isOnline(receiverId) {
+		t.Errorf("Expected the receiver to stay online through the laptop")
+	}
+
+	dialWs(t, server, senderToken)
+	var p dto.Presence
+	laptop.read(constants.WS_PRESENCE, &p)
+	if p.UserId != senderId || !p.Online {
+		t.Errorf("Expected the sender to come online, got %+v", p)
+	}
+
+	var sent testUtils.IdDto
+	if code := serve("POST", "/chat/chat", senderToken, dto.Chat{ReceiverId: receiverId, Message: "hello"}, &sent); code != http.StatusOK {
+		t.Fatalf("Expected status code: 200, got %d", code)
+	}
+	if _, err := testConfig.Relay.RelayPending(ctx); err != nil {
+		t.Fatalf("Error relaying chats: %s", err)
+	}
+	var chat dto.Chat
+	if laptop.read(constants.WS_MESSAGE, &chat); chat.Id != sent.Id {
+		t.Errorf("Expected chat %s on the remaining connection, got %s", sent.Id, chat.Id)
+	}
+
+	laptop.conn.Close()
+	waitForConnections(1)
+	if isOnline(receiverId) {
+		t.Errorf("Expected the receiver to go offline with their last connection")
+	}
+}
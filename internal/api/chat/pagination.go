@@ -0,0 +1,111 @@
+package chat_api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+)
+
+// parseChatPageQuery reads the before, after, limit, peer_id and
+// conversation_id query params of a history request
+func parseChatPageQuery(c *gin.Context, userId string) (*dto.ChatPageQuery, error) {
+	page := &dto.ChatPageQuery{
+		UserId:         userId,
+		PeerId:         c.Query("peer_id"),
+		ConversationId: c.Query("conversation_id"),
+	}
+
+	if page.PeerId != "" && page.ConversationId != "" {
+		return nil, fmt.Errorf("Only one of peer_id and conversation_id can be set")
+	}
+
+	var err error
+	if page.Limit, err = parseLimit(c); err != nil {
+		return nil, err
+	}
+
+	before, after := c.Query("before"), c.Query("after")
+	if before != "" && after != "" {
+		return nil, fmt.Errorf("Only one of before and after can be set")
+	}
+
+	if before != "" {
+		if page.Before, err = dto.DecodeChatCursor(before); err != nil {
+			return nil, err
+		}
+	}
+	if after != "" {
+		if page.After, err = dto.DecodeChatCursor(after); err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+// parseSearchQuery reads the q, sender_id, conversation_id, from, to, before
+// and limit query params of a search request
+func parseSearchQuery(c *gin.Context, userId string) (*dto.ChatSearchQuery, error) {
+	search := &dto.ChatSearchQuery{
+		UserId:         userId,
+		Query:          strings.TrimSpace(c.Query("q")),
+		SenderId:       c.Query("sender_id"),
+		ConversationId: c.Query("conversation_id"),
+	}
+
+	if search.Query == "" {
+		return nil, fmt.Errorf("Search query is required")
+	}
+
+	var err error
+	if search.Limit, err = parseLimit(c); err != nil {
+		return nil, err
+	}
+
+	if search.From, err = parseTime(c, "from"); err != nil {
+		return nil, err
+	}
+	if search.To, err = parseTime(c, "to"); err != nil {
+		return nil, err
+	}
+
+	if before := c.Query("before"); before != "" {
+		if search.Before, err = dto.DecodeChatCursor(before); err != nil {
+			return nil, err
+		}
+	}
+
+	return search, nil
+}
+
+// parseLimit reads the limit query param, defaulting to CHAT_PAGE_DEFAULT_LIMIT
+// and capped at CHAT_PAGE_MAX_LIMIT
+func parseLimit(c *gin.Context) (int, error) {
+	limit := c.Query("limit")
+	if limit == "" {
+		return constants.CHAT_PAGE_DEFAULT_LIMIT, nil
+	}
+	value, err := strconv.Atoi(limit)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("Invalid limit")
+	}
+	return min(value, constants.CHAT_PAGE_MAX_LIMIT), nil
+}
+
+// parseTime reads an optional RFC 3339 timestamp from a query param
+func parseTime(c *gin.Context, key string) (*time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s", key)
+	}
+	return &t, nil
+}
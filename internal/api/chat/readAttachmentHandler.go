@@ -0,0 +1,118 @@
+package chat_api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/storage"
+	"go.uber.org/zap"
+)
+
+type ReadAttachmentHandler struct {
+	dto.HandlerInterface
+	log         *zap.Logger
+	users       db.UserRepository
+	chats       db.ChatRepository
+	middlewares []gin.HandlerFunc
+	storage     storage.Storage
+}
+
+func NewReadAttachmentHandler(
+	users db.UserRepository,
+	chats db.ChatRepository,
+	log *zap.Logger,
+	storage storage.Storage,
+) *ReadAttachmentHandler {
+	return &ReadAttachmentHandler{
+		log:         log,
+		users:       users,
+		chats:       chats,
+		storage:     storage,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (h *ReadAttachmentHandler) Pattern() string {
+	return "/attachment/:id"
+}
+
+func (h *ReadAttachmentHandler) RequestMethod() string {
+	return constants.GET
+}
+
+func (h *ReadAttachmentHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
+
+func (h *ReadAttachmentHandler) Scope() string {
+	return constants.SCOPE_CHAT_READ
+}
+
+// Handler to download an attachment. Attachments that were not sent yet can
+// only be downloaded by their uploader, sent attachments by anyone who can see
+// the chat.
+// GET /chat/attachment/:id
+//
+//	Response:
+//	200 OK: the file
+//	404 Not Found: {
+//	"error": "Attachment does not exist"
+//	}
+func (h *ReadAttachmentHandler) Handler() gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		email := ginCtx.GetString("email")
+		user, err := h.users.GetUserFromEmail(email)
+		if err != nil {
+			h.log.Error("Error getting user from email")
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error getting user from email",
+			})
+			return
+		}
+
+		attachment, err := h.chats.GetAttachment(ginCtx.Param("id"))
+		if err != nil || !h.canDownload(&attachment, user.Id) {
+			ginCtx.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Attachment does not exist",
+			})
+			return
+		}
+
+		body, err := h.storage.Get(ginCtx.Request.Context(), attachment.StorageKey)
+		if err == storage.ErrNotFound {
+			ginCtx.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Attachment does not exist",
+			})
+			return
+		}
+		if err != nil {
+			h.log.Error("Error reading attachment", zap.Error(err))
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error reading attachment",
+			})
+			return
+		}
+		defer body.Close()
+
+		ginCtx.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, body, map[string]string{
+			"Content-Disposition":    fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(attachment.FileName)),
+			"X-Content-Type-Options": "nosniff",
+		})
+	}
+}
+
+func (h *ReadAttachmentHandler) canDownload(attachment *dto.Attachment, userId string) bool {
+	if attachment.UploaderId == userId {
+		return true
+	}
+	if attachment.ChatId == "" {
+		return false
+	}
+	chat, err := h.chats.GetChat(attachment.ChatId)
+	return err == nil && chat.DeletedAt == nil && canSeeChat(h.chats, &chat, userId)
+}
@@ -1,8 +1,6 @@
 package chat_api
 
 import (
-	"database/sql"
-
 	"github.com/gin-gonic/gin"
 	"github.com/nihal-ramaswamy/GoChat/internal/constants"
 	"github.com/nihal-ramaswamy/GoChat/internal/db"
@@ -13,13 +11,15 @@ import (
 type ReadChatDbHandler struct {
 	dto.HandlerInterface
 	middleware []gin.HandlerFunc
-	pdb        *sql.DB
+	users      db.UserRepository
+	chats      db.ChatRepository
 	log        *zap.Logger
 }
 
-func NewReadDbChatHandler(pdb *sql.DB, log *zap.Logger) *ReadChatDbHandler {
+func NewReadDbChatHandler(users db.UserRepository, chats db.ChatRepository, log *zap.Logger) *ReadChatDbHandler {
 	return &ReadChatDbHandler{
-		pdb:        pdb,
+		users:      users,
+		chats:      chats,
 		log:        log,
 		middleware: []gin.HandlerFunc{},
 	}
@@ -29,42 +29,80 @@ func (r *ReadChatDbHandler) Pattern() string {
 	return "/read"
 }
 
-// Handler reads chat for a user from DB
+// Handler reads one page of chat history for a user from DB.
+// Pages are ordered by created_at and id. Without a cursor, or with before,
+// the newest messages come first; with after, the oldest come first.
+// Pass next_cursor back as before (or after) to get the following page.
+// Without peer_id or conversation_id, the history also holds the messages of
+// the conversations the user is a member of.
 // GET /chat/read
 //
 //	Request Header: {
 //	  "Token": Bearer token,
 //	  }
 //
+//	Query Params:
+//	  before: optional, cursor to read messages older than
+//	  after: optional, cursor to read messages newer than
+//	  limit: optional, page size, defaults to 50, at most 200
+//	  peer_id: optional, only messages exchanged with this user
+//	  conversation_id: optional, only messages of a conversation the user is a member of
+//
 // Response:
 //
 //	200 OK: {
 //	 "messages": [{
-//	 "senderId": senderId,
-//	 "receiverId": receiverId,
+//	 "id": id,
+//	 "sender_id": senderId,
+//	 "receiver_id": receiverId,
+//	 "conversation_id": conversationId,
 //	 "message": message,
-//	 "timestamp": timestamp
-//	 }]
+//	 "created_at": createdAt
+//	 }],
+//	 "next_cursor": cursor
+//	 }
+//	400 Bad Request: {
+//	 "error": "Invalid cursor"
 //	 }
 func (r *ReadChatDbHandler) Handler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		email := c.GetString("email")
-		user, err := db.GetUserFromEmail(r.pdb, email)
+		user, err := r.users.GetUserFromEmail(email)
 		if err != nil {
 			r.log.Error("error getting user", zap.Error(err))
 			c.JSON(500, gin.H{"error": "error getting user"})
 			return
 		}
 
-		id := user.Id
+		page, err := parseChatPageQuery(c, user.Id)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if page.ConversationId != "" {
+			if _, status, err := getConversationForMember(r.chats, page.ConversationId, user.Id); err != nil {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+		}
 
-		messages, err := db.ReadChatForUser(r.pdb, id)
+		messages, next, err := r.chats.ReadChatPage(page)
 		if err != nil {
 			r.log.Error("error reading chat", zap.Error(err))
 			c.JSON(500, gin.H{"error": "error reading chat"})
+			return
 		}
 
-		c.JSON(200, messages)
+		response := dto.ChatPage{Messages: messages}
+		if response.Messages == nil {
+			response.Messages = []dto.Chat{}
+		}
+		if next != nil {
+			response.NextCursor = next.Encode()
+		}
+
+		c.JSON(200, response)
 	}
 }
 
@@ -75,3 +113,7 @@ func (r *ReadChatDbHandler) RequestMethod() string {
 func (r *ReadChatDbHandler) Middlewares() []gin.HandlerFunc {
 	return r.middleware
 }
+
+func (r *ReadChatDbHandler) Scope() string {
+	return constants.SCOPE_CHAT_READ
+}
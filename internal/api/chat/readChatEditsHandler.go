@@ -0,0 +1,89 @@
+package chat_api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+type ReadChatEditsHandler struct {
+	dto.HandlerInterface
+	log         *zap.Logger
+	users       db.UserRepository
+	chats       db.ChatRepository
+	middlewares []gin.HandlerFunc
+}
+
+func NewReadChatEditsHandler(users db.UserRepository, chats db.ChatRepository, log *zap.Logger) *ReadChatEditsHandler {
+	return &ReadChatEditsHandler{
+		log:         log,
+		users:       users,
+		chats:       chats,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (h *ReadChatEditsHandler) Pattern() string {
+	return "/message/:id/edits"
+}
+
+func (h *ReadChatEditsHandler) RequestMethod() string {
+	return constants.GET
+}
+
+func (h *ReadChatEditsHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
+
+func (h *ReadChatEditsHandler) Scope() string {
+	return constants.SCOPE_CHAT_READ
+}
+
+// Handler to read the previous versions of a message, oldest first
+// GET /chat/message/:id/edits
+//
+//	Response:
+//	200 OK: [{
+//	"message_id": messageId,
+//	"message": message,
+//	"edited_at": editedAt
+//	}]
+//	404 Not Found: {
+//	"error": "Message does not exist"
+//	}
+func (h *ReadChatEditsHandler) Handler() gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		email := ginCtx.GetString("email")
+		user, err := h.users.GetUserFromEmail(email)
+		if err != nil {
+			h.log.Error("Error getting user from email")
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error getting user from email",
+			})
+			return
+		}
+
+		chat, err := h.chats.GetChat(ginCtx.Param("id"))
+		if err != nil || !canSeeChat(h.chats, &chat, user.Id) {
+			ginCtx.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Message does not exist",
+			})
+			return
+		}
+
+		edits, err := h.chats.GetChatEdits(chat.Id)
+		if err != nil {
+			h.log.Error("Error reading edits", zap.Error(err))
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error reading edits",
+			})
+			return
+		}
+
+		ginCtx.JSON(http.StatusOK, edits)
+	}
+}
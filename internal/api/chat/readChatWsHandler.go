@@ -1,42 +1,73 @@
 package chat_api
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
-	amqpConfig "github.com/nihal-ramaswamy/GoChat/internal/amqp"
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
 	"github.com/nihal-ramaswamy/GoChat/internal/constants"
 	"github.com/nihal-ramaswamy/GoChat/internal/db"
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/outbox"
+	"github.com/nihal-ramaswamy/GoChat/internal/presence"
+	"github.com/nihal-ramaswamy/GoChat/internal/ratelimit"
 	"github.com/nihal-ramaswamy/GoChat/internal/utils"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
 type ReadChatWsHandler struct {
 	dto.HandlerInterface
-	middleware   []gin.HandlerFunc
-	pdb          *sql.DB
-	log          *zap.Logger
-	upgrader     *websocket.Upgrader
-	websocketMap *dto.WebsocketConnectionMap
-	amqpConfig   *amqpConfig.AmqpConfig
+	middleware           []gin.HandlerFunc
+	pdb                  *sql.DB
+	users                db.UserRepository
+	chats                db.ChatRepository
+	rdb                  *redis.Client
+	ctx                  context.Context
+	log                  *zap.Logger
+	upgrader             *websocket.Upgrader
+	websocketMap         *dto.WebsocketConnectionMap
+	broker               broker.Broker
+	relay                *outbox.Relay
+	requireVerifiedEmail bool
+	limiter              *ratelimit.Limiter
 }
 
 func NewReadChatWsHandler(
 	pdb *sql.DB,
+	users db.UserRepository,
+	chats db.ChatRepository,
+	rdb *redis.Client,
+	ctx context.Context,
 	log *zap.Logger,
 	upgrader *websocket.Upgrader,
 	websocketMap *dto.WebsocketConnectionMap,
-	amqpConfig *amqpConfig.AmqpConfig,
+	broker broker.Broker,
+	relay *outbox.Relay,
+	requireVerifiedEmail bool,
+	limiter *ratelimit.Limiter,
 ) *ReadChatWsHandler {
 	return &ReadChatWsHandler{
-		pdb:          pdb,
-		log:          log,
-		upgrader:     upgrader,
-		websocketMap: websocketMap,
-		amqpConfig:   amqpConfig,
+		pdb:                  pdb,
+		users:                users,
+		chats:                chats,
+		rdb:                  rdb,
+		ctx:                  ctx,
+		log:                  log,
+		upgrader:             upgrader,
+		websocketMap:         websocketMap,
+		broker:               broker,
+		relay:                relay,
+		requireVerifiedEmail: requireVerifiedEmail,
+		limiter:              limiter,
 	}
 }
 
@@ -44,23 +75,96 @@ func (r *ReadChatWsHandler) Pattern() string {
 	return "/ws"
 }
 
-// Handler to read chat for a user from queue. Opens a websocket connection
+// Handler to chat over a websocket connection. Every frame is a JSON envelope.
+// Events published to the user's queue are forwarded as they arrive, and
+// clients can send chats over the same socket. A user can open a connection
+// per device; each consumes the user's queue, so every event is forwarded to
+// one of them.
 // GET ws://HOST:PORT/chat/ws
 //
 //	Request Header: {
 //	  "Token": Bearer token,
 //	  }
 //
-//	Message: {
-//	  "senderId": senderId,
-//	  "receiverId": receiverId,
-//	  "message": message,
-//	  "timestamp": timestamp
+//	Client -> Server:
+//	  send: {
+//	    "type": "send",
+//	    "request_id": requestId,
+//	    "data": {"receiver_id": receiverId, "conversation_id": conversationId, "message": message,
+//	             "attachment_ids": [attachmentId]}
 //	  }
+//	  read: {
+//	    "type": "read",
+//	    "request_id": requestId,
+//	    "data": {"conversation_id": conversationId, "peer_id": peerId, "up_to": messageId}
+//	  }
+//	  signal: {
+//	    "type": "signal",
+//	    "data": {"receiver_id": receiverId, "conversation_id": conversationId,
+//	             "kind": "typing" | "recording", "active": active}
+//	  }
+//	  presence_subscribe, presence_unsubscribe: {
+//	    "type": "presence_subscribe" | "presence_unsubscribe",
+//	    "request_id": requestId,
+//	    "data": {"user_ids": [userId]}
+//	  }
+//
+//	Server -> Client:
+//	  ack: {
+//	    "type": "ack",
+//	    "request_id": requestId,
+//	    "data": {"id": id, "created_at": createdAt}
+//	  }
+//	  error: {
+//	    "type": "error",
+//	    "request_id": requestId,
+//	    "data": {"error": error}
+//	  }
+//	  error: {
+//	    "type": "error",
+//	    "request_id": requestId,
+//	    "data": {"error": "Rate limit exceeded", "retry_after": seconds}
+//	  }
+//	  message: {
+//	    "type": "message",
+//	    "dedup_key": dedupKey,
+//	    "data": {"id": id, "sender_id": senderId, "receiver_id": receiverId,
+//	             "conversation_id": conversationId, "message": message, "created_at": createdAt,
+//	             "attachments": [{"id": id, "file_name": fileName, "content_type": contentType,
+//	                              "size": size, "url": url}]}
+//	  }
+//	  receipt: {
+//	    "type": "receipt",
+//	    "data": {"message_ids": [messageId], "user_id": userId, "status": "delivered" | "read", "updated_at": updatedAt}
+//	  }
+//	  signal: {
+//	    "type": "signal",
+//	    "data": {"sender_id": senderId, "receiver_id": receiverId, "conversation_id": conversationId,
+//	             "kind": kind, "active": active, "expires_at": expiresAt}
+//	  }
+//	  presence: {
+//	    "type": "presence",
+//	    "data": {"user_id": userId, "online": online, "last_seen": lastSeen}
+//	  }
+//	  edit: {
+//	    "type": "edit",
+//	    "data": {"id": id, "sender_id": senderId, "receiver_id": receiverId,
+//	             "conversation_id": conversationId, "message": message, "created_at": createdAt,
+//	             "edited_at": editedAt}
+//	  }
+//	  delete: {
+//	    "type": "delete",
+//	    "data": {"id": id, "conversation_id": conversationId, "deleted_at": deletedAt}
+//	  }
+//	  Messages are delivered at least once. The server drops copies with a
+//	  dedup_key it forwarded recently on the same connection, clients should
+//	  drop the rest.
+//	  Signals are not persisted. Clients must resend active signals before
+//	  expires_at, otherwise peers treat them as stopped.
 func (r *ReadChatWsHandler) Handler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		email := c.GetString("email")
-		user, err := db.GetUserFromEmail(r.pdb, email)
+		user, err := r.users.GetUserFromEmail(email)
 		if err != nil {
 			r.log.Error("Error getting user from email")
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -70,62 +174,399 @@ func (r *ReadChatWsHandler) Handler() gin.HandlerFunc {
 		}
 		id := user.Id
 
-		if err := utils.CreateNewConnection(r.websocketMap, r.upgrader, c, id); err != nil {
+		// Every device or tab has its own connection and its own consumer on
+		// the user's queue
+		connectionId := presence.NewConnectionId()
+		conn, err := utils.CreateNewConnection(r.websocketMap, r.upgrader, c, connectionId)
+		if err != nil {
 			r.log.Error("Error upgrading to websocket connection")
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "Error upgrading to websocket connection",
 			})
+			return
 		}
 
-		conn, _ := r.websocketMap.Get(id)
-
-		go func(conn *dto.WebsocketConnection, c *gin.Context) {
+		go func(conn *dto.WebsocketConnection) {
+			defer r.websocketMap.Delete(connectionId)
 			defer conn.Close()
 
-			err = r.amqpConfig.Channel.QueueBind(
-				r.amqpConfig.Queue.Name,
-				id,                      // routing key
-				constants.EXCHANGE_NAME, // exchange
-				false,
-				nil)
+			subscription, err := r.broker.Subscribe(id)
 			if err != nil {
-				r.log.Error("Error binding queue", zap.Error(err))
-				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-					"error": "Error binding queue",
-				})
+				r.log.Error("Error consuming messages", zap.Error(err))
 				return
 			}
+			// Closing the subscription requeues unacknowledged messages so they
+			// are delivered on the next connection.
+			defer subscription.Close()
+			// Bindings are lost if RabbitMQ restarted while the user was away
+			r.bindConversations(id)
 
-			msgs, err := r.amqpConfig.Channel.Consume(
-				r.amqpConfig.Queue.Name,
-				"",    // consumer
-				true,  // auto ack
-				false, // exclusive
-				false, // no local
-				false, // no wait
-				nil,   // args
-			)
-			if err != nil {
-				r.log.Error("Error consuming messages", zap.Error(err))
-				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-					"error": "Error consuming messages",
-				})
-				return
+			session := &wsSession{
+				conn:         conn,
+				userId:       id,
+				connectionId: connectionId,
+				signals:      map[string]dto.Signal{},
+				presence:     map[string]bool{},
+				delivered:    newDedupWindow(constants.OUTBOX_DEDUP_WINDOW),
 			}
-			var forever chan struct{}
+
+			r.heartbeat(session)
+			defer r.disconnect(session)
+			heartbeat := time.NewTicker(constants.PRESENCE_HEARTBEAT)
+			defer heartbeat.Stop()
+
+			closed := make(chan struct{})
 			go func() {
-				for d := range msgs {
-					err := conn.Conn.WriteMessage(websocket.TextMessage, d.Body)
+				defer close(closed)
+				defer r.stopSignals(session)
+				defer r.unsubscribeAllPresence(session)
+				for {
+					_, data, err := conn.Conn.ReadMessage()
 					if err != nil {
-						r.log.Error("Error writing message to websocket", zap.Error(err))
+						return
 					}
+
+					var envelope dto.WsEnvelope
+					if err := json.Unmarshal(data, &envelope); err != nil {
+						r.writeError(conn, "", "Invalid envelope")
+						continue
+					}
+					r.handleEnvelope(session, &envelope)
 				}
 			}()
-			<-forever
-		}(conn, c)
+
+			for {
+				select {
+				case <-closed:
+					return
+				case <-heartbeat.C:
+					r.heartbeat(session)
+				case <-subscription.Recovered():
+					r.rebind(session)
+				case d, ok := <-subscription.Deliveries():
+					if !ok {
+						return
+					}
+					var envelope dto.WsEnvelope
+					if err := json.Unmarshal(d.Body, &envelope); err != nil {
+						r.log.Error("Error reading queued envelope", zap.Error(err))
+					}
+
+					// Copies of an event already forwarded are only acknowledged
+					duplicate := session.delivered.seen(envelope.DedupKey)
+					if !duplicate && !r.isOwnSignal(id, &envelope) {
+						if err := conn.WriteMessage(websocket.TextMessage, d.Body); err != nil {
+							r.log.Error("Error writing message to websocket", zap.Error(err))
+							return
+						}
+					}
+					if err := d.Ack(); err != nil {
+						r.log.Error("Error acknowledging message", zap.Error(err))
+					}
+					if !duplicate && envelope.Type == constants.WS_MESSAGE {
+						r.handleDelivered(id, envelope.Data)
+					}
+				}
+			}
+		}(conn)
 	}
 }
 
+type wsSession struct {
+	conn         *dto.WebsocketConnection
+	userId       string
+	connectionId string
+	// Signals currently active for peers, stopped when the connection closes
+	signals map[string]dto.Signal
+	// Users whose presence changes are forwarded to this connection, guarded
+	// by mu as it is rebound from the delivery loop
+	mu       sync.Mutex
+	presence map[string]bool
+	// Dedup keys of events recently forwarded to this connection
+	delivered *dedupWindow
+}
+
+// handleEnvelope processes one envelope sent by the client
+func (r *ReadChatWsHandler) handleEnvelope(session *wsSession, envelope *dto.WsEnvelope) {
+	conn, userId := session.conn, session.userId
+
+	switch envelope.Type {
+	case constants.WS_SEND:
+		var chat dto.Chat
+		if err := json.Unmarshal(envelope.Data, &chat); err != nil {
+			r.writeError(conn, envelope.RequestId, "Error reading payload")
+			return
+		}
+		chat.Id = ""
+		chat.SenderId = userId
+
+		// Shares the limit of POST /chat/chat so switching transports does not help
+		if result := r.limiter.Allow(r.ctx, ratelimit.ChatSend, "user:"+userId); !result.Allowed {
+			r.write(conn, constants.WS_ERROR, envelope.RequestId, dto.WsRateLimited{
+				Error:      "Rate limit exceeded",
+				RetryAfter: int64(math.Ceil(result.RetryAfter.Seconds())),
+			})
+			return
+		}
+
+		if _, err := sendChat(r.users, r.chats, r.relay, r.log, &chat, r.requireVerifiedEmail); err != nil {
+			r.writeError(conn, envelope.RequestId, err.Error())
+			return
+		}
+
+		r.write(conn, constants.WS_ACK, envelope.RequestId, dto.WsAck{
+			Id:        chat.Id,
+			CreatedAt: chat.CreatedAt,
+		})
+	case constants.WS_READ:
+		var request dto.ReadReceiptRequest
+		if err := json.Unmarshal(envelope.Data, &request); err != nil {
+			r.writeError(conn, envelope.RequestId, "Error reading payload")
+			return
+		}
+
+		ids, _, err := markRead(r.chats, r.broker, r.log, userId, &request)
+		if err != nil {
+			r.writeError(conn, envelope.RequestId, err.Error())
+			return
+		}
+
+		r.write(conn, constants.WS_ACK, envelope.RequestId, map[string][]string{
+			"message_ids": ids,
+		})
+	case constants.WS_SIGNAL:
+		var signal dto.Signal
+		if err := json.Unmarshal(envelope.Data, &signal); err != nil {
+			r.writeError(conn, envelope.RequestId, "Error reading payload")
+			return
+		}
+		signal.SenderId = userId
+
+		if _, err := publishSignal(r.users, r.chats, r.broker, r.log, &signal); err != nil {
+			r.writeError(conn, envelope.RequestId, err.Error())
+			return
+		}
+
+		if signal.Active {
+			session.signals[signal.Key()] = signal
+		} else {
+			delete(session.signals, signal.Key())
+		}
+	case constants.WS_PRESENCE_SUBSCRIBE, constants.WS_PRESENCE_UNSUBSCRIBE:
+		var subscription dto.PresenceSubscription
+		if err := json.Unmarshal(envelope.Data, &subscription); err != nil {
+			r.writeError(conn, envelope.RequestId, "Error reading payload")
+			return
+		}
+
+		presences := []dto.Presence{}
+		for _, targetId := range subscription.UserIds {
+			if envelope.Type == constants.WS_PRESENCE_UNSUBSCRIBE {
+				r.unsubscribePresence(session, targetId)
+				continue
+			}
+
+			if err := r.subscribePresence(session, targetId); err != nil {
+				r.writeError(conn, envelope.RequestId, err.Error())
+				return
+			}
+
+			p, err := presence.Get(r.ctx, r.rdb, r.users, targetId)
+			if err != nil && err != sql.ErrNoRows {
+				r.log.Error("Error getting presence", zap.Error(err))
+			}
+			presences = append(presences, p)
+		}
+
+		// The current presence, later changes arrive as presence envelopes
+		r.write(conn, constants.WS_ACK, envelope.RequestId, presences)
+	default:
+		r.writeError(conn, envelope.RequestId, "Unknown envelope type")
+	}
+}
+
+// heartbeat keeps the user online and tells subscribers when they come online
+func (r *ReadChatWsHandler) heartbeat(session *wsSession) {
+	cameOnline, err := presence.Heartbeat(r.ctx, r.rdb, session.userId, session.connectionId)
+	if err != nil {
+		r.log.Error("Error updating presence", zap.Error(err))
+		return
+	}
+	if !cameOnline {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.ctx, 5*time.Second)
+	defer cancel()
+	if err := presence.Publish(ctx, r.broker, dto.Presence{UserId: session.userId, Online: true}); err != nil {
+		r.log.Error("Error publishing presence", zap.Error(err))
+	}
+}
+
+// disconnect marks the user offline and tells subscribers
+func (r *ReadChatWsHandler) disconnect(session *wsSession) {
+	wentOffline, err := presence.Disconnect(r.ctx, r.rdb, session.userId, session.connectionId)
+	if err != nil {
+		r.log.Error("Error updating presence", zap.Error(err))
+		return
+	}
+	if !wentOffline {
+		return
+	}
+
+	p, err := presence.Get(r.ctx, r.rdb, r.users, session.userId)
+	if err != nil {
+		r.log.Error("Error getting presence", zap.Error(err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.ctx, 5*time.Second)
+	defer cancel()
+	if err := presence.Publish(ctx, r.broker, p); err != nil {
+		r.log.Error("Error publishing presence", zap.Error(err))
+	}
+}
+
+// subscribePresence forwards presence changes of targetId to the user's queue
+func (r *ReadChatWsHandler) subscribePresence(session *wsSession, targetId string) error {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if session.presence[targetId] {
+		return nil
+	}
+	if len(session.presence) >= constants.PRESENCE_MAX_SUBSCRIPTIONS {
+		return errors.New("Too many presence subscriptions")
+	}
+	if err := presence.Subscribe(r.ctx, r.rdb, session.userId, targetId); err != nil {
+		r.log.Error("Error subscribing to presence", zap.Error(err))
+		return errors.New("Error subscribing to presence")
+	}
+	session.presence[targetId] = true
+	if err := r.broker.BindPresence(session.userId, targetId); err != nil {
+		r.log.Error("Error subscribing to presence", zap.Error(err))
+		return errors.New("Error subscribing to presence")
+	}
+	return nil
+}
+
+// unsubscribePresence stops following targetId on this connection. The
+// queue is unbound once no other connection of the user follows it.
+func (r *ReadChatWsHandler) unsubscribePresence(session *wsSession, targetId string) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	r.unsubscribePresenceLocked(session, targetId)
+}
+
+// unsubscribeAllPresence removes the presence subscriptions of a closed connection
+func (r *ReadChatWsHandler) unsubscribeAllPresence(session *wsSession) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	for targetId := range session.presence {
+		r.unsubscribePresenceLocked(session, targetId)
+	}
+}
+
+func (r *ReadChatWsHandler) unsubscribePresenceLocked(session *wsSession, targetId string) {
+	if !session.presence[targetId] {
+		return
+	}
+	delete(session.presence, targetId)
+
+	last, err := presence.Unsubscribe(r.ctx, r.rdb, session.userId, targetId)
+	if err != nil {
+		r.log.Error("Error unsubscribing from presence", zap.Error(err))
+		return
+	}
+	if !last {
+		return
+	}
+	if err := r.broker.UnbindPresence(session.userId, targetId); err != nil {
+		r.log.Error("Error unsubscribing from presence", zap.Error(err))
+	}
+}
+
+// bindConversations binds the queue of a user to every conversation they are a member of
+func (r *ReadChatWsHandler) bindConversations(userId string) {
+	conversationIds, err := r.chats.GetConversationIds(userId)
+	if err != nil {
+		r.log.Error("Error getting conversations", zap.Error(err))
+		return
+	}
+	for _, conversationId := range conversationIds {
+		if err := r.broker.BindConversation(userId, conversationId); err != nil {
+			r.log.Error("Error binding conversation", zap.Error(err))
+		}
+	}
+}
+
+// rebind makes the bindings of a connection again after the broker recovered
+// it on a new connection, which may have lost them
+func (r *ReadChatWsHandler) rebind(session *wsSession) {
+	r.bindConversations(session.userId)
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	for targetId := range session.presence {
+		if err := r.broker.BindPresence(session.userId, targetId); err != nil {
+			r.log.Error("Error subscribing to presence", zap.Error(err))
+		}
+	}
+}
+
+// stopSignals tells peers that signals still active when the connection closed have stopped
+func (r *ReadChatWsHandler) stopSignals(session *wsSession) {
+	for _, signal := range session.signals {
+		if signal.ExpiresAt.Before(time.Now()) {
+			continue
+		}
+		signal.Active = false
+		if _, err := publishSignal(r.users, r.chats, r.broker, r.log, &signal); err != nil {
+			r.log.Error("Error stopping signal", zap.Error(err))
+		}
+	}
+}
+
+// isOwnSignal reports whether an envelope is a signal the user sent to a
+// conversation, which comes back through the user's own queue
+func (r *ReadChatWsHandler) isOwnSignal(userId string, envelope *dto.WsEnvelope) bool {
+	if envelope.Type != constants.WS_SIGNAL {
+		return false
+	}
+
+	var signal dto.Signal
+	if err := json.Unmarshal(envelope.Data, &signal); err != nil {
+		return false
+	}
+	return signal.SenderId == userId
+}
+
+// handleDelivered records the delivery of chats forwarded to the user
+func (r *ReadChatWsHandler) handleDelivered(userId string, data json.RawMessage) {
+	var chat dto.Chat
+	if err := json.Unmarshal(data, &chat); err != nil {
+		r.log.Error("Error reading delivered chat", zap.Error(err))
+		return
+	}
+
+	markDelivered(r.chats, r.broker, r.log, &chat, userId)
+}
+
+func (r *ReadChatWsHandler) write(conn *dto.WebsocketConnection, envelopeType, requestId string, data any) {
+	envelope, err := dto.NewWsEnvelope(envelopeType, requestId, data)
+	if err != nil {
+		r.log.Error("Error marshalling envelope", zap.Error(err))
+		return
+	}
+	if err := conn.WriteJSON(envelope); err != nil {
+		r.log.Error("Error writing message to websocket", zap.Error(err))
+	}
+}
+
+func (r *ReadChatWsHandler) writeError(conn *dto.WebsocketConnection, requestId, message string) {
+	r.write(conn, constants.WS_ERROR, requestId, dto.WsError{Error: message})
+}
+
 func (r *ReadChatWsHandler) RequestMethod() string {
 	return constants.GET
 }
@@ -0,0 +1,134 @@
+package chat_api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+type ReadReceiptsHandler struct {
+	dto.HandlerInterface
+	log         *zap.Logger
+	users       db.UserRepository
+	chats       db.ChatRepository
+	middlewares []gin.HandlerFunc
+}
+
+func NewReadReceiptsHandler(users db.UserRepository, chats db.ChatRepository, log *zap.Logger) *ReadReceiptsHandler {
+	return &ReadReceiptsHandler{
+		log:         log,
+		users:       users,
+		chats:       chats,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (h *ReadReceiptsHandler) Pattern() string {
+	return "/message/:id/receipts"
+}
+
+func (h *ReadReceiptsHandler) RequestMethod() string {
+	return constants.GET
+}
+
+func (h *ReadReceiptsHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
+
+func (h *ReadReceiptsHandler) Scope() string {
+	return constants.SCOPE_CHAT_READ
+}
+
+// Handler to read the delivery status of a message sent by the user.
+// The status is the least advanced status across all recipients.
+// GET /chat/message/:id/receipts
+//
+//	 Response:
+//	 200 OK: {
+//	 "status": "sent" | "delivered" | "read",
+//	 "receipts": [{
+//	 "message_id": messageId,
+//	 "user_id": userId,
+//	 "status": "delivered" | "read",
+//	 "updated_at": updatedAt
+//	 }]
+//	 }
+//	 404 Not Found: {
+//	 "error": "Message does not exist"
+//	 }
+func (h *ReadReceiptsHandler) Handler() gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		email := ginCtx.GetString("email")
+		user, err := h.users.GetUserFromEmail(email)
+		if err != nil {
+			h.log.Error("Error getting user from email")
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error getting user from email",
+			})
+			return
+		}
+
+		chat, err := h.chats.GetChat(ginCtx.Param("id"))
+		if err != nil || chat.SenderId != user.Id {
+			ginCtx.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Message does not exist",
+			})
+			return
+		}
+
+		recipientIds := []string{chat.ReceiverId}
+		if chat.ConversationId != "" {
+			conversation, err := h.chats.GetConversation(chat.ConversationId)
+			if err != nil {
+				h.log.Error("Error getting conversation", zap.Error(err))
+				ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error": "Error reading receipts",
+				})
+				return
+			}
+			recipientIds = []string{}
+			for _, memberId := range conversation.MemberIds {
+				if memberId != user.Id {
+					recipientIds = append(recipientIds, memberId)
+				}
+			}
+		}
+
+		receipts, err := h.chats.GetChatReceipts(chat.Id)
+		if err != nil {
+			h.log.Error("Error reading receipts", zap.Error(err))
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error reading receipts",
+			})
+			return
+		}
+
+		ginCtx.JSON(http.StatusOK, gin.H{
+			"status":   overallStatus(recipientIds, receipts),
+			"receipts": receipts,
+		})
+	}
+}
+
+func overallStatus(recipientIds []string, receipts []dto.ChatReceipt) string {
+	statuses := map[string]string{}
+	for _, receipt := range receipts {
+		statuses[receipt.UserId] = receipt.Status
+	}
+
+	status := constants.RECEIPT_READ
+	for _, recipientId := range recipientIds {
+		switch statuses[recipientId] {
+		case constants.RECEIPT_READ:
+		case constants.RECEIPT_DELIVERED:
+			status = constants.RECEIPT_DELIVERED
+		default:
+			return constants.RECEIPT_SENT
+		}
+	}
+	return status
+}
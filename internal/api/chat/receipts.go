@@ -0,0 +1,108 @@
+package chat_api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+// markDelivered records that chat reached userId and notifies the sender
+func markDelivered(
+	chats db.ChatRepository,
+	broker broker.Broker,
+	log *zap.Logger,
+	chat *dto.Chat,
+	userId string,
+) {
+	if chat.Id == "" || chat.SenderId == userId {
+		return
+	}
+
+	changed, err := chats.MarkChatDelivered(chat.Id, userId)
+	if err != nil {
+		log.Error("Error marking chat delivered", zap.Error(err))
+		return
+	}
+	if changed {
+		publishReceipts(broker, log, []dto.Chat{*chat}, userId, constants.RECEIPT_DELIVERED)
+	}
+}
+
+// markRead marks the chats received by userId up to request.UpTo as read and
+// notifies their senders. On failure it returns the status code and error
+// message to respond with.
+func markRead(
+	chats db.ChatRepository,
+	broker broker.Broker,
+	log *zap.Logger,
+	userId string,
+	request *dto.ReadReceiptRequest,
+) ([]string, int, error) {
+	if request.UpTo == "" || (request.ConversationId == "") == (request.PeerId == "") {
+		return nil, http.StatusBadRequest, fmt.Errorf("up_to and one of conversation_id and peer_id are required")
+	}
+
+	if request.ConversationId != "" {
+		if _, status, err := getConversationForMember(chats, request.ConversationId, userId); err != nil {
+			return nil, status, err
+		}
+	}
+
+	read, err := chats.MarkChatReadUpTo(userId, request)
+	if err == sql.ErrNoRows {
+		return nil, http.StatusNotFound, fmt.Errorf("Message does not exist in the thread")
+	}
+	if err != nil {
+		log.Error("Error marking chat read", zap.Error(err))
+		return nil, http.StatusInternalServerError, fmt.Errorf("Error marking chat read")
+	}
+
+	publishReceipts(broker, log, read, userId, constants.RECEIPT_READ)
+
+	ids := []string{}
+	for _, chat := range read {
+		ids = append(ids, chat.Id)
+	}
+	return ids, http.StatusOK, nil
+}
+
+// publishReceipts sends one receipt event per sender of chats
+func publishReceipts(
+	broker broker.Broker,
+	log *zap.Logger,
+	chats []dto.Chat,
+	userId string,
+	status string,
+) {
+	bySender := map[string][]string{}
+	for _, chat := range chats {
+		bySender[chat.SenderId] = append(bySender[chat.SenderId], chat.Id)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for senderId, ids := range bySender {
+		body, err := dto.MarshalWsEnvelope(constants.WS_RECEIPT, dto.ReceiptEvent{
+			MessageIds: ids,
+			UserId:     userId,
+			Status:     status,
+			UpdatedAt:  time.Now(),
+		})
+		if err != nil {
+			log.Error("Error marshalling receipt", zap.Error(err))
+			continue
+		}
+		if err := broker.Publish(ctx, senderId, body); err != nil {
+			log.Error("Error publishing receipt", zap.Error(err))
+		}
+	}
+}
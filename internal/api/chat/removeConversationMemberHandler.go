@@ -0,0 +1,130 @@
+package chat_api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+type RemoveConversationMemberHandler struct {
+	dto.HandlerInterface
+	log         *zap.Logger
+	users       db.UserRepository
+	chats       db.ChatRepository
+	middlewares []gin.HandlerFunc
+	broker      broker.Broker
+}
+
+func NewRemoveConversationMemberHandler(
+	users db.UserRepository,
+	chats db.ChatRepository,
+	log *zap.Logger,
+	broker broker.Broker,
+) *RemoveConversationMemberHandler {
+	return &RemoveConversationMemberHandler{
+		log:         log,
+		users:       users,
+		chats:       chats,
+		broker:      broker,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (h *RemoveConversationMemberHandler) Pattern() string {
+	return "/conversation/:id/members/:userId"
+}
+
+func (h *RemoveConversationMemberHandler) RequestMethod() string {
+	return constants.DELETE
+}
+
+func (h *RemoveConversationMemberHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
+
+// Handler to remove a member from a group conversation.
+// Members can remove themselves, the creator of the conversation can remove anyone.
+// DELETE /chat/conversation/:id/members/:userId
+//
+//	Response:
+//	202 Accepted: {
+//	"message": "Member removed"
+//	}
+//	400 Bad Request: {
+//	"error": "Members of a direct conversation cannot be changed"
+//	}
+//	403 Forbidden: {
+//	"error": "Not a member of the conversation"
+//	}
+//	403 Forbidden: {
+//	"error": "Only the creator can remove other members"
+//	}
+//	404 Not Found: {
+//	"error": "Conversation does not exist"
+//	}
+//	404 Not Found: {
+//	"error": "User is not a member of the conversation"
+//	}
+func (h *RemoveConversationMemberHandler) Handler() gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		email := ginCtx.GetString("email")
+		user, err := h.users.GetUserFromEmail(email)
+		if err != nil {
+			h.log.Error("Error getting user from email")
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error getting user from email",
+			})
+			return
+		}
+
+		conversation, status, err := getConversationForMember(h.chats, ginCtx.Param("id"), user.Id)
+		if err != nil {
+			ginCtx.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		if conversation.Type == constants.CONVERSATION_DIRECT {
+			ginCtx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Members of a direct conversation cannot be changed",
+			})
+			return
+		}
+
+		memberId := ginCtx.Param("userId")
+		if memberId != user.Id && conversation.CreatedBy != user.Id {
+			ginCtx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Only the creator can remove other members",
+			})
+			return
+		}
+
+		err = h.chats.RemoveConversationMember(conversation.Id, memberId)
+		if err == sql.ErrNoRows {
+			ginCtx.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "User is not a member of the conversation",
+			})
+			return
+		}
+		if err != nil {
+			h.log.Error("Error removing member", zap.Error(err))
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error removing member",
+			})
+			return
+		}
+
+		if err := h.broker.UnbindConversation(memberId, conversation.Id); err != nil {
+			h.log.Error("Error unbinding conversation", zap.Error(err))
+		}
+
+		ginCtx.JSON(http.StatusAccepted, gin.H{
+			"message": "Member removed",
+		})
+	}
+}
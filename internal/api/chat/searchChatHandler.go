@@ -0,0 +1,120 @@
+package chat_api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+type SearchChatHandler struct {
+	dto.HandlerInterface
+	middleware []gin.HandlerFunc
+	users      db.UserRepository
+	chats      db.ChatRepository
+	log        *zap.Logger
+}
+
+func NewSearchChatHandler(users db.UserRepository, chats db.ChatRepository, log *zap.Logger) *SearchChatHandler {
+	return &SearchChatHandler{
+		users:      users,
+		chats:      chats,
+		log:        log,
+		middleware: []gin.HandlerFunc{},
+	}
+}
+
+func (s *SearchChatHandler) Pattern() string {
+	return "/search"
+}
+
+// Handler searches the messages a user sent or received, including those of
+// their conversations, newest first.
+// q accepts web search syntax: quoted phrases, "or" and -excluded words.
+// The snippet is the HTML-escaped message with matching words wrapped in
+// <mark></mark>, so it can be rendered as HTML as is. The message field is
+// not escaped.
+// Pass next_cursor back as before to get the following page.
+// GET /chat/search
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	Query Params:
+//	  q: search terms
+//	  sender_id: optional, only messages sent by this user
+//	  conversation_id: optional, only messages of a conversation the user is a member of
+//	  from: optional, RFC 3339 time, only messages sent at or after it
+//	  to: optional, RFC 3339 time, only messages sent before it
+//	  before: optional, cursor to read results older than
+//	  limit: optional, page size, defaults to 50, at most 200
+//
+// Response:
+//
+//	200 OK: {
+//	 "results": [{
+//	 "id": id,
+//	 "sender_id": senderId,
+//	 "receiver_id": receiverId,
+//	 "conversation_id": conversationId,
+//	 "message": message,
+//	 "created_at": createdAt,
+//	 "snippet": snippet
+//	 }],
+//	 "next_cursor": cursor
+//	 }
+//	400 Bad Request: {
+//	 "error": "Search query is required"
+//	 }
+func (s *SearchChatHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email := c.GetString("email")
+		user, err := s.users.GetUserFromEmail(email)
+		if err != nil {
+			s.log.Error("error getting user", zap.Error(err))
+			c.JSON(500, gin.H{"error": "error getting user"})
+			return
+		}
+
+		search, err := parseSearchQuery(c, user.Id)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if search.ConversationId != "" {
+			if _, status, err := getConversationForMember(s.chats, search.ConversationId, user.Id); err != nil {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		results, next, err := s.chats.SearchChat(search)
+		if err != nil {
+			s.log.Error("error searching chat", zap.Error(err))
+			c.JSON(500, gin.H{"error": "error searching chat"})
+			return
+		}
+
+		response := dto.ChatSearchPage{Results: results}
+		if next != nil {
+			response.NextCursor = next.Encode()
+		}
+
+		c.JSON(200, response)
+	}
+}
+
+func (s *SearchChatHandler) RequestMethod() string {
+	return constants.GET
+}
+
+func (s *SearchChatHandler) Middlewares() []gin.HandlerFunc {
+	return s.middleware
+}
+
+func (s *SearchChatHandler) Scope() string {
+	return constants.SCOPE_CHAT_READ
+}
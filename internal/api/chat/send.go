@@ -0,0 +1,120 @@
+package chat_api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/outbox"
+	"go.uber.org/zap"
+)
+
+// sendChat saves a chat from chat.SenderId along with its message event, which
+// relay publishes to its recipients. Chats with a conversation id go to every
+// member of the conversation, others go to chat.ReceiverId, which has to be an
+// existing user so it cannot address any other routing key. On failure it
+// returns the status code and error message to respond with. With
+// requireVerifiedEmail, senders must have verified their email address.
+func sendChat(
+	users db.UserRepository,
+	chats db.ChatRepository,
+	relay *outbox.Relay,
+	log *zap.Logger,
+	chat *dto.Chat,
+	requireVerifiedEmail bool,
+) (int, error) {
+	if requireVerifiedEmail {
+		verified, err := users.IsEmailVerified(chat.SenderId)
+		if err != nil {
+			log.Error("Error checking email verification", zap.Error(err))
+			return http.StatusInternalServerError, fmt.Errorf("Error saving chat")
+		}
+		if !verified {
+			return http.StatusForbidden, fmt.Errorf("Email address is not verified")
+		}
+	}
+
+	if chat.ConversationId != "" {
+		conversation, status, err := getConversationForMember(chats, chat.ConversationId, chat.SenderId)
+		if err != nil {
+			return status, err
+		}
+
+		chat.ReceiverId = conversation.Id
+		if conversation.Type == constants.CONVERSATION_DIRECT {
+			for _, memberId := range conversation.MemberIds {
+				if memberId != chat.SenderId {
+					chat.ReceiverId = memberId
+				}
+			}
+		}
+	} else if chat.ReceiverId == "" {
+		return http.StatusBadRequest, fmt.Errorf("Receiver id is required")
+	} else if !users.DoesUserExist(chat.ReceiverId) {
+		return http.StatusNotFound, fmt.Errorf("User with id %s does not exist", chat.ReceiverId)
+	}
+	slices.Sort(chat.AttachmentIds)
+	chat.AttachmentIds = slices.Compact(chat.AttachmentIds)
+	if len(chat.AttachmentIds) > constants.ATTACHMENT_MAX_PER_CHAT {
+		return http.StatusBadRequest, fmt.Errorf("At most %d attachments can be sent", constants.ATTACHMENT_MAX_PER_CHAT)
+	}
+	chat.CreatedAt = time.Now()
+
+	if err := chats.SaveChat(chat); err != nil {
+		if err == db.ErrAttachmentUnavailable {
+			return http.StatusBadRequest, err
+		}
+		log.Error("Error saving chat", zap.Error(err))
+		return http.StatusInternalServerError, fmt.Errorf("Error saving chat")
+	}
+
+	relay.Notify()
+
+	return http.StatusOK, nil
+}
+
+// publishToRecipients publishes an event about chat to every member of its
+// conversation, or to its receiver. Failures are logged.
+func publishToRecipients(
+	broker broker.Broker,
+	log *zap.Logger,
+	chat *dto.Chat,
+	envelopeType string,
+	data any,
+) {
+	body, err := dto.MarshalWsEnvelope(envelopeType, data)
+	if err != nil {
+		log.Error("Error marshalling envelope", zap.Error(err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if chat.ConversationId != "" {
+		err = broker.PublishToConversation(ctx, chat.ConversationId, body)
+	} else {
+		err = broker.Publish(ctx, chat.ReceiverId, body)
+	}
+	if err != nil {
+		log.Error("Error publishing message", zap.Error(err))
+	}
+}
+
+// canSeeChat reports whether a user sent, received or is a member of the conversation of a chat
+func canSeeChat(chats db.ChatRepository, chat *dto.Chat, userId string) bool {
+	if chat.SenderId == userId || chat.ReceiverId == userId {
+		return true
+	}
+	if chat.ConversationId == "" {
+		return false
+	}
+	_, _, err := getConversationForMember(chats, chat.ConversationId, userId)
+	return err == nil
+}
@@ -1,40 +1,45 @@
 package chat_api
 
 import (
-	"context"
-	"database/sql"
-	"encoding/json"
 	"net/http"
-	"sync"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	amqpConfig "github.com/nihal-ramaswamy/GoChat/internal/amqp"
 	"github.com/nihal-ramaswamy/GoChat/internal/constants"
 	"github.com/nihal-ramaswamy/GoChat/internal/db"
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
-	"github.com/rabbitmq/amqp091-go"
+	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"github.com/nihal-ramaswamy/GoChat/internal/outbox"
+	"github.com/nihal-ramaswamy/GoChat/internal/ratelimit"
 	"go.uber.org/zap"
 )
 
 type SendChatHandler struct {
 	dto.HandlerInterface
-	log         *zap.Logger
-	pdb         *sql.DB
-	middlewares []gin.HandlerFunc
-	amqpConfig  *amqpConfig.AmqpConfig
+	log                  *zap.Logger
+	users                db.UserRepository
+	chats                db.ChatRepository
+	middlewares          []gin.HandlerFunc
+	relay                *outbox.Relay
+	requireVerifiedEmail bool
 }
 
 func NewSendChatHandler(
-	pdb *sql.DB,
+	users db.UserRepository,
+	chats db.ChatRepository,
 	log *zap.Logger,
-	amqpConfig *amqpConfig.AmqpConfig,
+	relay *outbox.Relay,
+	requireVerifiedEmail bool,
+	limiter *ratelimit.Limiter,
 ) *SendChatHandler {
 	return &SendChatHandler{
-		log:         log,
-		pdb:         pdb,
-		amqpConfig:  amqpConfig,
-		middlewares: []gin.HandlerFunc{},
+		log:                  log,
+		users:                users,
+		chats:                chats,
+		relay:                relay,
+		requireVerifiedEmail: requireVerifiedEmail,
+		middlewares: []gin.HandlerFunc{
+			middlewares.RateLimit(limiter, ratelimit.ChatSend, middlewares.ByUser),
+		},
 	}
 }
 
@@ -50,18 +55,36 @@ func (c *SendChatHandler) Middlewares() []gin.HandlerFunc {
 	return c.middlewares
 }
 
+func (c *SendChatHandler) Scope() string {
+	return constants.SCOPE_CHAT_SEND
+}
+
 // Handler to send a chat
 // POST /chat/chat
 //
 //	Request Body: {
 //	 "receiverId": receiverId,
-//	 "message": message
+//	 "message": message,
+//	 "attachment_ids": [attachmentId]
 //	 }
 //	 Response:
 //	 200 OK: {
-//	 "message": "Chat sent"
+//	 "message": "Chat sent",
+//	 "id": id
 //	 }
 //	 400 Bad Request: {
+//	 "error": "Receiver id is required"
+//	 }
+//	 403 Forbidden: {
+//	 "error": "Email address is not verified"
+//	 }
+//	 404 Not Found: {
+//	 "error": "User with id %s does not exist"
+//	 }
+//	 429 Too Many Requests: {
+//	 "error": "Rate limit exceeded"
+//	 }
+//	 500 Internal Server Error: {
 //	 "error": "Error reading payload"
 //	 }
 //	 500 Internal Server Error: {
@@ -70,13 +93,10 @@ func (c *SendChatHandler) Middlewares() []gin.HandlerFunc {
 //	 500 Internal Server Error: {
 //	 "error": "Error saving chat"
 //	 }
-//	 500 Internal Server Error: {
-//	 "error": "Error marshalling chat"
-//	 }
 func (c *SendChatHandler) Handler() gin.HandlerFunc {
 	return func(ginCtx *gin.Context) {
 		email := ginCtx.GetString("email")
-		sender, err := db.GetUserFromEmail(c.pdb, email)
+		sender, err := c.users.GetUserFromEmail(email)
 		if err != nil {
 			c.log.Error("Error getting user from email")
 			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
@@ -96,49 +116,17 @@ func (c *SendChatHandler) Handler() gin.HandlerFunc {
 			return
 		}
 		chat.SenderId = senderId
-		chat.CreatedAt = time.Now()
 
-		// Save to db
-		if err := db.SaveChat(c.pdb, &chat); err != nil {
-			c.log.Error("Error saving chat", zap.Error(err))
-			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-				"error": "Error saving chat",
+		if status, err := sendChat(c.users, c.chats, c.relay, c.log, &chat, c.requireVerifiedEmail); err != nil {
+			ginCtx.AbortWithStatusJSON(status, gin.H{
+				"error": err.Error(),
 			})
 			return
 		}
 
-		// Send to rmq queue
-		var wg sync.WaitGroup
-		wg.Add(1)
-
-		go func() {
-			defer wg.Done()
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-			body, err := json.Marshal(chat)
-			if err != nil {
-				c.log.Error("Error marshalling chat", zap.Error(err))
-			}
-
-			err = c.amqpConfig.Channel.PublishWithContext(
-				ctx,
-				constants.EXCHANGE_NAME, // Exchange
-				chat.ReceiverId,         // Routing key
-				false,                   // mandatory
-				false,                   // immediate
-				amqp091.Publishing{
-					ContentType: "text/plain",
-					Body:        body,
-				})
-			if err != nil {
-				c.log.Error("Error publishing message", zap.Error(err))
-			}
-		}()
-
-		wg.Wait()
-
 		ginCtx.JSON(http.StatusOK, gin.H{
 			"message": "Chat sent",
+			"id":      chat.Id,
 		})
 	}
 }
@@ -0,0 +1,130 @@
+package chat_api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"github.com/nihal-ramaswamy/GoChat/internal/outbox"
+	"github.com/nihal-ramaswamy/GoChat/internal/ratelimit"
+	"go.uber.org/zap"
+)
+
+type SendConversationChatHandler struct {
+	dto.HandlerInterface
+	log                  *zap.Logger
+	users                db.UserRepository
+	chats                db.ChatRepository
+	middlewares          []gin.HandlerFunc
+	relay                *outbox.Relay
+	requireVerifiedEmail bool
+}
+
+func NewSendConversationChatHandler(
+	users db.UserRepository,
+	chats db.ChatRepository,
+	log *zap.Logger,
+	relay *outbox.Relay,
+	requireVerifiedEmail bool,
+	limiter *ratelimit.Limiter,
+) *SendConversationChatHandler {
+	return &SendConversationChatHandler{
+		log:                  log,
+		users:                users,
+		chats:                chats,
+		relay:                relay,
+		requireVerifiedEmail: requireVerifiedEmail,
+		middlewares: []gin.HandlerFunc{
+			middlewares.RateLimit(limiter, ratelimit.ChatSend, middlewares.ByUser),
+		},
+	}
+}
+
+func (h *SendConversationChatHandler) Pattern() string {
+	return "/conversation/:id/chat"
+}
+
+func (h *SendConversationChatHandler) RequestMethod() string {
+	return constants.POST
+}
+
+func (h *SendConversationChatHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
+
+func (h *SendConversationChatHandler) Scope() string {
+	return constants.SCOPE_CHAT_SEND
+}
+
+// Handler to post a chat to a conversation. The chat is published once to the
+// conversation and every member's queue receives a single copy.
+// For direct conversations the receiver id is the other member, for groups it
+// is the conversation id.
+// POST /chat/conversation/:id/chat
+//
+//	Request Body: {
+//	 "message": message,
+//	 "attachment_ids": [attachmentId]
+//	 }
+//	 Response:
+//	 200 OK: {
+//	 "message": "Chat sent",
+//	 "id": id
+//	 }
+//	 400 Bad Request: {
+//	 "error": "Error reading payload"
+//	 }
+//	 403 Forbidden: {
+//	 "error": "Not a member of the conversation"
+//	 }
+//	 403 Forbidden: {
+//	 "error": "Email address is not verified"
+//	 }
+//	 404 Not Found: {
+//	 "error": "Conversation does not exist"
+//	 }
+//	 429 Too Many Requests: {
+//	 "error": "Rate limit exceeded"
+//	 }
+//	 500 Internal Server Error: {
+//	 "error": "Error saving chat"
+//	 }
+func (h *SendConversationChatHandler) Handler() gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		email := ginCtx.GetString("email")
+		sender, err := h.users.GetUserFromEmail(email)
+		if err != nil {
+			h.log.Error("Error getting user from email")
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error getting user from email",
+			})
+			return
+		}
+
+		var chat dto.Chat
+		if err := ginCtx.ShouldBindJSON(&chat); err != nil {
+			h.log.Error("Error binding json", zap.Error(err))
+			ginCtx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Error reading payload",
+			})
+			return
+		}
+		chat.SenderId = sender.Id
+		chat.ConversationId = ginCtx.Param("id")
+
+		if status, err := sendChat(h.users, h.chats, h.relay, h.log, &chat, h.requireVerifiedEmail); err != nil {
+			ginCtx.AbortWithStatusJSON(status, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		ginCtx.JSON(http.StatusOK, gin.H{
+			"message": "Chat sent",
+			"id":      chat.Id,
+		})
+	}
+}
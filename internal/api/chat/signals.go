@@ -0,0 +1,63 @@
+package chat_api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+// publishSignal forwards an ephemeral signal from signal.SenderId to a peer or
+// to every member of a conversation. Nothing is persisted; membership is only
+// read to stop non-members from signalling into a conversation, and peers
+// must be existing users so their id cannot address any other routing key. On
+// failure it returns the status code and error message to respond with.
+func publishSignal(
+	users db.UserRepository,
+	chats db.ChatRepository,
+	b broker.Broker,
+	log *zap.Logger,
+	signal *dto.Signal,
+) (int, error) {
+	switch signal.Kind {
+	case constants.SIGNAL_TYPING, constants.SIGNAL_RECORDING:
+	default:
+		return http.StatusBadRequest, fmt.Errorf("Unknown signal kind")
+	}
+
+	routingKey := signal.ReceiverId
+	if signal.ConversationId != "" {
+		if _, status, err := getConversationForMember(chats, signal.ConversationId, signal.SenderId); err != nil {
+			return status, err
+		}
+		signal.ReceiverId = ""
+		routingKey = broker.ConversationRoutingKey(signal.ConversationId)
+	} else if signal.ReceiverId == "" {
+		return http.StatusBadRequest, fmt.Errorf("Receiver id is required")
+	} else if !users.DoesUserExist(signal.ReceiverId) {
+		return http.StatusNotFound, fmt.Errorf("User with id %s does not exist", signal.ReceiverId)
+	}
+	signal.ExpiresAt = time.Now().Add(constants.SIGNAL_TTL)
+
+	body, err := dto.MarshalWsEnvelope(constants.WS_SIGNAL, signal)
+	if err != nil {
+		log.Error("Error marshalling signal", zap.Error(err))
+		return http.StatusInternalServerError, fmt.Errorf("Error sending signal")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := b.PublishEphemeral(ctx, routingKey, body, constants.SIGNAL_TTL); err != nil {
+		log.Error("Error publishing signal", zap.Error(err))
+		return http.StatusInternalServerError, fmt.Errorf("Error sending signal")
+	}
+
+	return http.StatusOK, nil
+}
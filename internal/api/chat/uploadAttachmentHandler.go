@@ -0,0 +1,179 @@
+package chat_api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/storage"
+	"go.uber.org/zap"
+)
+
+type UploadAttachmentHandler struct {
+	dto.HandlerInterface
+	log         *zap.Logger
+	users       db.UserRepository
+	chats       db.ChatRepository
+	middlewares []gin.HandlerFunc
+	storage     storage.Storage
+}
+
+func NewUploadAttachmentHandler(
+	users db.UserRepository,
+	chats db.ChatRepository,
+	log *zap.Logger,
+	storage storage.Storage,
+) *UploadAttachmentHandler {
+	return &UploadAttachmentHandler{
+		log:         log,
+		users:       users,
+		chats:       chats,
+		storage:     storage,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (h *UploadAttachmentHandler) Pattern() string {
+	return "/attachment"
+}
+
+func (h *UploadAttachmentHandler) RequestMethod() string {
+	return constants.POST
+}
+
+func (h *UploadAttachmentHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
+
+func (h *UploadAttachmentHandler) Scope() string {
+	return constants.SCOPE_CHAT_SEND
+}
+
+// Handler to upload an attachment. The content type is detected from the file
+// itself. The returned id can then be sent in the attachment_ids of a chat.
+// POST /chat/attachment
+//
+//	Request Body: multipart/form-data with the file in the "file" field
+//	 Response:
+//	 201 Created: {
+//	 "id": id,
+//	 "uploader_id": uploaderId,
+//	 "file_name": fileName,
+//	 "content_type": contentType,
+//	 "size": size,
+//	 "url": url,
+//	 "created_at": createdAt
+//	 }
+//	 400 Bad Request: {
+//	 "error": "Error reading file"
+//	 }
+//	 413 Request Entity Too Large: {
+//	 "error": "File is too large"
+//	 }
+//	 415 Unsupported Media Type: {
+//	 "error": "Unsupported file type"
+//	 }
+func (h *UploadAttachmentHandler) Handler() gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		email := ginCtx.GetString("email")
+		user, err := h.users.GetUserFromEmail(email)
+		if err != nil {
+			h.log.Error("Error getting user from email")
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error getting user from email",
+			})
+			return
+		}
+
+		ginCtx.Request.Body = http.MaxBytesReader(ginCtx.Writer, ginCtx.Request.Body,
+			constants.ATTACHMENT_MAX_SIZE+constants.ATTACHMENT_FORM_OVERHEAD)
+
+		fileHeader, err := ginCtx.FormFile(constants.ATTACHMENT_FORM_FIELD)
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				ginCtx.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+					"error": "File is too large",
+				})
+				return
+			}
+			ginCtx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Error reading file",
+			})
+			return
+		}
+		if fileHeader.Size > constants.ATTACHMENT_MAX_SIZE {
+			ginCtx.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": "File is too large",
+			})
+			return
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			ginCtx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Error reading file",
+			})
+			return
+		}
+		defer file.Close()
+
+		// Trust the bytes rather than the content type claimed by the client
+		head := make([]byte, constants.ATTACHMENT_SNIFF_SIZE)
+		n, err := io.ReadFull(file, head)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			ginCtx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Error reading file",
+			})
+			return
+		}
+		head = head[:n]
+
+		contentType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+		if err != nil || !slices.Contains(constants.ATTACHMENT_CONTENT_TYPES, contentType) {
+			ginCtx.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{
+				"error": "Unsupported file type",
+			})
+			return
+		}
+
+		attachment := dto.Attachment{
+			UploaderId:  user.Id,
+			FileName:    filepath.Base(fileHeader.Filename),
+			ContentType: contentType,
+			Size:        fileHeader.Size,
+			StorageKey:  storage.NewKey(user.Id),
+		}
+
+		body := io.MultiReader(bytes.NewReader(head), file)
+		err = h.storage.Put(ginCtx.Request.Context(), attachment.StorageKey, body, attachment.Size, attachment.ContentType)
+		if err != nil {
+			h.log.Error("Error storing attachment", zap.Error(err))
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error storing attachment",
+			})
+			return
+		}
+
+		if err := h.chats.SaveAttachment(&attachment); err != nil {
+			h.log.Error("Error saving attachment", zap.Error(err))
+			if err := h.storage.Delete(ginCtx.Request.Context(), attachment.StorageKey); err != nil {
+				h.log.Error("Error removing stored attachment", zap.Error(err))
+			}
+			ginCtx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Error saving attachment",
+			})
+			return
+		}
+
+		ginCtx.JSON(http.StatusCreated, attachment)
+	}
+}
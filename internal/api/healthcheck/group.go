@@ -5,7 +5,9 @@ import (
 	"database/sql"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
@@ -29,10 +31,13 @@ func NewHealthCheckGroup(
 	rdb *redis.Client,
 	ctx context.Context,
 	log *zap.Logger,
+	broker broker.Broker,
+	keys *jwtkeys.KeySet,
 ) *HealthCheckGroup {
 	handlers := []dto.HandlerInterface{
 		NewHealthCheckHandler(),
-		NewHealthCheckHandlerAuth(pdb, rdb, ctx, log),
+		NewHealthCheckHandlerAuth(pdb, rdb, ctx, log, keys),
+		NewHealthCheckReadyHandler(broker),
 	}
 
 	return &HealthCheckGroup{
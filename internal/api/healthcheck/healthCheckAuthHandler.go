@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/nihal-ramaswamy/GoChat/internal/constants"
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
 	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
@@ -23,9 +24,10 @@ func NewHealthCheckHandlerAuth(
 	rdb *redis.Client,
 	ctx context.Context,
 	log *zap.Logger,
+	keys *jwtkeys.KeySet,
 ) *HealthCheckHandlerAuth {
 	return &HealthCheckHandlerAuth{
-		middlewares: []gin.HandlerFunc{middlewares.AuthMiddleware(pdb, rdb, ctx, log)},
+		middlewares: []gin.HandlerFunc{middlewares.AuthMiddleware(pdb, rdb, ctx, log, keys)},
 	}
 }
 
@@ -0,0 +1,65 @@
+package healthcheck_api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+)
+
+type HealthCheckReadyHandler struct {
+	dto.HandlerInterface
+	middlewares []gin.HandlerFunc
+	broker      broker.Broker
+}
+
+func NewHealthCheckReadyHandler(broker broker.Broker) *HealthCheckReadyHandler {
+	return &HealthCheckReadyHandler{
+		middlewares: []gin.HandlerFunc{},
+		broker:      broker,
+	}
+}
+
+func (*HealthCheckReadyHandler) Pattern() string {
+	return "/ready"
+}
+
+// Handler reports whether the message broker is connected. Load balancers
+// should stop routing websocket connections to an instance that is not ready.
+// GET /healthcheck/ready
+//
+// Response Body:
+//
+//	200 OK: {
+//		"broker": "connected"
+//		}
+//	503 Service Unavailable: {
+//		"broker": "reconnecting" | "closed"
+//		}
+func (h *HealthCheckReadyHandler) Handler() gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		ctx, cancel := context.WithTimeout(ginCtx.Request.Context(), 2*time.Second)
+		defer cancel()
+
+		state := h.broker.State(ctx)
+		status := http.StatusOK
+		if state != constants.BROKER_STATE_CONNECTED {
+			status = http.StatusServiceUnavailable
+		}
+		ginCtx.JSON(status, gin.H{
+			"broker": state,
+		})
+	}
+}
+
+func (*HealthCheckReadyHandler) RequestMethod() string {
+	return constants.GET
+}
+
+func (h *HealthCheckReadyHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
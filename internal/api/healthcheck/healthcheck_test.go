@@ -4,11 +4,11 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
-	"os"
-	"path/filepath"
+	"strings"
 	"testing"
 
 	_ "github.com/lib/pq"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
 	"github.com/nihal-ramaswamy/GoChat/internal/routes"
 	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
 )
@@ -16,13 +16,7 @@ import (
 func TestHealthcheck(t *testing.T) {
 	ctx := context.Background()
 
-	wd, err := os.Getwd()
-	if err != nil {
-		t.Fatalf("Error getting working directory: %s", err)
-	}
-	rootDir := filepath.Join(wd, "..", "..", "..")
-
-	testConfig, err := testUtils.SetUpRouter(rootDir, ctx)
+	testConfig, err := testUtils.SetUpRouter(ctx)
 	if err != nil {
 		t.Fatalf("Error setting up server for testing: %s", err)
 	}
@@ -49,12 +43,20 @@ func TestHealthcheck(t *testing.T) {
 	routes.NewRoutes(
 		testConfig.Server,
 		testConfig.Db,
+		testConfig.Users,
+		testConfig.Chats,
+		testConfig.Rdb,
 		testConfig.Rdb,
 		ctx,
 		testConfig.Log,
-		testConfig.AmqpConfig,
+		testConfig.Broker,
+		testConfig.Relay,
 		testConfig.Upgrader,
 		testConfig.WebsocketMap,
+		testConfig.Storage,
+		testConfig.Mailer,
+		testConfig.OidcProvider,
+		testConfig.Keys,
 	)
 
 	w := httptest.NewRecorder()
@@ -67,3 +69,45 @@ func TestHealthcheck(t *testing.T) {
 		t.Errorf("Expected status code 200, got %d", w.Code)
 	}
 }
+
+func TestHealthcheckReadyMemory(t *testing.T) {
+	ctx := context.Background()
+
+	testConfig, err := testUtils.SetUpMemoryRouter()
+	if err != nil {
+		t.Fatalf("Error setting up server for testing: %s", err)
+	}
+	t.Cleanup(testConfig.MiniRedis.Close)
+
+	routes.NewRoutes(
+		testConfig.Server,
+		testConfig.Db,
+		testConfig.Users,
+		testConfig.Chats,
+		testConfig.Rdb,
+		testConfig.Rdb,
+		ctx,
+		testConfig.Log,
+		testConfig.Broker,
+		testConfig.Relay,
+		testConfig.Upgrader,
+		testConfig.WebsocketMap,
+		testConfig.Storage,
+		testConfig.Mailer,
+		testConfig.OidcProvider,
+		testConfig.Keys,
+	)
+
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/healthcheck/ready", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %s", err)
+	}
+	testConfig.Server.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Errorf("Expected status code 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), constants.BROKER_STATE_CONNECTED) {
+		t.Errorf("Expected the broker to be %s, got %s", constants.BROKER_STATE_CONNECTED, w.Body.String())
+	}
+}
@@ -0,0 +1,86 @@
+package presence_api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/presence"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type GetPresenceHandler struct {
+	dto.HandlerInterface
+	users       db.UserRepository
+	rdb         *redis.Client
+	ctx         context.Context
+	log         *zap.Logger
+	middlewares []gin.HandlerFunc
+}
+
+func NewGetPresenceHandler(
+	users db.UserRepository,
+	rdb *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+) *GetPresenceHandler {
+	return &GetPresenceHandler{
+		users:       users,
+		rdb:         rdb,
+		ctx:         ctx,
+		log:         log,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (*GetPresenceHandler) Pattern() string {
+	return "/:id"
+}
+
+func (*GetPresenceHandler) RequestMethod() string {
+	return constants.GET
+}
+
+// Handler returns whether a user is online and, unless they hide it, when
+// they were last seen
+// GET /presence/:id
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	Response:
+//	  200 OK: {
+//	  "user_id": userId,
+//	  "online": online,
+//	  "last_seen": lastSeen
+//	  }
+//	  404 Not Found: {
+//	  "error": "User with id %s does not exist"
+//	  }
+func (h *GetPresenceHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if !h.users.DoesUserExist(id) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User with id " + id + " does not exist"})
+			return
+		}
+
+		p, err := presence.Get(h.ctx, h.rdb, h.users, id)
+		if err != nil {
+			h.log.Error("Error getting presence", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting presence"})
+			return
+		}
+
+		c.JSON(http.StatusOK, p)
+	}
+}
+
+func (h *GetPresenceHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
@@ -0,0 +1,53 @@
+package presence_api
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type PresenceGroup struct {
+	dto.ServerGroupInterface
+	routeHandlers []dto.HandlerInterface
+	middlewares   []gin.HandlerFunc
+}
+
+func NewPresenceGroup(
+	pdb *sql.DB,
+	users db.UserRepository,
+	rdb_auth *redis.Client,
+	rdb_presence *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+	keys *jwtkeys.KeySet,
+) *PresenceGroup {
+	handlers := []dto.HandlerInterface{
+		NewGetPresenceHandler(users, rdb_presence, ctx, log),
+		NewListPresenceHandler(users, rdb_presence, ctx, log),
+		NewPrivacyHandler(users, log),
+	}
+
+	return &PresenceGroup{
+		routeHandlers: handlers,
+		middlewares:   []gin.HandlerFunc{middlewares.AuthMiddleware(pdb, rdb_auth, ctx, log, keys)},
+	}
+}
+
+func (pg *PresenceGroup) Group() string {
+	return "/presence"
+}
+
+func (pg *PresenceGroup) RouteHandlers() []dto.HandlerInterface {
+	return pg.routeHandlers
+}
+
+func (pg *PresenceGroup) Middlewares() []gin.HandlerFunc {
+	return pg.middlewares
+}
@@ -0,0 +1,93 @@
+package presence_api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/presence"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const maxPresenceIds = 100
+
+type ListPresenceHandler struct {
+	dto.HandlerInterface
+	users       db.UserRepository
+	rdb         *redis.Client
+	ctx         context.Context
+	log         *zap.Logger
+	middlewares []gin.HandlerFunc
+}
+
+func NewListPresenceHandler(
+	users db.UserRepository,
+	rdb *redis.Client,
+	ctx context.Context,
+	log *zap.Logger,
+) *ListPresenceHandler {
+	return &ListPresenceHandler{
+		users:       users,
+		rdb:         rdb,
+		ctx:         ctx,
+		log:         log,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (*ListPresenceHandler) Pattern() string {
+	return ""
+}
+
+func (*ListPresenceHandler) RequestMethod() string {
+	return constants.GET
+}
+
+// Handler returns the presence of several users at once
+// GET /presence?ids=id1,id2
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	Response:
+//	  200 OK: [{
+//	  "user_id": userId,
+//	  "online": online,
+//	  "last_seen": lastSeen
+//	  }]
+//	  400 Bad Request: {
+//	  "error": "Between 1 and 100 ids are required"
+//	  }
+func (h *ListPresenceHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ids := strings.Split(c.Query("ids"), ",")
+		if c.Query("ids") == "" || len(ids) > maxPresenceIds {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Between 1 and 100 ids are required"})
+			return
+		}
+
+		result := []dto.Presence{}
+		for _, id := range ids {
+			p, err := presence.Get(h.ctx, h.rdb, h.users, id)
+			if err != nil && err != sql.ErrNoRows {
+				h.log.Error("Error getting presence", zap.Error(err))
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting presence"})
+				return
+			}
+			result = append(result, p)
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func (h *ListPresenceHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
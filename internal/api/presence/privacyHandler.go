@@ -0,0 +1,78 @@
+package presence_api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+type PrivacyHandler struct {
+	dto.HandlerInterface
+	users       db.UserRepository
+	log         *zap.Logger
+	middlewares []gin.HandlerFunc
+}
+
+func NewPrivacyHandler(users db.UserRepository, log *zap.Logger) *PrivacyHandler {
+	return &PrivacyHandler{
+		users:       users,
+		log:         log,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (*PrivacyHandler) Pattern() string {
+	return "/privacy"
+}
+
+func (*PrivacyHandler) RequestMethod() string {
+	return constants.POST
+}
+
+// Handler updates whether other users can see when the user was last seen
+// POST /presence/privacy
+//
+//	Request Header: {
+//	  "Token": Bearer token,
+//	  }
+//
+//	Request Body: {
+//	  "hide_last_seen": hideLastSeen
+//	  }
+//
+//	Response:
+//	  202 Accepted: {
+//	  "hide_last_seen": hideLastSeen
+//	  }
+func (h *PrivacyHandler) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := h.users.GetUserFromEmail(c.GetString("email"))
+		if err != nil {
+			h.log.Error("Error getting user from email")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting user from email"})
+			return
+		}
+
+		var privacy dto.PresencePrivacy
+		if err := c.ShouldBindJSON(&privacy); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Error reading payload"})
+			return
+		}
+
+		if err := h.users.SetHideLastSeen(user.Id, privacy.HideLastSeen); err != nil {
+			h.log.Error("Error updating privacy", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating privacy"})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, privacy)
+	}
+}
+
+func (h *PrivacyHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
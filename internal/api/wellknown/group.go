@@ -0,0 +1,40 @@
+package wellknown_api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+)
+
+type WellKnownGroup struct {
+	dto.ServerGroupInterface
+	routeHandlers []dto.HandlerInterface
+	middlewares   []gin.HandlerFunc
+}
+
+func (*WellKnownGroup) Group() string {
+	return "/.well-known"
+}
+
+func (w *WellKnownGroup) RouteHandlers() []dto.HandlerInterface {
+	return w.routeHandlers
+}
+
+func NewWellKnownGroup(keys *jwtkeys.KeySet) *WellKnownGroup {
+	handlers := []dto.HandlerInterface{
+		NewJwksHandler(keys),
+	}
+
+	return &WellKnownGroup{
+		routeHandlers: handlers,
+		middlewares:   []gin.HandlerFunc{},
+	}
+}
+
+func (*WellKnownGroup) AuthRequired() bool {
+	return false
+}
+
+func (w *WellKnownGroup) Middlewares() []gin.HandlerFunc {
+	return w.middlewares
+}
@@ -0,0 +1,60 @@
+package wellknown_api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+)
+
+type JwksHandler struct {
+	dto.HandlerInterface
+	keys        *jwtkeys.KeySet
+	middlewares []gin.HandlerFunc
+}
+
+func NewJwksHandler(keys *jwtkeys.KeySet) *JwksHandler {
+	return &JwksHandler{
+		keys:        keys,
+		middlewares: []gin.HandlerFunc{},
+	}
+}
+
+func (*JwksHandler) Pattern() string {
+	return "/jwks.json"
+}
+
+func (*JwksHandler) RequestMethod() string {
+	return constants.GET
+}
+
+// Handler returns the public keys access tokens are signed with, so other
+// services can verify them. During a rotation both the new and old keys are listed.
+// GET /.well-known/jwks.json
+//
+// Response Body:
+//
+//	200 OK: {
+//		"keys": [{
+//		"kty": "RSA" | "OKP",
+//		"kid": kid,
+//		"use": "sig",
+//		"alg": "RS256" | "EdDSA",
+//		"n": n,
+//		"e": e,
+//		"crv": "Ed25519",
+//		"x": x
+//		}]
+//		}
+func (j *JwksHandler) Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Header("Cache-Control", "public, max-age=300")
+		ctx.JSON(http.StatusOK, j.keys.Jwks())
+	}
+}
+
+func (j *JwksHandler) Middlewares() []gin.HandlerFunc {
+	return j.middlewares
+}
@@ -0,0 +1,52 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+)
+
+// NewSecret returns the random part of a key and the hash stored for it.
+// Keys are long and random, so a fast hash is enough to protect them at rest.
+func NewSecret() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	secret := hex.EncodeToString(b)
+	return secret, Hash(secret), nil
+}
+
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Format returns the key handed to the user. The key id is kept in it so the
+// key can be looked up without scanning every hash.
+func Format(id, secret string) string {
+	return constants.API_KEY_PREFIX + id + "." + secret
+}
+
+// IsApiKey reports whether a bearer credential is an API key rather than an access token
+func IsApiKey(key string) bool {
+	return strings.HasPrefix(key, constants.API_KEY_PREFIX)
+}
+
+// Parse splits a key into its id and secret
+func Parse(key string) (string, string, bool) {
+	id, secret, found := strings.Cut(strings.TrimPrefix(key, constants.API_KEY_PREFIX), ".")
+	if !IsApiKey(key) || !found || id == "" || secret == "" {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+// Matches compares a secret against a stored hash in constant time
+func Matches(secret, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(Hash(secret)), []byte(hash)) == 1
+}
@@ -0,0 +1,36 @@
+package apikey_test
+
+import (
+	"testing"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/apikey"
+)
+
+func TestParse(t *testing.T) {
+	secret, hash, err := apikey.NewSecret()
+	if err != nil {
+		t.Fatalf("Error generating secret: %s", err)
+	}
+
+	key := apikey.Format("id", secret)
+	if !apikey.IsApiKey(key) {
+		t.Fatalf("Expected %s to be an API key", key)
+	}
+
+	id, parsed, ok := apikey.Parse(key)
+	if !ok || id != "id" || parsed != secret {
+		t.Fatalf("Expected id and secret, got %s %s %t", id, parsed, ok)
+	}
+	if !apikey.Matches(parsed, hash) {
+		t.Errorf("Expected secret to match its hash")
+	}
+	if apikey.Matches(parsed+"0", hash) {
+		t.Errorf("Expected modified secret not to match")
+	}
+
+	for _, key := range []string{"", "gck_", "gck_id", "gck_.secret", "gck_id.", "id.secret"} {
+		if _, _, ok := apikey.Parse(key); ok {
+			t.Errorf("Expected %q to be rejected", key)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package broker
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+)
+
+// Broker routes events to the queues of users. Every user has a durable
+// queue bound to their id; BindConversation and BindPresence bind it to more
+// routing keys so a single publish reaches every subscriber.
+type Broker interface {
+	// Publish sends a persistent message to the queue of a user. Messages for
+	// users that are offline are kept until they subscribe.
+	Publish(ctx context.Context, userId string, body []byte) error
+	// PublishToConversation sends a persistent message to every member of a conversation
+	PublishToConversation(ctx context.Context, conversationId string, body []byte) error
+	// PublishEphemeral sends a message to a routing key, either a user id,
+	// ConversationRoutingKey or PresenceRoutingKey. It is dropped if it is not
+	// consumed within ttl.
+	PublishEphemeral(ctx context.Context, routingKey string, body []byte, ttl time.Duration) error
+
+	// Subscribe starts consuming the queue of a user. Deliveries must be
+	// acknowledged; closing the subscription redelivers the rest later.
+	Subscribe(userId string) (Subscription, error)
+
+	BindConversation(userId, conversationId string) error
+	UnbindConversation(userId, conversationId string) error
+	BindPresence(userId, targetId string) error
+	UnbindPresence(userId, targetId string) error
+
+	// State reports the connection state of the broker, one of the
+	// constants.BROKER_STATE_* values
+	State(ctx context.Context) string
+
+	// Close releases the connections the broker owns
+	Close() error
+}
+
+// Subscription is a consumer of the queue of a user
+type Subscription interface {
+	// Deliveries is closed when the subscription ends
+	Deliveries() <-chan Delivery
+	// Recovered receives after the subscription resumed consuming on a new
+	// connection. Bindings made before can be lost with the connection, so
+	// they should be made again. It is nil for brokers that never lose them.
+	Recovered() <-chan struct{}
+	Close() error
+}
+
+// Delivery is a message read from the queue of a user
+type Delivery struct {
+	Body []byte
+	ack  func() error
+}
+
+// Ack removes the message from the queue so it is not delivered again
+func (d Delivery) Ack() error {
+	return d.ack()
+}
+
+// ConversationRoutingKey returns the routing key every member of a
+// conversation is bound to
+func ConversationRoutingKey(conversationId string) string {
+	return constants.CONVERSATION_ROUTING_PREFIX + conversationId
+}
+
+// PresenceRoutingKey returns the routing key presence changes of a user are published to
+func PresenceRoutingKey(userId string) string {
+	return constants.PRESENCE_ROUTING_PREFIX + userId
+}
+
+// isBindingKey reports whether routingKey is bound explicitly, as opposed to
+// the id of a user whose queue is always bound to it
+func isBindingKey(routingKey string) bool {
+	return strings.HasPrefix(routingKey, constants.CONVERSATION_ROUTING_PREFIX) ||
+		strings.HasPrefix(routingKey, constants.PRESENCE_ROUTING_PREFIX)
+}
@@ -0,0 +1,178 @@
+package broker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func receive(t *testing.T, subscription broker.Subscription) broker.Delivery {
+	t.Helper()
+	select {
+	case d, ok := <-subscription.Deliveries():
+		if !ok {
+			t.Fatalf("Subscription ended")
+		}
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for a delivery")
+	}
+	return broker.Delivery{}
+}
+
+func expectNothing(t *testing.T, subscription broker.Subscription) {
+	t.Helper()
+	select {
+	case d := <-subscription.Deliveries():
+		t.Fatalf("Expected no delivery, got %s", d.Body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// runBrokerTest checks the behaviour every backend shares
+func runBrokerTest(t *testing.T, b broker.Broker) {
+	ctx := context.Background()
+	userId := testUtils.RandStringRunes(10)
+	peerId := testUtils.RandStringRunes(10)
+	conversationId := testUtils.RandStringRunes(10)
+
+	if state := b.State(ctx); state != constants.BROKER_STATE_CONNECTED {
+		t.Fatalf("Expected broker to be %s, got %s", constants.BROKER_STATE_CONNECTED, state)
+	}
+
+	// Messages for users that never subscribed are kept
+	if err := b.Publish(ctx, userId, []byte("first")); err != nil {
+		t.Fatalf("Error publishing: %s", err)
+	}
+
+	subscription, err := b.Subscribe(userId)
+	if err != nil {
+		t.Fatalf("Error subscribing: %s", err)
+	}
+	if d := receive(t, subscription); string(d.Body) != "first" {
+		t.Fatalf("Expected %q, got %q", "first", d.Body)
+	}
+
+	// Closing without acknowledging redelivers on the next subscription
+	if err := subscription.Close(); err != nil {
+		t.Fatalf("Error closing subscription: %s", err)
+	}
+	subscription, err = b.Subscribe(userId)
+	if err != nil {
+		t.Fatalf("Error subscribing: %s", err)
+	}
+	d := receive(t, subscription)
+	if string(d.Body) != "first" {
+		t.Fatalf("Expected redelivery of %q, got %q", "first", d.Body)
+	}
+	if err := d.Ack(); err != nil {
+		t.Fatalf("Error acknowledging: %s", err)
+	}
+
+	// Conversations reach every bound member
+	for _, id := range []string{userId, peerId} {
+		if err := b.BindConversation(id, conversationId); err != nil {
+			t.Fatalf("Error binding conversation: %s", err)
+		}
+	}
+	if err := b.PublishToConversation(ctx, conversationId, []byte("hello")); err != nil {
+		t.Fatalf("Error publishing to conversation: %s", err)
+	}
+	d = receive(t, subscription)
+	if string(d.Body) != "hello" {
+		t.Fatalf("Expected %q, got %q", "hello", d.Body)
+	}
+	d.Ack()
+
+	peer, err := b.Subscribe(peerId)
+	if err != nil {
+		t.Fatalf("Error subscribing: %s", err)
+	}
+	d = receive(t, peer)
+	if string(d.Body) != "hello" {
+		t.Fatalf("Expected %q for the peer, got %q", "hello", d.Body)
+	}
+	d.Ack()
+
+	if err := b.UnbindConversation(peerId, conversationId); err != nil {
+		t.Fatalf("Error unbinding conversation: %s", err)
+	}
+	if err := b.PublishToConversation(ctx, conversationId, []byte("again")); err != nil {
+		t.Fatalf("Error publishing to conversation: %s", err)
+	}
+	d = receive(t, subscription)
+	d.Ack()
+	expectNothing(t, peer)
+
+	// Presence is ephemeral, so it is dropped if the peer does not consume it in time
+	if err := b.BindPresence(peerId, userId); err != nil {
+		t.Fatalf("Error binding presence: %s", err)
+	}
+	peer.Close()
+	if err := b.PublishEphemeral(ctx, broker.PresenceRoutingKey(userId), []byte("stale"), time.Millisecond); err != nil {
+		t.Fatalf("Error publishing presence: %s", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := b.PublishEphemeral(ctx, broker.PresenceRoutingKey(userId), []byte("online"), time.Minute); err != nil {
+		t.Fatalf("Error publishing presence: %s", err)
+	}
+	peer, err = b.Subscribe(peerId)
+	if err != nil {
+		t.Fatalf("Error subscribing: %s", err)
+	}
+	d = receive(t, peer)
+	if string(d.Body) != "online" {
+		t.Fatalf("Expected %q, got %q", "online", d.Body)
+	}
+	d.Ack()
+
+	// Ephemeral messages to users without a queue are dropped
+	if err := b.PublishEphemeral(ctx, testUtils.RandStringRunes(10), []byte("typing"), time.Minute); err != nil {
+		t.Fatalf("Error publishing signal: %s", err)
+	}
+
+	subscription.Close()
+	peer.Close()
+}
+
+func TestMemoryBroker(t *testing.T) {
+	runBrokerTest(t, broker.NewMemoryBroker())
+}
+
+func TestRedisBroker(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Error starting redis: %s", err)
+	}
+	t.Cleanup(server.Close)
+
+	rdb := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	runBrokerTest(t, broker.NewRedisBroker(rdb, zap.NewNop()))
+}
+
+func TestRabbitMqBroker(t *testing.T) {
+	ctx := context.Background()
+
+	container, config, err := testUtils.SetUpRabbitMqForTesting(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up rabbitmq for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		config.Close()
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	runBrokerTest(t, broker.NewRabbitMqBroker(config, zap.NewNop()))
+}
@@ -0,0 +1,41 @@
+package broker
+
+import (
+	"fmt"
+
+	amqpConfig "github.com/nihal-ramaswamy/GoChat/internal/amqp"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/utils"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// NewBroker returns the backend selected by backend. rdb is only used by the
+// Redis Streams backend.
+func NewBroker(backend string, rdb *redis.Client, log *zap.Logger) (Broker, error) {
+	switch backend {
+	case constants.BROKER_BACKEND_RABBITMQ:
+		// Starts reconnecting if RabbitMQ is down, /healthcheck/ready reports it
+		config := amqpConfig.NewAmqpConfig(utils.GetDotEnvVariable(constants.RABBITMQ_HOST), log)
+		return NewRabbitMqBroker(config, log), nil
+	case constants.BROKER_BACKEND_MEMORY:
+		return NewMemoryBroker(), nil
+	case constants.BROKER_BACKEND_REDIS:
+		return NewRedisBroker(rdb, log), nil
+	default:
+		return nil, fmt.Errorf("Unknown broker backend %q", backend)
+	}
+}
+
+func DefaultBroker(rdb *redis.Client, log *zap.Logger) Broker {
+	backend := utils.GetDotEnvVariable(constants.BROKER_BACKEND)
+	if backend == "" {
+		backend = constants.BROKER_BACKEND_RABBITMQ
+	}
+
+	broker, err := NewBroker(backend, rdb, log)
+	if err != nil {
+		panic(fmt.Errorf("Failed to get broker: %s", err))
+	}
+	return broker
+}
@@ -0,0 +1,231 @@
+package broker
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+)
+
+// MemoryBroker keeps queues in process. It suits a single instance, for
+// development and tests; messages are lost when the process stops.
+type MemoryBroker struct {
+	mu sync.Mutex
+	// Users bound to each conversation and presence routing key
+	bindings map[string]map[string]bool
+	queues   map[string]*memoryQueue
+}
+
+type memoryMessage struct {
+	body      []byte
+	expiresAt time.Time
+}
+
+type memoryQueue struct {
+	ready []*memoryMessage
+	// Signalled, without blocking, whenever a message becomes ready
+	notify chan struct{}
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		bindings: map[string]map[string]bool{},
+		queues:   map[string]*memoryQueue{},
+	}
+}
+
+// queue returns the queue of a user, declaring it on first use
+func (b *MemoryBroker) queue(userId string) *memoryQueue {
+	queue, ok := b.queues[userId]
+	if !ok {
+		queue = &memoryQueue{notify: make(chan struct{}, 1)}
+		b.queues[userId] = queue
+	}
+	return queue
+}
+
+func (q *memoryQueue) push(messages ...*memoryMessage) {
+	q.ready = append(q.ready, messages...)
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pop removes the first message that has not expired
+func (q *memoryQueue) pop(now time.Time) (*memoryMessage, bool) {
+	for len(q.ready) > 0 {
+		message := q.ready[0]
+		q.ready = q.ready[1:]
+		if message.expiresAt.IsZero() || message.expiresAt.After(now) {
+			return message, true
+		}
+	}
+	return nil, false
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, userId string, body []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.queue(userId).push(&memoryMessage{body: body})
+	return nil
+}
+
+func (b *MemoryBroker) PublishToConversation(ctx context.Context, conversationId string, body []byte) error {
+	return b.publishToKey(ConversationRoutingKey(conversationId), &memoryMessage{body: body})
+}
+
+func (b *MemoryBroker) PublishEphemeral(ctx context.Context, routingKey string, body []byte, ttl time.Duration) error {
+	return b.publishToKey(routingKey, &memoryMessage{body: body, expiresAt: time.Now().Add(ttl)})
+}
+
+// publishToKey copies a message to every queue bound to routingKey. Like an
+// exchange, it drops messages for user ids whose queue was never declared.
+func (b *MemoryBroker) publishToKey(routingKey string, message *memoryMessage) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !isBindingKey(routingKey) {
+		if queue, ok := b.queues[routingKey]; ok {
+			queue.push(message)
+		}
+		return nil
+	}
+	for userId := range b.bindings[routingKey] {
+		b.queue(userId).push(message)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) bind(userId, routingKey string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.queue(userId)
+	if b.bindings[routingKey] == nil {
+		b.bindings[routingKey] = map[string]bool{}
+	}
+	b.bindings[routingKey][userId] = true
+	return nil
+}
+
+func (b *MemoryBroker) unbind(userId, routingKey string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.bindings[routingKey], userId)
+	if len(b.bindings[routingKey]) == 0 {
+		delete(b.bindings, routingKey)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) BindConversation(userId, conversationId string) error {
+	return b.bind(userId, ConversationRoutingKey(conversationId))
+}
+
+func (b *MemoryBroker) UnbindConversation(userId, conversationId string) error {
+	return b.unbind(userId, ConversationRoutingKey(conversationId))
+}
+
+func (b *MemoryBroker) BindPresence(userId, targetId string) error {
+	return b.bind(userId, PresenceRoutingKey(targetId))
+}
+
+func (b *MemoryBroker) UnbindPresence(userId, targetId string) error {
+	return b.unbind(userId, PresenceRoutingKey(targetId))
+}
+
+// State is always connected, the queues live in this process
+func (b *MemoryBroker) State(ctx context.Context) string {
+	return constants.BROKER_STATE_CONNECTED
+}
+
+func (b *MemoryBroker) Close() error {
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(userId string) (Subscription, error) {
+	b.mu.Lock()
+	queue := b.queue(userId)
+	b.mu.Unlock()
+
+	subscription := &memorySubscription{
+		broker:     b,
+		queue:      queue,
+		deliveries: make(chan Delivery),
+		done:       make(chan struct{}),
+	}
+	go subscription.run()
+	return subscription, nil
+}
+
+type memorySubscription struct {
+	broker     *MemoryBroker
+	queue      *memoryQueue
+	deliveries chan Delivery
+	// Messages handed out and not acknowledged yet, oldest first, guarded by broker.mu
+	unacked   []*memoryMessage
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (s *memorySubscription) run() {
+	defer close(s.deliveries)
+	for {
+		s.broker.mu.Lock()
+		message, ok := s.queue.pop(time.Now())
+		if ok {
+			s.unacked = append(s.unacked, message)
+		}
+		s.broker.mu.Unlock()
+
+		if !ok {
+			select {
+			case <-s.done:
+				return
+			case <-s.queue.notify:
+			}
+			continue
+		}
+
+		delivery := Delivery{Body: message.body, ack: func() error {
+			s.broker.mu.Lock()
+			defer s.broker.mu.Unlock()
+			s.unacked = slices.DeleteFunc(s.unacked, func(m *memoryMessage) bool { return m == message })
+			return nil
+		}}
+		select {
+		case <-s.done:
+			return
+		case s.deliveries <- delivery:
+		}
+	}
+}
+
+func (s *memorySubscription) Deliveries() <-chan Delivery {
+	return s.deliveries
+}
+
+// Recovered is nil, bindings live as long as the broker
+func (s *memorySubscription) Recovered() <-chan struct{} {
+	return nil
+}
+
+// Close puts unacknowledged messages back at the front of the queue
+func (s *memorySubscription) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		for range s.deliveries {
+		}
+
+		s.broker.mu.Lock()
+		defer s.broker.mu.Unlock()
+		if len(s.unacked) > 0 {
+			s.queue.ready = append(s.unacked, s.queue.ready...)
+			s.unacked = nil
+			s.queue.push()
+		}
+	})
+	return nil
+}
@@ -0,0 +1,183 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	amqpConfig "github.com/nihal-ramaswamy/GoChat/internal/amqp"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+// RabbitMqBroker routes messages through a topic exchange with a durable
+// queue per user. Publishes fail while the connection is being recovered and
+// subscriptions resume consuming once it is back.
+type RabbitMqBroker struct {
+	config *amqpConfig.AmqpConfig
+	log    *zap.Logger
+}
+
+func NewRabbitMqBroker(config *amqpConfig.AmqpConfig, log *zap.Logger) *RabbitMqBroker {
+	return &RabbitMqBroker{config: config, log: log}
+}
+
+func (b *RabbitMqBroker) Publish(ctx context.Context, userId string, body []byte) error {
+	return b.config.Publish(ctx, userId, body)
+}
+
+func (b *RabbitMqBroker) PublishToConversation(ctx context.Context, conversationId string, body []byte) error {
+	return b.config.PublishToBinding(ctx, ConversationRoutingKey(conversationId), body)
+}
+
+func (b *RabbitMqBroker) PublishEphemeral(ctx context.Context, routingKey string, body []byte, ttl time.Duration) error {
+	return b.config.PublishEphemeral(ctx, routingKey, body, ttl)
+}
+
+func (b *RabbitMqBroker) BindConversation(userId, conversationId string) error {
+	return b.config.Bind(userId, ConversationRoutingKey(conversationId))
+}
+
+func (b *RabbitMqBroker) UnbindConversation(userId, conversationId string) error {
+	return b.config.Unbind(userId, ConversationRoutingKey(conversationId))
+}
+
+func (b *RabbitMqBroker) BindPresence(userId, targetId string) error {
+	return b.config.Bind(userId, PresenceRoutingKey(targetId))
+}
+
+func (b *RabbitMqBroker) UnbindPresence(userId, targetId string) error {
+	return b.config.Unbind(userId, PresenceRoutingKey(targetId))
+}
+
+func (b *RabbitMqBroker) State(ctx context.Context) string {
+	return b.config.State()
+}
+
+func (b *RabbitMqBroker) Close() error {
+	return b.config.Close()
+}
+
+func (b *RabbitMqBroker) Subscribe(userId string) (Subscription, error) {
+	ch, msgs, err := b.config.Consume(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription := &rabbitMqSubscription{
+		broker:     b,
+		userId:     userId,
+		ch:         ch,
+		deliveries: make(chan Delivery),
+		recovered:  make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	go subscription.run(msgs)
+	return subscription, nil
+}
+
+type rabbitMqSubscription struct {
+	broker     *RabbitMqBroker
+	userId     string
+	deliveries chan Delivery
+	recovered  chan struct{}
+	done       chan struct{}
+	closeOnce  sync.Once
+
+	mu sync.Mutex
+	// The channel of the current consumer, replaced when it is re-established
+	ch *amqp.Channel
+}
+
+// run forwards deliveries. When the consumer channel closes because the
+// connection was lost, it consumes again once the connection is recovered;
+// unacknowledged messages were requeued by RabbitMQ and are delivered again.
+func (s *rabbitMqSubscription) run(msgs <-chan amqp.Delivery) {
+	defer close(s.deliveries)
+	config, log := s.broker.config, s.broker.log
+
+	for {
+		for d := range msgs {
+			select {
+			case <-s.done:
+				return
+			case s.deliveries <- Delivery{Body: d.Body, ack: func() error { return d.Ack(false) }}:
+			}
+		}
+
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-config.Done():
+				return
+			case <-config.Ready():
+			}
+
+			ch, next, err := config.Consume(s.userId)
+			if err == nil {
+				if !s.setChannel(ch) {
+					return
+				}
+				msgs = next
+				s.notifyRecovered()
+				break
+			}
+			// The connection can drop again before the consumer is set up
+			log.Warn("Error consuming queue, retrying", zap.String("user_id", s.userId), zap.Error(err))
+			select {
+			case <-s.done:
+				return
+			case <-time.After(constants.AMQP_RECONNECT_BASE):
+			}
+		}
+	}
+}
+
+// setChannel replaces the consumer channel. It returns false and closes ch if
+// the subscription was closed meanwhile.
+func (s *rabbitMqSubscription) setChannel(ch *amqp.Channel) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.done:
+		ch.Close()
+		return false
+	default:
+	}
+	s.ch = ch
+	return true
+}
+
+// notifyRecovered signals Recovered without blocking; a pending signal
+// already covers this recovery
+func (s *rabbitMqSubscription) notifyRecovered() {
+	select {
+	case s.recovered <- struct{}{}:
+	default:
+	}
+}
+
+func (s *rabbitMqSubscription) Deliveries() <-chan Delivery {
+	return s.deliveries
+}
+
+func (s *rabbitMqSubscription) Recovered() <-chan struct{} {
+	return s.recovered
+}
+
+// Close closes the channel, which requeues unacknowledged messages
+func (s *rabbitMqSubscription) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		close(s.done)
+		if closeErr := s.ch.Close(); closeErr != nil && !errors.Is(closeErr, amqp.ErrClosed) {
+			err = closeErr
+		}
+		s.mu.Unlock()
+	})
+	return err
+}
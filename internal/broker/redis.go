@@ -0,0 +1,383 @@
+package broker
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	redisBodyField      = "body"
+	redisExpiresAtField = "expires_at"
+)
+
+// RedisBroker keeps the queue of every user in a Redis stream read through a
+// consumer group, so instances sharing a Redis deployment share queues.
+// Bindings are Redis sets and publishing to a routing key appends the message
+// to the stream of every user bound to it.
+type RedisBroker struct {
+	rdb *redis.Client
+	log *zap.Logger
+}
+
+func NewRedisBroker(rdb *redis.Client, log *zap.Logger) *RedisBroker {
+	return &RedisBroker{rdb: rdb, log: log}
+}
+
+func streamKey(userId string) string {
+	return constants.BROKER_STREAM_PREFIX + userId
+}
+
+func bindingKey(routingKey string) string {
+	return constants.BROKER_BINDING_PREFIX + routingKey
+}
+
+// declare creates the stream and consumer group of a user. Declaring is
+// idempotent, so the queue is created on first use and reused afterwards.
+func (b *RedisBroker) declare(ctx context.Context, userId string) error {
+	err := b.rdb.XGroupCreateMkStream(ctx, streamKey(userId), constants.BROKER_CONSUMER_GROUP, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, userId string, body []byte) error {
+	if err := b.declare(ctx, userId); err != nil {
+		return err
+	}
+	return b.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: streamKey(userId),
+		Values: map[string]any{redisBodyField: body},
+	}).Err()
+}
+
+func (b *RedisBroker) PublishToConversation(ctx context.Context, conversationId string, body []byte) error {
+	return b.publishToKey(ctx, ConversationRoutingKey(conversationId), map[string]any{redisBodyField: body})
+}
+
+func (b *RedisBroker) PublishEphemeral(ctx context.Context, routingKey string, body []byte, ttl time.Duration) error {
+	return b.publishToKey(ctx, routingKey, map[string]any{
+		redisBodyField:      body,
+		redisExpiresAtField: time.Now().Add(ttl).UnixMilli(),
+	})
+}
+
+// publishToKey appends a message to the stream of every user bound to
+// routingKey. Like an exchange, it drops messages for user ids whose stream
+// was never declared.
+func (b *RedisBroker) publishToKey(ctx context.Context, routingKey string, values map[string]any) error {
+	userIds := []string{routingKey}
+	if isBindingKey(routingKey) {
+		var err error
+		if userIds, err = b.rdb.SMembers(ctx, bindingKey(routingKey)).Result(); err != nil {
+			return err
+		}
+	} else if exists, err := b.rdb.Exists(ctx, streamKey(routingKey)).Result(); err != nil || exists == 0 {
+		return err
+	}
+	if len(userIds) == 0 {
+		return nil
+	}
+
+	pipe := b.rdb.Pipeline()
+	for _, userId := range userIds {
+		pipe.XAdd(ctx, &redis.XAddArgs{Stream: streamKey(userId), Values: values})
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (b *RedisBroker) bind(userId, routingKey string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := b.declare(ctx, userId); err != nil {
+		return err
+	}
+	return b.rdb.SAdd(ctx, bindingKey(routingKey), userId).Err()
+}
+
+func (b *RedisBroker) unbind(userId, routingKey string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return b.rdb.SRem(ctx, bindingKey(routingKey), userId).Err()
+}
+
+func (b *RedisBroker) BindConversation(userId, conversationId string) error {
+	return b.bind(userId, ConversationRoutingKey(conversationId))
+}
+
+func (b *RedisBroker) UnbindConversation(userId, conversationId string) error {
+	return b.unbind(userId, ConversationRoutingKey(conversationId))
+}
+
+func (b *RedisBroker) BindPresence(userId, targetId string) error {
+	return b.bind(userId, PresenceRoutingKey(targetId))
+}
+
+func (b *RedisBroker) UnbindPresence(userId, targetId string) error {
+	return b.unbind(userId, PresenceRoutingKey(targetId))
+}
+
+// State pings Redis. The client redials on its own, so a failed ping means it
+// is reconnecting.
+func (b *RedisBroker) State(ctx context.Context) string {
+	if err := b.rdb.Ping(ctx).Err(); err != nil {
+		return constants.BROKER_STATE_RECONNECTING
+	}
+	return constants.BROKER_STATE_CONNECTED
+}
+
+// Close does nothing, the Redis client is shared with presence
+func (b *RedisBroker) Close() error {
+	return nil
+}
+
+func newConsumerName() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
+
+// Subscribe reads the stream of a user as a new consumer of its group. It
+// first claims entries that another consumer read but has not acknowledged
+// for BROKER_CLAIM_IDLE, which belong to an instance that crashed.
+func (b *RedisBroker) Subscribe(userId string) (Subscription, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := b.declare(ctx, userId); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	subscription := &redisSubscription{
+		broker:     b,
+		stream:     streamKey(userId),
+		consumer:   newConsumerName(),
+		ctx:        ctx,
+		cancel:     cancel,
+		deliveries: make(chan Delivery),
+		unacked:    map[string]redis.XMessage{},
+	}
+	go subscription.run()
+	return subscription, nil
+}
+
+type redisSubscription struct {
+	broker     *RedisBroker
+	stream     string
+	consumer   string
+	ctx        context.Context
+	cancel     context.CancelFunc
+	deliveries chan Delivery
+
+	mu sync.Mutex
+	// Entries handed out and not acknowledged yet
+	unacked map[string]redis.XMessage
+}
+
+func (s *redisSubscription) run() {
+	defer close(s.deliveries)
+	defer s.requeue()
+	rdb, log := s.broker.rdb, s.broker.log
+
+	start := "0-0"
+	for {
+		claimed, next, err := rdb.XAutoClaim(s.ctx, &redis.XAutoClaimArgs{
+			Stream:   s.stream,
+			Group:    constants.BROKER_CONSUMER_GROUP,
+			Consumer: s.consumer,
+			MinIdle:  constants.BROKER_CLAIM_IDLE,
+			Start:    start,
+			Count:    100,
+		}).Result()
+		if err != nil {
+			if s.ctx.Err() == nil {
+				log.Error("Error claiming pending messages", zap.Error(err))
+			}
+			break
+		}
+		if !s.deliver(claimed) {
+			return
+		}
+		if next == "0-0" {
+			break
+		}
+		start = next
+	}
+
+	for {
+		streams, err := rdb.XReadGroup(s.ctx, &redis.XReadGroupArgs{
+			Group:    constants.BROKER_CONSUMER_GROUP,
+			Consumer: s.consumer,
+			Streams:  []string{s.stream, ">"},
+			Count:    100,
+			Block:    constants.BROKER_READ_BLOCK,
+		}).Result()
+		if s.ctx.Err() != nil {
+			// A read that was blocked when the subscription closed can still
+			// return entries, which are pending for this consumer now
+			for _, stream := range streams {
+				s.track(stream.Messages)
+			}
+			return
+		}
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			log.Error("Error reading stream", zap.Error(err))
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		for _, stream := range streams {
+			if !s.deliver(stream.Messages) {
+				return
+			}
+		}
+	}
+}
+
+// deliver hands messages to the consumer, acknowledging expired ones right
+// away. It returns false once the subscription is closed.
+func (s *redisSubscription) deliver(messages []redis.XMessage) bool {
+	// The whole batch is pending for this consumer, so it must be requeued
+	// even if the subscription closes before reaching every message
+	s.track(messages)
+
+	for _, message := range messages {
+		if expired(message, time.Now()) {
+			if err := s.ack(message.ID); err != nil {
+				s.broker.log.Error("Error dropping expired message", zap.Error(err))
+			}
+			continue
+		}
+
+		body, _ := message.Values[redisBodyField].(string)
+		id := message.ID
+		select {
+		case <-s.ctx.Done():
+			return false
+		case s.deliveries <- Delivery{Body: []byte(body), ack: func() error { return s.ack(id) }}:
+		}
+	}
+	return true
+}
+
+func (s *redisSubscription) track(messages []redis.XMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, message := range messages {
+		s.unacked[message.ID] = message
+	}
+}
+
+func expired(message redis.XMessage, now time.Time) bool {
+	value, ok := message.Values[redisExpiresAtField].(string)
+	if !ok {
+		return false
+	}
+	expiresAt, err := strconv.ParseInt(value, 10, 64)
+	return err == nil && now.UnixMilli() >= expiresAt
+}
+
+// ack acknowledges and deletes an entry. The stream has a single group, so
+// acknowledged entries are never read again.
+func (s *redisSubscription) ack(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pipe := s.broker.rdb.TxPipeline()
+	pipe.XAck(ctx, s.stream, constants.BROKER_CONSUMER_GROUP, id)
+	pipe.XDel(ctx, s.stream, id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.unacked, id)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *redisSubscription) Deliveries() <-chan Delivery {
+	return s.deliveries
+}
+
+// Recovered is nil, bindings are kept in Redis
+func (s *redisSubscription) Recovered() <-chan struct{} {
+	return nil
+}
+
+// Close stops reading the stream. Reads block for up to BROKER_READ_BLOCK,
+// so the deliveries channel may close a little later.
+func (s *redisSubscription) Close() error {
+	s.cancel()
+	return nil
+}
+
+// requeue appends unacknowledged entries to the end of the stream again and
+// acknowledges the originals, so the next subscriber reads them without
+// waiting for BROKER_CLAIM_IDLE
+func (s *redisSubscription) requeue() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.unacked) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ids := make([]string, 0, len(s.unacked))
+	for id := range s.unacked {
+		ids = append(ids, id)
+	}
+	// Stream ids sort by time, so requeued entries keep their relative order
+	slices.SortFunc(ids, compareStreamIds)
+
+	pipe := s.broker.rdb.TxPipeline()
+	for _, id := range ids {
+		pipe.XAdd(ctx, &redis.XAddArgs{Stream: s.stream, Values: s.unacked[id].Values})
+	}
+	pipe.XAck(ctx, s.stream, constants.BROKER_CONSUMER_GROUP, ids...)
+	pipe.XDel(ctx, s.stream, ids...)
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.broker.log.Error("Error requeueing messages", zap.Error(err))
+	}
+	s.unacked = map[string]redis.XMessage{}
+}
+
+// compareStreamIds orders stream entry ids of the form <ms>-<seq>
+func compareStreamIds(a, b string) int {
+	parse := func(id string) (int64, int64) {
+		ms, seq, _ := strings.Cut(id, "-")
+		m, _ := strconv.ParseInt(ms, 10, 64)
+		s, _ := strconv.ParseInt(seq, 10, 64)
+		return m, s
+	}
+	am, as := parse(a)
+	bm, bs := parse(b)
+	if am != bm {
+		return cmp.Compare(am, bm)
+	}
+	return cmp.Compare(as, bs)
+}
@@ -1,5 +1,15 @@
 package constants
 
+import "time"
+
 const (
-	EXCHANGE_NAME = "chat"
+	EXCHANGE_NAME     = "chat"
+	USER_QUEUE_PREFIX = "chat.user."
+
+	// A lost connection is redialed after AMQP_RECONNECT_BASE, doubling up to AMQP_RECONNECT_MAX
+	AMQP_RECONNECT_BASE = time.Second
+	AMQP_RECONNECT_MAX  = 30 * time.Second
+
+	// Persistent publishes wait for their confirmations on up to this many channels at once
+	AMQP_CONFIRM_CHANNELS = 8
 )
@@ -0,0 +1,21 @@
+package constants
+
+import "time"
+
+const (
+	USER_TYPE_HUMAN = "human"
+	USER_TYPE_BOT   = "bot"
+
+	// Bots cannot receive mail, so they get an address on a reserved domain
+	BOT_EMAIL_DOMAIN = "bots.gochat.invalid"
+
+	// Prefix that tells API keys apart from access tokens
+	API_KEY_PREFIX = "gck_"
+	// LAST_USED_AT of a key is written at most this often
+	API_KEY_LAST_USED_INTERVAL = time.Minute
+
+	SCOPE_CHAT_SEND = "chat:send"
+	SCOPE_CHAT_READ = "chat:read"
+)
+
+var API_KEY_SCOPES = []string{SCOPE_CHAT_SEND, SCOPE_CHAT_READ}
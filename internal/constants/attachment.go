@@ -0,0 +1,31 @@
+package constants
+
+const (
+	STORAGE_BACKEND_LOCAL      = "local"
+	STORAGE_BACKEND_S3         = "s3"
+	STORAGE_LOCAL_ROOT_DEFAULT = "data/attachments"
+
+	ATTACHMENT_FORM_FIELD = "file"
+	ATTACHMENT_URL_PREFIX = "/chat/attachment/"
+	ATTACHMENT_MAX_SIZE   = 25 << 20
+	// Extra room for the multipart boundaries and headers around the file
+	ATTACHMENT_FORM_OVERHEAD = 1 << 20
+	// Number of bytes sniffed to detect the content type of an upload
+	ATTACHMENT_SNIFF_SIZE   = 512
+	ATTACHMENT_MAX_PER_CHAT = 10
+)
+
+// ATTACHMENT_CONTENT_TYPES lists the content types accepted for upload
+var ATTACHMENT_CONTENT_TYPES = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"application/pdf",
+	"application/zip",
+	"text/plain",
+	"audio/mpeg",
+	"audio/wave",
+	"video/mp4",
+	"video/webm",
+}
@@ -0,0 +1,27 @@
+package constants
+
+import "time"
+
+const (
+	BROKER_BACKEND_RABBITMQ = "rabbitmq"
+	BROKER_BACKEND_MEMORY   = "memory"
+	BROKER_BACKEND_REDIS    = "redis"
+
+	// Connection states reported by Broker.State. Only connected brokers
+	// deliver messages.
+	BROKER_STATE_CONNECTED    = "connected"
+	BROKER_STATE_RECONNECTING = "reconnecting"
+	BROKER_STATE_CLOSED       = "closed"
+
+	// Redis Streams broker keys. Every user has a stream and every routing key
+	// a set of the users bound to it.
+	BROKER_STREAM_PREFIX  = "broker:queue:"
+	BROKER_BINDING_PREFIX = "broker:binding:"
+	BROKER_CONSUMER_GROUP = "deliver"
+	// Entries a consumer read but did not acknowledge for this long belong to
+	// a crashed instance and are claimed by the next subscriber
+	BROKER_CLAIM_IDLE = time.Minute
+	// How long a read waits for new entries, and so how long a closed
+	// subscription can take to stop
+	BROKER_READ_BLOCK = time.Second
+)
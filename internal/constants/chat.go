@@ -0,0 +1,25 @@
+package constants
+
+const (
+	CHAT_PAGE_DEFAULT_LIMIT = 50
+	CHAT_PAGE_MAX_LIMIT     = 200
+)
+
+// Scopes of DELETE /chat/message/:id
+const (
+	DELETE_FOR_ME       = "me"
+	DELETE_FOR_EVERYONE = "everyone"
+)
+
+// Full-text search over chat messages. SEARCH_CONFIG must match the text
+// search configuration of the SEARCH_VECTOR column in the migrations.
+const (
+	SEARCH_CONFIG          = "english"
+	SEARCH_HIGHLIGHT_START = "<mark>"
+	SEARCH_HIGHLIGHT_STOP  = "</mark>"
+	SEARCH_MAX_FRAGMENTS   = 2
+	// Control characters ts_headline marks matches with before the snippet is
+	// HTML-escaped; they are stripped from the message first
+	SEARCH_HEADLINE_START = "\x02"
+	SEARCH_HEADLINE_STOP  = "\x03"
+)
@@ -0,0 +1,8 @@
+package constants
+
+const (
+	CONVERSATION_DIRECT = "direct"
+	CONVERSATION_GROUP  = "group"
+
+	CONVERSATION_ROUTING_PREFIX = "conversation."
+)
@@ -5,6 +5,8 @@ const (
 	SERVER_PORT   = "SERVER_PORT"
 	SERVER_HOST   = "SERVER_HOST"
 	ENV           = "ENV"
+	// Comma separated addresses or CIDR ranges of reverse proxies in front of the server
+	TRUSTED_PROXIES = "TRUSTED_PROXIES"
 
 	REDIS_HOST     = "REDIS_HOST"
 	REDIS_PORT     = "REDIS_PORT"
@@ -16,5 +18,40 @@ const (
 	POSTGRES_PASSWORD = "POSTGRES_PASSWORD"
 	POSTGRES_NAME     = "POSTGRES_NAME"
 
+	// Set to false to only run migrations with the migrate subcommand
+	DB_MIGRATE_ON_START = "DB_MIGRATE_ON_START"
+
 	STUN_SERVERS = "STUN_SERVERS"
+
+	BROKER_BACKEND = "BROKER_BACKEND"
+
+	STORAGE_BACKEND    = "STORAGE_BACKEND"
+	STORAGE_LOCAL_ROOT = "STORAGE_LOCAL_ROOT"
+	S3_ENDPOINT        = "S3_ENDPOINT"
+	S3_ACCESS_KEY      = "S3_ACCESS_KEY"
+	S3_SECRET_KEY      = "S3_SECRET_KEY"
+	S3_BUCKET          = "S3_BUCKET"
+	S3_REGION          = "S3_REGION"
+	S3_USE_SSL         = "S3_USE_SSL"
+
+	MAILER_BACKEND = "MAILER_BACKEND"
+	MAILER_DIR     = "MAILER_DIR"
+	MAIL_FROM      = "MAIL_FROM"
+	SMTP_HOST      = "SMTP_HOST"
+	SMTP_PORT      = "SMTP_PORT"
+	SMTP_USERNAME  = "SMTP_USERNAME"
+	SMTP_PASSWORD  = "SMTP_PASSWORD"
+
+	REQUIRE_EMAIL_VERIFICATION = "REQUIRE_EMAIL_VERIFICATION"
+
+	JWT_KEYS_DIR       = "JWT_KEYS_DIR"
+	JWT_SIGNING_KEY_ID = "JWT_SIGNING_KEY_ID"
+	JWT_ISSUER         = "JWT_ISSUER"
+	JWT_AUDIENCE       = "JWT_AUDIENCE"
+
+	OIDC_ISSUER        = "OIDC_ISSUER"
+	OIDC_CLIENT_ID     = "OIDC_CLIENT_ID"
+	OIDC_CLIENT_SECRET = "OIDC_CLIENT_SECRET"
+	OIDC_REDIRECT_URL  = "OIDC_REDIRECT_URL"
+	OIDC_SCOPES        = "OIDC_SCOPES"
 )
@@ -3,6 +3,11 @@ package constants
 import "time"
 
 const (
-	BEARER            = "Bearer "
-	TOKEN_EXPIRY_TIME = time.Hour * 24 * 7
+	BEARER = "Bearer "
+
+	// Access tokens are short lived; clients use their refresh token to get a new one
+	ACCESS_TOKEN_EXPIRY_TIME  = 15 * time.Minute
+	REFRESH_TOKEN_EXPIRY_TIME = time.Hour * 24 * 30
+
+	JWT_DEFAULT_AUDIENCE = "gochat"
 )
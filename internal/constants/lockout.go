@@ -0,0 +1,18 @@
+package constants
+
+import "time"
+
+const (
+	LOGIN_FAILURES_KEY_PREFIX = "login_failures:"
+	LOGIN_LOCK_KEY_PREFIX     = "login_lock:"
+
+	// Failed attempts are forgotten once none happened for this long
+	LOGIN_FAILURE_WINDOW = 15 * time.Minute
+	// Failed attempts allowed before an account or address is locked
+	LOGIN_ACCOUNT_MAX_FAILURES = 5
+	LOGIN_IP_MAX_FAILURES      = 20
+	// The first lockout lasts LOGIN_LOCKOUT_BASE and doubles with every
+	// further failure up to LOGIN_LOCKOUT_MAX
+	LOGIN_LOCKOUT_BASE = 30 * time.Second
+	LOGIN_LOCKOUT_MAX  = 15 * time.Minute
+)
@@ -0,0 +1,20 @@
+package constants
+
+import "time"
+
+const (
+	MAILER_BACKEND_SMTP = "smtp"
+	MAILER_BACKEND_FILE = "file"
+
+	MAIL_TOKEN_KEY_PREFIX = "mail_token:"
+
+	// Purposes of single use tokens sent by mail
+	MAIL_TOKEN_VERIFY_EMAIL   = "verify_email"
+	MAIL_TOKEN_RESET_PASSWORD = "reset_password"
+
+	VERIFY_EMAIL_TOKEN_TTL   = 24 * time.Hour
+	RESET_PASSWORD_TOKEN_TTL = time.Hour
+
+	// How long mails sent in the background may take
+	MAIL_SEND_TIMEOUT = 30 * time.Second
+)
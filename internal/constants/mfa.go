@@ -0,0 +1,25 @@
+package constants
+
+import "time"
+
+const (
+	MFA_ISSUER = "GoChat"
+
+	// RFC 6238 parameters understood by every common authenticator app
+	TOTP_PERIOD = 30 * time.Second
+	TOTP_DIGITS = 6
+	// Number of periods before and after the current one a code is accepted in
+	TOTP_SKEW = 1
+
+	TOTP_USED_KEY_PREFIX    = "totp_used:"
+	MFA_PENDING_KEY_PREFIX  = "mfa_pending:"
+	MFA_PENDING_TTL         = 5 * time.Minute
+	MFA_MAX_ATTEMPTS        = 5
+	RECOVERY_CODE_COUNT     = 10
+	RECOVERY_CODE_BYTE_SIZE = 5
+
+	// Signed in users confirming or disabling two-factor authentication are
+	// blocked for the rest of the window after MFA_MAX_ATTEMPTS wrong codes
+	MFA_FAILURES_KEY_PREFIX = "mfa_failures:"
+	MFA_FAILURE_WINDOW      = 15 * time.Minute
+)
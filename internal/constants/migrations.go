@@ -0,0 +1,7 @@
+package constants
+
+const (
+	// Key of the Postgres advisory lock held while migrating, so instances
+	// starting together do not apply the same migration twice
+	MIGRATIONS_LOCK_KEY = 7_311_652_904
+)
@@ -0,0 +1,16 @@
+package constants
+
+import "time"
+
+const (
+	OIDC_STATE_KEY_PREFIX = "oidc_state:"
+	// Time a user has to log in at the identity provider
+	OIDC_STATE_TTL = 10 * time.Minute
+
+	// Cookie tying a login to the browser that started it
+	OIDC_STATE_COOKIE = "oidc_state"
+
+	OIDC_DEFAULT_SCOPES  = "openid email profile"
+	OIDC_CALLBACK_PATH   = "/auth/oidc/callback"
+	OIDC_DISCOVERY_LIMIT = 10 * time.Second
+)
@@ -0,0 +1,21 @@
+package constants
+
+import "time"
+
+const (
+	// The relay polls for pending events this often, and right away when a
+	// chat is saved on this instance
+	OUTBOX_POLL_INTERVAL = time.Second
+	OUTBOX_BATCH_SIZE    = 100
+	// A claimed event is published again if it is not marked sent or failed
+	// within OUTBOX_LEASE, so a crashed relay does not lose it
+	OUTBOX_LEASE = 30 * time.Second
+	// Failed publishes are retried after OUTBOX_RETRY_BASE, doubling up to OUTBOX_RETRY_MAX
+	OUTBOX_RETRY_BASE = time.Second
+	OUTBOX_RETRY_MAX  = 5 * time.Minute
+	// Sent events are kept this long before they are deleted
+	OUTBOX_RETENTION = 24 * time.Hour
+
+	// Number of recent dedup keys a websocket connection remembers
+	OUTBOX_DEDUP_WINDOW = 1000
+)
@@ -0,0 +1,27 @@
+package constants
+
+import "time"
+
+const (
+	PRESENCE_KEY_PREFIX       = "presence:"
+	PRESENCE_LAST_SEEN_PREFIX = "last_seen:"
+	PRESENCE_ROUTING_PREFIX   = "presence."
+	PRESENCE_ONLINE_KEY       = "presence_online"
+
+	// Counts the connections of a user following each user whose presence
+	// their queue is bound to
+	PRESENCE_SUBSCRIPTIONS_PREFIX = "presence_subscriptions:"
+
+	// A connection expires unless the websocket refreshes it, so users of a
+	// crashed instance are swept offline
+	PRESENCE_TTL       = 60 * time.Second
+	PRESENCE_HEARTBEAT = 20 * time.Second
+	PRESENCE_EVENT_TTL = 60 * time.Second
+
+	// How often expired connections are looked for, and how many users are
+	// marked offline at a time
+	PRESENCE_SWEEP_INTERVAL = 10 * time.Second
+	PRESENCE_SWEEP_BATCH    = 100
+
+	PRESENCE_MAX_SUBSCRIPTIONS = 500
+)
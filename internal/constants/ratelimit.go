@@ -0,0 +1,13 @@
+package constants
+
+const (
+	RATE_LIMIT_KEY_PREFIX = "rate_limit:"
+
+	// Requests per minute from one client address to any route
+	RATE_LIMIT_DEFAULT_RATE  = 600
+	RATE_LIMIT_DEFAULT_BURST = 100
+
+	// Chats per minute one user can send over HTTP and websockets combined
+	RATE_LIMIT_CHAT_SEND_RATE  = 60
+	RATE_LIMIT_CHAT_SEND_BURST = 20
+)
@@ -0,0 +1,8 @@
+package constants
+
+// Message status, in the only order a receipt can move through
+const (
+	RECEIPT_SENT      = "sent"
+	RECEIPT_DELIVERED = "delivered"
+	RECEIPT_READ      = "read"
+)
@@ -0,0 +1,32 @@
+package constants
+
+const (
+	ROLE_USER      = "user"
+	ROLE_MODERATOR = "moderator"
+	ROLE_ADMIN     = "admin"
+
+	PERMISSION_USERS_READ      = "users:read"
+	PERMISSION_USERS_DISABLE   = "users:disable"
+	PERMISSION_SESSIONS_REVOKE = "sessions:revoke"
+	PERMISSION_ROLES_WRITE     = "roles:write"
+
+	USER_PAGE_DEFAULT_LIMIT = 50
+	USER_PAGE_MAX_LIMIT     = 200
+)
+
+var ROLES = []string{ROLE_USER, ROLE_MODERATOR, ROLE_ADMIN}
+
+// ROLE_PERMISSIONS lists what each role may do. Users have no extra permissions.
+var ROLE_PERMISSIONS = map[string][]string{
+	ROLE_MODERATOR: {
+		PERMISSION_USERS_READ,
+		PERMISSION_USERS_DISABLE,
+		PERMISSION_SESSIONS_REVOKE,
+	},
+	ROLE_ADMIN: {
+		PERMISSION_USERS_READ,
+		PERMISSION_USERS_DISABLE,
+		PERMISSION_SESSIONS_REVOKE,
+		PERMISSION_ROLES_WRITE,
+	},
+}
@@ -1,6 +1,8 @@
 package constants
 
 const (
-	GET  = "GET"
-	POST = "POST"
+	GET    = "GET"
+	POST   = "POST"
+	PATCH  = "PATCH"
+	DELETE = "DELETE"
 )
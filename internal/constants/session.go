@@ -0,0 +1,7 @@
+package constants
+
+const (
+	SESSION_KEY_PREFIX      = "session:"
+	SESSION_USED_KEY_PREFIX = "session_used:"
+	USER_SESSIONS_PREFIX    = "user_sessions:"
+)
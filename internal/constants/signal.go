@@ -0,0 +1,12 @@
+package constants
+
+import "time"
+
+const (
+	SIGNAL_TYPING    = "typing"
+	SIGNAL_RECORDING = "recording"
+
+	// Peers drop a signal that was not refreshed within this time, so a
+	// dropped client does not leave them stuck on "typing…"
+	SIGNAL_TTL = 5 * time.Second
+)
@@ -0,0 +1,18 @@
+package constants
+
+// Websocket envelope types
+const (
+	WS_SEND    = "send"
+	WS_ACK     = "ack"
+	WS_ERROR   = "error"
+	WS_MESSAGE = "message"
+	WS_READ    = "read"
+	WS_RECEIPT = "receipt"
+	WS_SIGNAL  = "signal"
+	WS_EDIT    = "edit"
+	WS_DELETE  = "delete"
+
+	WS_PRESENCE             = "presence"
+	WS_PRESENCE_SUBSCRIBE   = "presence_subscribe"
+	WS_PRESENCE_UNSUBSCRIBE = "presence_unsubscribe"
+)
@@ -0,0 +1,933 @@
+package db
+
+import (
+	"cmp"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var errEmailTaken = errors.New("Email address already registered")
+
+type memoryRecoveryCode struct {
+	hash string
+	used bool
+}
+
+type memoryUser struct {
+	user          dto.User
+	passwordHash  string
+	emailVerified bool
+	hideLastSeen  bool
+	totpSecret    string
+	totpEnabled   bool
+	recoveryCodes []memoryRecoveryCode
+}
+
+type memoryOutbox struct {
+	message       dto.OutboxMessage
+	nextAttemptAt time.Time
+	sentAt        *time.Time
+	lastError     string
+}
+
+type memoryConversation struct {
+	conversation dto.Conversation
+	memberIds    []string
+}
+
+// MemoryRepository implements the repositories in memory with the same
+// semantics as PostgresRepository. Nothing is persisted, so it is meant for
+// tests and local development. Search approximates the Postgres text search
+// configuration by matching word prefixes.
+type MemoryRepository struct {
+	mu sync.Mutex
+
+	users      map[string]*memoryUser
+	emails     map[string]string
+	identities map[string]string
+
+	chats         map[string]*dto.Chat
+	edits         map[string][]dto.ChatEdit
+	hidden        map[string]map[string]bool
+	receipts      map[string]map[string]*dto.ChatReceipt
+	conversations map[string]*memoryConversation
+	attachments   map[string]*dto.Attachment
+	outbox        []*memoryOutbox
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		users:         map[string]*memoryUser{},
+		emails:        map[string]string{},
+		identities:    map[string]string{},
+		chats:         map[string]*dto.Chat{},
+		edits:         map[string][]dto.ChatEdit{},
+		hidden:        map[string]map[string]bool{},
+		receipts:      map[string]map[string]*dto.ChatReceipt{},
+		conversations: map[string]*memoryConversation{},
+		attachments:   map[string]*dto.Attachment{},
+	}
+}
+
+// newId returns a random version 4 UUID like the ids Postgres generates
+func newId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b)
+	return fmt.Sprintf("%s-%s-%s-%s-%s", h[0:8], h[8:12], h[12:16], h[16:20], h[20:32])
+}
+
+// hash uses the lowest bcrypt cost so tests stay fast. Hashes of any cost
+// compare the same way.
+func hash(password string) string {
+	b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+func (r *MemoryRepository) insertUser(user *dto.User, passwordHash string, emailVerified bool) error {
+	if _, ok := r.emails[user.Email]; ok {
+		return errEmailTaken
+	}
+	user.Id = newId()
+	user.Type = constants.USER_TYPE_HUMAN
+	user.Role = constants.ROLE_USER
+	r.users[user.Id] = &memoryUser{
+		user:          dto.User{Id: user.Id, Name: user.Name, Email: user.Email, Type: user.Type, Role: user.Role},
+		passwordHash:  passwordHash,
+		emailVerified: emailVerified,
+	}
+	r.emails[user.Email] = user.Id
+	return nil
+}
+
+func (r *MemoryRepository) userWithEmail(email string) (*memoryUser, bool) {
+	id, ok := r.emails[email]
+	if !ok {
+		return nil, false
+	}
+	return r.users[id], true
+}
+
+func (r *MemoryRepository) DoesEmailExist(email string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.emails[email]
+	return ok
+}
+
+func (r *MemoryRepository) DoesUserExist(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.users[id]
+	return ok
+}
+
+func (r *MemoryRepository) RegisterNewUser(user *dto.User) (string, error) {
+	user.Password = hash(user.Password)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := dto.User{Name: user.Name, Email: user.Email}
+	if err := r.insertUser(&stored, user.Password, false); err != nil {
+		return "", err
+	}
+	return stored.Id, nil
+}
+
+func (r *MemoryRepository) DoesPasswordMatch(user *dto.User) bool {
+	r.mu.Lock()
+	stored, ok := r.userWithEmail(user.Email)
+	passwordHash := string(unknownUserHash)
+	if ok {
+		passwordHash = stored.passwordHash
+	}
+	r.mu.Unlock()
+
+	match := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(user.Password)) == nil
+	return ok && match
+}
+
+func (r *MemoryRepository) GetUserFromEmail(email string) (dto.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.userWithEmail(email)
+	if !ok {
+		return dto.User{}, sql.ErrNoRows
+	}
+	return dto.User{Id: stored.user.Id, Name: stored.user.Name, Email: stored.user.Email, Type: stored.user.Type}, nil
+}
+
+func (r *MemoryRepository) GetActiveRole(id string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.users[id]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return stored.user.Role, nil
+}
+
+func (r *MemoryRepository) MarkEmailVerified(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stored, ok := r.users[id]; ok {
+		stored.emailVerified = true
+	}
+	return nil
+}
+
+func (r *MemoryRepository) IsEmailVerified(id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.users[id]
+	if !ok {
+		return false, sql.ErrNoRows
+	}
+	return stored.emailVerified, nil
+}
+
+func (r *MemoryRepository) SetHideLastSeen(id string, hide bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stored, ok := r.users[id]; ok {
+		stored.hideLastSeen = hide
+	}
+	return nil
+}
+
+func (r *MemoryRepository) IsLastSeenHidden(id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.users[id]
+	if !ok {
+		return false, sql.ErrNoRows
+	}
+	return stored.hideLastSeen, nil
+}
+
+func (r *MemoryRepository) ResetPassword(id, password string) error {
+	passwordHash := hash(password)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	stored.passwordHash = passwordHash
+	return nil
+}
+
+func (r *MemoryRepository) SetPendingTotpSecret(id, secret string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.users[id]
+	if !ok || stored.totpEnabled {
+		return sql.ErrNoRows
+	}
+	stored.totpSecret = secret
+	return nil
+}
+
+func (r *MemoryRepository) GetTotp(id string) (string, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.users[id]
+	if !ok {
+		return "", false, sql.ErrNoRows
+	}
+	return stored.totpSecret, stored.totpEnabled, nil
+}
+
+func (r *MemoryRepository) EnableTotp(id string, recoveryCodes []string) error {
+	codes := make([]memoryRecoveryCode, len(recoveryCodes))
+	for i, code := range recoveryCodes {
+		codes[i] = memoryRecoveryCode{hash: hash(code)}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.users[id]
+	if !ok || stored.totpSecret == "" || stored.totpEnabled {
+		return sql.ErrNoRows
+	}
+	stored.totpEnabled = true
+	stored.recoveryCodes = codes
+	return nil
+}
+
+func (r *MemoryRepository) DisableTotp(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stored, ok := r.users[id]; ok {
+		stored.totpEnabled = false
+		stored.totpSecret = ""
+		stored.recoveryCodes = nil
+	}
+	return nil
+}
+
+func (r *MemoryRepository) UseRecoveryCode(id, code string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.users[id]
+	if !ok {
+		return false, nil
+	}
+	for i := range stored.recoveryCodes {
+		recoveryCode := &stored.recoveryCodes[i]
+		if !recoveryCode.used && bcrypt.CompareHashAndPassword([]byte(recoveryCode.hash), []byte(code)) == nil {
+			recoveryCode.used = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *MemoryRepository) GetOrProvisionOidcUser(identity *dto.OidcIdentity) (dto.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := identity.Issuer + "\x00" + identity.Subject
+	if id, ok := r.identities[key]; ok {
+		stored := r.users[id]
+		return dto.User{Id: stored.user.Id, Name: stored.user.Name, Email: stored.user.Email}, nil
+	}
+
+	var user dto.User
+	stored, ok := r.userWithEmail(identity.Email)
+	switch {
+	case !ok:
+		password := make([]byte, 32)
+		if _, err := rand.Read(password); err != nil {
+			return dto.User{}, err
+		}
+		user = dto.User{Name: identity.Name, Email: identity.Email}
+		if err := r.insertUser(&user, hash(hex.EncodeToString(password)), identity.EmailVerified); err != nil {
+			return dto.User{}, err
+		}
+		user = dto.User{Id: user.Id, Name: user.Name, Email: user.Email}
+	case !identity.EmailVerified:
+		return dto.User{}, ErrIdentityEmailTaken
+	default:
+		stored.emailVerified = true
+		user = dto.User{Id: stored.user.Id, Name: stored.user.Name, Email: stored.user.Email}
+	}
+
+	r.identities[key] = user.Id
+	return user, nil
+}
+
+// compareChats orders chats by CreatedAt and then Id, like the history cursors
+func compareChats(a, b *dto.Chat) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Id, b.Id)
+}
+
+func (r *MemoryRepository) isHidden(chatId, userId string) bool {
+	return r.hidden[chatId][userId]
+}
+
+// copyChat returns a copy of a stored chat without its attachments
+// isVisible reports whether the user sent or received the chat, including
+// chats posted to the conversations they are a member of
+func (r *MemoryRepository) isVisible(chat *dto.Chat, userId string) bool {
+	if chat.SenderId == userId || chat.ReceiverId == userId {
+		return true
+	}
+	stored, ok := r.conversations[chat.ConversationId]
+	return ok && slices.Contains(stored.memberIds, userId)
+}
+
+func copyChat(chat *dto.Chat) dto.Chat {
+	c := *chat
+	c.AttachmentIds = nil
+	c.Attachments = nil
+	return c
+}
+
+// loadAttachments fills in the attachments of each chat
+func (r *MemoryRepository) loadAttachments(chats []dto.Chat) {
+	for i := range chats {
+		for _, attachment := range r.attachments {
+			if attachment.ChatId == chats[i].Id {
+				chats[i].Attachments = append(chats[i].Attachments, *attachment)
+			}
+		}
+		slices.SortFunc(chats[i].Attachments, func(a, b dto.Attachment) int {
+			if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.Id, b.Id)
+		})
+	}
+}
+
+func (r *MemoryRepository) SaveChat(chat *dto.Chat) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := newId()
+	var attachments []*dto.Attachment
+	for _, attachmentId := range slices.Compact(slices.Sorted(slices.Values(chat.AttachmentIds))) {
+		attachment, ok := r.attachments[attachmentId]
+		if ok && attachment.UploaderId == chat.SenderId && attachment.ChatId == "" {
+			attachments = append(attachments, attachment)
+		}
+	}
+	if len(attachments) != len(chat.AttachmentIds) {
+		return ErrAttachmentUnavailable
+	}
+
+	chat.Id = id
+	stored := copyChat(chat)
+	// Postgres keeps timestamps to the microsecond
+	stored.CreatedAt = stored.CreatedAt.Truncate(time.Microsecond)
+	r.chats[id] = &stored
+
+	if len(attachments) > 0 {
+		chat.Attachments = []dto.Attachment{}
+		for _, attachment := range attachments {
+			attachment.ChatId = id
+			chat.Attachments = append(chat.Attachments, *attachment)
+		}
+	}
+
+	message, err := dto.NewChatOutboxMessage(chat)
+	if err != nil {
+		return err
+	}
+	message.Id = newId()
+	message.CreatedAt = time.Now()
+	r.outbox = append(r.outbox, &memoryOutbox{message: *message, nextAttemptAt: message.CreatedAt})
+	return nil
+}
+
+func (r *MemoryRepository) GetChat(id string) (dto.Chat, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	chat, ok := r.chats[id]
+	if !ok {
+		return dto.Chat{}, sql.ErrNoRows
+	}
+	return copyChat(chat), nil
+}
+
+func (r *MemoryRepository) ReadChatPage(page *dto.ChatPageQuery) ([]dto.Chat, *dto.ChatCursor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var chats []dto.Chat
+	for _, chat := range r.chats {
+		switch {
+		case page.ConversationId != "":
+			if chat.ConversationId != page.ConversationId {
+				continue
+			}
+		case page.PeerId != "":
+			if !(chat.SenderId == page.UserId && chat.ReceiverId == page.PeerId) &&
+				!(chat.SenderId == page.PeerId && chat.ReceiverId == page.UserId) {
+				continue
+			}
+		default:
+			if !r.isVisible(chat, page.UserId) {
+				continue
+			}
+		}
+		if r.isHidden(chat.Id, page.UserId) {
+			continue
+		}
+		if page.After != nil && compareChats(chat, &dto.Chat{CreatedAt: page.After.CreatedAt, Id: page.After.Id}) <= 0 {
+			continue
+		}
+		if page.After == nil && page.Before != nil &&
+			compareChats(chat, &dto.Chat{CreatedAt: page.Before.CreatedAt, Id: page.Before.Id}) >= 0 {
+			continue
+		}
+		chats = append(chats, copyChat(chat))
+	}
+
+	slices.SortFunc(chats, func(a, b dto.Chat) int {
+		if page.After != nil {
+			return compareChats(&a, &b)
+		}
+		return compareChats(&b, &a)
+	})
+
+	var next *dto.ChatCursor
+	if len(chats) > page.Limit {
+		chats = chats[:page.Limit]
+		next = dto.NewChatCursor(&chats[page.Limit-1])
+	}
+	r.loadAttachments(chats)
+	return chats, next, nil
+}
+
+// searchTerms splits a web search style query into the words a message must
+// contain and the words it must not
+func searchTerms(query string) ([]string, []string) {
+	var include, exclude []string
+	for _, field := range strings.Fields(strings.ToLower(query)) {
+		negated := strings.HasPrefix(field, "-")
+		for _, word := range searchWords(field) {
+			if word == "or" {
+				continue
+			}
+			if negated {
+				exclude = append(exclude, word)
+			} else {
+				include = append(include, word)
+			}
+		}
+	}
+	return include, exclude
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// searchWords returns the lowercase words of text
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !isWordRune(r) })
+}
+
+func matchesTerm(word, term string) bool {
+	return strings.HasPrefix(word, term)
+}
+
+// searchMatch reports whether message matches the terms and returns it with
+// the matching words highlighted
+func searchMatch(message string, include, exclude []string) (string, bool) {
+	if len(include) == 0 {
+		return "", false
+	}
+	words := searchWords(message)
+	for _, term := range include {
+		if !slices.ContainsFunc(words, func(word string) bool { return matchesTerm(word, term) }) {
+			return "", false
+		}
+	}
+	for _, term := range exclude {
+		if slices.ContainsFunc(words, func(word string) bool { return matchesTerm(word, term) }) {
+			return "", false
+		}
+	}
+
+	var snippet, word strings.Builder
+	flush := func() {
+		lower := strings.ToLower(word.String())
+		if slices.ContainsFunc(include, func(term string) bool { return word.Len() > 0 && matchesTerm(lower, term) }) {
+			snippet.WriteString(constants.SEARCH_HIGHLIGHT_START + html.EscapeString(word.String()) + constants.SEARCH_HIGHLIGHT_STOP)
+		} else {
+			snippet.WriteString(html.EscapeString(word.String()))
+		}
+		word.Reset()
+	}
+	for _, r := range message {
+		if isWordRune(r) {
+			word.WriteRune(r)
+			continue
+		}
+		flush()
+		snippet.WriteString(html.EscapeString(string(r)))
+	}
+	flush()
+	return snippet.String(), true
+}
+
+func (r *MemoryRepository) SearchChat(search *dto.ChatSearchQuery) ([]dto.ChatSearchResult, *dto.ChatCursor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	include, exclude := searchTerms(search.Query)
+	results := []dto.ChatSearchResult{}
+	for _, chat := range r.chats {
+		if chat.DeletedAt != nil || r.isHidden(chat.Id, search.UserId) {
+			continue
+		}
+		if search.ConversationId != "" {
+			if chat.ConversationId != search.ConversationId {
+				continue
+			}
+		} else if !r.isVisible(chat, search.UserId) {
+			continue
+		}
+		if search.SenderId != "" && chat.SenderId != search.SenderId {
+			continue
+		}
+		if search.From != nil && chat.CreatedAt.Before(*search.From) {
+			continue
+		}
+		if search.To != nil && !chat.CreatedAt.Before(*search.To) {
+			continue
+		}
+		if search.Before != nil && compareChats(chat, &dto.Chat{CreatedAt: search.Before.CreatedAt, Id: search.Before.Id}) >= 0 {
+			continue
+		}
+		snippet, ok := searchMatch(chat.Message, include, exclude)
+		if !ok {
+			continue
+		}
+		results = append(results, dto.ChatSearchResult{Chat: copyChat(chat), Snippet: snippet})
+	}
+
+	slices.SortFunc(results, func(a, b dto.ChatSearchResult) int {
+		return compareChats(&b.Chat, &a.Chat)
+	})
+
+	if len(results) <= search.Limit {
+		return results, nil, nil
+	}
+	results = results[:search.Limit]
+	return results, dto.NewChatCursor(&results[search.Limit-1].Chat), nil
+}
+
+func (r *MemoryRepository) EditChat(id, senderId, message string) (dto.Chat, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	chat, ok := r.chats[id]
+	if !ok || chat.SenderId != senderId || chat.DeletedAt != nil {
+		return dto.Chat{}, sql.ErrNoRows
+	}
+
+	previous := chat.CreatedAt
+	if chat.EditedAt != nil {
+		previous = *chat.EditedAt
+	}
+	r.edits[id] = append(r.edits[id], dto.ChatEdit{ChatId: id, Message: chat.Message, EditedAt: previous})
+
+	now := time.Now()
+	chat.Message = message
+	chat.EditedAt = &now
+	return copyChat(chat), nil
+}
+
+func (r *MemoryRepository) DeleteChatForEveryone(id, senderId string) (dto.Chat, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	chat, ok := r.chats[id]
+	if !ok || chat.SenderId != senderId || chat.DeletedAt != nil {
+		return dto.Chat{}, sql.ErrNoRows
+	}
+
+	now := time.Now()
+	chat.Message = ""
+	chat.DeletedAt = &now
+	delete(r.edits, id)
+	return copyChat(chat), nil
+}
+
+func (r *MemoryRepository) DeleteChatForUser(id, userId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.chats[id]; !ok {
+		return sql.ErrNoRows
+	}
+	if r.hidden[id] == nil {
+		r.hidden[id] = map[string]bool{}
+	}
+	r.hidden[id][userId] = true
+	return nil
+}
+
+func (r *MemoryRepository) GetChatEdits(id string) ([]dto.ChatEdit, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	edits := slices.Clone(r.edits[id])
+	if edits == nil {
+		edits = []dto.ChatEdit{}
+	}
+	slices.SortStableFunc(edits, func(a, b dto.ChatEdit) int {
+		return a.EditedAt.Compare(b.EditedAt)
+	})
+	return edits, nil
+}
+
+// receiptRanks orders the statuses a receipt moves through
+var receiptRanks = map[string]int{constants.RECEIPT_DELIVERED: 1, constants.RECEIPT_READ: 2}
+
+// upsertReceipt moves the receipt of a user forward to status and reports
+// whether it changed
+func (r *MemoryRepository) upsertReceipt(chatId, userId, status string) bool {
+	if r.receipts[chatId] == nil {
+		r.receipts[chatId] = map[string]*dto.ChatReceipt{}
+	}
+	receipt, ok := r.receipts[chatId][userId]
+	if ok && receiptRanks[receipt.Status] >= receiptRanks[status] {
+		return false
+	}
+	r.receipts[chatId][userId] = &dto.ChatReceipt{ChatId: chatId, UserId: userId, Status: status, UpdatedAt: time.Now()}
+	return true
+}
+
+func (r *MemoryRepository) MarkChatDelivered(chatId, userId string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.chats[chatId]; !ok {
+		return false, sql.ErrNoRows
+	}
+	return r.upsertReceipt(chatId, userId, constants.RECEIPT_DELIVERED), nil
+}
+
+func (r *MemoryRepository) MarkChatReadUpTo(userId string, request *dto.ReadReceiptRequest) ([]dto.Chat, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	upTo, ok := r.chats[request.UpTo]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	if request.ConversationId != "" {
+		if upTo.ConversationId != request.ConversationId {
+			return nil, sql.ErrNoRows
+		}
+	} else if !(upTo.SenderId == request.PeerId && upTo.ReceiverId == userId) &&
+		!(upTo.SenderId == userId && upTo.ReceiverId == request.PeerId) {
+		return nil, sql.ErrNoRows
+	}
+
+	var chats []dto.Chat
+	for _, chat := range r.chats {
+		if request.ConversationId != "" {
+			if chat.ConversationId != request.ConversationId {
+				continue
+			}
+		} else if chat.SenderId != request.PeerId || chat.ReceiverId != userId {
+			continue
+		}
+		if chat.SenderId == userId || compareChats(chat, upTo) > 0 {
+			continue
+		}
+		if r.upsertReceipt(chat.Id, userId, constants.RECEIPT_READ) {
+			chats = append(chats, copyChat(chat))
+		}
+	}
+	return chats, nil
+}
+
+func (r *MemoryRepository) GetChatReceipts(chatId string) ([]dto.ChatReceipt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	receipts := []dto.ChatReceipt{}
+	for _, receipt := range r.receipts[chatId] {
+		receipts = append(receipts, *receipt)
+	}
+	slices.SortFunc(receipts, func(a, b dto.ChatReceipt) int {
+		return cmp.Compare(a.UserId, b.UserId)
+	})
+	return receipts, nil
+}
+
+func (r *MemoryRepository) CreateConversation(conversation *dto.Conversation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if conversation.Type == constants.CONVERSATION_DIRECT && len(conversation.MemberIds) == 2 {
+		if _, err := r.directConversationId(conversation.MemberIds[0], conversation.MemberIds[1]); err == nil {
+			return ErrConversationExists
+		}
+	}
+
+	conversation.Id = newId()
+	conversation.CreatedAt = time.Now()
+	stored := &memoryConversation{conversation: *conversation}
+	stored.conversation.MemberIds = nil
+	for _, memberId := range conversation.MemberIds {
+		if !slices.Contains(stored.memberIds, memberId) {
+			stored.memberIds = append(stored.memberIds, memberId)
+		}
+	}
+	r.conversations[conversation.Id] = stored
+	return nil
+}
+
+func (r *MemoryRepository) GetDirectConversationId(userId1, userId2 string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.directConversationId(userId1, userId2)
+}
+
+func (r *MemoryRepository) directConversationId(userId1, userId2 string) (string, error) {
+	for id, stored := range r.conversations {
+		if stored.conversation.Type == constants.CONVERSATION_DIRECT &&
+			slices.Contains(stored.memberIds, userId1) && slices.Contains(stored.memberIds, userId2) {
+			return id, nil
+		}
+	}
+	return "", sql.ErrNoRows
+}
+
+func (r *MemoryRepository) GetConversation(id string) (dto.Conversation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.conversations[id]
+	if !ok {
+		return dto.Conversation{}, sql.ErrNoRows
+	}
+	conversation := stored.conversation
+	conversation.MemberIds = append([]string{}, stored.memberIds...)
+	return conversation, nil
+}
+
+func (r *MemoryRepository) GetConversationIds(userId string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	conversationIds := []string{}
+	for id, stored := range r.conversations {
+		if slices.Contains(stored.memberIds, userId) {
+			conversationIds = append(conversationIds, id)
+		}
+	}
+	return conversationIds, nil
+}
+
+func (r *MemoryRepository) AddConversationMember(conversationId, userId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.conversations[conversationId]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if !slices.Contains(stored.memberIds, userId) {
+		stored.memberIds = append(stored.memberIds, userId)
+	}
+	return nil
+}
+
+func (r *MemoryRepository) RemoveConversationMember(conversationId, userId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.conversations[conversationId]
+	if !ok || !slices.Contains(stored.memberIds, userId) {
+		return sql.ErrNoRows
+	}
+	stored.memberIds = slices.DeleteFunc(stored.memberIds, func(id string) bool { return id == userId })
+	return nil
+}
+
+func (r *MemoryRepository) SaveAttachment(attachment *dto.Attachment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attachment.Id = newId()
+	attachment.CreatedAt = time.Now()
+	attachment.Url = constants.ATTACHMENT_URL_PREFIX + attachment.Id
+	stored := *attachment
+	r.attachments[attachment.Id] = &stored
+	return nil
+}
+
+func (r *MemoryRepository) GetAttachment(id string) (dto.Attachment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attachment, ok := r.attachments[id]
+	if !ok {
+		return dto.Attachment{}, sql.ErrNoRows
+	}
+	return *attachment, nil
+}
+
+func (r *MemoryRepository) DeleteChatAttachments(chatId string) ([]dto.Attachment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attachments := []dto.Attachment{}
+	for id, attachment := range r.attachments {
+		if attachment.ChatId == chatId {
+			attachments = append(attachments, *attachment)
+			delete(r.attachments, id)
+		}
+	}
+	return attachments, nil
+}
+
+func (r *MemoryRepository) ClaimOutbox(limit int, lease time.Duration) ([]dto.OutboxMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	messages := []dto.OutboxMessage{}
+	for _, entry := range r.outbox {
+		if len(messages) == limit {
+			break
+		}
+		if entry.sentAt != nil || entry.nextAttemptAt.After(now) {
+			continue
+		}
+		entry.nextAttemptAt = now.Add(lease)
+		messages = append(messages, entry.message)
+	}
+	return messages, nil
+}
+
+func (r *MemoryRepository) outboxEntry(id string) (*memoryOutbox, error) {
+	for _, entry := range r.outbox {
+		if entry.message.Id == id {
+			return entry, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *MemoryRepository) MarkOutboxSent(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, err := r.outboxEntry(id)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	entry.sentAt = &now
+	entry.message.Attempts++
+	entry.lastError = ""
+	return nil
+}
+
+func (r *MemoryRepository) MarkOutboxFailed(id, lastError string, retryAfter time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, err := r.outboxEntry(id)
+	if err != nil {
+		return err
+	}
+	entry.message.Attempts++
+	entry.lastError = lastError
+	entry.nextAttemptAt = time.Now().Add(retryAfter)
+	return nil
+}
+
+func (r *MemoryRepository) DeleteSentOutbox(age time.Duration) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	before := time.Now().Add(-age)
+	kept := r.outbox[:0]
+	for _, entry := range r.outbox {
+		if entry.sentAt == nil || !entry.sentAt.Before(before) {
+			kept = append(kept, entry)
+		}
+	}
+	deleted := int64(len(r.outbox) - len(kept))
+	r.outbox = kept
+	return deleted, nil
+}
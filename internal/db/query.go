@@ -1,13 +1,38 @@
 package db
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
 
+	"github.com/nihal-ramaswamy/GoChat/internal/apikey"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrAttachmentUnavailable is returned when a chat references an attachment
+// that does not exist, was uploaded by someone else or was already sent
+var ErrAttachmentUnavailable = errors.New("Attachment does not exist or was already sent")
+
+// ErrAccountDisabled is returned when signing in to an account an admin disabled
+var ErrAccountDisabled = errors.New("Account is disabled")
+
+// ErrInvalidApiKey is returned for API keys that are malformed, unknown,
+// revoked or expired
+var ErrInvalidApiKey = errors.New("Invalid API key")
+
+// ErrConversationExists is returned when creating a direct conversation
+// between two users who already have one
+var ErrConversationExists = errors.New("Conversation already exists")
+
+// ErrIdentityEmailTaken is returned when an identity provider signs in a user
+// with the email address of an existing account without verifying it
+var ErrIdentityEmailTaken = errors.New("Email address belongs to another account")
+
 func DoesEmailExist(db *sql.DB, email string) bool {
 	_, err := selectAllFromUserWhereEmailIs(db, email)
 	return err != sql.ErrNoRows
@@ -24,9 +49,17 @@ func RegisterNewUser(db *sql.DB, user *dto.User, log *zap.Logger) string {
 	return id
 }
 
+// unknownUserHash is compared against when an email has no account, so the
+// response takes as long as for a wrong password
+var unknownUserHash, _ = bcrypt.GenerateFromPassword([]byte("unknown user"), bcrypt.DefaultCost)
+
 func DoesPasswordMatch(db *sql.DB, user *dto.User, log *zap.Logger) bool {
 	password, err := selectPasswordFromUserWhereEmailIDs(db, user.Email)
 
+	if err == sql.ErrNoRows {
+		bcrypt.CompareHashAndPassword(unknownUserHash, []byte(user.Password))
+		return false
+	}
 	if nil != err {
 		log.Error(err.Error())
 		return false
@@ -44,5 +77,368 @@ func SaveChat(db *sql.DB, chat *dto.Chat) error {
 }
 
 func ReadChatForUser(db *sql.DB, id string) ([]dto.Chat, error) {
-	return selectAllFromChatWhereUserIdIs(db, id)
+	chats, err := selectAllFromChatWhereUserIdIs(db, id)
+	if err != nil {
+		return chats, err
+	}
+	return chats, loadAttachments(db, chats)
+}
+
+func DoesUserExist(db *sql.DB, id string) bool {
+	_, err := selectIdFromUserWhereIdIs(db, id)
+	return err == nil
+}
+
+// CreateConversation saves a conversation with its members. It returns
+// ErrConversationExists if the members already have a direct conversation.
+func CreateConversation(db *sql.DB, conversation *dto.Conversation) error {
+	return insertIntoConversationWithMembers(db, conversation)
+}
+
+// GetDirectConversationId returns the id of the direct conversation between two users, if any
+func GetDirectConversationId(db *sql.DB, userId1, userId2 string) (string, error) {
+	return selectIdFromConversationWhereDirectBetween(db, userId1, userId2)
+}
+
+func GetConversation(db *sql.DB, id string) (dto.Conversation, error) {
+	return selectAllFromConversationWhereIdIs(db, id)
+}
+
+func AddConversationMember(db *sql.DB, conversationId, userId string) error {
+	return insertIntoConversationMember(db, conversationId, userId)
+}
+
+func GetConversationIds(db *sql.DB, userId string) ([]string, error) {
+	return selectConversationIdFromConversationMemberWhereUserIdIs(db, userId)
+}
+
+// RemoveConversationMember returns sql.ErrNoRows if the user is not a member
+func RemoveConversationMember(db *sql.DB, conversationId, userId string) error {
+	return deleteFromConversationMember(db, conversationId, userId)
+}
+
+func ReadChatForConversation(db *sql.DB, conversationId string) ([]dto.Chat, error) {
+	chats, err := selectAllFromChatWhereConversationIdIs(db, conversationId)
+	if err != nil {
+		return chats, err
+	}
+	return chats, loadAttachments(db, chats)
+}
+
+// ReadChatPage returns one page of history and the cursor of the next page,
+// which is nil once there are no more messages
+func ReadChatPage(db *sql.DB, page *dto.ChatPageQuery) ([]dto.Chat, *dto.ChatCursor, error) {
+	limit := page.Limit
+	query := *page
+	query.Limit = limit + 1
+
+	chats, err := selectPageFromChat(db, &query)
+	if err != nil {
+		return chats, nil, err
+	}
+	if err := loadAttachments(db, chats); err != nil {
+		return chats, nil, err
+	}
+
+	if len(chats) <= limit {
+		return chats, nil, nil
+	}
+
+	chats = chats[:limit]
+	return chats, dto.NewChatCursor(&chats[limit-1]), nil
+}
+
+func GetChat(db *sql.DB, id string) (dto.Chat, error) {
+	return selectAllFromChatWhereIdIs(db, id)
+}
+
+// MarkChatDelivered records that a chat reached a device of the user. It
+// returns false if the chat was already delivered or read.
+func MarkChatDelivered(db *sql.DB, chatId, userId string) (bool, error) {
+	return upsertChatReceipt(db, chatId, userId, constants.RECEIPT_DELIVERED)
+}
+
+// MarkChatReadUpTo marks every chat the user received in a thread up to
+// request.UpTo as read and returns the chats whose status changed. It returns
+// sql.ErrNoRows if request.UpTo is not a message of the thread.
+func MarkChatReadUpTo(db *sql.DB, userId string, request *dto.ReadReceiptRequest) ([]dto.Chat, error) {
+	return upsertReadChatReceiptUpTo(db, userId, request)
+}
+
+func GetChatReceipts(db *sql.DB, chatId string) ([]dto.ChatReceipt, error) {
+	return selectAllFromChatReceiptWhereChatIdIs(db, chatId)
+}
+
+func SetHideLastSeen(db *sql.DB, id string, hide bool) error {
+	return updateUserSetHideLastSeen(db, id, hide)
+}
+
+func IsLastSeenHidden(db *sql.DB, id string) (bool, error) {
+	return selectHideLastSeenFromUserWhereIdIs(db, id)
+}
+
+func MarkEmailVerified(db *sql.DB, id string) error {
+	return updateUserSetEmailVerified(db, id)
+}
+
+func IsEmailVerified(db *sql.DB, id string) (bool, error) {
+	return selectEmailVerifiedFromUserWhereIdIs(db, id)
+}
+
+// ResetPassword replaces the password of a user with the hash of password
+func ResetPassword(db *sql.DB, id, password string) error {
+	user := &dto.User{Password: password}
+	return updateUserSetPassword(db, id, user.HashAndSalt().Password)
+}
+
+// SetPendingTotpSecret stores a TOTP secret that takes effect once EnableTotp
+// confirms the user can generate codes for it. It returns sql.ErrNoRows if
+// TOTP is already enabled.
+func SetPendingTotpSecret(db *sql.DB, id, secret string) error {
+	return updateUserSetTotpSecret(db, id, secret)
+}
+
+// GetTotp returns the TOTP secret of a user and whether it is enabled
+func GetTotp(db *sql.DB, id string) (string, bool, error) {
+	return selectTotpFromUserWhereIdIs(db, id)
+}
+
+// EnableTotp turns on TOTP for a user and stores the hashes of their recovery codes
+func EnableTotp(db *sql.DB, id string, recoveryCodes []string) error {
+	codeHashes := make([]string, len(recoveryCodes))
+	for i, code := range recoveryCodes {
+		user := &dto.User{Password: code}
+		codeHashes[i] = user.HashAndSalt().Password
+	}
+	return updateUserSetTotpEnabled(db, id, codeHashes)
+}
+
+func DisableTotp(db *sql.DB, id string) error {
+	return updateUserSetTotpDisabled(db, id)
+}
+
+// UseRecoveryCode reports whether code is an unused recovery code of the user
+// and marks it used if it is
+func UseRecoveryCode(db *sql.DB, id, code string) (bool, error) {
+	codes, err := selectUnusedFromRecoveryCodeWhereUserIdIs(db, id)
+	if err != nil {
+		return false, err
+	}
+	for codeId, codeHash := range codes {
+		if bcrypt.CompareHashAndPassword([]byte(codeHash), []byte(code)) == nil {
+			return updateRecoveryCodeSetUsedAt(db, codeId)
+		}
+	}
+	return false, nil
+}
+
+// GetOrProvisionOidcUser returns the user linked to an identity. On first
+// login the identity is linked to the user with the same verified email
+// address, or a user is created. Users created this way cannot sign in with a
+// password until they reset it.
+func GetOrProvisionOidcUser(db *sql.DB, identity *dto.OidcIdentity) (dto.User, error) {
+	user, err := selectUserFromUserIdentityWhereSubjectIs(db, identity.Issuer, identity.Subject)
+	if err != sql.ErrNoRows {
+		return user, err
+	}
+
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return dto.User{}, err
+	}
+	unusable := &dto.User{Password: hex.EncodeToString(password)}
+	return insertIntoUserIdentity(db, identity, unusable.HashAndSalt().Password)
+}
+
+// GetActiveRole returns the role of a user, or ErrAccountDisabled if the
+// account was disabled
+func GetActiveRole(db *sql.DB, id string) (string, error) {
+	role, disabled, err := selectRoleFromUserWhereIdIs(db, id)
+	if err != nil {
+		return "", err
+	}
+	if disabled {
+		return "", ErrAccountDisabled
+	}
+	return role, nil
+}
+
+func GetUsers(db *sql.DB, page *dto.UserPageQuery) ([]dto.AdminUser, error) {
+	return selectAdminUsersFromUser(db, page)
+}
+
+func GetAdminUser(db *sql.DB, id string) (dto.AdminUser, error) {
+	return selectAdminUserFromUserWhereIdIs(db, id)
+}
+
+func SetRole(db *sql.DB, id, role string) error {
+	return updateUserSetRole(db, id, role)
+}
+
+// SetDisabled disables or re-enables an account. Disabled accounts cannot
+// sign in and the API keys of their bots stop working.
+func SetDisabled(db *sql.DB, id string, disabled bool) error {
+	return updateUserSetDisabledAt(db, id, disabled)
+}
+
+// CreateBot creates a bot owned by bot.OwnerId. Bots get an address on a
+// reserved domain and a random password, so they can only use API keys.
+func CreateBot(db *sql.DB, bot *dto.Bot) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	bot.Email = hex.EncodeToString(b[:8]) + "@" + constants.BOT_EMAIL_DOMAIN
+	unusable := &dto.User{Password: hex.EncodeToString(b)}
+	return insertIntoUserBot(db, bot, unusable.HashAndSalt().Password)
+}
+
+func GetBot(db *sql.DB, id string) (dto.Bot, error) {
+	return selectBotFromUserWhereIdIs(db, id)
+}
+
+func GetBotsForOwner(db *sql.DB, ownerId string) ([]dto.Bot, error) {
+	return selectBotsFromUserWhereOwnerIdIs(db, ownerId)
+}
+
+// CreateApiKey stores a new key for key.UserId and returns the only copy of
+// the full key
+func CreateApiKey(db *sql.DB, key *dto.ApiKey) (string, error) {
+	secret, keyHash, err := apikey.NewSecret()
+	if err != nil {
+		return "", err
+	}
+	if err := insertIntoApiKey(db, key, keyHash); err != nil {
+		return "", err
+	}
+	return apikey.Format(key.Id, secret), nil
+}
+
+func GetApiKeys(db *sql.DB, userId string) ([]dto.ApiKey, error) {
+	return selectAllFromApiKeyWhereUserIdIs(db, userId)
+}
+
+// RevokeApiKey stops a key of userId from working. It returns sql.ErrNoRows
+// if the key does not belong to userId.
+func RevokeApiKey(db *sql.DB, id, userId string) error {
+	return updateApiKeySetRevokedAt(db, id, userId)
+}
+
+// AuthenticateApiKey returns the bot a key belongs to and records that the
+// key was used
+func AuthenticateApiKey(db *sql.DB, key string) (dto.ApiKeyPrincipal, error) {
+	id, secret, ok := apikey.Parse(key)
+	if !ok {
+		return dto.ApiKeyPrincipal{}, ErrInvalidApiKey
+	}
+
+	keyHash, principal, err := selectActiveApiKeyWhereIdIs(db, id)
+	if err == sql.ErrNoRows || (err == nil && !apikey.Matches(secret, keyHash)) {
+		return dto.ApiKeyPrincipal{}, ErrInvalidApiKey
+	}
+	if err != nil {
+		return dto.ApiKeyPrincipal{}, err
+	}
+
+	return principal, updateApiKeySetLastUsedAt(db, id)
+}
+
+// EditChat replaces the message of a chat sent by senderId and keeps the
+// previous version in the edit history. It returns sql.ErrNoRows if the chat
+// does not exist, was not sent by senderId or was deleted.
+func EditChat(db *sql.DB, id, senderId, message string) (dto.Chat, error) {
+	return updateChatSetMessage(db, id, senderId, message)
+}
+
+// DeleteChatForEveryone clears a chat sent by senderId and its edit history
+func DeleteChatForEveryone(db *sql.DB, id, senderId string) (dto.Chat, error) {
+	return updateChatSetDeleted(db, id, senderId)
+}
+
+// DeleteChatForUser hides a chat from the history of a single user
+func DeleteChatForUser(db *sql.DB, id, userId string) error {
+	return insertIntoChatHidden(db, id, userId)
+}
+
+// GetChatEdits returns the previous versions of a chat, oldest first
+func GetChatEdits(db *sql.DB, id string) ([]dto.ChatEdit, error) {
+	return selectAllFromChatEditWhereChatIdIs(db, id)
+}
+
+// SearchChat returns one page of the chats visible to the user that match
+// search.Query and the cursor of the next page, which is nil once there are no
+// more results
+func SearchChat(db *sql.DB, search *dto.ChatSearchQuery) ([]dto.ChatSearchResult, *dto.ChatCursor, error) {
+	limit := search.Limit
+	query := *search
+	query.Limit = limit + 1
+
+	results, err := selectSearchFromChat(db, &query)
+	if err != nil {
+		return results, nil, err
+	}
+
+	if len(results) <= limit {
+		return results, nil, nil
+	}
+
+	results = results[:limit]
+	return results, dto.NewChatCursor(&results[limit-1].Chat), nil
+}
+
+func SaveAttachment(db *sql.DB, attachment *dto.Attachment) error {
+	return insertIntoAttachment(db, attachment)
+}
+
+func GetAttachment(db *sql.DB, id string) (dto.Attachment, error) {
+	return selectAllFromAttachmentWhereIdIs(db, id)
+}
+
+// DeleteChatAttachments removes the attachments of a chat and returns them so
+// their blobs can be removed from storage
+func DeleteChatAttachments(db *sql.DB, chatId string) ([]dto.Attachment, error) {
+	return deleteFromAttachmentWhereChatIdIs(db, chatId)
+}
+
+// ClaimOutbox leases up to limit pending outbox messages, oldest first, so
+// other relays skip them until lease passes
+func ClaimOutbox(db *sql.DB, limit int, lease time.Duration) ([]dto.OutboxMessage, error) {
+	return updateOutboxClaimPending(db, limit, lease)
+}
+
+func MarkOutboxSent(db *sql.DB, id string) error {
+	return updateOutboxSetSent(db, id)
+}
+
+// MarkOutboxFailed records a failed publish and schedules the next attempt
+func MarkOutboxFailed(db *sql.DB, id, lastError string, retryAfter time.Duration) error {
+	return updateOutboxSetFailed(db, id, lastError, retryAfter)
+}
+
+// DeleteSentOutbox removes messages sent longer than age ago and returns how many
+func DeleteSentOutbox(db *sql.DB, age time.Duration) (int64, error) {
+	return deleteFromOutboxWhereSentBefore(db, age)
+}
+
+// loadAttachments fills in the attachments of each chat
+func loadAttachments(db *sql.DB, chats []dto.Chat) error {
+	if len(chats) == 0 {
+		return nil
+	}
+	ids := make([]string, len(chats))
+	index := make(map[string]int, len(chats))
+	for i, chat := range chats {
+		ids[i] = chat.Id
+		index[chat.Id] = i
+	}
+
+	attachments, err := selectAllFromAttachmentWhereChatIdIn(db, ids)
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		i := index[attachment.ChatId]
+		chats[i].Attachments = append(chats[i].Attachments, attachment)
+	}
+	return nil
 }
@@ -0,0 +1,286 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+// UserRepository stores accounts and their credentials. Lookups of missing
+// users return sql.ErrNoRows whatever the implementation.
+type UserRepository interface {
+	DoesEmailExist(email string) bool
+	DoesUserExist(id string) bool
+	// RegisterNewUser hashes the password of user and stores it, returning its id
+	RegisterNewUser(user *dto.User) (string, error)
+	// DoesPasswordMatch reports whether user.Password is the password of the
+	// account with user.Email. It takes as long for unknown emails.
+	DoesPasswordMatch(user *dto.User) bool
+	GetUserFromEmail(email string) (dto.User, error)
+	// GetActiveRole returns the role of a user, or ErrAccountDisabled if the
+	// account was disabled
+	GetActiveRole(id string) (string, error)
+	MarkEmailVerified(id string) error
+	IsEmailVerified(id string) (bool, error)
+	SetHideLastSeen(id string, hide bool) error
+	IsLastSeenHidden(id string) (bool, error)
+	// ResetPassword replaces the password of a user with the hash of password
+	ResetPassword(id, password string) error
+	// SetPendingTotpSecret stores a TOTP secret that takes effect once
+	// EnableTotp confirms it. It returns sql.ErrNoRows if TOTP is already enabled.
+	SetPendingTotpSecret(id, secret string) error
+	// GetTotp returns the TOTP secret of a user and whether it is enabled
+	GetTotp(id string) (string, bool, error)
+	// EnableTotp turns on TOTP for a user and replaces their recovery codes
+	EnableTotp(id string, recoveryCodes []string) error
+	DisableTotp(id string) error
+	// UseRecoveryCode reports whether code is an unused recovery code of the
+	// user and marks it used if it is
+	UseRecoveryCode(id, code string) (bool, error)
+	// GetOrProvisionOidcUser returns the user linked to an identity, linking or
+	// creating one on first login
+	GetOrProvisionOidcUser(identity *dto.OidcIdentity) (dto.User, error)
+}
+
+// ChatRepository stores chats, conversations, receipts and attachments.
+// Lookups of missing rows return sql.ErrNoRows whatever the implementation.
+type ChatRepository interface {
+	// SaveChat stores a chat, links the attachments it references and queues
+	// its message event in the outbox, all or nothing. It returns
+	// ErrAttachmentUnavailable if an attachment was not uploaded by the sender
+	// or was already sent.
+	SaveChat(chat *dto.Chat) error
+	GetChat(id string) (dto.Chat, error)
+	// ReadChatPage returns one page of history and the cursor of the next
+	// page, which is nil once there are no more messages
+	ReadChatPage(page *dto.ChatPageQuery) ([]dto.Chat, *dto.ChatCursor, error)
+	// SearchChat returns one page of the chats visible to the user that match
+	// search.Query and the cursor of the next page
+	SearchChat(search *dto.ChatSearchQuery) ([]dto.ChatSearchResult, *dto.ChatCursor, error)
+	// EditChat replaces the message of a chat sent by senderId and keeps the
+	// previous version. It returns sql.ErrNoRows if the chat does not exist,
+	// was not sent by senderId or was deleted.
+	EditChat(id, senderId, message string) (dto.Chat, error)
+	// DeleteChatForEveryone clears a chat sent by senderId and its edit history
+	DeleteChatForEveryone(id, senderId string) (dto.Chat, error)
+	// DeleteChatForUser hides a chat from the history of a single user
+	DeleteChatForUser(id, userId string) error
+	// GetChatEdits returns the previous versions of a chat, oldest first
+	GetChatEdits(id string) ([]dto.ChatEdit, error)
+
+	// MarkChatDelivered records that a chat reached a device of the user. It
+	// returns false if the chat was already delivered or read.
+	MarkChatDelivered(chatId, userId string) (bool, error)
+	// MarkChatReadUpTo marks every chat the user received in a thread up to
+	// request.UpTo as read and returns the chats whose status changed. It
+	// returns sql.ErrNoRows if request.UpTo is not a message of the thread.
+	MarkChatReadUpTo(userId string, request *dto.ReadReceiptRequest) ([]dto.Chat, error)
+	GetChatReceipts(chatId string) ([]dto.ChatReceipt, error)
+
+	// CreateConversation returns ErrConversationExists if the members already
+	// have a direct conversation
+	CreateConversation(conversation *dto.Conversation) error
+	// GetDirectConversationId returns the id of the direct conversation between two users, if any
+	GetDirectConversationId(userId1, userId2 string) (string, error)
+	GetConversation(id string) (dto.Conversation, error)
+	// GetConversationIds returns the ids of the conversations a user is a member of
+	GetConversationIds(userId string) ([]string, error)
+	AddConversationMember(conversationId, userId string) error
+	// RemoveConversationMember returns sql.ErrNoRows if the user is not a member
+	RemoveConversationMember(conversationId, userId string) error
+
+	SaveAttachment(attachment *dto.Attachment) error
+	GetAttachment(id string) (dto.Attachment, error)
+	// DeleteChatAttachments removes the attachments of a chat and returns them
+	// so their blobs can be removed from storage
+	DeleteChatAttachments(chatId string) ([]dto.Attachment, error)
+}
+
+// OutboxRepository stores the events saved along with chats until the relay
+// publishes them
+type OutboxRepository interface {
+	// ClaimOutbox leases up to limit pending messages, oldest first. A leased
+	// message is claimed again once lease passes unless it was marked sent.
+	ClaimOutbox(limit int, lease time.Duration) ([]dto.OutboxMessage, error)
+	MarkOutboxSent(id string) error
+	// MarkOutboxFailed records a failed publish and retries it after retryAfter
+	MarkOutboxFailed(id, lastError string, retryAfter time.Duration) error
+	// DeleteSentOutbox removes messages sent longer than age ago and returns how many
+	DeleteSentOutbox(age time.Duration) (int64, error)
+}
+
+// PostgresRepository implements the repositories on top of the package level queries
+type PostgresRepository struct {
+	db  *sql.DB
+	log *zap.Logger
+}
+
+func NewPostgresRepository(pdb *sql.DB, log *zap.Logger) *PostgresRepository {
+	return &PostgresRepository{db: pdb, log: log}
+}
+
+func (r *PostgresRepository) DoesEmailExist(email string) bool {
+	return DoesEmailExist(r.db, email)
+}
+
+func (r *PostgresRepository) DoesUserExist(id string) bool {
+	return DoesUserExist(r.db, id)
+}
+
+func (r *PostgresRepository) RegisterNewUser(user *dto.User) (string, error) {
+	return insertIntoUser(r.db, user.HashAndSalt())
+}
+
+func (r *PostgresRepository) DoesPasswordMatch(user *dto.User) bool {
+	return DoesPasswordMatch(r.db, user, r.log)
+}
+
+func (r *PostgresRepository) GetUserFromEmail(email string) (dto.User, error) {
+	return GetUserFromEmail(r.db, email)
+}
+
+func (r *PostgresRepository) GetActiveRole(id string) (string, error) {
+	return GetActiveRole(r.db, id)
+}
+
+func (r *PostgresRepository) MarkEmailVerified(id string) error {
+	return MarkEmailVerified(r.db, id)
+}
+
+func (r *PostgresRepository) IsEmailVerified(id string) (bool, error) {
+	return IsEmailVerified(r.db, id)
+}
+
+func (r *PostgresRepository) SetHideLastSeen(id string, hide bool) error {
+	return SetHideLastSeen(r.db, id, hide)
+}
+
+func (r *PostgresRepository) IsLastSeenHidden(id string) (bool, error) {
+	return IsLastSeenHidden(r.db, id)
+}
+
+func (r *PostgresRepository) ResetPassword(id, password string) error {
+	return ResetPassword(r.db, id, password)
+}
+
+func (r *PostgresRepository) SetPendingTotpSecret(id, secret string) error {
+	return SetPendingTotpSecret(r.db, id, secret)
+}
+
+func (r *PostgresRepository) GetTotp(id string) (string, bool, error) {
+	return GetTotp(r.db, id)
+}
+
+func (r *PostgresRepository) EnableTotp(id string, recoveryCodes []string) error {
+	return EnableTotp(r.db, id, recoveryCodes)
+}
+
+func (r *PostgresRepository) DisableTotp(id string) error {
+	return DisableTotp(r.db, id)
+}
+
+func (r *PostgresRepository) UseRecoveryCode(id, code string) (bool, error) {
+	return UseRecoveryCode(r.db, id, code)
+}
+
+func (r *PostgresRepository) GetOrProvisionOidcUser(identity *dto.OidcIdentity) (dto.User, error) {
+	return GetOrProvisionOidcUser(r.db, identity)
+}
+
+func (r *PostgresRepository) SaveChat(chat *dto.Chat) error {
+	return SaveChat(r.db, chat)
+}
+
+func (r *PostgresRepository) GetChat(id string) (dto.Chat, error) {
+	return GetChat(r.db, id)
+}
+
+func (r *PostgresRepository) ReadChatPage(page *dto.ChatPageQuery) ([]dto.Chat, *dto.ChatCursor, error) {
+	return ReadChatPage(r.db, page)
+}
+
+func (r *PostgresRepository) SearchChat(search *dto.ChatSearchQuery) ([]dto.ChatSearchResult, *dto.ChatCursor, error) {
+	return SearchChat(r.db, search)
+}
+
+func (r *PostgresRepository) EditChat(id, senderId, message string) (dto.Chat, error) {
+	return EditChat(r.db, id, senderId, message)
+}
+
+func (r *PostgresRepository) DeleteChatForEveryone(id, senderId string) (dto.Chat, error) {
+	return DeleteChatForEveryone(r.db, id, senderId)
+}
+
+func (r *PostgresRepository) DeleteChatForUser(id, userId string) error {
+	return DeleteChatForUser(r.db, id, userId)
+}
+
+func (r *PostgresRepository) GetChatEdits(id string) ([]dto.ChatEdit, error) {
+	return GetChatEdits(r.db, id)
+}
+
+func (r *PostgresRepository) MarkChatDelivered(chatId, userId string) (bool, error) {
+	return MarkChatDelivered(r.db, chatId, userId)
+}
+
+func (r *PostgresRepository) MarkChatReadUpTo(userId string, request *dto.ReadReceiptRequest) ([]dto.Chat, error) {
+	return MarkChatReadUpTo(r.db, userId, request)
+}
+
+func (r *PostgresRepository) GetChatReceipts(chatId string) ([]dto.ChatReceipt, error) {
+	return GetChatReceipts(r.db, chatId)
+}
+
+func (r *PostgresRepository) CreateConversation(conversation *dto.Conversation) error {
+	return CreateConversation(r.db, conversation)
+}
+
+func (r *PostgresRepository) GetDirectConversationId(userId1, userId2 string) (string, error) {
+	return GetDirectConversationId(r.db, userId1, userId2)
+}
+
+func (r *PostgresRepository) GetConversation(id string) (dto.Conversation, error) {
+	return GetConversation(r.db, id)
+}
+
+func (r *PostgresRepository) GetConversationIds(userId string) ([]string, error) {
+	return GetConversationIds(r.db, userId)
+}
+
+func (r *PostgresRepository) AddConversationMember(conversationId, userId string) error {
+	return AddConversationMember(r.db, conversationId, userId)
+}
+
+func (r *PostgresRepository) RemoveConversationMember(conversationId, userId string) error {
+	return RemoveConversationMember(r.db, conversationId, userId)
+}
+
+func (r *PostgresRepository) SaveAttachment(attachment *dto.Attachment) error {
+	return SaveAttachment(r.db, attachment)
+}
+
+func (r *PostgresRepository) GetAttachment(id string) (dto.Attachment, error) {
+	return GetAttachment(r.db, id)
+}
+
+func (r *PostgresRepository) DeleteChatAttachments(chatId string) ([]dto.Attachment, error) {
+	return DeleteChatAttachments(r.db, chatId)
+}
+
+func (r *PostgresRepository) ClaimOutbox(limit int, lease time.Duration) ([]dto.OutboxMessage, error) {
+	return ClaimOutbox(r.db, limit, lease)
+}
+
+func (r *PostgresRepository) MarkOutboxSent(id string) error {
+	return MarkOutboxSent(r.db, id)
+}
+
+func (r *PostgresRepository) MarkOutboxFailed(id, lastError string, retryAfter time.Duration) error {
+	return MarkOutboxFailed(r.db, id, lastError, retryAfter)
+}
+
+func (r *PostgresRepository) DeleteSentOutbox(age time.Duration) (int64, error) {
+	return DeleteSentOutbox(r.db, age)
+}
@@ -0,0 +1,357 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	query "github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+	"go.uber.org/zap"
+)
+
+// repository is implemented by MemoryRepository and PostgresRepository
+type repository interface {
+	query.UserRepository
+	query.ChatRepository
+}
+
+// runRepositoryTests checks that a repository behaves like every other one.
+// Handler tests run against the MemoryRepository, so it must not drift from
+// the PostgresRepository. Tests use random ids, so they can share a database.
+func runRepositoryTests(t *testing.T, newRepository func() repository) {
+	t.Run("User", func(t *testing.T) { runRepositoryUserTest(t, newRepository()) })
+	t.Run("ChatPagination", func(t *testing.T) { runRepositoryChatPaginationTest(t, newRepository()) })
+	t.Run("ChatSearch", func(t *testing.T) { runRepositoryChatSearchTest(t, newRepository()) })
+	t.Run("Conversation", func(t *testing.T) { runRepositoryConversationTest(t, newRepository()) })
+}
+
+func TestMemoryRepository(t *testing.T) {
+	runRepositoryTests(t, func() repository { return query.NewMemoryRepository() })
+}
+
+func TestPostgresRepository(t *testing.T) {
+	ctx := context.Background()
+	container, db, err := testUtils.SetUpPostgresForTesting(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up postgres for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+		db.Close()
+	})
+
+	runRepositoryTests(t, func() repository { return query.NewPostgresRepository(db, zap.NewNop()) })
+}
+
+func runRepositoryUserTest(t *testing.T, repository repository) {
+	user := &dto.User{
+		Name:     testUtils.RandStringRunes(10),
+		Email:    testUtils.RandStringRunes(10),
+		Password: testUtils.RandStringRunes(10),
+	}
+
+	password := user.Password
+
+	// This replaces user.Password with its hash
+	id, err := repository.RegisterNewUser(user)
+	if err != nil {
+		t.Fatalf("Error registering user: %s", err)
+	}
+	if !repository.DoesEmailExist(user.Email) || !repository.DoesUserExist(id) {
+		t.Fatalf("Expected user to exist")
+	}
+	userFromRepository, err := repository.GetUserFromEmail(user.Email)
+	if err != nil {
+		t.Fatalf("Error getting user: %s", err)
+	}
+	if userFromRepository.Id != id || userFromRepository.Name != user.Name {
+		t.Errorf("Expected %s, got %+v", id, userFromRepository)
+	}
+	if !repository.DoesPasswordMatch(&dto.User{Email: user.Email, Password: password}) {
+		t.Errorf("Expected password to match")
+	}
+	if repository.DoesPasswordMatch(&dto.User{Email: user.Email, Password: "wrong"}) {
+		t.Errorf("Expected wrong password not to match")
+	}
+	if _, err := repository.GetUserFromEmail(testUtils.RandStringRunes(10)); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+
+	if err := repository.ResetPassword(id, "changed"); err != nil {
+		t.Fatalf("Error resetting password: %s", err)
+	}
+	if repository.DoesPasswordMatch(&dto.User{Email: user.Email, Password: password}) {
+		t.Errorf("Expected old password not to match after a reset")
+	}
+	if !repository.DoesPasswordMatch(&dto.User{Email: user.Email, Password: "changed"}) {
+		t.Errorf("Expected new password to match after a reset")
+	}
+
+	if err := repository.SetHideLastSeen(id, true); err != nil {
+		t.Fatalf("Error hiding last seen: %s", err)
+	}
+	if hidden, err := repository.IsLastSeenHidden(id); err != nil || !hidden {
+		t.Errorf("Expected last seen to be hidden, got %t %v", hidden, err)
+	}
+}
+
+func runRepositoryChatPaginationTest(t *testing.T, repository repository) {
+	userId := testUtils.RandStringRunes(10)
+	peerId := testUtils.RandStringRunes(10)
+	start := time.Now().UTC().Truncate(time.Second)
+
+	// Pairs of chats share a timestamp
+	for i := range 25 {
+		err := repository.SaveChat(&dto.Chat{
+			SenderId:   userId,
+			ReceiverId: peerId,
+			Message:    testUtils.RandStringRunes(10),
+			CreatedAt:  start.Add(time.Duration(i/2) * time.Second),
+		})
+		if err != nil {
+			t.Fatalf("Error inserting chat: %s", err)
+		}
+	}
+
+	seen := map[string]bool{}
+	page := &dto.ChatPageQuery{UserId: userId, PeerId: peerId, Limit: 10}
+	var hidden string
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("Expected 3 pages")
+		}
+		chats, next, err := repository.ReadChatPage(page)
+		if err != nil {
+			t.Fatalf("Error reading page: %s", err)
+		}
+		for i := range chats {
+			if seen[chats[i].Id] {
+				t.Fatalf("Chat %s returned twice", chats[i].Id)
+			}
+			seen[chats[i].Id] = true
+			hidden = chats[i].Id
+		}
+		if next == nil {
+			break
+		}
+		page.Before = next
+	}
+	if len(seen) != 25 {
+		t.Errorf("Expected 25 chats, got %d", len(seen))
+	}
+
+	if err := repository.DeleteChatForUser(hidden, userId); err != nil {
+		t.Fatalf("Error deleting chat: %s", err)
+	}
+	chats, _, err := repository.ReadChatPage(&dto.ChatPageQuery{UserId: userId, Limit: 50})
+	if err != nil {
+		t.Fatalf("Error reading page: %s", err)
+	}
+	if len(chats) != 24 {
+		t.Errorf("Expected the deleted chat to be hidden, got %d chats", len(chats))
+	}
+	chats, _, err = repository.ReadChatPage(&dto.ChatPageQuery{UserId: peerId, Limit: 50})
+	if err != nil {
+		t.Fatalf("Error reading page: %s", err)
+	}
+	if len(chats) != 25 {
+		t.Errorf("Expected the peer to still see every chat, got %d chats", len(chats))
+	}
+}
+
+func runRepositoryChatSearchTest(t *testing.T, repository repository) {
+	userId := testUtils.RandStringRunes(10)
+	peerId := testUtils.RandStringRunes(10)
+	start := time.Now().UTC().Truncate(time.Second)
+
+	messages := []string{
+		"Lunch tomorrow at the usual place?",
+		"Sure, lunches there are great",
+		"Running late for lunch",
+	}
+	for i, message := range messages {
+		chat := &dto.Chat{
+			SenderId:   userId,
+			ReceiverId: peerId,
+			Message:    message,
+			CreatedAt:  start.Add(time.Duration(i) * time.Second),
+		}
+		if err := repository.SaveChat(chat); err != nil {
+			t.Fatalf("Error inserting chat: %s", err)
+		}
+	}
+
+	results, next, err := repository.SearchChat(&dto.ChatSearchQuery{UserId: userId, Query: "lunch", Limit: 2})
+	if err != nil {
+		t.Fatalf("Error searching chat: %s", err)
+	}
+	if len(results) != 2 || next == nil {
+		t.Fatalf("Expected a full first page, got %d results", len(results))
+	}
+	if results[0].Message != messages[2] {
+		t.Errorf("Expected newest match first, got %s", results[0].Message)
+	}
+	if !strings.Contains(results[0].Snippet, "<mark>lunch</mark>") {
+		t.Errorf("Expected highlighted snippet, got %s", results[0].Snippet)
+	}
+
+	results, _, err = repository.SearchChat(&dto.ChatSearchQuery{UserId: userId, Query: "lunch -late", Limit: 10})
+	if err != nil {
+		t.Fatalf("Error searching chat: %s", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected excluded words to filter results, got %v", results)
+	}
+
+	// Message text is escaped so only the highlight is markup
+	chat := &dto.Chat{SenderId: userId, ReceiverId: peerId, Message: "<script>alert(1)</script> pizza", CreatedAt: time.Now()}
+	if err := repository.SaveChat(chat); err != nil {
+		t.Fatalf("Error inserting chat: %s", err)
+	}
+	escaped, _, err := repository.SearchChat(&dto.ChatSearchQuery{UserId: userId, Query: "pizza", Limit: 10})
+	if err != nil {
+		t.Fatalf("Error searching chat: %s", err)
+	}
+	if len(escaped) != 1 || strings.Contains(escaped[0].Snippet, "<script>") ||
+		!strings.Contains(escaped[0].Snippet, "&lt;script&gt;") || !strings.Contains(escaped[0].Snippet, "<mark>pizza</mark>") {
+		t.Errorf("Expected an escaped snippet, got %v", escaped)
+	}
+
+	edited, err := repository.EditChat(results[0].Id, userId, "Dinner instead")
+	if err != nil {
+		t.Fatalf("Error editing chat: %s", err)
+	}
+	if _, err := repository.EditChat(edited.Id, peerId, "Not mine"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected only the sender to edit, got %v", err)
+	}
+	edits, err := repository.GetChatEdits(edited.Id)
+	if err != nil {
+		t.Fatalf("Error reading edits: %s", err)
+	}
+	if len(edits) != 1 || edits[0].Message != results[0].Message {
+		t.Errorf("Expected the previous version to be kept, got %v", edits)
+	}
+	results, _, err = repository.SearchChat(&dto.ChatSearchQuery{UserId: userId, Query: "dinner", Limit: 10})
+	if err != nil {
+		t.Fatalf("Error searching chat: %s", err)
+	}
+	if len(results) != 1 || results[0].Id != edited.Id {
+		t.Errorf("Expected the edited chat to be found by its new text, got %v", results)
+	}
+}
+
+func runRepositoryConversationTest(t *testing.T, repository repository) {
+	memberIds := []string{}
+	for range 3 {
+		memberIds = append(memberIds, testUtils.RandStringRunes(10))
+	}
+
+	conversation := &dto.Conversation{
+		Type:      constants.CONVERSATION_GROUP,
+		Name:      testUtils.RandStringRunes(10),
+		CreatedBy: memberIds[0],
+		MemberIds: memberIds,
+	}
+	if err := repository.CreateConversation(conversation); err != nil {
+		t.Fatalf("Error creating conversation: %s", err)
+	}
+	if conversation.Id == "" {
+		t.Fatalf("Expected conversation id, got empty string")
+	}
+
+	newMemberId := testUtils.RandStringRunes(10)
+	if err := repository.AddConversationMember(conversation.Id, newMemberId); err != nil {
+		t.Fatalf("Error adding member: %s", err)
+	}
+	if err := repository.RemoveConversationMember(conversation.Id, memberIds[1]); err != nil {
+		t.Fatalf("Error removing member: %s", err)
+	}
+	if err := repository.RemoveConversationMember(conversation.Id, memberIds[1]); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows removing a non-member, got %v", err)
+	}
+
+	stored, err := repository.GetConversation(conversation.Id)
+	if err != nil {
+		t.Fatalf("Error getting conversation: %s", err)
+	}
+	if len(stored.MemberIds) != 3 || !stored.HasMember(newMemberId) || stored.HasMember(memberIds[1]) {
+		t.Errorf("Unexpected members: %v", stored.MemberIds)
+	}
+	if _, err := repository.GetConversation(testUtils.RandStringRunes(10)); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+
+	conversationIds, err := repository.GetConversationIds(newMemberId)
+	if err != nil {
+		t.Fatalf("Error getting conversations: %s", err)
+	}
+	if !slices.Equal(conversationIds, []string{conversation.Id}) {
+		t.Errorf("Expected %s, got %v", conversation.Id, conversationIds)
+	}
+	if conversationIds, _ := repository.GetConversationIds(memberIds[1]); len(conversationIds) != 0 {
+		t.Errorf("Expected removed members to have no conversations, got %v", conversationIds)
+	}
+
+	// Group chats are addressed to the conversation and read by every member
+	groupChat := &dto.Chat{
+		SenderId:       memberIds[0],
+		ReceiverId:     conversation.Id,
+		ConversationId: conversation.Id,
+		Message:        "group lunch",
+		CreatedAt:      time.Now(),
+	}
+	if err := repository.SaveChat(groupChat); err != nil {
+		t.Fatalf("Error saving chat: %s", err)
+	}
+	chats, _, err := repository.ReadChatPage(&dto.ChatPageQuery{UserId: newMemberId, Limit: 10})
+	if err != nil {
+		t.Fatalf("Error reading chat: %s", err)
+	}
+	if len(chats) != 1 || chats[0].Id != groupChat.Id {
+		t.Errorf("Expected the group chat in the history of a member, got %v", chats)
+	}
+	results, _, err := repository.SearchChat(&dto.ChatSearchQuery{UserId: newMemberId, Query: "lunch", Limit: 10})
+	if err != nil {
+		t.Fatalf("Error searching chat: %s", err)
+	}
+	if len(results) != 1 || results[0].Id != groupChat.Id {
+		t.Errorf("Expected the group chat to be found by a member, got %v", results)
+	}
+	if chats, _, _ := repository.ReadChatPage(&dto.ChatPageQuery{UserId: memberIds[1], Limit: 10}); len(chats) != 0 {
+		t.Errorf("Expected removed members not to read the group chat, got %v", chats)
+	}
+
+	direct := &dto.Conversation{
+		Type:      constants.CONVERSATION_DIRECT,
+		CreatedBy: memberIds[0],
+		MemberIds: memberIds[:2],
+	}
+	if err := repository.CreateConversation(direct); err != nil {
+		t.Fatalf("Error creating conversation: %s", err)
+	}
+	if id, err := repository.GetDirectConversationId(memberIds[1], memberIds[0]); err != nil || id != direct.Id {
+		t.Errorf("Expected %s, got %s: %v", direct.Id, id, err)
+	}
+	duplicate := &dto.Conversation{
+		Type:      constants.CONVERSATION_DIRECT,
+		CreatedBy: memberIds[1],
+		MemberIds: []string{memberIds[1], memberIds[0]},
+	}
+	if err := repository.CreateConversation(duplicate); !errors.Is(err, query.ErrConversationExists) {
+		t.Errorf("Expected query.ErrConversationExists, got %v", err)
+	}
+	if _, err := repository.GetDirectConversationId(memberIds[0], memberIds[2]); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+}
@@ -2,7 +2,14 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/lib/pq"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
 )
 
@@ -24,8 +31,8 @@ func selectAllFromUserWhereEmailIs(db *sql.DB, email string) (dto.User, error) {
 	}
 
 	var user dto.User
-	query := `SELECT ID, NAME, EMAIL FROM "USER" WHERE EMAIL = $1`
-	err := db.QueryRow(query, email).Scan(&user.Id, &user.Name, &user.Email)
+	query := `SELECT ID, NAME, EMAIL, TYPE FROM "USER" WHERE EMAIL = $1`
+	err := db.QueryRow(query, email).Scan(&user.Id, &user.Name, &user.Email, &user.Type)
 	if err != nil {
 		return user, err
 	}
@@ -44,13 +51,63 @@ func selectPasswordFromUserWhereEmailIDs(db *sql.DB, email string) (string, erro
 	return password, err
 }
 
+const chatColumns = `ID, SENDER_ID, RECEIVER_ID, COALESCE(CONVERSATION_ID, ''), MESSAGE, CREATED_AT, EDITED_AT, DELETED_AT`
+
+// visibleTo matches the chats a user sent or received, including those posted
+// to the conversations they are a member of, whose receiver is the conversation
+const visibleTo = `(SENDER_ID = %[1]s OR RECEIVER_ID = %[1]s OR
+	CONVERSATION_ID IN (SELECT CONVERSATION_ID FROM "CONVERSATION_MEMBER" WHERE USER_ID = %[1]s))`
+
+// insertIntoChat saves a chat, links the attachments it references and
+// queues its message event in the outbox. It returns ErrAttachmentUnavailable
+// if an attachment was not uploaded by the sender or was already sent.
 func insertIntoChat(db *sql.DB, chat *dto.Chat) error {
 	if db == nil {
 		panic("db cannot be nil")
 	}
-	query := `INSERT INTO "CHAT" (SENDER_ID, RECEIVER_ID, MESSAGE, CREATED_AT) VALUES ($1, $2, $3, $4)`
-	_, err := db.Exec(query, chat.SenderId, chat.ReceiverId, chat.Message, chat.CreatedAt)
-	return err
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO "CHAT" (SENDER_ID, RECEIVER_ID, CONVERSATION_ID, MESSAGE, CREATED_AT) VALUES ($1, $2, NULLIF($3, ''), $4, $5) RETURNING ID`
+	err = tx.QueryRow(query, chat.SenderId, chat.ReceiverId, chat.ConversationId, chat.Message, chat.CreatedAt).
+		Scan(&chat.Id)
+	if err != nil {
+		return err
+	}
+
+	if len(chat.AttachmentIds) > 0 {
+		query = `UPDATE "ATTACHMENT" SET CHAT_ID = $1
+			WHERE ID = ANY($2) AND UPLOADER_ID = $3 AND CHAT_ID IS NULL
+			RETURNING ` + attachmentColumns
+		rows, err := tx.Query(query, chat.Id, pq.Array(chat.AttachmentIds), chat.SenderId)
+		if err != nil {
+			return err
+		}
+		attachments, err := scanAttachments(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+		if len(attachments) != len(chat.AttachmentIds) {
+			return ErrAttachmentUnavailable
+		}
+		chat.Attachments = attachments
+	}
+
+	// Published by the relay once committed, so the chat is never saved
+	// without its event or announced without being saved
+	message, err := dto.NewChatOutboxMessage(chat)
+	if err != nil {
+		return err
+	}
+	if err := insertIntoOutbox(tx, message); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func selectAllFromChatWhereUserIdIs(db *sql.DB, id string) ([]dto.Chat, error) {
@@ -58,19 +115,1110 @@ func selectAllFromChatWhereUserIdIs(db *sql.DB, id string) ([]dto.Chat, error) {
 		panic("db cannot be nil")
 	}
 	var chats []dto.Chat
-	query := `SELECT SENDER_ID, RECEIVER_ID, MESSAGE, CREATED_AT FROM "CHAT" WHERE SENDER_ID = $1 OR RECEIVER_ID = $1`
+	query := `SELECT ` + chatColumns + ` FROM "CHAT" WHERE ` + fmt.Sprintf(visibleTo, "$1") + ` ORDER BY CREATED_AT, ID`
 	rows, err := db.Query(query, id)
-	defer rows.Close()
 	if err != nil {
 		return chats, err
 	}
+	defer rows.Close()
+	return scanChats(rows)
+}
+
+func selectAllFromChatWhereConversationIdIs(db *sql.DB, conversationId string) ([]dto.Chat, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `SELECT ` + chatColumns + ` FROM "CHAT" WHERE CONVERSATION_ID = $1 ORDER BY CREATED_AT, ID`
+	rows, err := db.Query(query, conversationId)
+	if err != nil {
+		return []dto.Chat{}, err
+	}
+	defer rows.Close()
+	return scanChats(rows)
+}
+
+func selectPageFromChat(db *sql.DB, page *dto.ChatPageQuery) ([]dto.Chat, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+
+	args := []any{}
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	var conditions []string
+	if page.ConversationId != "" {
+		conditions = append(conditions, "CONVERSATION_ID = "+arg(page.ConversationId))
+	} else if page.PeerId != "" {
+		user, peer := arg(page.UserId), arg(page.PeerId)
+		conditions = append(conditions, fmt.Sprintf(
+			"((SENDER_ID = %s AND RECEIVER_ID = %s) OR (SENDER_ID = %s AND RECEIVER_ID = %s))", user, peer, peer, user))
+	} else {
+		conditions = append(conditions, fmt.Sprintf(visibleTo, arg(page.UserId)))
+	}
+
+	conditions = append(conditions, `NOT EXISTS (SELECT 1 FROM "CHAT_HIDDEN" H WHERE H.CHAT_ID = "CHAT".ID AND H.USER_ID = `+
+		arg(page.UserId)+`)`)
+
+	order := "DESC"
+	if page.After != nil {
+		order = "ASC"
+		conditions = append(conditions, fmt.Sprintf(
+			"(CREATED_AT, ID) > (%s, %s)", arg(page.After.CreatedAt), arg(page.After.Id)))
+	} else if page.Before != nil {
+		conditions = append(conditions, fmt.Sprintf(
+			"(CREATED_AT, ID) < (%s, %s)", arg(page.Before.CreatedAt), arg(page.Before.Id)))
+	}
+
+	query := `SELECT ` + chatColumns + ` FROM "CHAT" WHERE ` + strings.Join(conditions, " AND ") +
+		fmt.Sprintf(" ORDER BY CREATED_AT %s, ID %s LIMIT %s", order, order, arg(page.Limit))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return []dto.Chat{}, err
+	}
+	defer rows.Close()
+	return scanChats(rows)
+}
+
+// selectSearchFromChat returns the chats visible to search.UserId that match
+// search.Query, newest first, along with a highlighted snippet of each
+func selectSearchFromChat(db *sql.DB, search *dto.ChatSearchQuery) ([]dto.ChatSearchResult, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+
+	args := []any{}
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	config := arg(constants.SEARCH_CONFIG)
+	tsQuery := fmt.Sprintf("websearch_to_tsquery(%s, %s)", config, arg(search.Query))
+
+	conditions := []string{"SEARCH_VECTOR @@ " + tsQuery, "DELETED_AT IS NULL"}
+	if search.ConversationId != "" {
+		conditions = append(conditions, "CONVERSATION_ID = "+arg(search.ConversationId))
+	} else {
+		conditions = append(conditions, fmt.Sprintf(visibleTo, arg(search.UserId)))
+	}
+	conditions = append(conditions, `NOT EXISTS (SELECT 1 FROM "CHAT_HIDDEN" H WHERE H.CHAT_ID = "CHAT".ID AND H.USER_ID = `+
+		arg(search.UserId)+`)`)
+
+	if search.SenderId != "" {
+		conditions = append(conditions, "SENDER_ID = "+arg(search.SenderId))
+	}
+	if search.From != nil {
+		conditions = append(conditions, "CREATED_AT >= "+arg(*search.From))
+	}
+	if search.To != nil {
+		conditions = append(conditions, "CREATED_AT < "+arg(*search.To))
+	}
+	if search.Before != nil {
+		conditions = append(conditions, fmt.Sprintf(
+			"(CREATED_AT, ID) < (%s, %s)", arg(search.Before.CreatedAt), arg(search.Before.Id)))
+	}
+
+	// Matches are marked with control characters so the snippet can be
+	// escaped before they become tags
+	options := arg(fmt.Sprintf("StartSel=%s, StopSel=%s, MaxFragments=%d",
+		constants.SEARCH_HEADLINE_START, constants.SEARCH_HEADLINE_STOP, constants.SEARCH_MAX_FRAGMENTS))
+	message := fmt.Sprintf("translate(MESSAGE, %s, '')", arg(constants.SEARCH_HEADLINE_START+constants.SEARCH_HEADLINE_STOP))
+	query := `SELECT ` + chatColumns + fmt.Sprintf(`, ts_headline(%s, %s, %s, %s)`, config, message, tsQuery, options) +
+		` FROM "CHAT" WHERE ` + strings.Join(conditions, " AND ") +
+		" ORDER BY CREATED_AT DESC, ID DESC LIMIT " + arg(search.Limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return []dto.ChatSearchResult{}, err
+	}
+	defer rows.Close()
+
+	results := []dto.ChatSearchResult{}
+	for rows.Next() {
+		var snippet string
+		chat, err := scanChat(rows, &snippet)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, dto.ChatSearchResult{Chat: chat, Snippet: highlightHeadline(snippet)})
+	}
+	return results, rows.Err()
+}
+
+// highlightHeadline HTML-escapes a ts_headline snippet and wraps the marked
+// matches in SEARCH_HIGHLIGHT_START and SEARCH_HIGHLIGHT_STOP
+func highlightHeadline(headline string) string {
+	return strings.NewReplacer(
+		constants.SEARCH_HEADLINE_START, constants.SEARCH_HIGHLIGHT_START,
+		constants.SEARCH_HEADLINE_STOP, constants.SEARCH_HIGHLIGHT_STOP,
+	).Replace(html.EscapeString(headline))
+}
+
+func scanChats(rows *sql.Rows) ([]dto.Chat, error) {
+	var chats []dto.Chat
 	for rows.Next() {
-		var chat dto.Chat
-		err = rows.Scan(&chat.SenderId, &chat.ReceiverId, &chat.Message, &chat.CreatedAt)
+		chat, err := scanChat(rows)
 		if err != nil {
 			return chats, err
 		}
 		chats = append(chats, chat)
 	}
-	return chats, err
+	return chats, rows.Err()
+}
+
+// scanChat reads the chatColumns of a single row, followed by any extra columns
+func scanChat(row interface{ Scan(...any) error }, extra ...any) (dto.Chat, error) {
+	var chat dto.Chat
+	var editedAt, deletedAt sql.NullTime
+	dest := []any{
+		&chat.Id, &chat.SenderId, &chat.ReceiverId, &chat.ConversationId, &chat.Message, &chat.CreatedAt,
+		&editedAt, &deletedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if editedAt.Valid {
+		chat.EditedAt = &editedAt.Time
+	}
+	if deletedAt.Valid {
+		chat.DeletedAt = &deletedAt.Time
+	}
+	return chat, err
+}
+
+func selectIdFromUserWhereIdIs(db *sql.DB, id string) (string, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	var userId string
+	query := `SELECT ID FROM "USER" WHERE ID = $1`
+	err := db.QueryRow(query, id).Scan(&userId)
+
+	return userId, err
+}
+
+// directKey identifies the direct conversation between two users, whichever
+// of them created it
+func directKey(userId1, userId2 string) string {
+	return min(userId1, userId2) + ":" + max(userId1, userId2)
+}
+
+func insertIntoConversationWithMembers(db *sql.DB, conversation *dto.Conversation) error {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	key := ""
+	if conversation.Type == constants.CONVERSATION_DIRECT && len(conversation.MemberIds) == 2 {
+		key = directKey(conversation.MemberIds[0], conversation.MemberIds[1])
+	}
+
+	query := `INSERT INTO "CONVERSATION" (TYPE, NAME, CREATED_BY, DIRECT_KEY) VALUES ($1, $2, $3, NULLIF($4, ''))
+		ON CONFLICT (DIRECT_KEY) DO NOTHING RETURNING ID, CREATED_AT`
+	err = tx.QueryRow(query, conversation.Type, conversation.Name, conversation.CreatedBy, key).
+		Scan(&conversation.Id, &conversation.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrConversationExists
+	}
+	if err != nil {
+		return err
+	}
+
+	query = `INSERT INTO "CONVERSATION_MEMBER" (CONVERSATION_ID, USER_ID) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	for _, memberId := range conversation.MemberIds {
+		if _, err := tx.Exec(query, conversation.Id, memberId); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func selectAllFromConversationWhereIdIs(db *sql.DB, id string) (dto.Conversation, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	var conversation dto.Conversation
+	query := `SELECT ID, TYPE, NAME, CREATED_BY, CREATED_AT FROM "CONVERSATION" WHERE ID = $1`
+	err := db.QueryRow(query, id).Scan(
+		&conversation.Id, &conversation.Type, &conversation.Name, &conversation.CreatedBy, &conversation.CreatedAt)
+	if err != nil {
+		return conversation, err
+	}
+
+	conversation.MemberIds, err = selectUserIdFromConversationMemberWhereConversationIdIs(db, id)
+	return conversation, err
+}
+
+func selectIdFromConversationWhereDirectBetween(db *sql.DB, userId1, userId2 string) (string, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	var id string
+	query := `SELECT ID FROM "CONVERSATION" WHERE DIRECT_KEY = $1`
+	err := db.QueryRow(query, directKey(userId1, userId2)).Scan(&id)
+
+	return id, err
+}
+
+func selectUserIdFromConversationMemberWhereConversationIdIs(db *sql.DB, conversationId string) ([]string, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	memberIds := []string{}
+	query := `SELECT USER_ID FROM "CONVERSATION_MEMBER" WHERE CONVERSATION_ID = $1 ORDER BY JOINED_AT`
+	rows, err := db.Query(query, conversationId)
+	if err != nil {
+		return memberIds, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var memberId string
+		if err := rows.Scan(&memberId); err != nil {
+			return memberIds, err
+		}
+		memberIds = append(memberIds, memberId)
+	}
+	return memberIds, rows.Err()
+}
+
+func selectConversationIdFromConversationMemberWhereUserIdIs(db *sql.DB, userId string) ([]string, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	conversationIds := []string{}
+	query := `SELECT CONVERSATION_ID FROM "CONVERSATION_MEMBER" WHERE USER_ID = $1`
+	rows, err := db.Query(query, userId)
+	if err != nil {
+		return conversationIds, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var conversationId string
+		if err := rows.Scan(&conversationId); err != nil {
+			return conversationIds, err
+		}
+		conversationIds = append(conversationIds, conversationId)
+	}
+	return conversationIds, rows.Err()
+}
+
+func insertIntoConversationMember(db *sql.DB, conversationId, userId string) error {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `INSERT INTO "CONVERSATION_MEMBER" (CONVERSATION_ID, USER_ID) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	_, err := db.Exec(query, conversationId, userId)
+	return err
+}
+
+func deleteFromConversationMember(db *sql.DB, conversationId, userId string) error {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `DELETE FROM "CONVERSATION_MEMBER" WHERE CONVERSATION_ID = $1 AND USER_ID = $2`
+	result, err := db.Exec(query, conversationId, userId)
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Receipts only move forward: delivered can become read, never the opposite
+const receiptRank = `CASE %s WHEN 'delivered' THEN 1 WHEN 'read' THEN 2 ELSE 0 END`
+
+func upsertChatReceipt(db *sql.DB, chatId, userId, status string) (bool, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `INSERT INTO "CHAT_RECEIPT" (CHAT_ID, USER_ID, STATUS) VALUES ($1, $2, $3)
+		ON CONFLICT (CHAT_ID, USER_ID) DO UPDATE SET STATUS = EXCLUDED.STATUS, UPDATED_AT = NOW()
+		WHERE ` + fmt.Sprintf(receiptRank, `"CHAT_RECEIPT".STATUS`) + ` < ` + fmt.Sprintf(receiptRank, `EXCLUDED.STATUS`)
+	result, err := db.Exec(query, chatId, userId, status)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+func upsertReadChatReceiptUpTo(db *sql.DB, userId string, request *dto.ReadReceiptRequest) ([]dto.Chat, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+
+	// up_to is resolved within the thread, so a message of another thread
+	// cannot mark this one read
+	received := `C.SENDER_ID = $3 AND C.RECEIVER_ID = $1`
+	thread := `ID = $1 AND ((SENDER_ID = $2 AND RECEIVER_ID = $3) OR (SENDER_ID = $3 AND RECEIVER_ID = $2))`
+	args := []any{request.UpTo, request.PeerId, userId}
+	threadId := request.PeerId
+	if request.ConversationId != "" {
+		received = `C.CONVERSATION_ID = $3`
+		thread = `ID = $1 AND CONVERSATION_ID = $2`
+		args = []any{request.UpTo, request.ConversationId}
+		threadId = request.ConversationId
+	}
+
+	var upTo dto.Chat
+	query := `SELECT CREATED_AT, ID FROM "CHAT" WHERE ` + thread
+	if err := db.QueryRow(query, args...).Scan(&upTo.CreatedAt, &upTo.Id); err != nil {
+		return []dto.Chat{}, err
+	}
+
+	query = `WITH UPDATED AS (
+			INSERT INTO "CHAT_RECEIPT" (CHAT_ID, USER_ID, STATUS)
+			SELECT C.ID, $1, 'read' FROM "CHAT" C
+			WHERE ` + received + ` AND C.SENDER_ID <> $1 AND (C.CREATED_AT, C.ID) <= ($2, $4)
+			ON CONFLICT (CHAT_ID, USER_ID) DO UPDATE SET STATUS = 'read', UPDATED_AT = NOW()
+			WHERE "CHAT_RECEIPT".STATUS <> 'read'
+			RETURNING CHAT_ID
+		)
+		SELECT ` + chatColumns + ` FROM "CHAT" JOIN UPDATED ON UPDATED.CHAT_ID = "CHAT".ID`
+	rows, err := db.Query(query, userId, upTo.CreatedAt, threadId, upTo.Id)
+	if err != nil {
+		return []dto.Chat{}, err
+	}
+	defer rows.Close()
+	return scanChats(rows)
+}
+
+func selectAllFromChatReceiptWhereChatIdIs(db *sql.DB, chatId string) ([]dto.ChatReceipt, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	receipts := []dto.ChatReceipt{}
+	query := `SELECT CHAT_ID, USER_ID, STATUS, UPDATED_AT FROM "CHAT_RECEIPT" WHERE CHAT_ID = $1`
+	rows, err := db.Query(query, chatId)
+	if err != nil {
+		return receipts, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var receipt dto.ChatReceipt
+		if err := rows.Scan(&receipt.ChatId, &receipt.UserId, &receipt.Status, &receipt.UpdatedAt); err != nil {
+			return receipts, err
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, rows.Err()
+}
+
+func selectAllFromChatWhereIdIs(db *sql.DB, id string) (dto.Chat, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `SELECT ` + chatColumns + ` FROM "CHAT" WHERE ID = $1`
+	return scanChat(db.QueryRow(query, id))
+}
+
+func updateUserSetHideLastSeen(db *sql.DB, id string, hide bool) error {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `UPDATE "USER" SET HIDE_LAST_SEEN = $2 WHERE ID = $1`
+	_, err := db.Exec(query, id, hide)
+	return err
+}
+
+func selectHideLastSeenFromUserWhereIdIs(db *sql.DB, id string) (bool, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	var hide bool
+	query := `SELECT HIDE_LAST_SEEN FROM "USER" WHERE ID = $1`
+	err := db.QueryRow(query, id).Scan(&hide)
+	return hide, err
+}
+
+func updateUserSetEmailVerified(db *sql.DB, id string) error {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `UPDATE "USER" SET EMAIL_VERIFIED = TRUE WHERE ID = $1`
+	_, err := db.Exec(query, id)
+	return err
+}
+
+func selectEmailVerifiedFromUserWhereIdIs(db *sql.DB, id string) (bool, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	var verified bool
+	query := `SELECT EMAIL_VERIFIED FROM "USER" WHERE ID = $1`
+	err := db.QueryRow(query, id).Scan(&verified)
+	return verified, err
+}
+
+func updateUserSetPassword(db *sql.DB, id, password string) error {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `UPDATE "USER" SET PASSWORD = $2 WHERE ID = $1`
+	result, err := db.Exec(query, id, password)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func updateUserSetTotpSecret(db *sql.DB, id, secret string) error {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `UPDATE "USER" SET TOTP_SECRET = $2 WHERE ID = $1 AND NOT TOTP_ENABLED`
+	result, err := db.Exec(query, id, secret)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func selectTotpFromUserWhereIdIs(db *sql.DB, id string) (string, bool, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	var secret string
+	var enabled bool
+	query := `SELECT COALESCE(TOTP_SECRET, ''), TOTP_ENABLED FROM "USER" WHERE ID = $1`
+	err := db.QueryRow(query, id).Scan(&secret, &enabled)
+	return secret, enabled, err
+}
+
+// updateUserSetTotpEnabled turns on TOTP for a user and replaces their
+// recovery codes with codeHashes
+func updateUserSetTotpEnabled(db *sql.DB, id string, codeHashes []string) error {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE "USER" SET TOTP_ENABLED = TRUE WHERE ID = $1 AND TOTP_SECRET IS NOT NULL AND NOT TOTP_ENABLED`
+	result, err := tx.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec(`DELETE FROM "RECOVERY_CODE" WHERE USER_ID = $1`, id); err != nil {
+		return err
+	}
+	query = `INSERT INTO "RECOVERY_CODE" (USER_ID, CODE_HASH) VALUES ($1, $2)`
+	for _, codeHash := range codeHashes {
+		if _, err := tx.Exec(query, id, codeHash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// updateUserSetTotpDisabled turns off TOTP for a user and drops their recovery codes
+func updateUserSetTotpDisabled(db *sql.DB, id string) error {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE "USER" SET TOTP_ENABLED = FALSE, TOTP_SECRET = NULL WHERE ID = $1`
+	if _, err := tx.Exec(query, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM "RECOVERY_CODE" WHERE USER_ID = $1`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// selectUnusedFromRecoveryCodeWhereUserIdIs returns the ids and hashes of the
+// recovery codes of a user that were not used yet
+func selectUnusedFromRecoveryCodeWhereUserIdIs(db *sql.DB, userId string) (map[string]string, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `SELECT ID, CODE_HASH FROM "RECOVERY_CODE" WHERE USER_ID = $1 AND USED_AT IS NULL`
+	rows, err := db.Query(query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := map[string]string{}
+	for rows.Next() {
+		var id, codeHash string
+		if err := rows.Scan(&id, &codeHash); err != nil {
+			return nil, err
+		}
+		codes[id] = codeHash
+	}
+	return codes, rows.Err()
+}
+
+// updateRecoveryCodeSetUsedAt marks a recovery code used. It returns false if
+// the code was already used by a concurrent request.
+func updateRecoveryCodeSetUsedAt(db *sql.DB, id string) (bool, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `UPDATE "RECOVERY_CODE" SET USED_AT = NOW() WHERE ID = $1 AND USED_AT IS NULL`
+	result, err := db.Exec(query, id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+func selectUserFromUserIdentityWhereSubjectIs(db *sql.DB, issuer, subject string) (dto.User, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	var user dto.User
+	query := `SELECT U.ID, U.NAME, U.EMAIL FROM "USER_IDENTITY" I
+		JOIN "USER" U ON U.ID = I.USER_ID
+		WHERE I.ISSUER = $1 AND I.SUBJECT = $2`
+	err := db.QueryRow(query, issuer, subject).Scan(&user.Id, &user.Name, &user.Email)
+	return user, err
+}
+
+// insertIntoUserIdentity links an identity to the user with its email
+// address, creating the user if there is none. passwordHash is only used for
+// new users. It returns ErrIdentityEmailTaken if the address belongs to a user
+// and the identity provider did not verify it.
+func insertIntoUserIdentity(db *sql.DB, identity *dto.OidcIdentity, passwordHash string) (dto.User, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return dto.User{}, err
+	}
+	defer tx.Rollback()
+
+	var user dto.User
+	query := `SELECT ID, NAME, EMAIL FROM "USER" WHERE EMAIL = $1 FOR UPDATE`
+	err = tx.QueryRow(query, identity.Email).Scan(&user.Id, &user.Name, &user.Email)
+	switch {
+	case err == sql.ErrNoRows:
+		user = dto.User{Name: identity.Name, Email: identity.Email}
+		query = `INSERT INTO "USER" (NAME, EMAIL, PASSWORD, EMAIL_VERIFIED) VALUES ($1, $2, $3, $4) RETURNING ID`
+		err = tx.QueryRow(query, user.Name, user.Email, passwordHash, identity.EmailVerified).Scan(&user.Id)
+		if err != nil {
+			return dto.User{}, err
+		}
+	case err != nil:
+		return dto.User{}, err
+	case !identity.EmailVerified:
+		return dto.User{}, ErrIdentityEmailTaken
+	default:
+		query = `UPDATE "USER" SET EMAIL_VERIFIED = TRUE WHERE ID = $1`
+		if _, err := tx.Exec(query, user.Id); err != nil {
+			return dto.User{}, err
+		}
+	}
+
+	query = `INSERT INTO "USER_IDENTITY" (ISSUER, SUBJECT, USER_ID) VALUES ($1, $2, $3)`
+	if _, err := tx.Exec(query, identity.Issuer, identity.Subject, user.Id); err != nil {
+		return dto.User{}, err
+	}
+
+	return user, tx.Commit()
+}
+
+func selectRoleFromUserWhereIdIs(db *sql.DB, id string) (string, bool, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	var role string
+	var disabled bool
+	query := `SELECT ROLE, DISABLED_AT IS NOT NULL FROM "USER" WHERE ID = $1`
+	err := db.QueryRow(query, id).Scan(&role, &disabled)
+	return role, disabled, err
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern, so a search term
+// matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+const adminUserColumns = `ID, NAME, EMAIL, TYPE, ROLE, CREATED_AT, DISABLED_AT`
+
+func scanAdminUser(row interface{ Scan(...any) error }) (dto.AdminUser, error) {
+	var user dto.AdminUser
+	var disabledAt sql.NullTime
+	err := row.Scan(&user.Id, &user.Name, &user.Email, &user.Type, &user.Role, &user.CreatedAt, &disabledAt)
+	if disabledAt.Valid {
+		user.DisabledAt = &disabledAt.Time
+	}
+	return user, err
+}
+
+func selectAdminUsersFromUser(db *sql.DB, page *dto.UserPageQuery) ([]dto.AdminUser, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `SELECT ` + adminUserColumns + ` FROM "USER"
+		WHERE $1 = '' OR EMAIL ILIKE '%' || $1 || '%' ESCAPE '\' OR NAME ILIKE '%' || $1 || '%' ESCAPE '\'
+		ORDER BY CREATED_AT, ID LIMIT $2 OFFSET $3`
+	rows, err := db.Query(query, likeEscaper.Replace(page.Query), page.Limit, page.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []dto.AdminUser{}
+	for rows.Next() {
+		user, err := scanAdminUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func selectAdminUserFromUserWhereIdIs(db *sql.DB, id string) (dto.AdminUser, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `SELECT ` + adminUserColumns + ` FROM "USER" WHERE ID = $1`
+	return scanAdminUser(db.QueryRow(query, id))
+}
+
+func updateUserSetRole(db *sql.DB, id, role string) error {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `UPDATE "USER" SET ROLE = $2 WHERE ID = $1`
+	_, err := db.Exec(query, id, role)
+	return err
+}
+
+func updateUserSetDisabledAt(db *sql.DB, id string, disabled bool) error {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `UPDATE "USER" SET DISABLED_AT = NULL WHERE ID = $1`
+	if disabled {
+		query = `UPDATE "USER" SET DISABLED_AT = COALESCE(DISABLED_AT, NOW()) WHERE ID = $1`
+	}
+	_, err := db.Exec(query, id)
+	return err
+}
+
+func insertIntoUserBot(db *sql.DB, bot *dto.Bot, passwordHash string) error {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `INSERT INTO "USER" (NAME, EMAIL, PASSWORD, EMAIL_VERIFIED, TYPE, OWNER_ID) VALUES ($1, $2, $3, TRUE, $4, $5) RETURNING ID`
+	return db.QueryRow(query, bot.Name, bot.Email, passwordHash, constants.USER_TYPE_BOT, bot.OwnerId).Scan(&bot.Id)
+}
+
+const botColumns = `ID, NAME, EMAIL, OWNER_ID`
+
+func selectBotFromUserWhereIdIs(db *sql.DB, id string) (dto.Bot, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	var bot dto.Bot
+	query := `SELECT ` + botColumns + ` FROM "USER" WHERE ID = $1 AND TYPE = $2`
+	err := db.QueryRow(query, id, constants.USER_TYPE_BOT).Scan(&bot.Id, &bot.Name, &bot.Email, &bot.OwnerId)
+	return bot, err
+}
+
+func selectBotsFromUserWhereOwnerIdIs(db *sql.DB, ownerId string) ([]dto.Bot, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `SELECT ` + botColumns + ` FROM "USER" WHERE OWNER_ID = $1 AND TYPE = $2 ORDER BY NAME`
+	rows, err := db.Query(query, ownerId, constants.USER_TYPE_BOT)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bots := []dto.Bot{}
+	for rows.Next() {
+		var bot dto.Bot
+		if err := rows.Scan(&bot.Id, &bot.Name, &bot.Email, &bot.OwnerId); err != nil {
+			return nil, err
+		}
+		bots = append(bots, bot)
+	}
+	return bots, rows.Err()
+}
+
+const apiKeyColumns = `ID, USER_ID, NAME, SCOPES, CREATED_AT, LAST_USED_AT, EXPIRES_AT, REVOKED_AT`
+
+func scanApiKey(row interface{ Scan(...any) error }) (dto.ApiKey, error) {
+	var key dto.ApiKey
+	var lastUsedAt, expiresAt, revokedAt sql.NullTime
+	err := row.Scan(&key.Id, &key.UserId, &key.Name, pq.Array(&key.Scopes), &key.CreatedAt, &lastUsedAt, &expiresAt, &revokedAt)
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return key, err
+}
+
+func insertIntoApiKey(db *sql.DB, key *dto.ApiKey, keyHash string) error {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `INSERT INTO "API_KEY" (USER_ID, NAME, KEY_HASH, SCOPES, EXPIRES_AT) VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + apiKeyColumns
+	inserted, err := scanApiKey(db.QueryRow(query, key.UserId, key.Name, keyHash, pq.Array(key.Scopes), key.ExpiresAt))
+	if err != nil {
+		return err
+	}
+	*key = inserted
+	return nil
+}
+
+func selectAllFromApiKeyWhereUserIdIs(db *sql.DB, userId string) ([]dto.ApiKey, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `SELECT ` + apiKeyColumns + ` FROM "API_KEY" WHERE USER_ID = $1 ORDER BY CREATED_AT`
+	rows, err := db.Query(query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []dto.ApiKey{}
+	for rows.Next() {
+		key, err := scanApiKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func updateApiKeySetRevokedAt(db *sql.DB, id, userId string) error {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `UPDATE "API_KEY" SET REVOKED_AT = COALESCE(REVOKED_AT, NOW()) WHERE ID = $1 AND USER_ID = $2`
+	result, err := db.Exec(query, id, userId)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// selectActiveApiKeyWhereIdIs returns the hash and owner of a key that is
+// neither revoked nor expired and whose bot and owner are not disabled
+func selectActiveApiKeyWhereIdIs(db *sql.DB, id string) (string, dto.ApiKeyPrincipal, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	var keyHash string
+	principal := dto.ApiKeyPrincipal{KeyId: id}
+	query := `SELECT K.KEY_HASH, K.USER_ID, U.EMAIL, K.SCOPES FROM "API_KEY" K
+		JOIN "USER" U ON U.ID = K.USER_ID
+		LEFT JOIN "USER" O ON O.ID = U.OWNER_ID
+		WHERE K.ID = $1 AND K.REVOKED_AT IS NULL AND (K.EXPIRES_AT IS NULL OR K.EXPIRES_AT > NOW())
+		AND U.DISABLED_AT IS NULL AND O.DISABLED_AT IS NULL`
+	err := db.QueryRow(query, id).Scan(&keyHash, &principal.UserId, &principal.Email, pq.Array(&principal.Scopes))
+	return keyHash, principal, err
+}
+
+func updateApiKeySetLastUsedAt(db *sql.DB, id string) error {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `UPDATE "API_KEY" SET LAST_USED_AT = NOW()
+		WHERE ID = $1 AND (LAST_USED_AT IS NULL OR LAST_USED_AT < NOW() - $2 * INTERVAL '1 second')`
+	_, err := db.Exec(query, id, int64(constants.API_KEY_LAST_USED_INTERVAL.Seconds()))
+	return err
+}
+
+func updateChatSetMessage(db *sql.DB, id, senderId, message string) (dto.Chat, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return dto.Chat{}, err
+	}
+	defer tx.Rollback()
+
+	// Locked until the edit commits, so concurrent edits each keep the version
+	// the other one replaced and a deleted chat is never edited
+	var previous string
+	var previousAt time.Time
+	query := `SELECT MESSAGE, COALESCE(EDITED_AT, CREATED_AT) FROM "CHAT"
+		WHERE ID = $1 AND SENDER_ID = $2 AND DELETED_AT IS NULL FOR UPDATE`
+	if err := tx.QueryRow(query, id, senderId).Scan(&previous, &previousAt); err != nil {
+		return dto.Chat{}, err
+	}
+
+	query = `INSERT INTO "CHAT_EDIT" (CHAT_ID, MESSAGE, EDITED_AT) VALUES ($1, $2, $3)`
+	if _, err := tx.Exec(query, id, previous, previousAt); err != nil {
+		return dto.Chat{}, err
+	}
+
+	query = `UPDATE "CHAT" SET MESSAGE = $2, EDITED_AT = NOW() WHERE ID = $1 RETURNING ` + chatColumns
+	chat, err := scanChat(tx.QueryRow(query, id, message))
+	if err != nil {
+		return chat, err
+	}
+
+	return chat, tx.Commit()
+}
+
+func updateChatSetDeleted(db *sql.DB, id, senderId string) (dto.Chat, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return dto.Chat{}, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE "CHAT" SET MESSAGE = '', DELETED_AT = NOW()
+		WHERE ID = $1 AND SENDER_ID = $2 AND DELETED_AT IS NULL RETURNING ` + chatColumns
+	chat, err := scanChat(tx.QueryRow(query, id, senderId))
+	if err != nil {
+		return chat, err
+	}
+
+	query = `DELETE FROM "CHAT_EDIT" WHERE CHAT_ID = $1`
+	if _, err := tx.Exec(query, id); err != nil {
+		return chat, err
+	}
+
+	return chat, tx.Commit()
+}
+
+func insertIntoChatHidden(db *sql.DB, chatId, userId string) error {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `INSERT INTO "CHAT_HIDDEN" (CHAT_ID, USER_ID) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	_, err := db.Exec(query, chatId, userId)
+	return err
+}
+
+func selectAllFromChatEditWhereChatIdIs(db *sql.DB, chatId string) ([]dto.ChatEdit, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	edits := []dto.ChatEdit{}
+	query := `SELECT CHAT_ID, MESSAGE, EDITED_AT FROM "CHAT_EDIT" WHERE CHAT_ID = $1 ORDER BY EDITED_AT, ID`
+	rows, err := db.Query(query, chatId)
+	if err != nil {
+		return edits, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var edit dto.ChatEdit
+		if err := rows.Scan(&edit.ChatId, &edit.Message, &edit.EditedAt); err != nil {
+			return edits, err
+		}
+		edits = append(edits, edit)
+	}
+	return edits, rows.Err()
+}
+
+const attachmentColumns = `ID, UPLOADER_ID, COALESCE(CHAT_ID, ''), FILE_NAME, CONTENT_TYPE, SIZE, STORAGE_KEY, CREATED_AT`
+
+func insertIntoAttachment(db *sql.DB, attachment *dto.Attachment) error {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `INSERT INTO "ATTACHMENT" (UPLOADER_ID, FILE_NAME, CONTENT_TYPE, SIZE, STORAGE_KEY)
+		VALUES ($1, $2, $3, $4, $5) RETURNING ID, CREATED_AT`
+	err := db.QueryRow(query, attachment.UploaderId, attachment.FileName, attachment.ContentType, attachment.Size, attachment.StorageKey).
+		Scan(&attachment.Id, &attachment.CreatedAt)
+	if err != nil {
+		return err
+	}
+	attachment.Url = constants.ATTACHMENT_URL_PREFIX + attachment.Id
+	return nil
+}
+
+func selectAllFromAttachmentWhereIdIs(db *sql.DB, id string) (dto.Attachment, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `SELECT ` + attachmentColumns + ` FROM "ATTACHMENT" WHERE ID = $1`
+	return scanAttachment(db.QueryRow(query, id))
+}
+
+func selectAllFromAttachmentWhereChatIdIn(db *sql.DB, chatIds []string) ([]dto.Attachment, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `SELECT ` + attachmentColumns + ` FROM "ATTACHMENT" WHERE CHAT_ID = ANY($1) ORDER BY CREATED_AT, ID`
+	rows, err := db.Query(query, pq.Array(chatIds))
+	if err != nil {
+		return []dto.Attachment{}, err
+	}
+	defer rows.Close()
+	return scanAttachments(rows)
+}
+
+func deleteFromAttachmentWhereChatIdIs(db *sql.DB, chatId string) ([]dto.Attachment, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `DELETE FROM "ATTACHMENT" WHERE CHAT_ID = $1 RETURNING ` + attachmentColumns
+	rows, err := db.Query(query, chatId)
+	if err != nil {
+		return []dto.Attachment{}, err
+	}
+	defer rows.Close()
+	return scanAttachments(rows)
+}
+
+func scanAttachments(rows *sql.Rows) ([]dto.Attachment, error) {
+	attachments := []dto.Attachment{}
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return attachments, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, rows.Err()
+}
+
+// scanAttachment reads the attachmentColumns of a single row
+func scanAttachment(row interface{ Scan(...any) error }) (dto.Attachment, error) {
+	var attachment dto.Attachment
+	err := row.Scan(
+		&attachment.Id,
+		&attachment.UploaderId,
+		&attachment.ChatId,
+		&attachment.FileName,
+		&attachment.ContentType,
+		&attachment.Size,
+		&attachment.StorageKey,
+		&attachment.CreatedAt,
+	)
+	attachment.Url = constants.ATTACHMENT_URL_PREFIX + attachment.Id
+	return attachment, err
+}
+
+const outboxColumns = `ID, DEDUP_KEY, COALESCE(RECEIVER_ID, ''), COALESCE(CONVERSATION_ID, ''), BODY, ATTEMPTS, CREATED_AT`
+
+func insertIntoOutbox(tx *sql.Tx, message *dto.OutboxMessage) error {
+	query := `INSERT INTO "OUTBOX" (DEDUP_KEY, RECEIVER_ID, CONVERSATION_ID, BODY)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4) RETURNING ID, CREATED_AT`
+	return tx.QueryRow(query, message.DedupKey, message.ReceiverId, message.ConversationId, message.Body).
+		Scan(&message.Id, &message.CreatedAt)
+}
+
+// updateOutboxClaimPending leases up to limit pending messages, oldest first.
+// Rows locked by another relay are skipped, and a leased row is only claimed
+// again once lease passes.
+func updateOutboxClaimPending(db *sql.DB, limit int, lease time.Duration) ([]dto.OutboxMessage, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `UPDATE "OUTBOX" SET NEXT_ATTEMPT_AT = NOW() + $2 * INTERVAL '1 millisecond'
+		WHERE ID IN (
+			SELECT ID FROM "OUTBOX" WHERE SENT_AT IS NULL AND NEXT_ATTEMPT_AT <= NOW()
+			ORDER BY CREATED_AT LIMIT $1 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + outboxColumns
+	rows, err := db.Query(query, limit, lease.Milliseconds())
+	if err != nil {
+		return []dto.OutboxMessage{}, err
+	}
+	defer rows.Close()
+
+	messages := []dto.OutboxMessage{}
+	for rows.Next() {
+		var message dto.OutboxMessage
+		err := rows.Scan(
+			&message.Id,
+			&message.DedupKey,
+			&message.ReceiverId,
+			&message.ConversationId,
+			&message.Body,
+			&message.Attempts,
+			&message.CreatedAt,
+		)
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, message)
+	}
+	// RETURNING does not keep the order of the subquery
+	slices.SortFunc(messages, func(a, b dto.OutboxMessage) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return messages, rows.Err()
+}
+
+func updateOutboxSetSent(db *sql.DB, id string) error {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `UPDATE "OUTBOX" SET SENT_AT = NOW(), ATTEMPTS = ATTEMPTS + 1, LAST_ERROR = NULL WHERE ID = $1`
+	_, err := db.Exec(query, id)
+	return err
+}
+
+func updateOutboxSetFailed(db *sql.DB, id, lastError string, retryAfter time.Duration) error {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `UPDATE "OUTBOX" SET ATTEMPTS = ATTEMPTS + 1, LAST_ERROR = $2,
+		NEXT_ATTEMPT_AT = NOW() + $3 * INTERVAL '1 millisecond' WHERE ID = $1`
+	_, err := db.Exec(query, id, lastError, retryAfter.Milliseconds())
+	return err
+}
+
+func deleteFromOutboxWhereSentBefore(db *sql.DB, age time.Duration) (int64, error) {
+	if db == nil {
+		panic("db cannot be nil")
+	}
+	query := `DELETE FROM "OUTBOX" WHERE SENT_AT < NOW() - $1 * INTERVAL '1 millisecond'`
+	result, err := db.Exec(query, age.Milliseconds())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
@@ -5,12 +5,13 @@ import (
 	"database/sql"
 	"fmt"
 	"math/rand"
-	"os"
-	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
 	query "github.com/nihal-ramaswamy/GoChat/internal/db"
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
 	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
@@ -105,14 +106,7 @@ func runChatTest(t *testing.T, db *sql.DB) {
 // 3. Selecting the password of a user
 func TestUser(t *testing.T) {
 	ctx := context.Background()
-	wd, err := os.Getwd()
-	if err != nil {
-		t.Fatalf("Error getting working directory: %s", err)
-	}
-	rootDir := filepath.Join(wd, "..", "..")
-	fmt.Println(rootDir)
-
-	container, db, err := testUtils.SetUpPostgresForTesting(ctx, rootDir)
+	container, db, err := testUtils.SetUpPostgresForTesting(ctx)
 	if err != nil {
 		t.Fatalf("Error setting up postgres for testing: %s", err)
 	}
@@ -138,14 +132,25 @@ func TestUser(t *testing.T) {
 // Tests inserting a chat into the database and selecting all chats for each user
 func TestChat(t *testing.T) {
 	ctx := context.Background()
-	wd, err := os.Getwd()
+	container, db, err := testUtils.SetUpPostgresForTesting(ctx)
 	if err != nil {
-		t.Fatalf("Error getting working directory: %s", err)
+		t.Fatalf("Error setting up postgres for testing: %s", err)
 	}
-	rootDir := filepath.Join(wd, "..", "..")
-	fmt.Println(rootDir)
 
-	container, db, err := testUtils.SetUpPostgresForTesting(ctx, rootDir)
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+		db.Close()
+	})
+
+	runChatTest(t, db)
+}
+
+// Tests creating a group conversation, changing its members and reading its history
+func TestConversation(t *testing.T) {
+	ctx := context.Background()
+	container, db, err := testUtils.SetUpPostgresForTesting(ctx)
 	if err != nil {
 		t.Fatalf("Error setting up postgres for testing: %s", err)
 	}
@@ -157,7 +162,581 @@ func TestChat(t *testing.T) {
 		db.Close()
 	})
 
-	runChatTest(t, db)
+	memberIds := []string{}
+	for range 3 {
+		memberIds = append(memberIds, testUtils.RandStringRunes(10))
+	}
+
+	conversation := &dto.Conversation{
+		Type:      constants.CONVERSATION_GROUP,
+		Name:      testUtils.RandStringRunes(10),
+		CreatedBy: memberIds[0],
+		MemberIds: memberIds,
+	}
+	if err := query.CreateConversation(db, conversation); err != nil {
+		t.Fatalf("Error creating conversation: %s", err)
+	}
+	if conversation.Id == "" {
+		t.Fatalf("Expected conversation id, got empty string")
+	}
+
+	newMemberId := testUtils.RandStringRunes(10)
+	if err := query.AddConversationMember(db, conversation.Id, newMemberId); err != nil {
+		t.Fatalf("Error adding member: %s", err)
+	}
+	if err := query.RemoveConversationMember(db, conversation.Id, memberIds[1]); err != nil {
+		t.Fatalf("Error removing member: %s", err)
+	}
+
+	conversationFromDB, err := query.GetConversation(db, conversation.Id)
+	if err != nil {
+		t.Fatalf("Error selecting conversation: %s", err)
+	}
+	if len(conversationFromDB.MemberIds) != 3 {
+		t.Errorf("Expected 3 members, got %d", len(conversationFromDB.MemberIds))
+	}
+	if !conversationFromDB.HasMember(newMemberId) || conversationFromDB.HasMember(memberIds[1]) {
+		t.Errorf("Unexpected members: %v", conversationFromDB.MemberIds)
+	}
+
+	for range 5 {
+		err := query.SaveChat(db, &dto.Chat{
+			SenderId:       memberIds[0],
+			ReceiverId:     conversation.Id,
+			ConversationId: conversation.Id,
+			Message:        testUtils.RandStringRunes(10),
+		})
+		if err != nil {
+			t.Fatalf("Error inserting chat: %s", err)
+		}
+	}
+	if err := query.SaveChat(db, &dto.Chat{
+		SenderId:   memberIds[0],
+		ReceiverId: memberIds[2],
+		Message:    testUtils.RandStringRunes(10),
+	}); err != nil {
+		t.Fatalf("Error inserting chat: %s", err)
+	}
+
+	chats, err := query.ReadChatForConversation(db, conversation.Id)
+	if err != nil {
+		t.Fatalf("Error selecting chats: %s", err)
+	}
+	if len(chats) != 5 {
+		t.Errorf("Expected 5 chats, got %d", len(chats))
+	}
+
+	// Only one of the concurrent requests creates the direct conversation
+	userId1, userId2 := testUtils.RandStringRunes(10), testUtils.RandStringRunes(10)
+	errs := make(chan error, 10)
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			memberIds := []string{userId1, userId2}
+			if i%2 == 1 {
+				memberIds = []string{userId2, userId1}
+			}
+			errs <- query.CreateConversation(db, &dto.Conversation{
+				Type:      constants.CONVERSATION_DIRECT,
+				CreatedBy: memberIds[0],
+				MemberIds: memberIds,
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			created++
+		case query.ErrConversationExists:
+		default:
+			t.Fatalf("Error creating conversation: %s", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("Expected 1 direct conversation to be created, got %d", created)
+	}
+	if _, err := query.GetDirectConversationId(db, userId2, userId1); err != nil {
+		t.Errorf("Error getting direct conversation: %s", err)
+	}
+}
+
+// Tests reading history page by page, backwards and forwards, including
+// messages that share the same timestamp
+func TestChatPagination(t *testing.T) {
+	ctx := context.Background()
+	container, db, err := testUtils.SetUpPostgresForTesting(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up postgres for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+		db.Close()
+	})
+
+	userId := testUtils.RandStringRunes(10)
+	peerId := testUtils.RandStringRunes(10)
+	otherId := testUtils.RandStringRunes(10)
+	start := time.Now().UTC().Truncate(time.Second)
+
+	for i := range 25 {
+		err := query.SaveChat(db, &dto.Chat{
+			SenderId:   userId,
+			ReceiverId: peerId,
+			Message:    testUtils.RandStringRunes(10),
+			CreatedAt:  start.Add(time.Duration(i/2) * time.Second),
+		})
+		if err != nil {
+			t.Fatalf("Error inserting chat: %s", err)
+		}
+	}
+	if err := query.SaveChat(db, &dto.Chat{
+		SenderId:   userId,
+		ReceiverId: otherId,
+		Message:    testUtils.RandStringRunes(10),
+		CreatedAt:  start.Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("Error inserting chat: %s", err)
+	}
+
+	seen := map[string]bool{}
+	page := &dto.ChatPageQuery{UserId: userId, PeerId: peerId, Limit: 10}
+	var previous *dto.Chat
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("Expected 3 pages")
+		}
+		chats, next, err := query.ReadChatPage(db, page)
+		if err != nil {
+			t.Fatalf("Error reading page: %s", err)
+		}
+		for i := range chats {
+			if seen[chats[i].Id] {
+				t.Fatalf("Chat %s returned twice", chats[i].Id)
+			}
+			seen[chats[i].Id] = true
+			if previous != nil && chats[i].CreatedAt.After(previous.CreatedAt) {
+				t.Fatalf("Expected newest first")
+			}
+			previous = &chats[i]
+		}
+		if next == nil {
+			break
+		}
+		page.Before = next
+	}
+	if len(seen) != 25 {
+		t.Errorf("Expected 25 chats, got %d", len(seen))
+	}
+
+	chats, next, err := query.ReadChatPage(db, &dto.ChatPageQuery{
+		UserId: userId,
+		After:  dto.NewChatCursor(previous),
+		Limit:  50,
+	})
+	if err != nil {
+		t.Fatalf("Error reading page: %s", err)
+	}
+	if next != nil {
+		t.Errorf("Expected no next cursor")
+	}
+	// Everything except the oldest message with the peer, plus the one with otherId
+	if len(chats) != 25 {
+		t.Errorf("Expected 25 chats, got %d", len(chats))
+	}
+}
+
+// Tests that receipts only move forward from delivered to read, and that
+// marking read up to a message only touches older messages received from the
+// peer and only accepts a message of that thread
+func TestChatReceipt(t *testing.T) {
+	ctx := context.Background()
+	container, db, err := testUtils.SetUpPostgresForTesting(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up postgres for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+		db.Close()
+	})
+
+	senderId := testUtils.RandStringRunes(10)
+	receiverId := testUtils.RandStringRunes(10)
+	start := time.Now().UTC().Truncate(time.Second)
+
+	chats := []*dto.Chat{}
+	for i := range 4 {
+		chat := &dto.Chat{
+			SenderId:   senderId,
+			ReceiverId: receiverId,
+			Message:    testUtils.RandStringRunes(10),
+			CreatedAt:  start.Add(time.Duration(i) * time.Second),
+		}
+		if err := query.SaveChat(db, chat); err != nil {
+			t.Fatalf("Error inserting chat: %s", err)
+		}
+		chats = append(chats, chat)
+	}
+
+	changed, err := query.MarkChatDelivered(db, chats[0].Id, receiverId)
+	if err != nil || !changed {
+		t.Fatalf("Expected chat to be marked delivered: %v", err)
+	}
+	changed, err = query.MarkChatDelivered(db, chats[0].Id, receiverId)
+	if err != nil || changed {
+		t.Fatalf("Expected delivered chat to stay unchanged: %v", err)
+	}
+
+	other := &dto.Chat{
+		SenderId:   testUtils.RandStringRunes(10),
+		ReceiverId: receiverId,
+		Message:    testUtils.RandStringRunes(10),
+		CreatedAt:  start.Add(time.Minute),
+	}
+	if err := query.SaveChat(db, other); err != nil {
+		t.Fatalf("Error inserting chat: %s", err)
+	}
+	_, err = query.MarkChatReadUpTo(db, receiverId, &dto.ReadReceiptRequest{
+		PeerId: senderId,
+		UpTo:   other.Id,
+	})
+	if err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows for a message of another thread, got %v", err)
+	}
+
+	read, err := query.MarkChatReadUpTo(db, receiverId, &dto.ReadReceiptRequest{
+		PeerId: senderId,
+		UpTo:   chats[2].Id,
+	})
+	if err != nil {
+		t.Fatalf("Error marking chats read: %s", err)
+	}
+	if len(read) != 3 {
+		t.Errorf("Expected 3 chats marked read, got %d", len(read))
+	}
+
+	changed, err = query.MarkChatDelivered(db, chats[1].Id, receiverId)
+	if err != nil || changed {
+		t.Fatalf("Expected read chat to stay read: %v", err)
+	}
+
+	receipts, err := query.GetChatReceipts(db, chats[1].Id)
+	if err != nil {
+		t.Fatalf("Error reading receipts: %s", err)
+	}
+	if len(receipts) != 1 || receipts[0].Status != constants.RECEIPT_READ {
+		t.Errorf("Expected a single read receipt, got %v", receipts)
+	}
+
+	receipts, err = query.GetChatReceipts(db, chats[3].Id)
+	if err != nil {
+		t.Fatalf("Error reading receipts: %s", err)
+	}
+	if len(receipts) != 0 {
+		t.Errorf("Expected no receipts, got %v", receipts)
+	}
+}
+
+// Tests editing, deleting for everyone and hiding a chat for a single user
+func TestChatEdit(t *testing.T) {
+	ctx := context.Background()
+	container, db, err := testUtils.SetUpPostgresForTesting(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up postgres for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+		db.Close()
+	})
+
+	senderId := testUtils.RandStringRunes(10)
+	receiverId := testUtils.RandStringRunes(10)
+	start := time.Now().UTC().Truncate(time.Second)
+
+	chats := []*dto.Chat{}
+	for i := range 2 {
+		chat := &dto.Chat{
+			SenderId:   senderId,
+			ReceiverId: receiverId,
+			Message:    testUtils.RandStringRunes(10),
+			CreatedAt:  start.Add(time.Duration(i) * time.Second),
+		}
+		if err := query.SaveChat(db, chat); err != nil {
+			t.Fatalf("Error inserting chat: %s", err)
+		}
+		chats = append(chats, chat)
+	}
+
+	if _, err := query.EditChat(db, chats[0].Id, receiverId, "not mine"); err != sql.ErrNoRows {
+		t.Errorf("Expected only the sender to edit a chat, got %v", err)
+	}
+
+	edited, err := query.EditChat(db, chats[0].Id, senderId, "edited")
+	if err != nil {
+		t.Fatalf("Error editing chat: %s", err)
+	}
+	if edited.Message != "edited" || edited.EditedAt == nil {
+		t.Errorf("Expected edited chat, got %v", edited)
+	}
+
+	edits, err := query.GetChatEdits(db, chats[0].Id)
+	if err != nil {
+		t.Fatalf("Error reading edits: %s", err)
+	}
+	if len(edits) != 1 || edits[0].Message != chats[0].Message {
+		t.Errorf("Expected the original message in the edit history, got %v", edits)
+	}
+
+	// Concurrent edits each keep the version they replaced exactly once
+	errs := make(chan error, 10)
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := query.EditChat(db, chats[0].Id, senderId, fmt.Sprintf("concurrent %d", i))
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Error editing chat: %s", err)
+		}
+	}
+
+	current, err := query.GetChat(db, chats[0].Id)
+	if err != nil {
+		t.Fatalf("Error getting chat: %s", err)
+	}
+	edits, err = query.GetChatEdits(db, chats[0].Id)
+	if err != nil {
+		t.Fatalf("Error reading edits: %s", err)
+	}
+	versions := map[string]bool{current.Message: true}
+	for _, edit := range edits {
+		versions[edit.Message] = true
+	}
+	if len(edits) != 11 || len(versions) != 12 {
+		t.Errorf("Expected every replaced version once in the edit history, got %v", edits)
+	}
+
+	deleted, err := query.DeleteChatForEveryone(db, chats[0].Id, senderId)
+	if err != nil {
+		t.Fatalf("Error deleting chat: %s", err)
+	}
+	if deleted.Message != "" || deleted.DeletedAt == nil {
+		t.Errorf("Expected deleted chat, got %v", deleted)
+	}
+	if _, err := query.EditChat(db, chats[0].Id, senderId, "again"); err != sql.ErrNoRows {
+		t.Errorf("Expected deleted chat to not be editable, got %v", err)
+	}
+	edits, err = query.GetChatEdits(db, chats[0].Id)
+	if err != nil || len(edits) != 0 {
+		t.Errorf("Expected edit history to be cleared, got %v %v", edits, err)
+	}
+
+	if err := query.DeleteChatForUser(db, chats[1].Id, receiverId); err != nil {
+		t.Fatalf("Error hiding chat: %s", err)
+	}
+
+	page, _, err := query.ReadChatPage(db, &dto.ChatPageQuery{UserId: receiverId, PeerId: senderId, Limit: 10})
+	if err != nil {
+		t.Fatalf("Error reading chat page: %s", err)
+	}
+	if len(page) != 1 || page[0].Id != chats[0].Id {
+		t.Errorf("Expected only the deleted chat for the receiver, got %v", page)
+	}
+
+	page, _, err = query.ReadChatPage(db, &dto.ChatPageQuery{UserId: senderId, PeerId: receiverId, Limit: 10})
+	if err != nil {
+		t.Fatalf("Error reading chat page: %s", err)
+	}
+	if len(page) != 2 {
+		t.Errorf("Expected the hidden chat to stay visible to the sender, got %v", page)
+	}
+}
+
+// Tests that attachments can only be sent once, by their uploader
+func TestAttachment(t *testing.T) {
+	ctx := context.Background()
+	container, db, err := testUtils.SetUpPostgresForTesting(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up postgres for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+		db.Close()
+	})
+
+	senderId := testUtils.RandStringRunes(10)
+	receiverId := testUtils.RandStringRunes(10)
+
+	attachment := &dto.Attachment{
+		UploaderId:  senderId,
+		FileName:    "file.txt",
+		ContentType: "text/plain",
+		Size:        10,
+		StorageKey:  testUtils.RandStringRunes(20),
+	}
+	if err := query.SaveAttachment(db, attachment); err != nil {
+		t.Fatalf("Error saving attachment: %s", err)
+	}
+
+	stolen := &dto.Chat{
+		SenderId:      receiverId,
+		ReceiverId:    senderId,
+		CreatedAt:     time.Now(),
+		AttachmentIds: []string{attachment.Id},
+	}
+	if err := query.SaveChat(db, stolen); err != query.ErrAttachmentUnavailable {
+		t.Errorf("Expected attachments of other users to be rejected, got %v", err)
+	}
+
+	chat := &dto.Chat{
+		SenderId:      senderId,
+		ReceiverId:    receiverId,
+		CreatedAt:     time.Now(),
+		AttachmentIds: []string{attachment.Id},
+	}
+	if err := query.SaveChat(db, chat); err != nil {
+		t.Fatalf("Error saving chat: %s", err)
+	}
+	if len(chat.Attachments) != 1 || chat.Attachments[0].ChatId != chat.Id {
+		t.Errorf("Expected attachment to be linked to the chat, got %v", chat.Attachments)
+	}
+
+	resent := &dto.Chat{
+		SenderId:      senderId,
+		ReceiverId:    receiverId,
+		CreatedAt:     time.Now(),
+		AttachmentIds: []string{attachment.Id},
+	}
+	if err := query.SaveChat(db, resent); err != query.ErrAttachmentUnavailable {
+		t.Errorf("Expected attachments to be sent only once, got %v", err)
+	}
+
+	chats, err := query.ReadChatForUser(db, receiverId)
+	if err != nil {
+		t.Fatalf("Error reading chats: %s", err)
+	}
+	if len(chats) != 1 || len(chats[0].Attachments) != 1 {
+		t.Errorf("Expected a single chat with its attachment, got %v", chats)
+	}
+}
+
+// Tests keyword search, visibility, filters and pagination of search results
+func TestChatSearch(t *testing.T) {
+	ctx := context.Background()
+	container, db, err := testUtils.SetUpPostgresForTesting(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up postgres for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+		db.Close()
+	})
+
+	userId := testUtils.RandStringRunes(10)
+	peerId := testUtils.RandStringRunes(10)
+	otherId := testUtils.RandStringRunes(10)
+	start := time.Now().UTC().Truncate(time.Second)
+
+	messages := []struct {
+		senderId, receiverId, message string
+	}{
+		{userId, peerId, "Lunch tomorrow at the usual place?"},
+		{peerId, userId, "Sure, lunches there are great"},
+		{peerId, userId, "Did you finish the report?"},
+		{otherId, peerId, "Lunch without the others"},
+		{userId, peerId, "Running late for lunch"},
+	}
+	for i, m := range messages {
+		chat := &dto.Chat{
+			SenderId:   m.senderId,
+			ReceiverId: m.receiverId,
+			Message:    m.message,
+			CreatedAt:  start.Add(time.Duration(i) * time.Second),
+		}
+		if err := query.SaveChat(db, chat); err != nil {
+			t.Fatalf("Error inserting chat: %s", err)
+		}
+	}
+
+	results, next, err := query.SearchChat(db, &dto.ChatSearchQuery{UserId: userId, Query: "lunch", Limit: 2})
+	if err != nil {
+		t.Fatalf("Error searching chat: %s", err)
+	}
+	if len(results) != 2 || next == nil {
+		t.Fatalf("Expected a full first page, got %d results", len(results))
+	}
+	if results[0].Message != messages[4].message {
+		t.Errorf("Expected newest match first, got %s", results[0].Message)
+	}
+	if !strings.Contains(results[0].Snippet, "<mark>lunch</mark>") {
+		t.Errorf("Expected highlighted snippet, got %s", results[0].Snippet)
+	}
+
+	results, next, err = query.SearchChat(db, &dto.ChatSearchQuery{UserId: userId, Query: "lunch", Limit: 2, Before: next})
+	if err != nil {
+		t.Fatalf("Error searching chat: %s", err)
+	}
+	if len(results) != 1 || next != nil || results[0].Message != messages[0].message {
+		t.Errorf("Expected only the oldest match on the last page, got %v", results)
+	}
+
+	results, _, err = query.SearchChat(db, &dto.ChatSearchQuery{UserId: userId, Query: "lunch", SenderId: peerId, Limit: 10})
+	if err != nil {
+		t.Fatalf("Error searching chat: %s", err)
+	}
+	if len(results) != 1 || results[0].Message != messages[1].message {
+		t.Errorf("Expected stemmed match from the peer only, got %v", results)
+	}
+
+	from := start.Add(time.Second)
+	to := start.Add(3 * time.Second)
+	results, _, err = query.SearchChat(db, &dto.ChatSearchQuery{UserId: userId, Query: "lunch", From: &from, To: &to, Limit: 10})
+	if err != nil {
+		t.Fatalf("Error searching chat: %s", err)
+	}
+	if len(results) != 1 || results[0].Message != messages[1].message {
+		t.Errorf("Expected a single match in the date range, got %v", results)
+	}
+
+	// Message text is escaped so only the highlight is markup
+	chat := &dto.Chat{SenderId: userId, ReceiverId: peerId, Message: "<script>alert(1)</script> pizza\x02?", CreatedAt: time.Now()}
+	if err := query.SaveChat(db, chat); err != nil {
+		t.Fatalf("Error inserting chat: %s", err)
+	}
+	results, _, err = query.SearchChat(db, &dto.ChatSearchQuery{UserId: userId, Query: "pizza", Limit: 10})
+	if err != nil {
+		t.Fatalf("Error searching chat: %s", err)
+	}
+	if len(results) != 1 || strings.Contains(results[0].Snippet, "<script>") ||
+		!strings.Contains(results[0].Snippet, "&lt;script&gt;") || !strings.Contains(results[0].Snippet, "<mark>pizza</mark>?") {
+		t.Errorf("Expected an escaped snippet, got %v", results)
+	}
 }
 
 // Tests password encryption and decryption
@@ -174,7 +753,7 @@ func TestChat(t *testing.T) {
 // 	}
 //
 // 	rootDir := filepath.Join(wd, "..", "..")
-// 	container, db, err := testUtils.SetUpPostgresForTesting(ctx, rootDir)
+// 	container, db, err := testUtils.SetUpPostgresForTesting(ctx)
 // 	if err != nil {
 // 		t.Fatalf("Error setting up postgres for testing: %s", err)
 // 	}
@@ -201,3 +780,121 @@ func TestChat(t *testing.T) {
 // 		t.Fatalf("Error matching password. Expected no match")
 // 	}
 // }
+
+// Tests that chats are saved together with their outbox event and that
+// concurrent relays never claim the same event
+func TestOutbox(t *testing.T) {
+	ctx := context.Background()
+	container, db, err := testUtils.SetUpPostgresForTesting(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up postgres for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+		db.Close()
+	})
+
+	countOutbox := func() int {
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM "OUTBOX"`).Scan(&count); err != nil {
+			t.Fatalf("Error counting outbox events: %s", err)
+		}
+		return count
+	}
+
+	senderId := testUtils.RandStringRunes(10)
+	receiverId := testUtils.RandStringRunes(10)
+
+	chat := &dto.Chat{SenderId: senderId, ReceiverId: receiverId, Message: "hello", CreatedAt: time.Now()}
+	if err := query.SaveChat(db, chat); err != nil {
+		t.Fatalf("Error saving chat: %s", err)
+	}
+	var outboxReceiverId string
+	err = db.QueryRow(`SELECT RECEIVER_ID FROM "OUTBOX" WHERE DEDUP_KEY = $1`, constants.WS_MESSAGE+":"+chat.Id).
+		Scan(&outboxReceiverId)
+	if err != nil {
+		t.Fatalf("Expected an outbox event for the chat: %s", err)
+	}
+	if outboxReceiverId != receiverId {
+		t.Errorf("Expected the event for %s, got %s", receiverId, outboxReceiverId)
+	}
+
+	// The attachment belongs to someone else, so the chat and its event roll back
+	attachment := &dto.Attachment{
+		UploaderId:  receiverId,
+		FileName:    "file.txt",
+		ContentType: "text/plain",
+		Size:        10,
+		StorageKey:  testUtils.RandStringRunes(20),
+	}
+	if err := query.SaveAttachment(db, attachment); err != nil {
+		t.Fatalf("Error saving attachment: %s", err)
+	}
+	before := countOutbox()
+	rejected := &dto.Chat{SenderId: senderId, ReceiverId: receiverId, CreatedAt: time.Now(), AttachmentIds: []string{attachment.Id}}
+	if err := query.SaveChat(db, rejected); err != query.ErrAttachmentUnavailable {
+		t.Fatalf("Expected ErrAttachmentUnavailable, got %v", err)
+	}
+	if after := countOutbox(); after != before {
+		t.Errorf("Expected no outbox event for the rejected chat, got %d more", after-before)
+	}
+
+	for range 49 {
+		chat := &dto.Chat{SenderId: senderId, ReceiverId: receiverId, Message: "hello", CreatedAt: time.Now()}
+		if err := query.SaveChat(db, chat); err != nil {
+			t.Fatalf("Error saving chat: %s", err)
+		}
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		claimed = map[string]int{}
+		errs    = make(chan error, 10)
+	)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			messages, err := query.ClaimOutbox(db, 10, time.Minute)
+			if err != nil {
+				errs <- err
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, message := range messages {
+				claimed[message.Id]++
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Error claiming outbox events: %s", err)
+	}
+
+	// A claim can come back short when rows it skipped were leased meanwhile
+	rest, err := query.ClaimOutbox(db, 50, time.Minute)
+	if err != nil {
+		t.Fatalf("Error claiming outbox events: %s", err)
+	}
+	for _, message := range rest {
+		claimed[message.Id]++
+	}
+
+	for id, count := range claimed {
+		if count > 1 {
+			t.Errorf("Event %s was claimed %d times", id, count)
+		}
+	}
+	if len(claimed) != 50 {
+		t.Errorf("Expected every event to be claimed once, got %d", len(claimed))
+	}
+	if messages, err := query.ClaimOutbox(db, 10, time.Minute); err != nil || len(messages) != 0 {
+		t.Errorf("Expected leased events not to be claimed again, got %d: %v", len(messages), err)
+	}
+}
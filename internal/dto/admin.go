@@ -0,0 +1,24 @@
+package dto
+
+import "time"
+
+// AdminUser is a user as seen by moderators and admins
+type AdminUser struct {
+	Id         string     `json:"id"`
+	Name       string     `json:"name"`
+	Email      string     `json:"email"`
+	Type       string     `json:"type"`
+	Role       string     `json:"role"`
+	CreatedAt  time.Time  `json:"created_at"`
+	DisabledAt *time.Time `json:"disabled_at"`
+}
+
+type UserPageQuery struct {
+	Query  string
+	Limit  int
+	Offset int
+}
+
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
@@ -0,0 +1,17 @@
+package dto
+
+import "time"
+
+// Attachment is a file uploaded by a user. It belongs to the uploader until it
+// is sent with a chat, after which everyone who can see the chat can download it.
+type Attachment struct {
+	Id          string    `json:"id"`
+	UploaderId  string    `json:"uploader_id"`
+	ChatId      string    `json:"message_id,omitempty"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	StorageKey  string    `json:"-"`
+	Url         string    `json:"url"`
+	CreatedAt   time.Time `json:"created_at"`
+}
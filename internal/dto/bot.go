@@ -0,0 +1,48 @@
+package dto
+
+import "time"
+
+// Bot is a service account owned by a user. It cannot sign in with a
+// password and authenticates with API keys instead.
+type Bot struct {
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	OwnerId string `json:"owner_id"`
+}
+
+type CreateBotRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type ApiKey struct {
+	Id         string     `json:"id"`
+	UserId     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+type CreateApiKeyRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required"`
+	// Keys never expire when ExpiresInDays is 0
+	ExpiresInDays int `json:"expires_in_days"`
+}
+
+// NewApiKey is returned once when a key is created. Only a hash of Key is stored.
+type NewApiKey struct {
+	ApiKey
+	Key string `json:"key"`
+}
+
+// ApiKeyPrincipal is the bot an API key authenticated as
+type ApiKeyPrincipal struct {
+	KeyId  string
+	UserId string
+	Email  string
+	Scopes []string
+}
@@ -1,10 +1,90 @@
 package dto
 
-import "time"
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
 
 type Chat struct {
-	SenderId   string    `json:"sender_id"`
-	ReceiverId string    `json:"receiver_id"`
-	Message    string    `json:"message"`
-	CreatedAt  time.Time `json:"created_at"`
+	Id             string     `json:"id"`
+	SenderId       string     `json:"sender_id"`
+	ReceiverId     string     `json:"receiver_id"`
+	ConversationId string     `json:"conversation_id,omitempty"`
+	Message        string     `json:"message"`
+	CreatedAt      time.Time  `json:"created_at"`
+	EditedAt       *time.Time `json:"edited_at,omitempty"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+	// AttachmentIds references uploaded attachments when sending a chat
+	AttachmentIds []string     `json:"attachment_ids,omitempty"`
+	Attachments   []Attachment `json:"attachments,omitempty"`
+}
+
+// ChatEdit is a previous version of a chat message
+type ChatEdit struct {
+	ChatId   string    `json:"message_id"`
+	Message  string    `json:"message"`
+	EditedAt time.Time `json:"edited_at"`
+}
+
+// ChatDeleteEvent is pushed to recipients when a chat is deleted for everyone
+type ChatDeleteEvent struct {
+	Id             string    `json:"id"`
+	ConversationId string    `json:"conversation_id,omitempty"`
+	DeletedAt      time.Time `json:"deleted_at"`
+}
+
+// ChatCursor is a position in the history, ordered by CreatedAt and then Id
+type ChatCursor struct {
+	CreatedAt time.Time
+	Id        string
+}
+
+func NewChatCursor(chat *Chat) *ChatCursor {
+	return &ChatCursor{
+		CreatedAt: chat.CreatedAt,
+		Id:        chat.Id,
+	}
+}
+
+// Encode returns an opaque string that can be handed to clients
+func (c *ChatCursor) Encode() string {
+	raw := c.CreatedAt.Format(time.RFC3339Nano) + "|" + c.Id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeChatCursor(encoded string) (*ChatCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid cursor: %s", err)
+	}
+
+	createdAt, id, found := strings.Cut(string(raw), "|")
+	if !found || id == "" {
+		return nil, fmt.Errorf("Invalid cursor")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid cursor: %s", err)
+	}
+
+	return &ChatCursor{CreatedAt: t, Id: id}, nil
+}
+
+// ChatPageQuery selects one page of the history visible to UserId.
+// Without Before or After the newest messages are returned first.
+type ChatPageQuery struct {
+	UserId         string
+	PeerId         string
+	ConversationId string
+	Before         *ChatCursor
+	After          *ChatCursor
+	Limit          int
+}
+
+type ChatPage struct {
+	Messages   []Chat `json:"messages"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
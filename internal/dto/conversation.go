@@ -0,0 +1,25 @@
+package dto
+
+import "time"
+
+type Conversation struct {
+	Id        string    `json:"id"`
+	Type      string    `json:"type"`
+	Name      string    `json:"name"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	MemberIds []string  `json:"member_ids"`
+}
+
+func (c *Conversation) HasMember(id string) bool {
+	for _, memberId := range c.MemberIds {
+		if memberId == id {
+			return true
+		}
+	}
+	return false
+}
+
+type ConversationMember struct {
+	UserId string `json:"user_id"`
+}
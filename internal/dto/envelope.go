@@ -0,0 +1,53 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WsEnvelope frames every message exchanged over the websocket and every
+// event published to a user's queue
+type WsEnvelope struct {
+	Type      string `json:"type"`
+	RequestId string `json:"request_id,omitempty"`
+	// DedupKey is set on events that can be published more than once
+	DedupKey string          `json:"dedup_key,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+func NewWsEnvelope(envelopeType, requestId string, data any) (*WsEnvelope, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WsEnvelope{
+		Type:      envelopeType,
+		RequestId: requestId,
+		Data:      raw,
+	}, nil
+}
+
+type WsAck struct {
+	Id        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WsError struct {
+	Error string `json:"error"`
+}
+
+// WsRateLimited is the error sent for a send over the chat rate limit
+type WsRateLimited struct {
+	Error      string `json:"error"`
+	RetryAfter int64  `json:"retry_after"`
+}
+
+// MarshalWsEnvelope returns the JSON of an envelope, ready to be published
+func MarshalWsEnvelope(envelopeType string, data any) ([]byte, error) {
+	envelope, err := NewWsEnvelope(envelopeType, "", data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelope)
+}
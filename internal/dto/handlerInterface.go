@@ -10,3 +10,10 @@ type HandlerInterface interface {
 	RequestMethod() string
 	Middlewares() []gin.HandlerFunc
 }
+
+// ScopedHandlerInterface is implemented by handlers that bots may call with
+// an API key granted the returned scope
+type ScopedHandlerInterface interface {
+	HandlerInterface
+	Scope() string
+}
@@ -0,0 +1,16 @@
+package dto
+
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
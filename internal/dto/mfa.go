@@ -0,0 +1,33 @@
+package dto
+
+type TotpEnrollment struct {
+	Secret string `json:"secret"`
+	Uri    string `json:"uri"`
+}
+
+type TotpCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MfaChallenge is returned by sign in instead of a TokenPair when the user
+// has two-factor authentication enabled
+type MfaChallenge struct {
+	MfaRequired bool   `json:"mfa_required"`
+	MfaToken    string `json:"mfa_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// SecondFactor is either a TOTP code or one of the recovery codes
+type SecondFactor struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type MfaVerifyRequest struct {
+	MfaToken string `json:"mfa_token" binding:"required"`
+	SecondFactor
+}
@@ -0,0 +1,16 @@
+package dto
+
+// OidcIdentity is the user an identity provider vouched for in an ID token
+type OidcIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// OidcLogin is kept between redirecting to the identity provider and its callback
+type OidcLogin struct {
+	Verifier string
+	Nonce    string
+}
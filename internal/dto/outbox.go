@@ -0,0 +1,42 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+)
+
+// OutboxMessage is an event waiting to be published to the receiver of a
+// chat, or to every member of its conversation
+type OutboxMessage struct {
+	Id             string
+	DedupKey       string
+	ReceiverId     string
+	ConversationId string
+	Body           []byte
+	Attempts       int
+	CreatedAt      time.Time
+}
+
+// NewChatOutboxMessage returns the message envelope announcing a saved chat.
+// Its dedup key is derived from the chat id, so consumers can drop copies
+// published more than once.
+func NewChatOutboxMessage(chat *Chat) (*OutboxMessage, error) {
+	dedupKey := constants.WS_MESSAGE + ":" + chat.Id
+	data, err := json.Marshal(chat)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(&WsEnvelope{Type: constants.WS_MESSAGE, DedupKey: dedupKey, Data: data})
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutboxMessage{
+		DedupKey:       dedupKey,
+		ReceiverId:     chat.ReceiverId,
+		ConversationId: chat.ConversationId,
+		Body:           body,
+	}, nil
+}
@@ -0,0 +1,17 @@
+package dto
+
+import "time"
+
+type Presence struct {
+	UserId   string     `json:"user_id"`
+	Online   bool       `json:"online"`
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+}
+
+type PresencePrivacy struct {
+	HideLastSeen bool `json:"hide_last_seen"`
+}
+
+type PresenceSubscription struct {
+	UserIds []string `json:"user_ids"`
+}
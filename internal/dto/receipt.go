@@ -0,0 +1,26 @@
+package dto
+
+import "time"
+
+type ChatReceipt struct {
+	ChatId    string    `json:"message_id"`
+	UserId    string    `json:"user_id"`
+	Status    string    `json:"status"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ReadReceiptRequest marks every message received in a conversation, or from
+// a peer, up to and including UpTo as read
+type ReadReceiptRequest struct {
+	ConversationId string `json:"conversation_id"`
+	PeerId         string `json:"peer_id"`
+	UpTo           string `json:"up_to"`
+}
+
+// ReceiptEvent is pushed to the sender when the status of their messages changes
+type ReceiptEvent struct {
+	MessageIds []string  `json:"message_ids"`
+	UserId     string    `json:"user_id"`
+	Status     string    `json:"status"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
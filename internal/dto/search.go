@@ -0,0 +1,27 @@
+package dto
+
+import "time"
+
+// ChatSearchQuery selects one page of the messages visible to UserId that
+// match Query, newest first
+type ChatSearchQuery struct {
+	UserId         string
+	Query          string
+	SenderId       string
+	ConversationId string
+	From           *time.Time
+	To             *time.Time
+	Before         *ChatCursor
+	Limit          int
+}
+
+// ChatSearchResult is a matching chat with the matching parts of its message highlighted
+type ChatSearchResult struct {
+	Chat
+	Snippet string `json:"snippet"`
+}
+
+type ChatSearchPage struct {
+	Results    []ChatSearchResult `json:"results"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
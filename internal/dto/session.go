@@ -0,0 +1,29 @@
+package dto
+
+import "time"
+
+// Session is a signed in device. Its refresh tokens form a single family:
+// every refresh replaces the token, and replaying a replaced token revokes
+// the session.
+type Session struct {
+	Id         string    `json:"id"`
+	UserId     string    `json:"user_id"`
+	Email      string    `json:"-"`
+	Role       string    `json:"-"`
+	UserAgent  string    `json:"user_agent"`
+	Ip         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	// Current is set when listing sessions for the session making the request
+	Current bool `json:"current"`
+}
+
+type TokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
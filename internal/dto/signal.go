@@ -0,0 +1,19 @@
+package dto
+
+import "time"
+
+// Signal is an ephemeral, non-persisted event such as typing started or
+// stopped. Exactly one of ReceiverId and ConversationId is set.
+type Signal struct {
+	SenderId       string    `json:"sender_id"`
+	ReceiverId     string    `json:"receiver_id,omitempty"`
+	ConversationId string    `json:"conversation_id,omitempty"`
+	Kind           string    `json:"kind"`
+	Active         bool      `json:"active"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+// Key identifies the target and kind of a signal
+func (s *Signal) Key() string {
+	return s.Kind + "|" + s.ReceiverId + "|" + s.ConversationId
+}
@@ -7,6 +7,10 @@ type User struct {
 	Name     string `json:"name"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// Type is either human or bot. It is never read from requests.
+	Type string `json:"-"`
+	// Role is loaded when a session starts and carried in its access tokens
+	Role string `json:"-"`
 }
 
 func (u *User) HashAndSalt() *User {
@@ -7,24 +7,37 @@ import (
 )
 
 type WebsocketConnection struct {
-	Conn   *websocket.Conn
-	Active bool
+	Conn *websocket.Conn
+	// gorilla/websocket supports a single concurrent writer
+	writeLock sync.Mutex
 }
 
 func NewWebsocketConnection(conn *websocket.Conn) *WebsocketConnection {
 	return &WebsocketConnection{
-		Conn:   conn,
-		Active: true,
+		Conn: conn,
 	}
 }
 
+func (wc *WebsocketConnection) WriteMessage(messageType int, data []byte) error {
+	wc.writeLock.Lock()
+	defer wc.writeLock.Unlock()
+	return wc.Conn.WriteMessage(messageType, data)
+}
+
+func (wc *WebsocketConnection) WriteJSON(v any) error {
+	wc.writeLock.Lock()
+	defer wc.writeLock.Unlock()
+	return wc.Conn.WriteJSON(v)
+}
+
 func (wc *WebsocketConnection) Close() {
-	wc.Active = false
 	wc.Conn.Close()
 }
 
 // ------------------------------------------------------------------------------------------------
 
+// WebsocketConnectionMap holds the open connections by connection id. A user
+// can have several, one per device or tab.
 type WebsocketConnectionMap struct {
 	mp   map[string]*WebsocketConnection
 	lock sync.RWMutex
@@ -55,3 +68,9 @@ func (wm *WebsocketConnectionMap) Delete(id string) {
 	defer wm.lock.Unlock()
 	delete(wm.mp, id)
 }
+
+func (wm *WebsocketConnectionMap) Len() int {
+	wm.lock.RLock()
+	defer wm.lock.RUnlock()
+	return len(wm.mp)
+}
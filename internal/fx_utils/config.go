@@ -1,10 +1,14 @@
 package fx_utils
 
 import (
-	amqpConfig "github.com/nihal-ramaswamy/GoChat/internal/amqp"
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/mailer"
 	postgresConfig "github.com/nihal-ramaswamy/GoChat/internal/postgres"
 	redis_config "github.com/nihal-ramaswamy/GoChat/internal/redis"
 	"github.com/nihal-ramaswamy/GoChat/internal/server"
+	"github.com/nihal-ramaswamy/GoChat/internal/sso"
+	"github.com/nihal-ramaswamy/GoChat/internal/storage"
 	"go.uber.org/fx"
 )
 
@@ -18,5 +22,20 @@ var ConfigModule = fx.Module(
 			fx.ResultTags(`name:"auth_rdb_config"`),
 		),
 	),
-	fx.Provide(amqpConfig.DefaultAmqpConfig),
+	fx.Provide(
+		fx.Annotate(
+			redis_config.DefaultRedisPresenceConfig,
+			fx.ResultTags(`name:"presence_rdb_config"`),
+		),
+	),
+	fx.Provide(
+		fx.Annotate(
+			broker.DefaultBroker,
+			fx.ParamTags(`name:"rdb_presence"`),
+		),
+	),
+	fx.Provide(storage.DefaultStorage),
+	fx.Provide(mailer.DefaultMailer),
+	fx.Provide(sso.DefaultOidcProvider),
+	fx.Provide(jwtkeys.DefaultKeySet),
 )
@@ -8,5 +8,7 @@ var MicroServicesModule = fx.Module(
 	"MicroServices",
 	cacheModule,
 	postgresModule,
+	outboxModule,
+	presenceModule,
 	serverModule,
 )
@@ -0,0 +1,11 @@
+package fx_utils
+
+import (
+	"github.com/nihal-ramaswamy/GoChat/internal/outbox"
+	"go.uber.org/fx"
+)
+
+var outboxModule = fx.Module(
+	"OutboxService",
+	fx.Provide(outbox.NewRelay),
+)
@@ -1,11 +1,42 @@
 package fx_utils
 
 import (
+	"context"
+	"database/sql"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
 	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/migrations"
+	"github.com/nihal-ramaswamy/GoChat/internal/utils"
 	"go.uber.org/fx"
+	"go.uber.org/zap"
 )
 
+// migrateOnStart brings the schema up to date before the server starts
+func migrateOnStart(pdb *sql.DB, log *zap.Logger) error {
+	if utils.GetDotEnvVariable(constants.DB_MIGRATE_ON_START) == "false" {
+		log.Info("Skipping migrations on start")
+		return nil
+	}
+
+	count, err := migrations.Up(context.Background(), pdb, log)
+	if err != nil {
+		return err
+	}
+	log.Info("Database schema is up to date", zap.Int("applied", count))
+	return nil
+}
+
 var postgresModule = fx.Module(
 	"PostgresService",
 	fx.Provide(db.GetPostgresDbInstanceWithConfig),
+	fx.Provide(
+		fx.Annotate(
+			db.NewPostgresRepository,
+			fx.As(new(db.UserRepository)),
+			fx.As(new(db.ChatRepository)),
+			fx.As(new(db.OutboxRepository)),
+		),
+	),
+	fx.Invoke(migrateOnStart),
 )
@@ -0,0 +1,16 @@
+package fx_utils
+
+import (
+	"github.com/nihal-ramaswamy/GoChat/internal/presence"
+	"go.uber.org/fx"
+)
+
+var presenceModule = fx.Module(
+	"PresenceService",
+	fx.Provide(
+		fx.Annotate(
+			presence.NewSweeper,
+			fx.ParamTags(`name:"rdb_presence"`),
+		),
+	),
+)
@@ -20,4 +20,12 @@ var cacheModule = fx.Module(
 			fx.ResultTags(`name:"rdb_auth"`),
 		),
 	),
+
+	fx.Provide(
+		fx.Annotate(
+			db.GetRedisDbInstanceWithConfig,
+			fx.ParamTags(`name:"presence_rdb_config"`),
+			fx.ResultTags(`name:"rdb_presence"`),
+		),
+	),
 )
@@ -4,13 +4,18 @@ import (
 	"context"
 	"database/sql"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
-	amqpConfig "github.com/nihal-ramaswamy/GoChat/internal/amqp"
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/mailer"
+	"github.com/nihal-ramaswamy/GoChat/internal/outbox"
 	"github.com/nihal-ramaswamy/GoChat/internal/routes"
 	"github.com/nihal-ramaswamy/GoChat/internal/server"
+	"github.com/nihal-ramaswamy/GoChat/internal/sso"
+	"github.com/nihal-ramaswamy/GoChat/internal/storage"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
@@ -19,21 +24,28 @@ import (
 func newServerEngine(
 	lc fx.Lifecycle,
 	rdb_auth *redis.Client,
+	rdb_presence *redis.Client,
 	config *server.Config,
 	log *zap.Logger,
 	pdb *sql.DB,
+	users db.UserRepository,
+	chats db.ChatRepository,
 	ctx context.Context,
-	amqpConfig *amqpConfig.AmqpConfig,
+	broker broker.Broker,
+	relay *outbox.Relay,
 	upgrader *websocket.Upgrader,
 	websocketMap *dto.WebsocketConnectionMap,
+	storage storage.Storage,
+	mailer mailer.Mailer,
+	oidcProvider *sso.OidcProvider,
+	keys *jwtkeys.KeySet,
 ) *gin.Engine {
-	gin.SetMode(config.GinMode)
+	server, err := server.NewEngine(config)
+	if err != nil {
+		log.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
 
-	server := gin.Default()
-	server.Use(cors.New(config.Cors))
-	server.Use(gin.Recovery())
-
-	routes.NewRoutes(server, pdb, rdb_auth, ctx, log, amqpConfig, upgrader, websocketMap)
+	routes.NewRoutes(server, pdb, users, chats, rdb_auth, rdb_presence, ctx, log, broker, relay, upgrader, websocketMap, storage, mailer, oidcProvider, keys)
 
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
@@ -62,7 +74,7 @@ var serverModule = fx.Module(
 	fx.Provide(
 		fx.Annotate(
 			newServerEngine,
-			fx.ParamTags(``, `name:"rdb_auth"`),
+			fx.ParamTags(``, `name:"rdb_auth"`, `name:"rdb_presence"`),
 		),
 	),
 )
@@ -0,0 +1,113 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/utils"
+	"go.uber.org/zap"
+)
+
+// parsePem reads a PKCS#8 or PKCS#1 private key or a PKIX public key
+func parsePem(data []byte) (any, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("No PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("Unsupported PEM block %s", block.Type)
+	}
+}
+
+// LoadKeySet reads every <kid>.pem file in dir. The key named signingKeyId
+// signs new tokens and must be a private key; the others only verify tokens.
+// To rotate, add the new key, switch signingKeyId to it and remove the old key
+// once the access tokens it signed have expired.
+func LoadKeySet(dir, signingKeyId, issuer, audience string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	var signing *Key
+	verification := []*Key{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parsePem(data)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read %s: %s", path, err)
+		}
+
+		key, err := NewKey(strings.TrimSuffix(filepath.Base(path), ".pem"), parsed)
+		if err != nil {
+			return nil, err
+		}
+		if key.Id == signingKeyId {
+			signing = key
+		} else {
+			verification = append(verification, key)
+		}
+	}
+
+	if signing == nil {
+		return nil, fmt.Errorf("Signing key %s.pem not found in %s", signingKeyId, dir)
+	}
+	return NewKeySet(issuer, audience, signing, verification...)
+}
+
+// GenerateKeySet returns a set with a new Ed25519 key. Tokens it signs stop
+// working when the process exits.
+func GenerateKeySet(issuer, audience string) (*KeySet, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	key, err := NewKey("ephemeral", private)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(issuer, audience, key)
+}
+
+// DefaultKeySet returns the key set configured in the environment. Without
+// JWT_KEYS_DIR a key is generated, which is only suitable for a single
+// development server.
+func DefaultKeySet(log *zap.Logger) (*KeySet, error) {
+	issuer := utils.GetDotEnvVariable(constants.JWT_ISSUER)
+	if issuer == "" {
+		issuer = utils.GetDotEnvVariable(constants.SERVER_HOST) + utils.GetDotEnvVariable(constants.SERVER_PORT)
+	}
+	audience := utils.GetDotEnvVariable(constants.JWT_AUDIENCE)
+	if audience == "" {
+		audience = constants.JWT_DEFAULT_AUDIENCE
+	}
+
+	dir := utils.GetDotEnvVariable(constants.JWT_KEYS_DIR)
+	if dir == "" {
+		log.Warn("JWT_KEYS_DIR is not set, signing access tokens with a generated key")
+		return GenerateKeySet(issuer, audience)
+	}
+	keys, err := LoadKeySet(dir, utils.GetDotEnvVariable(constants.JWT_SIGNING_KEY_ID), issuer, audience)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load JWT keys: %s", err)
+	}
+	return keys, nil
+}
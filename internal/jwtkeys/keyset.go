@@ -0,0 +1,118 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+)
+
+var ErrUnknownKey = errors.New("Unknown signing key")
+
+// Key is a key tokens are signed or verified with. Private is nil for keys
+// that are only kept to verify tokens signed before a rotation.
+type Key struct {
+	Id      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// NewKey returns a key for a private or public RSA or Ed25519 key, choosing
+// RS256 or EdDSA to match
+func NewKey(id string, key any) (*Key, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &Key{Id: id, Method: jwt.SigningMethodRS256, Private: k, Public: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &Key{Id: id, Method: jwt.SigningMethodRS256, Public: k}, nil
+	case ed25519.PrivateKey:
+		return &Key{Id: id, Method: jwt.SigningMethodEdDSA, Private: k, Public: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &Key{Id: id, Method: jwt.SigningMethodEdDSA, Public: k}, nil
+	default:
+		return nil, fmt.Errorf("Unsupported key type %T for key %s", key, id)
+	}
+}
+
+// Jwk returns the public part of the key
+func (k *Key) Jwk() dto.Jwk {
+	jwk := dto.Jwk{Kid: k.Id, Use: "sig", Alg: k.Method.Alg()}
+	switch public := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
+
+// KeySet signs access tokens with one key and accepts tokens signed by any
+// of its keys, so the signing key can be rotated without signing everyone out
+type KeySet struct {
+	Issuer   string
+	Audience string
+	signing  *Key
+	keys     map[string]*Key
+}
+
+func NewKeySet(issuer, audience string, signing *Key, verification ...*Key) (*KeySet, error) {
+	if signing == nil || signing.Private == nil {
+		return nil, fmt.Errorf("Signing key must be a private key")
+	}
+
+	keys := map[string]*Key{signing.Id: signing}
+	for _, key := range verification {
+		if _, found := keys[key.Id]; found {
+			return nil, fmt.Errorf("Duplicate key id %s", key.Id)
+		}
+		keys[key.Id] = key
+	}
+
+	return &KeySet{
+		Issuer:   issuer,
+		Audience: audience,
+		signing:  signing,
+		keys:     keys,
+	}, nil
+}
+
+// Jwks returns the public keys of the set for other services to verify tokens with
+func (k *KeySet) Jwks() dto.Jwks {
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	jwks := dto.Jwks{Keys: make([]dto.Jwk, 0, len(ids))}
+	for _, id := range ids {
+		jwks.Keys = append(jwks.Keys, k.keys[id].Jwk())
+	}
+	return jwks
+}
+
+// keyFunc finds the key a token was signed with from its kid header and makes
+// sure the token uses the algorithm of that key
+func (k *KeySet) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, found := k.keys[kid]
+	if !found {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("Unexpected signing method %s", token.Method.Alg())
+	}
+	return key.Public, nil
+}
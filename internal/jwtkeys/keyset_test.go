@@ -0,0 +1,137 @@
+package jwtkeys_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+)
+
+func writePem(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Error writing key: %s", err)
+	}
+}
+
+// Tests that tokens signed before a rotation are still accepted while the old
+// public key is kept, and rejected once it is removed
+func TestRotation(t *testing.T) {
+	dir := t.TempDir()
+	user := &dto.User{Id: "user", Email: "user@test"}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+	writePem(t, filepath.Join(dir, "old.pem"), "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+	old, err := jwtkeys.LoadKeySet(dir, "old", "issuer", "audience")
+	if err != nil {
+		t.Fatalf("Error loading keys: %s", err)
+	}
+	token, err := old.Issue(user, "session")
+	if err != nil {
+		t.Fatalf("Error issuing token: %s", err)
+	}
+
+	// Rotate to an Ed25519 key and keep only the public part of the old one
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatalf("Error encoding key: %s", err)
+	}
+	writePem(t, filepath.Join(dir, "new.pem"), "PRIVATE KEY", der)
+	der, err = x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Error encoding key: %s", err)
+	}
+	writePem(t, filepath.Join(dir, "old.pem"), "PUBLIC KEY", der)
+
+	rotated, err := jwtkeys.LoadKeySet(dir, "new", "issuer", "audience")
+	if err != nil {
+		t.Fatalf("Error loading keys: %s", err)
+	}
+	if len(rotated.Jwks().Keys) != 2 {
+		t.Errorf("Expected both keys to be published, got %d", len(rotated.Jwks().Keys))
+	}
+
+	claims, err := rotated.Parse(token)
+	if err != nil {
+		t.Fatalf("Expected token of the old key to be accepted: %s", err)
+	}
+	if claims.Subject != user.Id || claims.Email != user.Email || claims.SessionId != "session" {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+
+	next, err := rotated.Issue(user, "session")
+	if err != nil {
+		t.Fatalf("Error issuing token: %s", err)
+	}
+	parsed, _ := jwt.Parse(next, nil)
+	if parsed.Header["kid"] != "new" || parsed.Method.Alg() != "EdDSA" {
+		t.Errorf("Expected token signed by the new key, got %v", parsed.Header)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "old.pem")); err != nil {
+		t.Fatalf("Error removing key: %s", err)
+	}
+	retired, err := jwtkeys.LoadKeySet(dir, "new", "issuer", "audience")
+	if err != nil {
+		t.Fatalf("Error loading keys: %s", err)
+	}
+	if _, err := retired.Parse(token); err == nil {
+		t.Errorf("Expected token of the removed key to be rejected")
+	}
+}
+
+// Tests that tokens for another issuer or audience, or signed with the
+// public key as an HMAC secret, are rejected
+func TestParseRejects(t *testing.T) {
+	user := &dto.User{Id: "user", Email: "user@test"}
+
+	keys, err := jwtkeys.GenerateKeySet("issuer", "audience")
+	if err != nil {
+		t.Fatalf("Error generating keys: %s", err)
+	}
+	other, err := jwtkeys.GenerateKeySet("issuer", "other")
+	if err != nil {
+		t.Fatalf("Error generating keys: %s", err)
+	}
+
+	token, err := keys.Issue(user, "session")
+	if err != nil {
+		t.Fatalf("Error issuing token: %s", err)
+	}
+	if _, err := keys.Parse(token); err != nil {
+		t.Errorf("Expected token to be accepted: %s", err)
+	}
+	if _, err := other.Parse(token); err == nil {
+		t.Errorf("Expected token for another audience to be rejected")
+	}
+
+	jwk := keys.Jwks().Keys[0]
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": user.Id, "sid": "session", "iss": "issuer", "aud": "audience", "jti": "jti", "iat": 1,
+	})
+	forged.Header["kid"] = jwk.Kid
+	signed, err := forged.SignedString([]byte(jwk.X))
+	if err != nil {
+		t.Fatalf("Error signing token: %s", err)
+	}
+	if _, err := keys.Parse(signed); err == nil {
+		t.Errorf("Expected HS256 token to be rejected")
+	}
+}
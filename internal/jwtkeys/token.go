@@ -0,0 +1,66 @@
+package jwtkeys
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+)
+
+var ErrInvalidToken = errors.New("Invalid token")
+
+// Claims of an access token. The user id is the subject.
+type Claims struct {
+	jwt.StandardClaims
+	Email     string `json:"email"`
+	SessionId string `json:"sid"`
+	Role      string `json:"role,omitempty"`
+}
+
+// Issue returns a short lived access token for a session of the user
+func (k *KeySet) Issue(user *dto.User, sessionId string) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(k.signing.Method, Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    k.Issuer,
+			Subject:   user.Id,
+			Audience:  k.Audience,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(constants.ACCESS_TOKEN_EXPIRY_TIME).Unix(),
+			Id:        hex.EncodeToString(jti),
+		},
+		Email:     user.Email,
+		SessionId: sessionId,
+		Role:      user.Role,
+	})
+	token.Header["kid"] = k.signing.Id
+
+	return token.SignedString(k.signing.Private)
+}
+
+// Parse verifies the signature, expiry, issuer and audience of an access
+// token and returns its claims
+func (k *KeySet) Parse(token string) (*Claims, error) {
+	claims := &Claims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, k.keyFunc)
+	if err != nil || !parsed.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	if !claims.VerifyIssuer(k.Issuer, true) || !claims.VerifyAudience(k.Audience, true) {
+		return nil, ErrInvalidToken
+	}
+	if claims.Subject == "" || claims.Id == "" || claims.IssuedAt == 0 || claims.SessionId == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
@@ -0,0 +1,87 @@
+package lockout
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/redis/go-redis/v9"
+)
+
+// Counts a failed attempt and locks once the count reaches ARGV[1]. Every
+// further failure doubles the lock, starting at ARGV[3] seconds and capped at
+// ARGV[4]. Returns the lock in seconds, 0 if not locked.
+var failScript = redis.NewScript(`
+local failures = redis.call("INCR", KEYS[1])
+redis.call("EXPIRE", KEYS[1], ARGV[2])
+local max = tonumber(ARGV[1])
+if failures < max then
+	return 0
+end
+local lock = math.min(tonumber(ARGV[3]) * 2 ^ (failures - max), tonumber(ARGV[4]))
+redis.call("SET", KEYS[2], failures, "EX", math.floor(lock))
+return math.floor(lock)
+`)
+
+type counter struct {
+	id  string
+	max int
+}
+
+func counters(email, ip string) []counter {
+	return []counter{
+		{"account:" + strings.ToLower(strings.TrimSpace(email)), constants.LOGIN_ACCOUNT_MAX_FAILURES},
+		{"ip:" + ip, constants.LOGIN_IP_MAX_FAILURES},
+	}
+}
+
+func failuresKey(id string) string {
+	return constants.LOGIN_FAILURES_KEY_PREFIX + id
+}
+
+func lockKey(id string) string {
+	return constants.LOGIN_LOCK_KEY_PREFIX + id
+}
+
+// Check returns how long sign in is still locked for an email address or the
+// address the request came from, or 0 if it is not locked. Emails that do not
+// belong to an account are locked the same way, so a lock reveals nothing.
+func Check(ctx context.Context, rdb *redis.Client, email, ip string) (time.Duration, error) {
+	var remaining time.Duration
+	for _, c := range counters(email, ip) {
+		ttl, err := rdb.PTTL(ctx, lockKey(c.id)).Result()
+		if err != nil {
+			return 0, err
+		}
+		remaining = max(remaining, ttl)
+	}
+	return remaining, nil
+}
+
+// Fail records a failed sign in and returns how long the email address or
+// the address the request came from is now locked, or 0 if neither is
+func Fail(ctx context.Context, rdb *redis.Client, email, ip string) (time.Duration, error) {
+	var locked time.Duration
+	for _, c := range counters(email, ip) {
+		keys := []string{failuresKey(c.id), lockKey(c.id)}
+		seconds, err := failScript.Run(ctx, rdb, keys,
+			c.max,
+			int64(constants.LOGIN_FAILURE_WINDOW.Seconds()),
+			int64(constants.LOGIN_LOCKOUT_BASE.Seconds()),
+			int64(constants.LOGIN_LOCKOUT_MAX.Seconds()),
+		).Int64()
+		if err != nil {
+			return 0, err
+		}
+		locked = max(locked, time.Duration(seconds)*time.Second)
+	}
+	return locked, nil
+}
+
+// Reset forgets the failed attempts of an account after it signed in. Failures
+// from the address are kept so one good password does not unlock a spray.
+func Reset(ctx context.Context, rdb *redis.Client, email string) error {
+	id := counters(email, "")[0].id
+	return rdb.Del(ctx, failuresKey(id), lockKey(id)).Err()
+}
@@ -0,0 +1,68 @@
+package lockout_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/lockout"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+)
+
+// Tests that an account locks after too many failures, that the lock doubles
+// with every further failure and that signing in resets it
+func TestFail(t *testing.T) {
+	ctx := context.Background()
+
+	container, rdb, err := testUtils.SetUpRedisForTesting(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up redis for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		rdb.Close()
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	email := testUtils.RandStringRunes(10) + "@test"
+
+	for i := 1; i < constants.LOGIN_ACCOUNT_MAX_FAILURES; i++ {
+		locked, err := lockout.Fail(ctx, rdb, email, "127.0.0.1")
+		if err != nil {
+			t.Fatalf("Error recording failure: %s", err)
+		}
+		if locked != 0 {
+			t.Fatalf("Expected no lock after %d failures, got %s", i, locked)
+		}
+	}
+
+	locked, err := lockout.Fail(ctx, rdb, email, "127.0.0.1")
+	if err != nil || locked != constants.LOGIN_LOCKOUT_BASE {
+		t.Fatalf("Expected lock of %s, got %s %v", constants.LOGIN_LOCKOUT_BASE, locked, err)
+	}
+	locked, err = lockout.Fail(ctx, rdb, email, "127.0.0.1")
+	if err != nil || locked != 2*constants.LOGIN_LOCKOUT_BASE {
+		t.Fatalf("Expected lock of %s, got %s %v", 2*constants.LOGIN_LOCKOUT_BASE, locked, err)
+	}
+
+	// The email is locked from any address, regardless of case
+	remaining, err := lockout.Check(ctx, rdb, "  "+strings.ToUpper(email)+" ", "127.0.0.2")
+	if err != nil || remaining <= constants.LOGIN_LOCKOUT_BASE {
+		t.Fatalf("Expected email to be locked, got %s %v", remaining, err)
+	}
+	remaining, err = lockout.Check(ctx, rdb, "other@test", "127.0.0.2")
+	if err != nil || remaining != 0 {
+		t.Fatalf("Expected other email not to be locked, got %s %v", remaining, err)
+	}
+
+	if err := lockout.Reset(ctx, rdb, email); err != nil {
+		t.Fatalf("Error resetting: %s", err)
+	}
+	remaining, err = lockout.Check(ctx, rdb, email, "127.0.0.2")
+	if err != nil || remaining != 0 {
+		t.Fatalf("Expected email not to be locked after reset, got %s %v", remaining, err)
+	}
+}
@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"fmt"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/utils"
+	"go.uber.org/zap"
+)
+
+// DefaultMailer returns the mailer selected by MAILER_BACKEND, defaulting to
+// logging mails when it is not set
+func DefaultMailer(log *zap.Logger) Mailer {
+	from := utils.GetDotEnvVariable(constants.MAIL_FROM)
+
+	switch backend := utils.GetDotEnvVariable(constants.MAILER_BACKEND); backend {
+	case constants.MAILER_BACKEND_SMTP:
+		return NewSmtpMailer(
+			utils.GetDotEnvVariable(constants.SMTP_HOST),
+			utils.GetDotEnvVariable(constants.SMTP_PORT),
+			utils.GetDotEnvVariable(constants.SMTP_USERNAME),
+			utils.GetDotEnvVariable(constants.SMTP_PASSWORD),
+			from,
+		)
+	case constants.MAILER_BACKEND_FILE, "":
+		mailer, err := NewFileMailer(utils.GetDotEnvVariable(constants.MAILER_DIR), from, log)
+		if err != nil {
+			panic(err)
+		}
+		return mailer
+	default:
+		panic(fmt.Errorf("Unknown mailer backend %q", backend))
+	}
+}
@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"go.uber.org/zap"
+)
+
+// FileMailer is meant for development. It writes every mail to Dir as an
+// .eml file, or only logs it when Dir is empty.
+type FileMailer struct {
+	Dir  string
+	From string
+	log  *zap.Logger
+}
+
+func NewFileMailer(dir, from string, log *zap.Logger) (*FileMailer, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, fmt.Errorf("Failed to create mail directory: %s", err)
+		}
+	}
+	return &FileMailer{Dir: dir, From: from, log: log}, nil
+}
+
+func (f *FileMailer) Send(ctx context.Context, mail dto.Mail) error {
+	if f.Dir == "" {
+		f.log.Info("Mail",
+			zap.String("to", mail.To), zap.String("subject", mail.Subject), zap.String("body", mail.Body))
+		return nil
+	}
+
+	name := fmt.Sprintf("%d.eml", time.Now().UnixNano())
+	path := filepath.Join(f.Dir, name)
+	// Renamed once written, so readers of Dir never see a partial mail
+	if err := os.WriteFile(path+".tmp", format(f.From, mail), 0o640); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	f.log.Info("Mail written", zap.String("to", mail.To), zap.String("path", path))
+	return nil
+}
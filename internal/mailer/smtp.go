@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+)
+
+// SmtpMailer sends mail through an SMTP server
+type SmtpMailer struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+// NewSmtpMailer returns a mailer for the server at host:port. Authentication is
+// skipped when username is empty, which is what local servers like Mailpit expect.
+func NewSmtpMailer(host, port, username, password, from string) *SmtpMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SmtpMailer{
+		Addr: net.JoinHostPort(host, port),
+		From: from,
+		Auth: auth,
+	}
+}
+
+func (s *SmtpMailer) Send(ctx context.Context, mail dto.Mail) error {
+	if strings.ContainsAny(mail.To, "\r\n") || strings.ContainsAny(mail.Subject, "\r\n") {
+		return fmt.Errorf("Invalid mail header")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.Addr, s.Auth, s.From, []string{mail.To}, format(s.From, mail))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,59 @@
+package mailer_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+)
+
+// Tests that mails sent over SMTP arrive with their headers and body
+func TestSmtpMailer(t *testing.T) {
+	ctx := context.Background()
+
+	container, smtpMailer, err := testUtils.SetUpMailpitForTesting(ctx, "no-reply@gochat.test")
+	if err != nil {
+		t.Fatalf("Error setting up mailpit for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	mail := dto.Mail{
+		To:      "user@gochat.test",
+		Subject: "Reset your password",
+		Body:    "Hi user,\n\nYour token is abc.123\n",
+	}
+	if err := smtpMailer.Send(ctx, mail); err != nil {
+		t.Fatalf("Error sending mail: %s", err)
+	}
+
+	messages, err := container.Messages(ctx)
+	if err != nil {
+		t.Fatalf("Error reading mail: %s", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 mail, got %d", len(messages))
+	}
+	message := messages[0]
+	if message.Subject != mail.Subject || message.From.Address != "no-reply@gochat.test" {
+		t.Errorf("Unexpected headers: %+v", message)
+	}
+	if len(message.To) != 1 || message.To[0].Address != mail.To {
+		t.Errorf("Expected the mail for %s, got %+v", mail.To, message.To)
+	}
+	if !strings.Contains(message.Text, "Your token is abc.123") {
+		t.Errorf("Expected the body, got %q", message.Text)
+	}
+
+	// Header injection is rejected before anything is sent
+	mail.Subject = "Hi\r\nBcc: someone@gochat.test"
+	if err := smtpMailer.Send(ctx, mail); err == nil {
+		t.Errorf("Expected a subject with a line break to be rejected")
+	}
+}
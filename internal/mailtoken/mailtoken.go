@@ -0,0 +1,59 @@
+package mailtoken
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrInvalidToken = errors.New("Invalid or expired token")
+
+func key(purpose, id string) string {
+	return constants.MAIL_TOKEN_KEY_PREFIX + purpose + ":" + id
+}
+
+func sign(secret []byte, purpose, id string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose + ":" + id))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Issue returns a token for userId that can be consumed once for purpose
+// within ttl. The token is signed with secret so forged tokens are rejected
+// without a lookup.
+func Issue(ctx context.Context, rdb *redis.Client, secret []byte, purpose, userId string, ttl time.Duration) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+
+	if err := rdb.Set(ctx, key(purpose, id), userId, ttl).Err(); err != nil {
+		return "", err
+	}
+	return id + "." + sign(secret, purpose, id), nil
+}
+
+// Consume returns the user a token was issued to and invalidates it. It
+// returns ErrInvalidToken if the token was forged, issued for another
+// purpose, expired or already used.
+func Consume(ctx context.Context, rdb *redis.Client, secret []byte, purpose, token string) (string, error) {
+	id, signature, found := strings.Cut(token, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(sign(secret, purpose, id))) {
+		return "", ErrInvalidToken
+	}
+
+	userId, err := rdb.GetDel(ctx, key(purpose, id)).Result()
+	if err == redis.Nil {
+		return "", ErrInvalidToken
+	}
+	return userId, err
+}
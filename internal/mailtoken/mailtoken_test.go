@@ -0,0 +1,54 @@
+package mailtoken_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/mailtoken"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+)
+
+// Tests that a token is single use, bound to its purpose and signed
+func TestConsume(t *testing.T) {
+	ctx := context.Background()
+
+	container, rdb, err := testUtils.SetUpRedisForTesting(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up redis for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		rdb.Close()
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	secret := []byte("secret")
+	userId := testUtils.RandStringRunes(10)
+
+	token, err := mailtoken.Issue(ctx, rdb, secret, "verify", userId, time.Minute)
+	if err != nil {
+		t.Fatalf("Error issuing token: %s", err)
+	}
+
+	if _, err := mailtoken.Consume(ctx, rdb, secret, "reset", token); err != mailtoken.ErrInvalidToken {
+		t.Errorf("Expected token for another purpose to be rejected, got %v", err)
+	}
+	if _, err := mailtoken.Consume(ctx, rdb, []byte("other"), "verify", token); err != mailtoken.ErrInvalidToken {
+		t.Errorf("Expected token signed with another secret to be rejected, got %v", err)
+	}
+
+	got, err := mailtoken.Consume(ctx, rdb, secret, "verify", token)
+	if err != nil {
+		t.Fatalf("Error consuming token: %s", err)
+	}
+	if got != userId {
+		t.Errorf("Expected user %s, got %s", userId, got)
+	}
+
+	if _, err := mailtoken.Consume(ctx, rdb, secret, "verify", token); err != mailtoken.ErrInvalidToken {
+		t.Errorf("Expected used token to be rejected, got %v", err)
+	}
+}
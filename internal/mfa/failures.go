@@ -0,0 +1,56 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/redis/go-redis/v9"
+)
+
+// Counts a wrong code sent by a signed in user. The window starts with the
+// first failure and lasts ARGV[1] milliseconds. Returns the number of failures.
+var userFailScript = redis.NewScript(`
+local failures = redis.call("INCR", KEYS[1])
+if failures == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return failures
+`)
+
+func failuresKey(userId string) string {
+	return constants.MFA_FAILURES_KEY_PREFIX + userId
+}
+
+// Locked returns how long a signed in user is still blocked from sending
+// codes, or 0 if they are not. Users are blocked for the rest of the
+// MFA_FAILURE_WINDOW once MFA_MAX_ATTEMPTS codes failed in it.
+func Locked(ctx context.Context, rdb *redis.Client, userId string) (time.Duration, error) {
+	failures, err := rdb.Get(ctx, failuresKey(userId)).Int()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if failures < constants.MFA_MAX_ATTEMPTS {
+		return 0, nil
+	}
+	return rdb.PTTL(ctx, failuresKey(userId)).Result()
+}
+
+// FailUser records a wrong code sent by a signed in user and returns how
+// long they are now blocked, or 0 if they are not
+func FailUser(ctx context.Context, rdb *redis.Client, userId string) (time.Duration, error) {
+	err := userFailScript.Run(ctx, rdb, []string{failuresKey(userId)}, constants.MFA_FAILURE_WINDOW.Milliseconds()).Err()
+	if err != nil {
+		return 0, err
+	}
+	return Locked(ctx, rdb, userId)
+}
+
+// ResetUser forgets the wrong codes of a user after a valid one
+func ResetUser(ctx context.Context, rdb *redis.Client, userId string) error {
+	return rdb.Del(ctx, failuresKey(userId)).Err()
+}
@@ -0,0 +1,91 @@
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrInvalidMfaToken = errors.New("Invalid or expired mfa token")
+	// ErrTooManyAttempts is returned once MFA_MAX_ATTEMPTS wrong codes were sent
+	// for a pending login. The user has to sign in with their password again.
+	ErrTooManyAttempts = errors.New("Too many attempts")
+)
+
+// Counts a wrong code for a pending login and deletes it once ARGV[1]
+// attempts failed. Returns the number of attempts, or -1 if the login expired.
+var failScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return -1
+end
+local attempts = redis.call("HINCRBY", KEYS[1], "attempts", 1)
+if attempts >= tonumber(ARGV[1]) then
+	redis.call("DEL", KEYS[1])
+end
+return attempts
+`)
+
+func pendingKey(token string) string {
+	return constants.MFA_PENDING_KEY_PREFIX + token
+}
+
+// IssuePending returns a short lived token proving the password of user was
+// checked. It is exchanged for a session once the second factor is verified.
+func IssuePending(ctx context.Context, rdb *redis.Client, user *dto.User) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, pendingKey(token), "user_id", user.Id, "email", user.Email, "attempts", 0)
+		pipe.Expire(ctx, pendingKey(token), constants.MFA_PENDING_TTL)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Pending returns the user a pending login token was issued to
+func Pending(ctx context.Context, rdb *redis.Client, token string) (dto.User, error) {
+	fields, err := rdb.HGetAll(ctx, pendingKey(token)).Result()
+	if err != nil {
+		return dto.User{}, err
+	}
+	if len(fields) == 0 {
+		return dto.User{}, ErrInvalidMfaToken
+	}
+	return dto.User{Id: fields["user_id"], Email: fields["email"]}, nil
+}
+
+// Fail records a wrong code for a pending login and drops it after
+// MFA_MAX_ATTEMPTS failures
+func Fail(ctx context.Context, rdb *redis.Client, token string) error {
+	attempts, err := failScript.Run(ctx, rdb, []string{pendingKey(token)}, constants.MFA_MAX_ATTEMPTS).Int()
+	if err != nil {
+		return err
+	}
+	if attempts < 0 {
+		return ErrInvalidMfaToken
+	}
+	if attempts >= constants.MFA_MAX_ATTEMPTS {
+		return ErrTooManyAttempts
+	}
+	return nil
+}
+
+// Complete invalidates a pending login token. Only the first caller gets
+// true, so a token cannot be exchanged for two sessions.
+func Complete(ctx context.Context, rdb *redis.Client, token string) (bool, error) {
+	deleted, err := rdb.Del(ctx, pendingKey(token)).Result()
+	return deleted == 1, err
+}
@@ -0,0 +1,34 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"strings"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+)
+
+// GenerateRecoveryCodes returns RECOVERY_CODE_COUNT single use codes, each
+// formatted as two groups of lower case base32 characters
+func GenerateRecoveryCodes() []string {
+	codes := make([]string, constants.RECOVERY_CODE_COUNT)
+	for i := range codes {
+		b := make([]byte, constants.RECOVERY_CODE_BYTE_SIZE)
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+		code := strings.ToLower(encoding.EncodeToString(b))
+		codes[i] = code[:len(code)/2] + "-" + code[len(code)/2:]
+	}
+	return codes
+}
+
+// NormalizeRecoveryCode lets users type a recovery code without the dash or in upper case
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) < 2 {
+		return code
+	}
+	return code[:len(code)/2] + "-" + code[len(code)/2:]
+}
@@ -0,0 +1,103 @@
+package mfa
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/redis/go-redis/v9"
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a random base32 encoded TOTP secret
+func GenerateSecret() string {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return encoding.EncodeToString(b)
+}
+
+// URI returns the otpauth URI authenticator apps read from a QR code
+func URI(account, secret string) string {
+	label := url.PathEscape(constants.MFA_ISSUER + ":" + account)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", constants.MFA_ISSUER)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", strconv.Itoa(constants.TOTP_DIGITS))
+	query.Set("period", strconv.Itoa(int(constants.TOTP_PERIOD/time.Second)))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+func step(t time.Time) int64 {
+	return t.Unix() / int64(constants.TOTP_PERIOD/time.Second)
+}
+
+// code returns the HOTP value of secret for a time step as described in RFC 4226
+func code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("Invalid TOTP secret: %s", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for range constants.TOTP_DIGITS {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", constants.TOTP_DIGITS, value%mod), nil
+}
+
+// Code returns the code for secret at t
+func Code(secret string, t time.Time) (string, error) {
+	return code(secret, step(t))
+}
+
+// Validate reports whether a code matches secret at t, allowing for clock
+// drift of TOTP_SKEW periods. It returns the matching time step.
+func Validate(secret, candidate string, t time.Time) (int64, bool) {
+	candidate = strings.ReplaceAll(candidate, " ", "")
+	current := step(t)
+	for delta := int64(-constants.TOTP_SKEW); delta <= constants.TOTP_SKEW; delta++ {
+		expected, err := code(secret, current+delta)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(candidate)) == 1 {
+			return current + delta, true
+		}
+	}
+	return 0, false
+}
+
+// Check validates a code of a user and remembers it so the same code cannot be
+// replayed while it is still within the accepted window
+func Check(ctx context.Context, rdb *redis.Client, userId, secret, candidate string) (bool, error) {
+	matched, ok := Validate(secret, candidate, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	key := constants.TOTP_USED_KEY_PREFIX + userId + ":" + strconv.FormatInt(matched, 10)
+	ttl := time.Duration(2*constants.TOTP_SKEW+1) * constants.TOTP_PERIOD
+	return rdb.SetNX(ctx, key, 1, ttl).Result()
+}
@@ -0,0 +1,56 @@
+package mfa_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/mfa"
+)
+
+// Tests codes against the SHA1 vectors of RFC 6238, truncated to 6 digits
+func TestCode(t *testing.T) {
+	// base32 of the ASCII secret "12345678901234567890"
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, expected := range vectors {
+		code, err := mfa.Code(secret, time.Unix(unix, 0))
+		if err != nil {
+			t.Fatalf("Error generating code: %s", err)
+		}
+		if code != expected {
+			t.Errorf("Expected %s at %d, got %s", expected, unix, code)
+		}
+	}
+}
+
+// Tests that codes of neighbouring periods are accepted and older ones are not
+func TestValidate(t *testing.T) {
+	secret := mfa.GenerateSecret()
+	now := time.Now()
+
+	for _, offset := range []time.Duration{-30 * time.Second, 0, 30 * time.Second} {
+		code, err := mfa.Code(secret, now.Add(offset))
+		if err != nil {
+			t.Fatalf("Error generating code: %s", err)
+		}
+		if _, ok := mfa.Validate(secret, code, now); !ok {
+			t.Errorf("Expected code from %s to be accepted", offset)
+		}
+	}
+
+	code, err := mfa.Code(secret, now.Add(-2*time.Minute))
+	if err != nil {
+		t.Fatalf("Error generating code: %s", err)
+	}
+	if _, ok := mfa.Validate(secret, code, now); ok {
+		t.Errorf("Expected expired code to be rejected")
+	}
+}
@@ -3,14 +3,15 @@ package middlewares
 import (
 	"context"
 	"database/sql"
-	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
+	"github.com/nihal-ramaswamy/GoChat/internal/apikey"
 	"github.com/nihal-ramaswamy/GoChat/internal/constants"
-	"github.com/nihal-ramaswamy/GoChat/internal/utils"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/session"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
@@ -20,10 +21,8 @@ func AuthMiddleware(
 	rdb *redis.Client,
 	ctx context.Context,
 	log *zap.Logger,
+	keys *jwtkeys.KeySet,
 ) gin.HandlerFunc {
-	secret := utils.GetDotEnvVariable("SECRET_KEY")
-	signingKey := []byte(secret)
-
 	return func(c *gin.Context) {
 		token := c.GetHeader("token")
 		if token == "" {
@@ -32,41 +31,54 @@ func AuthMiddleware(
 			return
 		}
 		splitToken := strings.Split(token, constants.BEARER)
-		token = splitToken[1]
-
 		if len(splitToken) != 2 {
 			c.AbortWithStatusJSON(http.StatusUnauthorized,
 				gin.H{"error": "Invalid token. Token should be 'Bearer <Token>'"})
 			return
 		}
+		token = splitToken[1]
 
-		parsedToken, err := jwt.Parse(
-			token,
-			func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("Error in parsing token")
+		// Bots authenticate with API keys instead of sessions
+		if apikey.IsApiKey(token) {
+			principal, err := db.AuthenticateApiKey(pdb, token)
+			if err != nil {
+				if err != db.ErrInvalidApiKey {
+					log.Error("Error authenticating API key", zap.Error(err))
 				}
-				return signingKey, nil
-			})
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+				return
+			}
 
-		if nil != err {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Set("email", principal.Email)
+			c.Set("user_id", principal.UserId)
+			c.Set("role", constants.ROLE_USER)
+			c.Set("api_key_id", principal.KeyId)
+			c.Set("scopes", principal.Scopes)
+			c.Set("authenticated", true)
+
+			c.Next()
 			return
 		}
 
-		email := parsedToken.Claims.(jwt.MapClaims)["email"].(string)
-
-		_, err = rdb.Get(ctx, email).Result()
+		claims, err := keys.Parse(token)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			return
 		}
 
-		if claims, ok := parsedToken.Claims.(jwt.MapClaims); ok && parsedToken.Valid {
-			c.Set("email", claims["email"])
-			c.Set("authenticated", true)
+		// Access tokens of revoked sessions stop working before they expire
+		active, err := session.Exists(ctx, rdb, claims.SessionId)
+		if err != nil || !active {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
 		}
 
+		c.Set("email", claims.Email)
+		c.Set("user_id", claims.Subject)
+		c.Set("session_id", claims.SessionId)
+		c.Set("role", claims.Role)
+		c.Set("authenticated", true)
+
 		c.Next()
 	}
 }
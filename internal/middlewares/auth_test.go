@@ -8,8 +8,6 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
-	"os"
-	"path/filepath"
 	"testing"
 
 	_ "github.com/lib/pq"
@@ -22,13 +20,7 @@ import (
 func TestAuthMiddleware(t *testing.T) {
 	ctx := context.Background()
 
-	wd, err := os.Getwd()
-	if err != nil {
-		t.Fatalf("Error getting working directory: %s", err)
-	}
-	rootDir := filepath.Join(wd, "..", "..")
-
-	testConfig, err := testUtils.SetUpRouter(rootDir, ctx)
+	testConfig, err := testUtils.SetUpRouter(ctx)
 	if err != nil {
 		t.Fatalf("Error setting up server for testing: %s", err)
 	}
@@ -55,12 +47,20 @@ func TestAuthMiddleware(t *testing.T) {
 	routes.NewRoutes(
 		testConfig.Server,
 		testConfig.Db,
+		testConfig.Users,
+		testConfig.Chats,
+		testConfig.Rdb,
 		testConfig.Rdb,
 		ctx,
 		testConfig.Log,
-		testConfig.AmqpConfig,
+		testConfig.Broker,
+		testConfig.Relay,
 		testConfig.Upgrader,
 		testConfig.WebsocketMap,
+		testConfig.Storage,
+		testConfig.Mailer,
+		testConfig.OidcProvider,
+		testConfig.Keys,
 	)
 
 	// Rgister user
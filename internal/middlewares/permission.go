@@ -0,0 +1,22 @@
+package middlewares
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+)
+
+// RequirePermission rejects requests whose role does not grant permission.
+// It reads the role AuthMiddleware put in the context, so it has to run after it.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(constants.ROLE_PERMISSIONS[c.GetString("role")], permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			return
+		}
+
+		c.Next()
+	}
+}
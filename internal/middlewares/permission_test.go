@@ -0,0 +1,46 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+)
+
+// Tests that each role only reaches routes its permissions allow
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		role       string
+		permission string
+		expected   int
+	}{
+		{"", constants.PERMISSION_USERS_READ, http.StatusForbidden},
+		{constants.ROLE_USER, constants.PERMISSION_USERS_READ, http.StatusForbidden},
+		{constants.ROLE_MODERATOR, constants.PERMISSION_USERS_DISABLE, http.StatusOK},
+		{constants.ROLE_MODERATOR, constants.PERMISSION_ROLES_WRITE, http.StatusForbidden},
+		{constants.ROLE_ADMIN, constants.PERMISSION_ROLES_WRITE, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		server := gin.New()
+		server.GET("/",
+			func(c *gin.Context) { c.Set("role", test.role) },
+			middlewares.RequirePermission(test.permission),
+			func(c *gin.Context) { c.Status(http.StatusOK) },
+		)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		server.ServeHTTP(w, req)
+
+		if w.Code != test.expected {
+			t.Errorf("Role %q with permission %s: expected status code %d, got %d",
+				test.role, test.permission, test.expected, w.Code)
+		}
+	}
+}
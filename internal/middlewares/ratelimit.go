@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/ratelimit"
+)
+
+// RateLimitKey picks the bucket a request is counted in
+type RateLimitKey func(c *gin.Context) string
+
+// ByIp counts requests per client address
+func ByIp(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser counts requests per signed in user, or per client address before
+// AuthMiddleware ran
+func ByUser(c *gin.Context) string {
+	if userId := c.GetString("user_id"); userId != "" {
+		return "user:" + userId
+	}
+	return ByIp(c)
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// RateLimit rejects requests over limit with 429 Too Many Requests. Every
+// response carries the X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset headers, and rejected ones a Retry-After header.
+func RateLimit(limiter *ratelimit.Limiter, limit ratelimit.Limit, key RateLimitKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := limiter.Allow(c.Request.Context(), limit, key(c))
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", seconds(result.ResetAfter))
+
+		if !result.Allowed {
+			c.Header("Retry-After", seconds(result.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+			return
+		}
+
+		c.Next()
+	}
+}
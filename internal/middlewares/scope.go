@@ -0,0 +1,28 @@
+package middlewares
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScope restricts requests authenticated with an API key to routes
+// whose scope the key was granted. Routes without a scope are not available
+// to API keys at all. Requests authenticated with a session are not affected.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("api_key_id") == "" {
+			c.Next()
+			return
+		}
+
+		if scope == "" || !slices.Contains(c.GetStringSlice("scopes"), scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden,
+				gin.H{"error": "API key is not allowed to access this route"})
+			return
+		}
+
+		c.Next()
+	}
+}
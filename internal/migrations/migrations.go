@@ -0,0 +1,216 @@
+package migrations
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"go.uber.org/zap"
+)
+
+// Migrations are named <version>_<name>.up.sql and <version>_<name>.down.sql
+// and compiled into the binary
+//
+//go:embed sql/*.sql
+var files embed.FS
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load returns the embedded migrations ordered by version
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("Invalid migration file name %s", entry.Name())
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		content, err := fs.ReadFile(files, "sql/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("Migration %d has two names", version)
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("Migration %d needs an up and a down file", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return migrations, nil
+}
+
+// withLock runs f on a single connection holding the migrations advisory
+// lock, after making sure the table of applied versions exists
+func withLock(ctx context.Context, db *sql.DB, f func(conn *sql.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, constants.MIGRATIONS_LOCK_KEY); err != nil {
+		return fmt.Errorf("Failed to lock migrations: %s", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, constants.MIGRATIONS_LOCK_KEY)
+
+	query := `CREATE TABLE IF NOT EXISTS "SCHEMA_MIGRATIONS" (
+		VERSION BIGINT NOT NULL PRIMARY KEY,
+		NAME VARCHAR(255) NOT NULL,
+		APPLIED_AT TIMESTAMP NOT NULL DEFAULT NOW()
+	)`
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("Failed to create migrations table: %s", err)
+	}
+
+	return f(conn)
+}
+
+func applied(ctx context.Context, conn *sql.Conn) ([]int64, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT VERSION FROM "SCHEMA_MIGRATIONS" ORDER BY VERSION`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []int64{}
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+// run executes one migration and records the change in the same transaction,
+// so a failed migration leaves nothing behind
+func run(ctx context.Context, conn *sql.Conn, script string, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Up applies every migration that was not applied yet and returns how many ran
+func Up(ctx context.Context, db *sql.DB, log *zap.Logger) (int, error) {
+	migrations, err := Load()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = withLock(ctx, db, func(conn *sql.Conn) error {
+		done, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			if slices.Contains(done, migration.Version) {
+				continue
+			}
+			log.Info("Applying migration", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+			record := `INSERT INTO "SCHEMA_MIGRATIONS" (VERSION, NAME) VALUES ($1, $2)`
+			if err := run(ctx, conn, migration.Up, record, migration.Version, migration.Name); err != nil {
+				return fmt.Errorf("Failed to apply migration %d_%s: %s", migration.Version, migration.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down reverts the last steps applied migrations and returns how many ran
+func Down(ctx context.Context, db *sql.DB, log *zap.Logger, steps int) (int, error) {
+	migrations, err := Load()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = withLock(ctx, db, func(conn *sql.Conn) error {
+		done, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(done) - 1; i >= 0 && count < steps; i-- {
+			index := slices.IndexFunc(migrations, func(m Migration) bool { return m.Version == done[i] })
+			if index == -1 {
+				return fmt.Errorf("Applied migration %d is unknown to this build", done[i])
+			}
+			migration := migrations[index]
+
+			log.Info("Reverting migration", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+			record := `DELETE FROM "SCHEMA_MIGRATIONS" WHERE VERSION = $1`
+			if err := run(ctx, conn, migration.Down, record, migration.Version); err != nil {
+				return fmt.Errorf("Failed to revert migration %d_%s: %s", migration.Version, migration.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Version returns the latest applied migration, or 0 if none was applied
+func Version(ctx context.Context, db *sql.DB) (int64, error) {
+	var version int64
+	err := withLock(ctx, db, func(conn *sql.Conn) error {
+		done, err := applied(ctx, conn)
+		if len(done) > 0 {
+			version = done[len(done)-1]
+		}
+		return err
+	})
+	return version, err
+}
@@ -0,0 +1,167 @@
+package migrations_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/migrations"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+	"go.uber.org/zap"
+)
+
+// Tests that the embedded migrations are complete and ordered
+func TestLoad(t *testing.T) {
+	all, err := migrations.Load()
+	if err != nil {
+		t.Fatalf("Error loading migrations: %s", err)
+	}
+	if len(all) == 0 {
+		t.Fatalf("Expected embedded migrations")
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].Version <= all[i-1].Version {
+			t.Errorf("Expected migrations ordered by version, got %d after %d", all[i].Version, all[i-1].Version)
+		}
+	}
+}
+
+// Tests reverting and reapplying every migration, with instances racing to
+// apply them at the same time
+func TestUpDown(t *testing.T) {
+	ctx := context.Background()
+	log := zap.NewNop()
+
+	container, db, err := testUtils.SetUpPostgresForTesting(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up postgres for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+		db.Close()
+	})
+
+	all, err := migrations.Load()
+	if err != nil {
+		t.Fatalf("Error loading migrations: %s", err)
+	}
+	latest := all[len(all)-1].Version
+
+	if version, err := migrations.Version(ctx, db); err != nil || version != latest {
+		t.Fatalf("Expected version %d, got %d %v", latest, version, err)
+	}
+	if count, err := migrations.Up(ctx, db, log); err != nil || count != 0 {
+		t.Fatalf("Expected nothing to apply, got %d %v", count, err)
+	}
+
+	if count, err := migrations.Down(ctx, db, log, len(all)); err != nil || count != len(all) {
+		t.Fatalf("Expected %d migrations reverted, got %d %v", len(all), count, err)
+	}
+	if version, err := migrations.Version(ctx, db); err != nil || version != 0 {
+		t.Fatalf("Expected version 0, got %d %v", version, err)
+	}
+	if _, err := db.Exec(`SELECT 1 FROM "USER"`); err == nil {
+		t.Errorf("Expected tables to be dropped")
+	}
+
+	var wg sync.WaitGroup
+	counts := make([]int, 3)
+	for i := range counts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counts[i], err = migrations.Up(ctx, db, log)
+			if err != nil {
+				t.Errorf("Error applying migrations: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	if total != len(all) {
+		t.Errorf("Expected every migration applied once, got %d", total)
+	}
+	if _, err := db.Exec(`SELECT 1 FROM "USER"`); err != nil {
+		t.Errorf("Expected tables to exist: %s", err)
+	}
+}
+
+// The schema db/init.sql created before migrations existed
+const initSql = `
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS "CHAT" (
+  ID VARCHAR(255) NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+  SENDER_ID VARCHAR(255) NOT NULL,
+  RECEIVER_ID VARCHAR(255) NOT NULL,
+  MESSAGE TEXT NOT NULL,
+  CREATED_AT TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS "USER" (
+  ID VARCHAR(255) NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+  NAME VARCHAR(255) NOT NULL,
+  EMAIL VARCHAR(255) NOT NULL UNIQUE,
+  PASSWORD VARCHAR(255) NOT NULL
+);`
+
+// Tests migrating a database created by db/init.sql, keeping its rows
+func TestUpFromInitSql(t *testing.T) {
+	ctx := context.Background()
+	log := zap.NewNop()
+
+	container, db, err := testUtils.SetUpPostgresForTesting(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up postgres for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+		db.Close()
+	})
+
+	all, err := migrations.Load()
+	if err != nil {
+		t.Fatalf("Error loading migrations: %s", err)
+	}
+	if _, err := migrations.Down(ctx, db, log, len(all)); err != nil {
+		t.Fatalf("Error reverting migrations: %s", err)
+	}
+	if _, err := db.Exec(`DROP TABLE "SCHEMA_MIGRATIONS"`); err != nil {
+		t.Fatalf("Error dropping migrations table: %s", err)
+	}
+
+	if _, err := db.Exec(initSql); err != nil {
+		t.Fatalf("Error creating init.sql schema: %s", err)
+	}
+	if _, err := db.Exec(`INSERT INTO "USER" (NAME, EMAIL, PASSWORD) VALUES ('test', 'test@test', 'test')`); err != nil {
+		t.Fatalf("Error saving user: %s", err)
+	}
+	if _, err := db.Exec(`INSERT INTO "CHAT" (SENDER_ID, RECEIVER_ID, MESSAGE) VALUES ('a', 'b', 'hello world')`); err != nil {
+		t.Fatalf("Error saving chat: %s", err)
+	}
+
+	if count, err := migrations.Up(ctx, db, log); err != nil || count != len(all) {
+		t.Fatalf("Expected %d migrations applied, got %d %v", len(all), count, err)
+	}
+
+	var role, userType string
+	query := `SELECT ROLE, TYPE FROM "USER" WHERE EMAIL = 'test@test'`
+	if err := db.QueryRow(query).Scan(&role, &userType); err != nil || role != "user" || userType != "human" {
+		t.Errorf("Expected the user to be kept with defaults, got %s %s %v", role, userType, err)
+	}
+	var found int
+	query = `SELECT COUNT(*) FROM "CHAT" WHERE CONVERSATION_ID IS NULL AND SEARCH_VECTOR @@ plainto_tsquery('english', 'hello')`
+	if err := db.QueryRow(query).Scan(&found); err != nil || found != 1 {
+		t.Errorf("Expected the chat to be kept and searchable, got %d %v", found, err)
+	}
+}
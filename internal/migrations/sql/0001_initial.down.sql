@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "USER";
+DROP TABLE IF EXISTS "CHAT";
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "CONVERSATION_MEMBER";
+DROP TABLE IF EXISTS "CONVERSATION";
+ALTER TABLE "CHAT" DROP COLUMN IF EXISTS CONVERSATION_ID;
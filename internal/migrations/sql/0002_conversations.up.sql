@@ -0,0 +1,18 @@
+ALTER TABLE "CHAT" ADD COLUMN IF NOT EXISTS CONVERSATION_ID VARCHAR(255);
+
+CREATE TABLE IF NOT EXISTS "CONVERSATION" (
+  ID VARCHAR(255) NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+  TYPE VARCHAR(16) NOT NULL CHECK (TYPE IN ('direct', 'group')),
+  NAME VARCHAR(255) NOT NULL DEFAULT '',
+  CREATED_BY VARCHAR(255) NOT NULL,
+  CREATED_AT TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS "CONVERSATION_MEMBER" (
+  CONVERSATION_ID VARCHAR(255) NOT NULL REFERENCES "CONVERSATION" (ID) ON DELETE CASCADE,
+  USER_ID VARCHAR(255) NOT NULL,
+  JOINED_AT TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (CONVERSATION_ID, USER_ID)
+);
+
+CREATE INDEX IF NOT EXISTS CONVERSATION_MEMBER_USER_ID_IDX ON "CONVERSATION_MEMBER" (USER_ID);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS CHAT_CONVERSATION_ID_CREATED_AT_IDX;
+DROP INDEX IF EXISTS CHAT_RECEIVER_ID_CREATED_AT_IDX;
+DROP INDEX IF EXISTS CHAT_SENDER_ID_CREATED_AT_IDX;
@@ -0,0 +1,5 @@
+-- Keyset pagination walks the history of a user or conversation by
+-- (CREATED_AT, ID)
+CREATE INDEX IF NOT EXISTS CHAT_SENDER_ID_CREATED_AT_IDX ON "CHAT" (SENDER_ID, CREATED_AT, ID);
+CREATE INDEX IF NOT EXISTS CHAT_RECEIVER_ID_CREATED_AT_IDX ON "CHAT" (RECEIVER_ID, CREATED_AT, ID);
+CREATE INDEX IF NOT EXISTS CHAT_CONVERSATION_ID_CREATED_AT_IDX ON "CHAT" (CONVERSATION_ID, CREATED_AT, ID);
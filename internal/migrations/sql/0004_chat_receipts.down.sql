@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "CHAT_RECEIPT";
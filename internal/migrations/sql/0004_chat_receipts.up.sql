@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS "CHAT_RECEIPT" (
+  CHAT_ID VARCHAR(255) NOT NULL REFERENCES "CHAT" (ID) ON DELETE CASCADE,
+  USER_ID VARCHAR(255) NOT NULL,
+  STATUS VARCHAR(16) NOT NULL CHECK (STATUS IN ('delivered', 'read')),
+  UPDATED_AT TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (CHAT_ID, USER_ID)
+);
@@ -0,0 +1 @@
+ALTER TABLE "USER" DROP COLUMN IF EXISTS HIDE_LAST_SEEN;
@@ -0,0 +1 @@
+ALTER TABLE "USER" ADD COLUMN IF NOT EXISTS HIDE_LAST_SEEN BOOLEAN NOT NULL DEFAULT FALSE;
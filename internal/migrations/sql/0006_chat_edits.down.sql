@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS "CHAT_HIDDEN";
+DROP TABLE IF EXISTS "CHAT_EDIT";
+ALTER TABLE "CHAT" DROP COLUMN IF EXISTS DELETED_AT;
+ALTER TABLE "CHAT" DROP COLUMN IF EXISTS EDITED_AT;
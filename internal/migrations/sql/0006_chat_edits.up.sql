@@ -0,0 +1,17 @@
+ALTER TABLE "CHAT" ADD COLUMN IF NOT EXISTS EDITED_AT TIMESTAMP;
+ALTER TABLE "CHAT" ADD COLUMN IF NOT EXISTS DELETED_AT TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS "CHAT_EDIT" (
+  ID VARCHAR(255) NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+  CHAT_ID VARCHAR(255) NOT NULL REFERENCES "CHAT" (ID) ON DELETE CASCADE,
+  MESSAGE TEXT NOT NULL,
+  EDITED_AT TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS CHAT_EDIT_CHAT_ID_IDX ON "CHAT_EDIT" (CHAT_ID);
+
+CREATE TABLE IF NOT EXISTS "CHAT_HIDDEN" (
+  CHAT_ID VARCHAR(255) NOT NULL REFERENCES "CHAT" (ID) ON DELETE CASCADE,
+  USER_ID VARCHAR(255) NOT NULL,
+  PRIMARY KEY (CHAT_ID, USER_ID)
+);
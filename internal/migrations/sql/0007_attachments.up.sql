@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS "ATTACHMENT" (
+  ID VARCHAR(255) NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+  UPLOADER_ID VARCHAR(255) NOT NULL,
+  CHAT_ID VARCHAR(255) REFERENCES "CHAT" (ID) ON DELETE CASCADE,
+  FILE_NAME VARCHAR(255) NOT NULL,
+  CONTENT_TYPE VARCHAR(255) NOT NULL,
+  SIZE BIGINT NOT NULL,
+  STORAGE_KEY VARCHAR(255) NOT NULL UNIQUE,
+  CREATED_AT TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS ATTACHMENT_CHAT_ID_IDX ON "ATTACHMENT" (CHAT_ID);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS CHAT_SEARCH_VECTOR_IDX;
+ALTER TABLE "CHAT" DROP COLUMN IF EXISTS SEARCH_VECTOR;
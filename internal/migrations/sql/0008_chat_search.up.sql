@@ -0,0 +1,4 @@
+ALTER TABLE "CHAT" ADD COLUMN IF NOT EXISTS SEARCH_VECTOR TSVECTOR
+  GENERATED ALWAYS AS (to_tsvector('english', MESSAGE)) STORED;
+
+CREATE INDEX IF NOT EXISTS CHAT_SEARCH_VECTOR_IDX ON "CHAT" USING GIN (SEARCH_VECTOR);
@@ -0,0 +1 @@
+ALTER TABLE "USER" ADD COLUMN IF NOT EXISTS EMAIL_VERIFIED BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "RECOVERY_CODE";
+ALTER TABLE "USER" DROP COLUMN IF EXISTS TOTP_ENABLED;
+ALTER TABLE "USER" DROP COLUMN IF EXISTS TOTP_SECRET;
@@ -0,0 +1,11 @@
+ALTER TABLE "USER" ADD COLUMN IF NOT EXISTS TOTP_SECRET VARCHAR(64);
+ALTER TABLE "USER" ADD COLUMN IF NOT EXISTS TOTP_ENABLED BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS "RECOVERY_CODE" (
+  ID VARCHAR(255) NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+  USER_ID VARCHAR(255) NOT NULL REFERENCES "USER" (ID) ON DELETE CASCADE,
+  CODE_HASH VARCHAR(255) NOT NULL,
+  USED_AT TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS RECOVERY_CODE_USER_ID_IDX ON "RECOVERY_CODE" (USER_ID);
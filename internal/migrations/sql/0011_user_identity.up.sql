@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS "USER_IDENTITY" (
+  ISSUER VARCHAR(255) NOT NULL,
+  SUBJECT VARCHAR(255) NOT NULL,
+  USER_ID VARCHAR(255) NOT NULL REFERENCES "USER" (ID) ON DELETE CASCADE,
+  CREATED_AT TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (ISSUER, SUBJECT)
+);
+
+CREATE INDEX IF NOT EXISTS USER_IDENTITY_USER_ID_IDX ON "USER_IDENTITY" (USER_ID);
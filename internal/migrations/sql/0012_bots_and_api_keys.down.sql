@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS "API_KEY";
+DROP INDEX IF EXISTS USER_OWNER_ID_IDX;
+ALTER TABLE "USER" DROP COLUMN IF EXISTS OWNER_ID;
+ALTER TABLE "USER" DROP COLUMN IF EXISTS TYPE;
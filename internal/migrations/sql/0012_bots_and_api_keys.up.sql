@@ -0,0 +1,18 @@
+ALTER TABLE "USER" ADD COLUMN IF NOT EXISTS TYPE VARCHAR(16) NOT NULL DEFAULT 'human' CHECK (TYPE IN ('human', 'bot'));
+ALTER TABLE "USER" ADD COLUMN IF NOT EXISTS OWNER_ID VARCHAR(255) REFERENCES "USER" (ID) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS USER_OWNER_ID_IDX ON "USER" (OWNER_ID);
+
+CREATE TABLE IF NOT EXISTS "API_KEY" (
+  ID VARCHAR(255) NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+  USER_ID VARCHAR(255) NOT NULL REFERENCES "USER" (ID) ON DELETE CASCADE,
+  NAME VARCHAR(255) NOT NULL,
+  KEY_HASH VARCHAR(64) NOT NULL,
+  SCOPES TEXT[] NOT NULL,
+  CREATED_AT TIMESTAMP NOT NULL DEFAULT NOW(),
+  LAST_USED_AT TIMESTAMP,
+  EXPIRES_AT TIMESTAMP,
+  REVOKED_AT TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS API_KEY_USER_ID_IDX ON "API_KEY" (USER_ID);
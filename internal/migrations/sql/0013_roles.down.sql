@@ -0,0 +1,3 @@
+ALTER TABLE "USER" DROP COLUMN IF EXISTS DISABLED_AT;
+ALTER TABLE "USER" DROP COLUMN IF EXISTS CREATED_AT;
+ALTER TABLE "USER" DROP COLUMN IF EXISTS ROLE;
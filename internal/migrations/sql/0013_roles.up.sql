@@ -0,0 +1,3 @@
+ALTER TABLE "USER" ADD COLUMN IF NOT EXISTS ROLE VARCHAR(16) NOT NULL DEFAULT 'user' CHECK (ROLE IN ('user', 'moderator', 'admin'));
+ALTER TABLE "USER" ADD COLUMN IF NOT EXISTS CREATED_AT TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE "USER" ADD COLUMN IF NOT EXISTS DISABLED_AT TIMESTAMP;
@@ -0,0 +1,18 @@
+-- Events written in the same transaction as the rows they describe and
+-- published by the relay, so a broker outage delays delivery instead of
+-- losing it
+CREATE TABLE IF NOT EXISTS "OUTBOX" (
+  ID VARCHAR(255) NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+  DEDUP_KEY VARCHAR(255) NOT NULL UNIQUE,
+  RECEIVER_ID VARCHAR(255),
+  CONVERSATION_ID VARCHAR(255),
+  BODY BYTEA NOT NULL,
+  ATTEMPTS INT NOT NULL DEFAULT 0,
+  LAST_ERROR TEXT,
+  NEXT_ATTEMPT_AT TIMESTAMP NOT NULL DEFAULT NOW(),
+  CREATED_AT TIMESTAMP NOT NULL DEFAULT NOW(),
+  SENT_AT TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS OUTBOX_PENDING_IDX ON "OUTBOX" (NEXT_ATTEMPT_AT) WHERE SENT_AT IS NULL;
+CREATE INDEX IF NOT EXISTS OUTBOX_SENT_AT_IDX ON "OUTBOX" (SENT_AT) WHERE SENT_AT IS NOT NULL;
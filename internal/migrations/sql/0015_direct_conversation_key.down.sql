@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS CONVERSATION_DIRECT_KEY_IDX;
+ALTER TABLE "CONVERSATION" DROP COLUMN IF EXISTS DIRECT_KEY;
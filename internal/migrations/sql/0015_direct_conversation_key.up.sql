@@ -0,0 +1,17 @@
+-- Direct conversations are unique per pair of members, so two users creating
+-- one at the same time end up with the same conversation. Earlier duplicates
+-- keep no key and the oldest one of each pair is used.
+ALTER TABLE "CONVERSATION" ADD COLUMN IF NOT EXISTS DIRECT_KEY VARCHAR(511);
+
+WITH PAIRS AS (
+  SELECT C.ID, C.CREATED_AT, string_agg(M.USER_ID, ':' ORDER BY M.USER_ID) AS DIRECT_KEY
+  FROM "CONVERSATION" C
+  JOIN "CONVERSATION_MEMBER" M ON M.CONVERSATION_ID = C.ID
+  WHERE C.TYPE = 'direct'
+  GROUP BY C.ID, C.CREATED_AT
+), OLDEST AS (
+  SELECT DISTINCT ON (DIRECT_KEY) ID, DIRECT_KEY FROM PAIRS ORDER BY DIRECT_KEY, CREATED_AT, ID
+)
+UPDATE "CONVERSATION" C SET DIRECT_KEY = O.DIRECT_KEY FROM OLDEST O WHERE C.ID = O.ID;
+
+CREATE UNIQUE INDEX IF NOT EXISTS CONVERSATION_DIRECT_KEY_IDX ON "CONVERSATION" (DIRECT_KEY);
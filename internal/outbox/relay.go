@@ -0,0 +1,126 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"go.uber.org/zap"
+)
+
+// Relay publishes the events saved in the outbox. An event is marked sent only
+// after the broker accepted it, so every event is delivered at least once;
+// consumers drop copies by their dedup key.
+type Relay struct {
+	outbox db.OutboxRepository
+	broker broker.Broker
+	log    *zap.Logger
+	notify chan struct{}
+}
+
+func NewRelay(outbox db.OutboxRepository, broker broker.Broker, log *zap.Logger) *Relay {
+	return &Relay{
+		outbox: outbox,
+		broker: broker,
+		log:    log,
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// Notify wakes the relay up after an event was saved. It never blocks.
+func (r *Relay) Notify() {
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Run relays pending events every OUTBOX_POLL_INTERVAL and whenever it is
+// notified, until ctx is done. It also deletes events sent more than
+// OUTBOX_RETENTION ago.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(constants.OUTBOX_POLL_INTERVAL)
+	defer ticker.Stop()
+	lastPrune := time.Time{}
+
+	for {
+		r.relayAll(ctx)
+
+		if time.Since(lastPrune) >= time.Hour {
+			lastPrune = time.Now()
+			if deleted, err := r.outbox.DeleteSentOutbox(constants.OUTBOX_RETENTION); err != nil {
+				r.log.Error("Error deleting sent outbox events", zap.Error(err))
+			} else if deleted > 0 {
+				r.log.Info("Deleted sent outbox events", zap.Int64("deleted", deleted))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.notify:
+		}
+	}
+}
+
+// relayAll relays batches until the outbox has no pending events left
+func (r *Relay) relayAll(ctx context.Context) {
+	for ctx.Err() == nil {
+		count, err := r.RelayPending(ctx)
+		if err != nil {
+			r.log.Error("Error claiming outbox events", zap.Error(err))
+			return
+		}
+		if count < constants.OUTBOX_BATCH_SIZE {
+			return
+		}
+	}
+}
+
+// RelayPending claims a batch of pending events and publishes them. Events
+// that fail to publish are retried later with exponential backoff. It returns
+// the number of events claimed.
+func (r *Relay) RelayPending(ctx context.Context) (int, error) {
+	messages, err := r.outbox.ClaimOutbox(constants.OUTBOX_BATCH_SIZE, constants.OUTBOX_LEASE)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, message := range messages {
+		publishCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		if message.ConversationId != "" {
+			err = r.broker.PublishToConversation(publishCtx, message.ConversationId, message.Body)
+		} else {
+			err = r.broker.Publish(publishCtx, message.ReceiverId, message.Body)
+		}
+		cancel()
+
+		if err != nil {
+			r.log.Warn("Error publishing outbox event",
+				zap.String("id", message.Id), zap.Int("attempts", message.Attempts+1), zap.Error(err))
+			if err := r.outbox.MarkOutboxFailed(message.Id, err.Error(), retryAfter(message.Attempts)); err != nil {
+				r.log.Error("Error marking outbox event failed", zap.Error(err))
+			}
+			continue
+		}
+		if err := r.outbox.MarkOutboxSent(message.Id); err != nil {
+			// The lease runs out and the event is published again, which
+			// consumers drop by its dedup key
+			r.log.Error("Error marking outbox event sent", zap.Error(err))
+		}
+	}
+	return len(messages), nil
+}
+
+// retryAfter returns how long to wait before publishing an event again after
+// it failed attempts times before
+func retryAfter(attempts int) time.Duration {
+	delay := constants.OUTBOX_RETRY_BASE
+	for i := 0; i < attempts && delay < constants.OUTBOX_RETRY_MAX; i++ {
+		delay *= 2
+	}
+	return min(delay, constants.OUTBOX_RETRY_MAX)
+}
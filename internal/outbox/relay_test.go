@@ -0,0 +1,88 @@
+package outbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/outbox"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+	"go.uber.org/zap"
+)
+
+// failingBroker fails every publish while down is set
+type failingBroker struct {
+	broker.Broker
+	down bool
+}
+
+func (b *failingBroker) Publish(ctx context.Context, userId string, body []byte) error {
+	if b.down {
+		return errors.New("broker is down")
+	}
+	return b.Broker.Publish(ctx, userId, body)
+}
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+	repository := db.NewMemoryRepository()
+	b := &failingBroker{Broker: broker.NewMemoryBroker(), down: true}
+	relay := outbox.NewRelay(repository, b, zap.NewNop())
+
+	receiverId := testUtils.RandStringRunes(10)
+	chat := &dto.Chat{
+		SenderId:   testUtils.RandStringRunes(10),
+		ReceiverId: receiverId,
+		Message:    "hello",
+		CreatedAt:  time.Now(),
+	}
+	if err := repository.SaveChat(chat); err != nil {
+		t.Fatalf("Error saving chat: %s", err)
+	}
+
+	// A failed publish is retried later, not right away
+	if count, err := relay.RelayPending(ctx); err != nil || count != 1 {
+		t.Fatalf("Expected to claim 1 event, got %d: %v", count, err)
+	}
+	if count, _ := relay.RelayPending(ctx); count != 0 {
+		t.Fatalf("Expected the failed event to wait for its retry, claimed %d", count)
+	}
+
+	time.Sleep(constants.OUTBOX_RETRY_BASE)
+	b.down = false
+	if count, err := relay.RelayPending(ctx); err != nil || count != 1 {
+		t.Fatalf("Expected to claim the event again, got %d: %v", count, err)
+	}
+	if count, _ := relay.RelayPending(ctx); count != 0 {
+		t.Fatalf("Expected sent events not to be claimed, claimed %d", count)
+	}
+
+	subscription, err := b.Subscribe(receiverId)
+	if err != nil {
+		t.Fatalf("Error subscribing: %s", err)
+	}
+	defer subscription.Close()
+
+	select {
+	case d := <-subscription.Deliveries():
+		var envelope dto.WsEnvelope
+		if err := json.Unmarshal(d.Body, &envelope); err != nil {
+			t.Fatalf("Error reading envelope: %s", err)
+		}
+		if envelope.Type != constants.WS_MESSAGE || envelope.DedupKey != constants.WS_MESSAGE+":"+chat.Id {
+			t.Errorf("Unexpected envelope %s", d.Body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the chat")
+	}
+
+	if deleted, err := repository.DeleteSentOutbox(0); err != nil || deleted != 1 {
+		t.Errorf("Expected to delete 1 sent event, got %d: %v", deleted, err)
+	}
+}
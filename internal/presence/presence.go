@@ -0,0 +1,207 @@
+package presence
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/redis/go-redis/v9"
+)
+
+// The online key of a user is a sorted set of their connections scored by
+// when each expires, so every tab keeps the user online on its own. The online
+// index scores users by when their last connection expires, so the sweep finds
+// users whose connections all expired without disconnecting. The set itself
+// outlives its connections long enough to be swept.
+
+// Adds or refreshes a connection. Returns 1 if the user had no live connection.
+var heartbeatScript = redis.NewScript(`
+local now, ttl = tonumber(ARGV[2]), tonumber(ARGV[3])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+local offline = redis.call("ZCARD", KEYS[1]) == 0
+redis.call("ZADD", KEYS[1], now + ttl, ARGV[1])
+redis.call("PEXPIRE", KEYS[1], 2 * ttl)
+redis.call("ZADD", KEYS[2], now + ttl, ARGV[4])
+if offline then
+	return 1
+end
+return 0
+`)
+
+// Removes a connection, or only expired ones if ARGV[1] is empty. Returns 1 if
+// this removed the last live connection of the user, who is then marked
+// offline as of their last heartbeat.
+var removeScript = redis.NewScript(`
+local now, ttl = tonumber(ARGV[2]), tonumber(ARGV[3])
+local last = redis.call("ZRANGE", KEYS[1], -1, -1, "WITHSCORES")[2]
+local removed = redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+if ARGV[1] ~= "" then
+	removed = removed + redis.call("ZREM", KEYS[1], ARGV[1])
+end
+if redis.call("ZCARD", KEYS[1]) > 0 then
+	local expiresAt = redis.call("ZRANGE", KEYS[1], -1, -1, "WITHSCORES")[2]
+	redis.call("ZADD", KEYS[3], expiresAt, ARGV[4])
+	return 0
+end
+redis.call("ZREM", KEYS[3], ARGV[4])
+if removed == 0 then
+	return 0
+end
+local lastSeen = now
+if ARGV[1] == "" then
+	lastSeen = tonumber(last) - ttl
+end
+redis.call("SET", KEYS[2], math.floor(lastSeen / 1000))
+return 1
+`)
+
+// Removes a subscription of a connection. Returns 1 if no connection of the
+// user follows the target anymore.
+var unsubscribeScript = redis.NewScript(`
+if redis.call("HINCRBY", KEYS[1], ARGV[1], -1) > 0 then
+	return 0
+end
+redis.call("HDEL", KEYS[1], ARGV[1])
+return 1
+`)
+
+func onlineKey(userId string) string {
+	return constants.PRESENCE_KEY_PREFIX + userId
+}
+
+func lastSeenKey(userId string) string {
+	return constants.PRESENCE_LAST_SEEN_PREFIX + userId
+}
+
+func subscriptionsKey(userId string) string {
+	return constants.PRESENCE_SUBSCRIPTIONS_PREFIX + userId
+}
+
+// NewConnectionId returns a random id for a websocket connection
+func NewConnectionId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Heartbeat keeps a connection of the user online for constants.PRESENCE_TTL.
+// It returns true if the user had no other connection online.
+func Heartbeat(ctx context.Context, rdb *redis.Client, userId, connectionId string) (bool, error) {
+	keys := []string{onlineKey(userId), constants.PRESENCE_ONLINE_KEY}
+	result, err := heartbeatScript.Run(ctx, rdb, keys,
+		connectionId, time.Now().UnixMilli(), constants.PRESENCE_TTL.Milliseconds(), userId).Int()
+	return result == 1, err
+}
+
+// Disconnect removes a connection of the user. It returns true if it was the
+// last one, in which case the user is offline and their last seen time is
+// recorded.
+func Disconnect(ctx context.Context, rdb *redis.Client, userId, connectionId string) (bool, error) {
+	return remove(ctx, rdb, userId, connectionId, time.Now())
+}
+
+func remove(ctx context.Context, rdb *redis.Client, userId, connectionId string, now time.Time) (bool, error) {
+	keys := []string{onlineKey(userId), lastSeenKey(userId), constants.PRESENCE_ONLINE_KEY}
+	result, err := removeScript.Run(ctx, rdb, keys,
+		connectionId, now.UnixMilli(), constants.PRESENCE_TTL.Milliseconds(), userId).Int()
+	return result == 1, err
+}
+
+// Subscribe records that a connection of the user follows the presence of
+// targetId. Connections of a user share their queue and its bindings, so the
+// count tells when the last one stopped following.
+func Subscribe(ctx context.Context, rdb *redis.Client, userId, targetId string) error {
+	return rdb.HIncrBy(ctx, subscriptionsKey(userId), targetId, 1).Err()
+}
+
+// Unsubscribe removes a subscription made by Subscribe. It returns true if no
+// connection of the user follows targetId anymore, so the queue of the user
+// can be unbound from it.
+func Unsubscribe(ctx context.Context, rdb *redis.Client, userId, targetId string) (bool, error) {
+	result, err := unsubscribeScript.Run(ctx, rdb, []string{subscriptionsKey(userId)}, targetId).Int()
+	return result == 1, err
+}
+
+// Sweep marks users offline whose connections all expired before now without
+// disconnecting, such as the connections of a crashed instance. It returns
+// those users, each of them to a single caller even when instances sweep
+// concurrently.
+func Sweep(ctx context.Context, rdb *redis.Client, now time.Time) ([]string, error) {
+	userIds, err := rdb.ZRangeByScore(ctx, constants.PRESENCE_ONLINE_KEY, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.UnixMilli(), 10),
+		Count: constants.PRESENCE_SWEEP_BATCH,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	offline := []string{}
+	for _, userId := range userIds {
+		wentOffline, err := remove(ctx, rdb, userId, "", now)
+		if err != nil {
+			return offline, err
+		}
+		if wentOffline {
+			offline = append(offline, userId)
+		}
+	}
+	return offline, nil
+}
+
+// Get returns the presence of a user as seen by others, without the last
+// seen time if the user hides it
+func Get(ctx context.Context, rdb *redis.Client, users db.UserRepository, userId string) (dto.Presence, error) {
+	presence := dto.Presence{UserId: userId}
+
+	// Expired connections stay in the set until they are swept
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	online, err := rdb.ZCount(ctx, onlineKey(userId), "("+now, "+inf").Result()
+	if err != nil {
+		return presence, err
+	}
+	presence.Online = online > 0
+	if presence.Online {
+		return presence, nil
+	}
+
+	hidden, err := users.IsLastSeenHidden(userId)
+	if err != nil {
+		return presence, err
+	}
+	if hidden {
+		return presence, nil
+	}
+
+	lastSeen, err := rdb.Get(ctx, lastSeenKey(userId)).Int64()
+	if err == redis.Nil {
+		return presence, nil
+	}
+	if err != nil {
+		return presence, err
+	}
+	t := time.Unix(lastSeen, 0).UTC()
+	presence.LastSeen = &t
+
+	return presence, nil
+}
+
+// Publish pushes a presence change to every user subscribed to it. Events
+// expire, so subscribers that are offline do not get stale presence later.
+func Publish(ctx context.Context, b broker.Broker, presence dto.Presence) error {
+	body, err := dto.MarshalWsEnvelope(constants.WS_PRESENCE, presence)
+	if err != nil {
+		return err
+	}
+
+	return b.PublishEphemeral(
+		ctx, broker.PresenceRoutingKey(presence.UserId), body, constants.PRESENCE_EVENT_TTL)
+}
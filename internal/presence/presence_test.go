@@ -0,0 +1,128 @@
+package presence_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/presence"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+)
+
+// Tests coming online, heartbeats and going offline with several connections
+// open at once, and connections that expire without disconnecting
+func TestHeartbeatDisconnect(t *testing.T) {
+	ctx := context.Background()
+
+	container, rdb, err := testUtils.SetUpRedisForTesting(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up redis for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		rdb.Close()
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	userId := testUtils.RandStringRunes(10)
+	first := presence.NewConnectionId()
+	second := presence.NewConnectionId()
+
+	cameOnline, err := presence.Heartbeat(ctx, rdb, userId, first)
+	if err != nil || !cameOnline {
+		t.Fatalf("Expected user to come online: %v", err)
+	}
+	cameOnline, err = presence.Heartbeat(ctx, rdb, userId, second)
+	if err != nil || cameOnline {
+		t.Fatalf("Expected user to stay online: %v", err)
+	}
+	cameOnline, err = presence.Heartbeat(ctx, rdb, userId, first)
+	if err != nil || cameOnline {
+		t.Fatalf("Expected user to stay online: %v", err)
+	}
+
+	// Closing one tab keeps the user online through the other
+	wentOffline, err := presence.Disconnect(ctx, rdb, userId, first)
+	if err != nil || wentOffline {
+		t.Fatalf("Expected second connection to keep the user online: %v", err)
+	}
+	if p, err := presence.Get(ctx, rdb, nil, userId); err != nil || !p.Online {
+		t.Fatalf("Expected user to be online: %v", err)
+	}
+
+	wentOffline, err = presence.Disconnect(ctx, rdb, userId, second)
+	if err != nil || !wentOffline {
+		t.Fatalf("Expected user to go offline: %v", err)
+	}
+	exists, _, err := testUtils.ReadFromRedis(rdb, constants.PRESENCE_KEY_PREFIX+userId)
+	if err != nil || exists {
+		t.Fatalf("Expected user to be offline: %v", err)
+	}
+	exists, lastSeen, err := testUtils.ReadFromRedis(rdb, constants.PRESENCE_LAST_SEEN_PREFIX+userId)
+	if err != nil || !exists || lastSeen == "" {
+		t.Fatalf("Expected last seen to be recorded: %v", err)
+	}
+
+	// Connections that stop sending heartbeats are swept once
+	crashedId := testUtils.RandStringRunes(10)
+	if _, err := presence.Heartbeat(ctx, rdb, crashedId, first); err != nil {
+		t.Fatalf("Error sending heartbeat: %s", err)
+	}
+	if offline, err := presence.Sweep(ctx, rdb, time.Now()); err != nil || slices.Contains(offline, crashedId) {
+		t.Fatalf("Expected live connections not to be swept, got %v: %v", offline, err)
+	}
+	later := time.Now().Add(constants.PRESENCE_TTL + time.Second)
+	if offline, err := presence.Sweep(ctx, rdb, later); err != nil || !slices.Contains(offline, crashedId) {
+		t.Fatalf("Expected expired connections to be swept, got %v: %v", offline, err)
+	}
+	if offline, err := presence.Sweep(ctx, rdb, later); err != nil || len(offline) != 0 {
+		t.Fatalf("Expected users to be swept once, got %v: %v", offline, err)
+	}
+	exists, _, err = testUtils.ReadFromRedis(rdb, constants.PRESENCE_LAST_SEEN_PREFIX+crashedId)
+	if err != nil || !exists {
+		t.Fatalf("Expected last seen to be recorded: %v", err)
+	}
+	if wentOffline, err := presence.Disconnect(ctx, rdb, crashedId, first); err != nil || wentOffline {
+		t.Errorf("Expected a swept connection not to go offline again: %v", err)
+	}
+}
+
+// Tests that presence subscriptions are counted across the connections of a user
+func TestSubscribeUnsubscribe(t *testing.T) {
+	ctx := context.Background()
+
+	container, rdb, err := testUtils.SetUpRedisForTesting(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up redis for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		rdb.Close()
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	userId := testUtils.RandStringRunes(10)
+	targetId := testUtils.RandStringRunes(10)
+
+	for range 2 {
+		if err := presence.Subscribe(ctx, rdb, userId, targetId); err != nil {
+			t.Fatalf("Error subscribing: %s", err)
+		}
+	}
+	if last, err := presence.Unsubscribe(ctx, rdb, userId, targetId); err != nil || last {
+		t.Fatalf("Expected the other connection to keep following: %v", err)
+	}
+	if last, err := presence.Unsubscribe(ctx, rdb, userId, targetId); err != nil || !last {
+		t.Fatalf("Expected the last connection to stop following: %v", err)
+	}
+	exists, _, err := testUtils.ReadFromRedis(rdb, constants.PRESENCE_SUBSCRIPTIONS_PREFIX+userId)
+	if err != nil || exists {
+		t.Errorf("Expected the subscriptions to be removed: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package presence
+
+import (
+	"context"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// Sweeper marks users offline whose connections expired without
+// disconnecting, and tells their subscribers.
+type Sweeper struct {
+	rdb    *redis.Client
+	users  db.UserRepository
+	broker broker.Broker
+	log    *zap.Logger
+}
+
+func NewSweeper(rdb *redis.Client, users db.UserRepository, broker broker.Broker, log *zap.Logger) *Sweeper {
+	return &Sweeper{
+		rdb:    rdb,
+		users:  users,
+		broker: broker,
+		log:    log,
+	}
+}
+
+// Run sweeps expired connections every PRESENCE_SWEEP_INTERVAL until ctx is
+// done
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(constants.PRESENCE_SWEEP_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		s.sweepAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweepAll sweeps batches until no expired users are left
+func (s *Sweeper) sweepAll(ctx context.Context) {
+	for ctx.Err() == nil {
+		count, err := s.SweepExpired(ctx)
+		if err != nil {
+			s.log.Error("Error sweeping presence", zap.Error(err))
+			return
+		}
+		if count < constants.PRESENCE_SWEEP_BATCH {
+			return
+		}
+	}
+}
+
+// SweepExpired marks a batch of users with expired connections offline and
+// publishes their presence. It returns the number of users marked offline.
+func (s *Sweeper) SweepExpired(ctx context.Context) (int, error) {
+	userIds, err := Sweep(ctx, s.rdb, time.Now())
+	for _, userId := range userIds {
+		p, err := Get(ctx, s.rdb, s.users, userId)
+		if err != nil {
+			s.log.Error("Error getting presence", zap.String("userId", userId), zap.Error(err))
+			continue
+		}
+
+		publishCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		if err := Publish(publishCtx, s.broker, p); err != nil {
+			s.log.Error("Error publishing presence", zap.String("userId", userId), zap.Error(err))
+		}
+		cancel()
+	}
+	return len(userIds), err
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// Token bucket stored as the time the bucket is next full (GCRA). ARGV[1] is
+// the time one token takes to refill and ARGV[2] the bucket size, both in
+// microseconds and tokens. The clock of Redis is used so every instance
+// agrees. Returns whether the request is allowed, the tokens left, and the
+// microseconds until the next token and until the bucket is full.
+var allowScript = redis.NewScript(`
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+
+local allowAt = tat + interval - burst * interval
+if now < allowAt then
+	return {0, 0, allowAt - now, tat - now}
+end
+
+local newTat = tat + interval
+redis.call("SET", KEYS[1], string.format("%.0f", newTat), "PX", math.ceil((newTat - now) / 1000))
+return {1, math.floor((now - (newTat - burst * interval)) / interval), 0, newTat - now}
+`)
+
+// Limit allows Rate requests per Period on average, and up to Burst at once
+type Limit struct {
+	Name   string
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+var (
+	Default = Limit{
+		Name:   "default",
+		Rate:   constants.RATE_LIMIT_DEFAULT_RATE,
+		Period: time.Minute,
+		Burst:  constants.RATE_LIMIT_DEFAULT_BURST,
+	}
+	ChatSend = Limit{
+		Name:   "chat_send",
+		Rate:   constants.RATE_LIMIT_CHAT_SEND_RATE,
+		Period: time.Minute,
+		Burst:  constants.RATE_LIMIT_CHAT_SEND_BURST,
+	}
+)
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long to wait before the next request is allowed
+	RetryAfter time.Duration
+	// ResetAfter is how long until the bucket is full again
+	ResetAfter time.Duration
+}
+
+// Limiter counts requests in Redis so limits hold across instances
+type Limiter struct {
+	rdb *redis.Client
+	log *zap.Logger
+}
+
+func NewLimiter(rdb *redis.Client, log *zap.Logger) *Limiter {
+	return &Limiter{rdb: rdb, log: log}
+}
+
+// Allow takes a token from the bucket of key under limit. Requests are
+// allowed if Redis cannot be reached, so an outage does not take the API down.
+func (l *Limiter) Allow(ctx context.Context, limit Limit, key string) Result {
+	interval := limit.Period.Microseconds() / int64(limit.Rate)
+	redisKey := constants.RATE_LIMIT_KEY_PREFIX + limit.Name + ":" + key
+
+	values, err := allowScript.Run(ctx, l.rdb, []string{redisKey}, interval, limit.Burst).Int64Slice()
+	if err != nil || len(values) != 4 {
+		l.log.Error("Error checking rate limit", zap.String("limit", limit.Name), zap.Error(err))
+		return Result{Allowed: true, Limit: limit.Burst, Remaining: limit.Burst}
+	}
+
+	return Result{
+		Allowed:    values[0] == 1,
+		Limit:      limit.Burst,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Microsecond,
+		ResetAfter: time.Duration(values[3]) * time.Microsecond,
+	}
+}
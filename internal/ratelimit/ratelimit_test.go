@@ -0,0 +1,59 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/ratelimit"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+	"go.uber.org/zap"
+)
+
+// Tests that a bucket allows a burst, then one request per refill, and that
+// buckets of different keys are independent
+func TestAllow(t *testing.T) {
+	ctx := context.Background()
+
+	container, rdb, err := testUtils.SetUpRedisForTesting(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up redis for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		rdb.Close()
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	limiter := ratelimit.NewLimiter(rdb, zap.NewNop())
+	limit := ratelimit.Limit{Name: "test", Rate: 10, Period: time.Second, Burst: 3}
+
+	for i := range limit.Burst {
+		result := limiter.Allow(ctx, limit, "a")
+		if !result.Allowed {
+			t.Fatalf("Expected request %d to be allowed", i)
+		}
+		if result.Remaining != limit.Burst-i-1 {
+			t.Errorf("Expected %d remaining, got %d", limit.Burst-i-1, result.Remaining)
+		}
+	}
+
+	result := limiter.Allow(ctx, limit, "a")
+	if result.Allowed {
+		t.Fatalf("Expected request over the burst to be rejected")
+	}
+	if result.RetryAfter <= 0 || result.RetryAfter > 100*time.Millisecond {
+		t.Errorf("Expected to retry within one refill, got %s", result.RetryAfter)
+	}
+
+	if !limiter.Allow(ctx, limit, "b").Allowed {
+		t.Errorf("Expected another key to have its own bucket")
+	}
+
+	time.Sleep(result.RetryAfter)
+	if !limiter.Allow(ctx, limit, "a").Allowed {
+		t.Errorf("Expected a request to be allowed after a refill")
+	}
+}
@@ -44,3 +44,11 @@ func DefaultRedisAuthConfig() *RedisConfig {
 		WithDBRedis(0),
 	)
 }
+
+func DefaultRedisPresenceConfig() *RedisConfig {
+	return NewRedisConfig(
+		WithAddrRedis(utils.GetDotEnvVariable(constants.REDIS_HOST)+":"+utils.GetDotEnvVariable(constants.REDIS_PORT)),
+		WithPasswordRedis(utils.GetDotEnvVariable(constants.REDIS_PASSWORD)),
+		WithDBRedis(1),
+	)
+}
@@ -3,15 +3,28 @@ package routes
 import (
 	"context"
 	"database/sql"
+	"slices"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
-	amqpConfig "github.com/nihal-ramaswamy/GoChat/internal/amqp"
+	admin_api "github.com/nihal-ramaswamy/GoChat/internal/api/admin"
 	auth_api "github.com/nihal-ramaswamy/GoChat/internal/api/auth"
+	bot_api "github.com/nihal-ramaswamy/GoChat/internal/api/bot"
 	chat_api "github.com/nihal-ramaswamy/GoChat/internal/api/chat"
 	healthcheck_api "github.com/nihal-ramaswamy/GoChat/internal/api/healthcheck"
+	presence_api "github.com/nihal-ramaswamy/GoChat/internal/api/presence"
+	wellknown_api "github.com/nihal-ramaswamy/GoChat/internal/api/wellknown"
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
 	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/mailer"
+	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"github.com/nihal-ramaswamy/GoChat/internal/outbox"
+	"github.com/nihal-ramaswamy/GoChat/internal/ratelimit"
+	"github.com/nihal-ramaswamy/GoChat/internal/sso"
+	"github.com/nihal-ramaswamy/GoChat/internal/storage"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
@@ -19,17 +32,33 @@ import (
 func NewRoutes(
 	server *gin.Engine,
 	pdb *sql.DB,
+	users db.UserRepository,
+	chats db.ChatRepository,
 	rdb_auth *redis.Client,
+	rdb_presence *redis.Client,
 	ctx context.Context,
 	log *zap.Logger,
-	amqpConfig *amqpConfig.AmqpConfig,
+	broker broker.Broker,
+	relay *outbox.Relay,
 	upgrader *websocket.Upgrader,
 	websocketMap *dto.WebsocketConnectionMap,
+	storage storage.Storage,
+	mailer mailer.Mailer,
+	oidcProvider *sso.OidcProvider,
+	keys *jwtkeys.KeySet,
 ) {
+	// Registered before the groups so it applies to every route
+	limiter := ratelimit.NewLimiter(rdb_auth, log)
+	server.Use(middlewares.RateLimit(limiter, ratelimit.Default, middlewares.ByIp))
+
 	serverGroupHandlers := []dto.ServerGroupInterface{
-		healthcheck_api.NewHealthCheckGroup(pdb, rdb_auth, ctx, log),
-		auth_api.NewAuthGroup(pdb, rdb_auth, ctx, log),
-		chat_api.NewChatGroup(pdb, rdb_auth, ctx, log, amqpConfig, upgrader, websocketMap),
+		healthcheck_api.NewHealthCheckGroup(pdb, rdb_auth, ctx, log, broker, keys),
+		auth_api.NewAuthGroup(pdb, users, rdb_auth, ctx, log, mailer, oidcProvider, keys),
+		chat_api.NewChatGroup(pdb, users, chats, rdb_auth, rdb_presence, ctx, log, broker, relay, upgrader, websocketMap, storage, limiter, keys),
+		presence_api.NewPresenceGroup(pdb, users, rdb_auth, rdb_presence, ctx, log, keys),
+		bot_api.NewBotGroup(pdb, rdb_auth, ctx, log, keys),
+		admin_api.NewAdminGroup(pdb, rdb_auth, ctx, log, keys),
+		wellknown_api.NewWellKnownGroup(keys),
 	}
 
 	for _, serverGroupHandler := range serverGroupHandlers {
@@ -47,12 +76,21 @@ func newGroup(server *gin.Engine, groupHandler dto.ServerGroupInterface) {
 }
 
 func newRoute(server *gin.RouterGroup, routeHandler dto.HandlerInterface) {
-	middlewares := routeHandler.Middlewares()
-	middlewares = append(middlewares, routeHandler.Handler())
+	var scope string
+	if scoped, ok := routeHandler.(dto.ScopedHandlerInterface); ok {
+		scope = scoped.Scope()
+	}
+
+	handlers := slices.Clone(routeHandler.Middlewares())
+	handlers = append(handlers, middlewares.RequireScope(scope), routeHandler.Handler())
 	switch routeHandler.RequestMethod() {
 	case constants.GET:
-		server.GET(routeHandler.Pattern(), middlewares...)
+		server.GET(routeHandler.Pattern(), handlers...)
 	case constants.POST:
-		server.POST(routeHandler.Pattern(), middlewares...)
+		server.POST(routeHandler.Pattern(), handlers...)
+	case constants.PATCH:
+		server.PATCH(routeHandler.Pattern(), handlers...)
+	case constants.DELETE:
+		server.DELETE(routeHandler.Pattern(), handlers...)
 	}
 }
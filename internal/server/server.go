@@ -2,6 +2,7 @@ package server
 
 import (
 	"log"
+	"strings"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -13,6 +14,10 @@ type Config struct {
 	Port    string
 	GinMode string // "debug", "release", "test"
 	Cors    cors.Config
+	// Addresses or CIDR ranges of reverse proxies whose X-Forwarded-For header
+	// is believed. Requests from anywhere else are identified by their own
+	// address, so clients cannot pick the address they are rate limited by.
+	TrustedProxies []string
 }
 
 func NewServerConfig(options ...func(*Config)) *Config {
@@ -58,11 +63,34 @@ func WithCorsHosts(hosts []string) func(*Config) {
 	}
 }
 
+func WithTrustedProxies(proxies []string) func(*Config) {
+	return func(c *Config) {
+		c.TrustedProxies = proxies
+	}
+}
+
+// NewEngine returns a gin engine that only trusts forwarding headers from
+// config.TrustedProxies
+func NewEngine(config *Config) (*gin.Engine, error) {
+	gin.SetMode(config.GinMode)
+
+	server := gin.Default()
+	if err := server.SetTrustedProxies(config.TrustedProxies); err != nil {
+		return nil, err
+	}
+	server.Use(cors.New(config.Cors))
+	server.Use(gin.Recovery())
+	return server, nil
+}
+
 func Default() *Config {
 	return NewServerConfig(
 		WithPort(utils.GetDotEnvVariable(constants.SERVER_PORT)),
 		WithGinMode(utils.GetDotEnvVariable(constants.ENV)),
 		WithCors(cors.DefaultConfig()),
 		WithCorsHosts([]string{utils.GetDotEnvVariable(constants.SERVER_HOST) + utils.GetDotEnvVariable(constants.SERVER_PORT)}),
+		WithTrustedProxies(strings.FieldsFunc(utils.GetDotEnvVariable(constants.TRUSTED_PROXIES), func(r rune) bool {
+			return r == ',' || r == ' '
+		})),
 	)
 }
@@ -0,0 +1,82 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/middlewares"
+	"github.com/nihal-ramaswamy/GoChat/internal/server"
+)
+
+// bucket returns the rate limit bucket a request from remoteAddr lands in
+func bucket(t *testing.T, engine *gin.Engine, remoteAddr, forwardedFor string) string {
+	t.Helper()
+	req, err := http.NewRequest("GET", "/bucket", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %s", err)
+	}
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w.Body.String()
+}
+
+func newEngine(t *testing.T, trustedProxies []string) *gin.Engine {
+	t.Helper()
+	engine, err := server.NewEngine(server.NewServerConfig(
+		server.WithGinMode(gin.TestMode),
+		server.WithCors(cors.DefaultConfig()),
+		server.WithCorsHosts([]string{"http://localhost"}),
+		server.WithTrustedProxies(trustedProxies),
+	))
+	if err != nil {
+		t.Fatalf("Error creating engine: %s", err)
+	}
+	engine.GET("/bucket", func(c *gin.Context) {
+		c.String(http.StatusOK, middlewares.ByIp(c))
+	})
+	return engine
+}
+
+// Tests that clients cannot pick their rate limit bucket with X-Forwarded-For
+func TestSpoofedForwardedFor(t *testing.T) {
+	engine := newEngine(t, nil)
+
+	expected := "ip:203.0.113.7"
+	for _, forwardedFor := range []string{"", "198.51.100.1", "198.51.100.2, 10.0.0.1"} {
+		if got := bucket(t, engine, "203.0.113.7:1234", forwardedFor); got != expected {
+			t.Errorf("Expected %q with X-Forwarded-For %q, got %q", expected, forwardedFor, got)
+		}
+	}
+}
+
+// Tests that requests through a trusted proxy are counted per client
+func TestTrustedProxy(t *testing.T) {
+	engine := newEngine(t, []string{"10.0.0.0/8"})
+
+	if got := bucket(t, engine, "10.0.0.1:1234", "198.51.100.1"); got != "ip:198.51.100.1" {
+		t.Errorf("Expected the forwarded address, got %q", got)
+	}
+	// Only the proxy is trusted, not the client sending the header
+	if got := bucket(t, engine, "203.0.113.7:1234", "198.51.100.1"); got != "ip:203.0.113.7" {
+		t.Errorf("Expected the address of the untrusted client, got %q", got)
+	}
+}
+
+func TestInvalidTrustedProxy(t *testing.T) {
+	_, err := server.NewEngine(server.NewServerConfig(
+		server.WithGinMode(gin.TestMode),
+		server.WithCors(cors.DefaultConfig()),
+		server.WithCorsHosts([]string{"http://localhost"}),
+		server.WithTrustedProxies([]string{"not an address"}),
+	))
+	if err == nil {
+		t.Errorf("Expected an invalid proxy to be rejected")
+	}
+}
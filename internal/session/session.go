@@ -0,0 +1,246 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("Invalid refresh token")
+	// ErrRefreshTokenReused is returned when a refresh token that was already
+	// exchanged is presented again. The session is revoked when this happens.
+	ErrRefreshTokenReused = errors.New("Refresh token reuse detected")
+)
+
+// Swaps the refresh token hash if the presented one is current. Replaying a
+// previously used hash deletes the session. Returns 1 when rotated, -1 on
+// reuse and 0 otherwise.
+var rotateScript = redis.NewScript(`
+local current = redis.call("HGET", KEYS[1], "refresh_hash")
+if not current then
+	return 0
+end
+if current == ARGV[1] then
+	redis.call("HSET", KEYS[1], "refresh_hash", ARGV[2], "last_used_at", ARGV[3])
+	redis.call("SADD", KEYS[2], ARGV[1])
+	redis.call("EXPIRE", KEYS[1], ARGV[4])
+	redis.call("EXPIRE", KEYS[2], ARGV[4])
+	return 1
+end
+if redis.call("SISMEMBER", KEYS[2], ARGV[1]) == 1 then
+	redis.call("DEL", KEYS[1], KEYS[2])
+	return -1
+end
+return 0
+`)
+
+func sessionKey(id string) string {
+	return constants.SESSION_KEY_PREFIX + id
+}
+
+func usedKey(id string) string {
+	return constants.SESSION_USED_KEY_PREFIX + id
+}
+
+func userSessionsKey(userId string) string {
+	return constants.USER_SESSIONS_PREFIX + userId
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newRefreshToken returns a refresh token for a session. The session id is
+// kept in the token so it can be looked up without an extra index.
+func newRefreshToken(sessionId string) string {
+	return sessionId + "." + randomHex(32)
+}
+
+// Create starts a session for a device of the user and returns it along with its first refresh token
+func Create(ctx context.Context, rdb *redis.Client, user *dto.User, userAgent, ip string) (dto.Session, string, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	session := dto.Session{
+		Id:         randomHex(16),
+		UserId:     user.Id,
+		Email:      user.Email,
+		Role:       user.Role,
+		UserAgent:  userAgent,
+		Ip:         ip,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+	refreshToken := newRefreshToken(session.Id)
+
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, sessionKey(session.Id), map[string]any{
+			"user_id":      session.UserId,
+			"email":        session.Email,
+			"role":         session.Role,
+			"user_agent":   session.UserAgent,
+			"ip":           session.Ip,
+			"created_at":   now.Unix(),
+			"last_used_at": now.Unix(),
+			"refresh_hash": hashToken(refreshToken),
+		})
+		pipe.Expire(ctx, sessionKey(session.Id), constants.REFRESH_TOKEN_EXPIRY_TIME)
+		pipe.SAdd(ctx, userSessionsKey(session.UserId), session.Id)
+		pipe.Expire(ctx, userSessionsKey(session.UserId), constants.REFRESH_TOKEN_EXPIRY_TIME)
+		return nil
+	})
+	if err != nil {
+		return session, "", err
+	}
+
+	return session, refreshToken, nil
+}
+
+// Get returns a session, or redis.Nil if it expired or was revoked
+func Get(ctx context.Context, rdb *redis.Client, id string) (dto.Session, error) {
+	fields, err := rdb.HGetAll(ctx, sessionKey(id)).Result()
+	if err != nil {
+		return dto.Session{}, err
+	}
+	if len(fields) == 0 {
+		return dto.Session{}, redis.Nil
+	}
+
+	createdAt, _ := strconv.ParseInt(fields["created_at"], 10, 64)
+	lastUsedAt, _ := strconv.ParseInt(fields["last_used_at"], 10, 64)
+	return dto.Session{
+		Id:         id,
+		UserId:     fields["user_id"],
+		Email:      fields["email"],
+		Role:       fields["role"],
+		UserAgent:  fields["user_agent"],
+		Ip:         fields["ip"],
+		CreatedAt:  time.Unix(createdAt, 0).UTC(),
+		LastUsedAt: time.Unix(lastUsedAt, 0).UTC(),
+	}, nil
+}
+
+// Exists reports whether a session is still active
+func Exists(ctx context.Context, rdb *redis.Client, id string) (bool, error) {
+	exists, err := rdb.Exists(ctx, sessionKey(id)).Result()
+	return exists == 1, err
+}
+
+// Rotate exchanges a refresh token for a new one. Presenting a token that was
+// already exchanged revokes the session and returns ErrRefreshTokenReused.
+func Rotate(ctx context.Context, rdb *redis.Client, refreshToken string) (dto.Session, string, error) {
+	id, _, found := strings.Cut(refreshToken, ".")
+	if !found || id == "" {
+		return dto.Session{}, "", ErrInvalidRefreshToken
+	}
+
+	session, err := Get(ctx, rdb, id)
+	if err == redis.Nil {
+		return session, "", ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return session, "", err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	next := newRefreshToken(id)
+	keys := []string{sessionKey(id), usedKey(id)}
+	ttl := int64(constants.REFRESH_TOKEN_EXPIRY_TIME / time.Second)
+	result, err := rotateScript.Run(ctx, rdb, keys, hashToken(refreshToken), hashToken(next), now.Unix(), ttl).Int()
+	if err != nil {
+		return session, "", err
+	}
+
+	switch result {
+	case 1:
+		session.LastUsedAt = now
+		rdb.Expire(ctx, userSessionsKey(session.UserId), constants.REFRESH_TOKEN_EXPIRY_TIME)
+		return session, next, nil
+	case -1:
+		rdb.SRem(ctx, userSessionsKey(session.UserId), id)
+		return session, "", ErrRefreshTokenReused
+	default:
+		return session, "", ErrInvalidRefreshToken
+	}
+}
+
+// Revoke ends a session. Its access and refresh tokens stop working immediately.
+func Revoke(ctx context.Context, rdb *redis.Client, id string) error {
+	userId, err := rdb.HGet(ctx, sessionKey(id), "user_id").Result()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, sessionKey(id), usedKey(id))
+		pipe.SRem(ctx, userSessionsKey(userId), id)
+		return nil
+	})
+	return err
+}
+
+// List returns the active sessions of a user, most recently used first.
+// Sessions that expired are dropped from the user's index along the way.
+func List(ctx context.Context, rdb *redis.Client, userId string) ([]dto.Session, error) {
+	ids, err := rdb.SMembers(ctx, userSessionsKey(userId)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []dto.Session{}
+	for _, id := range ids {
+		session, err := Get(ctx, rdb, id)
+		if err == redis.Nil {
+			rdb.SRem(ctx, userSessionsKey(userId), id)
+			continue
+		}
+		if err != nil {
+			return sessions, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	slices.SortFunc(sessions, func(a, b dto.Session) int {
+		return b.LastUsedAt.Compare(a.LastUsedAt)
+	})
+	return sessions, nil
+}
+
+// RevokeAll ends every session of a user except exceptId
+func RevokeAll(ctx context.Context, rdb *redis.Client, userId, exceptId string) error {
+	ids, err := rdb.SMembers(ctx, userSessionsKey(userId)).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if id == exceptId {
+			continue
+		}
+		if err := Revoke(ctx, rdb, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
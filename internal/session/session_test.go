@@ -0,0 +1,126 @@
+package session_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/session"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+)
+
+// Tests refresh token rotation and that replaying a rotated token revokes the
+// whole session without touching other sessions of the user
+func TestRotateReuseDetection(t *testing.T) {
+	ctx := context.Background()
+
+	container, rdb, err := testUtils.SetUpRedisForTesting(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up redis for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		rdb.Close()
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	user := &dto.User{Id: testUtils.RandStringRunes(10), Email: testUtils.RandStringRunes(10)}
+
+	laptop, first, err := session.Create(ctx, rdb, user, "laptop", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Error creating session: %s", err)
+	}
+	phone, _, err := session.Create(ctx, rdb, user, "phone", "127.0.0.2")
+	if err != nil {
+		t.Fatalf("Error creating session: %s", err)
+	}
+
+	rotated, second, err := session.Rotate(ctx, rdb, first)
+	if err != nil {
+		t.Fatalf("Error rotating refresh token: %s", err)
+	}
+	if rotated.Id != laptop.Id || rotated.UserId != user.Id || second == first {
+		t.Errorf("Expected a new refresh token for the same session, got %v", rotated)
+	}
+
+	if _, _, err := session.Rotate(ctx, rdb, laptop.Id+".forged"); err != session.ErrInvalidRefreshToken {
+		t.Errorf("Expected unknown token to be rejected, got %v", err)
+	}
+	if active, err := session.Exists(ctx, rdb, laptop.Id); err != nil || !active {
+		t.Fatalf("Expected unknown token to leave the session active: %v", err)
+	}
+
+	if _, _, err := session.Rotate(ctx, rdb, first); err != session.ErrRefreshTokenReused {
+		t.Fatalf("Expected reuse to be detected, got %v", err)
+	}
+	if active, err := session.Exists(ctx, rdb, laptop.Id); err != nil || active {
+		t.Errorf("Expected session to be revoked after reuse: %v", err)
+	}
+	if _, _, err := session.Rotate(ctx, rdb, second); err != session.ErrInvalidRefreshToken {
+		t.Errorf("Expected the latest token of a revoked session to be rejected, got %v", err)
+	}
+
+	if active, err := session.Exists(ctx, rdb, phone.Id); err != nil || !active {
+		t.Errorf("Expected other sessions to stay active: %v", err)
+	}
+}
+
+// Tests listing the sessions of a user and revoking all but the current one
+func TestListRevokeAll(t *testing.T) {
+	ctx := context.Background()
+
+	container, rdb, err := testUtils.SetUpRedisForTesting(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up redis for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		rdb.Close()
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	user := &dto.User{Id: testUtils.RandStringRunes(10), Email: testUtils.RandStringRunes(10)}
+	other := &dto.User{Id: testUtils.RandStringRunes(10), Email: testUtils.RandStringRunes(10)}
+
+	current, _, err := session.Create(ctx, rdb, user, "laptop", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Error creating session: %s", err)
+	}
+	for range 2 {
+		if _, _, err := session.Create(ctx, rdb, user, "phone", "127.0.0.2"); err != nil {
+			t.Fatalf("Error creating session: %s", err)
+		}
+	}
+	otherSession, _, err := session.Create(ctx, rdb, other, "tablet", "127.0.0.3")
+	if err != nil {
+		t.Fatalf("Error creating session: %s", err)
+	}
+
+	sessions, err := session.List(ctx, rdb, user.Id)
+	if err != nil {
+		t.Fatalf("Error listing sessions: %s", err)
+	}
+	if len(sessions) != 3 {
+		t.Errorf("Expected 3 sessions, got %d", len(sessions))
+	}
+
+	if err := session.RevokeAll(ctx, rdb, user.Id, current.Id); err != nil {
+		t.Fatalf("Error revoking sessions: %s", err)
+	}
+
+	sessions, err = session.List(ctx, rdb, user.Id)
+	if err != nil {
+		t.Fatalf("Error listing sessions: %s", err)
+	}
+	if len(sessions) != 1 || sessions[0].Id != current.Id || sessions[0].UserAgent != "laptop" {
+		t.Errorf("Expected only the current session to remain, got %v", sessions)
+	}
+
+	if active, err := session.Exists(ctx, rdb, otherSession.Id); err != nil || !active {
+		t.Errorf("Expected sessions of other users to stay active: %v", err)
+	}
+}
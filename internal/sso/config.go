@@ -0,0 +1,46 @@
+package sso
+
+import (
+	"strings"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/utils"
+	"go.uber.org/zap"
+)
+
+type OidcConfig struct {
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	RedirectUrl  string
+	Scopes       []string
+}
+
+func DefaultOidcConfig() *OidcConfig {
+	config := &OidcConfig{
+		Issuer:       utils.GetDotEnvVariable(constants.OIDC_ISSUER),
+		ClientId:     utils.GetDotEnvVariable(constants.OIDC_CLIENT_ID),
+		ClientSecret: utils.GetDotEnvVariable(constants.OIDC_CLIENT_SECRET),
+		RedirectUrl:  utils.GetDotEnvVariable(constants.OIDC_REDIRECT_URL),
+		Scopes:       strings.Fields(utils.GetDotEnvVariable(constants.OIDC_SCOPES)),
+	}
+	if config.RedirectUrl == "" {
+		config.RedirectUrl = utils.GetDotEnvVariable(constants.SERVER_HOST) +
+			utils.GetDotEnvVariable(constants.SERVER_PORT) + constants.OIDC_CALLBACK_PATH
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = strings.Fields(constants.OIDC_DEFAULT_SCOPES)
+	}
+	return config
+}
+
+// DefaultOidcProvider returns the provider configured in the environment, or
+// nil when OIDC_ISSUER is not set and single sign-on is disabled
+func DefaultOidcProvider(log *zap.Logger) *OidcProvider {
+	config := DefaultOidcConfig()
+	if config.Issuer == "" {
+		log.Info("OIDC_ISSUER is not set, single sign-on is disabled")
+		return nil
+	}
+	return NewOidcProvider(config)
+}
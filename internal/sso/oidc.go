@@ -0,0 +1,115 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"golang.org/x/oauth2"
+)
+
+var ErrNonceMismatch = errors.New("ID token nonce does not match")
+
+// OidcProvider signs users in with the authorization code flow and PKCE.
+// The provider metadata is discovered on first use so the server starts even
+// if the identity provider is unreachable.
+type OidcProvider struct {
+	config   *OidcConfig
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func NewOidcProvider(config *OidcConfig) *OidcProvider {
+	return &OidcProvider{config: config}
+}
+
+func (p *OidcProvider) discover(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth != nil {
+		return p.oauth, p.verifier, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, constants.OIDC_DISCOVERY_LIMIT)
+	defer cancel()
+	provider, err := oidc.NewProvider(ctx, p.config.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to discover OIDC provider: %s", err)
+	}
+
+	p.oauth = &oauth2.Config{
+		ClientID:     p.config.ClientId,
+		ClientSecret: p.config.ClientSecret,
+		RedirectURL:  p.config.RedirectUrl,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       p.config.Scopes,
+	}
+	// The verifier fetches signing keys lazily, so it must outlive ctx
+	p.verifier = provider.VerifierContext(context.Background(), &oidc.Config{ClientID: p.config.ClientId})
+	return p.oauth, p.verifier, nil
+}
+
+// SecureCallback reports whether the identity provider redirects back over
+// https, in which case cookies for the callback are only sent over https
+func (p *OidcProvider) SecureCallback() bool {
+	return strings.HasPrefix(p.config.RedirectUrl, "https://")
+}
+
+// AuthCodeURL returns the identity provider URL to send the user to
+func (p *OidcProvider) AuthCodeURL(ctx context.Context, state string, login dto.OidcLogin) (string, error) {
+	oauth, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return oauth.AuthCodeURL(state, oidc.Nonce(login.Nonce), oauth2.S256ChallengeOption(login.Verifier)), nil
+}
+
+// Exchange redeems the code the identity provider redirected back with and
+// returns the identity from its verified ID token
+func (p *OidcProvider) Exchange(ctx context.Context, code string, login dto.OidcLogin) (dto.OidcIdentity, error) {
+	oauth, verifier, err := p.discover(ctx)
+	if err != nil {
+		return dto.OidcIdentity{}, err
+	}
+
+	token, err := oauth.Exchange(ctx, code, oauth2.VerifierOption(login.Verifier))
+	if err != nil {
+		return dto.OidcIdentity{}, fmt.Errorf("Failed to exchange code: %s", err)
+	}
+	rawIdToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return dto.OidcIdentity{}, fmt.Errorf("Token response has no id_token")
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIdToken)
+	if err != nil {
+		return dto.OidcIdentity{}, fmt.Errorf("Failed to verify ID token: %s", err)
+	}
+	if idToken.Nonce != login.Nonce {
+		return dto.OidcIdentity{}, ErrNonceMismatch
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return dto.OidcIdentity{}, fmt.Errorf("Failed to read ID token claims: %s", err)
+	}
+
+	return dto.OidcIdentity{
+		Issuer:        idToken.Issuer,
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
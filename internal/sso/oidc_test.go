@@ -0,0 +1,84 @@
+package sso_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/sso"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+	"golang.org/x/oauth2"
+)
+
+// authorize follows the redirect to the identity provider and returns the
+// code it redirects back with
+func authorize(t *testing.T, authUrl string) string {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authUrl)
+	if err != nil {
+		t.Fatalf("Error calling authorization endpoint: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("Expected status code: 302, got %d", resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("Error parsing redirect: %s", err)
+	}
+	return location.Query().Get("code")
+}
+
+// Tests the authorization code flow with PKCE against the mock provider
+func TestExchange(t *testing.T) {
+	ctx := context.Background()
+
+	mock, err := testUtils.NewMockOidcServer("client", "secret")
+	if err != nil {
+		t.Fatalf("Error starting mock oidc server: %s", err)
+	}
+	t.Cleanup(mock.Close)
+	mock.User = testUtils.MockOidcUser{Subject: "subject", Email: "sso@test", EmailVerified: true, Name: "sso"}
+
+	provider := sso.NewOidcProvider(&sso.OidcConfig{
+		Issuer:       mock.Issuer(),
+		ClientId:     "client",
+		ClientSecret: "secret",
+		RedirectUrl:  "http://localhost/auth/oidc/callback",
+		Scopes:       []string{"openid", "email"},
+	})
+
+	login := dto.OidcLogin{Verifier: oauth2.GenerateVerifier(), Nonce: "nonce"}
+	authUrl, err := provider.AuthCodeURL(ctx, "state", login)
+	if err != nil {
+		t.Fatalf("Error building auth url: %s", err)
+	}
+
+	identity, err := provider.Exchange(ctx, authorize(t, authUrl), login)
+	if err != nil {
+		t.Fatalf("Error exchanging code: %s", err)
+	}
+	if identity.Issuer != mock.Issuer() || identity.Subject != "subject" ||
+		identity.Email != "sso@test" || !identity.EmailVerified {
+		t.Errorf("Unexpected identity %+v", identity)
+	}
+
+	// A code is bound to the verifier it was requested with
+	code := authorize(t, authUrl)
+	if _, err := provider.Exchange(ctx, code, dto.OidcLogin{Verifier: oauth2.GenerateVerifier(), Nonce: "nonce"}); err == nil {
+		t.Errorf("Expected exchange with another verifier to fail")
+	}
+
+	// The ID token must carry the nonce of the login
+	code = authorize(t, authUrl)
+	if _, err := provider.Exchange(ctx, code, dto.OidcLogin{Verifier: login.Verifier, Nonce: "other"}); err != sso.ErrNonceMismatch {
+		t.Errorf("Expected %v, got %v", sso.ErrNonceMismatch, err)
+	}
+}
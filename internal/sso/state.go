@@ -0,0 +1,86 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/oauth2"
+)
+
+var ErrInvalidState = errors.New("Invalid or expired login state")
+
+func stateKey(state string) string {
+	return constants.OIDC_STATE_KEY_PREFIX + state
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Begin starts a login and returns its state along with the PKCE verifier and
+// nonce that the callback has to present
+func Begin(ctx context.Context, rdb *redis.Client) (string, dto.OidcLogin, error) {
+	state, err := randomHex(32)
+	if err != nil {
+		return "", dto.OidcLogin{}, err
+	}
+	nonce, err := randomHex(16)
+	if err != nil {
+		return "", dto.OidcLogin{}, err
+	}
+	login := dto.OidcLogin{Verifier: oauth2.GenerateVerifier(), Nonce: nonce}
+
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, stateKey(state), "verifier", login.Verifier, "nonce", login.Nonce)
+		pipe.Expire(ctx, stateKey(state), constants.OIDC_STATE_TTL)
+		return nil
+	})
+	if err != nil {
+		return "", dto.OidcLogin{}, err
+	}
+	return state, login, nil
+}
+
+// Finish returns the login started with state. Each state can be used once.
+func Finish(ctx context.Context, rdb *redis.Client, state string) (dto.OidcLogin, error) {
+	var fields *redis.MapStringStringCmd
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		fields = pipe.HGetAll(ctx, stateKey(state))
+		pipe.Del(ctx, stateKey(state))
+		return nil
+	})
+	if err != nil {
+		return dto.OidcLogin{}, err
+	}
+
+	values := fields.Val()
+	if len(values) == 0 {
+		return dto.OidcLogin{}, ErrInvalidState
+	}
+	return dto.OidcLogin{Verifier: values["verifier"], Nonce: values["nonce"]}, nil
+}
+
+// StateCookie returns the value of the cookie that ties a login to the
+// browser that started it. The browser only keeps a hash of the state.
+func StateCookie(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
+
+// MatchesStateCookie reports whether cookie was set by the browser that
+// started the login with state, so a callback URL of someone else's login
+// cannot sign the browser in to their account
+func MatchesStateCookie(state, cookie string) bool {
+	return subtle.ConstantTimeCompare([]byte(StateCookie(state)), []byte(cookie)) == 1
+}
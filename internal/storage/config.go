@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/utils"
+)
+
+type StorageConfig struct {
+	Backend   string
+	LocalRoot string
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	Region    string
+	UseSSL    bool
+}
+
+// NewStorage returns the backend selected by config.Backend
+func NewStorage(config *StorageConfig) (Storage, error) {
+	switch config.Backend {
+	case constants.STORAGE_BACKEND_LOCAL:
+		return NewLocalStorage(config.LocalRoot)
+	case constants.STORAGE_BACKEND_S3:
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return NewS3Storage(ctx, config)
+	default:
+		return nil, fmt.Errorf("Unknown storage backend %q", config.Backend)
+	}
+}
+
+func DefaultStorageConfig() *StorageConfig {
+	config := &StorageConfig{
+		Backend:   utils.GetDotEnvVariable(constants.STORAGE_BACKEND),
+		LocalRoot: utils.GetDotEnvVariable(constants.STORAGE_LOCAL_ROOT),
+		Endpoint:  utils.GetDotEnvVariable(constants.S3_ENDPOINT),
+		AccessKey: utils.GetDotEnvVariable(constants.S3_ACCESS_KEY),
+		SecretKey: utils.GetDotEnvVariable(constants.S3_SECRET_KEY),
+		Bucket:    utils.GetDotEnvVariable(constants.S3_BUCKET),
+		Region:    utils.GetDotEnvVariable(constants.S3_REGION),
+		UseSSL:    utils.GetDotEnvVariable(constants.S3_USE_SSL) == "true",
+	}
+	if config.Backend == "" {
+		config.Backend = constants.STORAGE_BACKEND_LOCAL
+	}
+	if config.LocalRoot == "" {
+		config.LocalRoot = constants.STORAGE_LOCAL_ROOT_DEFAULT
+	}
+	return config
+}
+
+func DefaultStorage() Storage {
+	storage, err := NewStorage(DefaultStorageConfig())
+	if err != nil {
+		panic(fmt.Errorf("Failed to get storage: %s", err))
+	}
+	return storage
+}
@@ -0,0 +1,78 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/storage"
+	"github.com/nihal-ramaswamy/GoChat/internal/testUtils"
+)
+
+func runStorageTest(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	key := storage.NewKey(testUtils.RandStringRunes(10))
+	content := []byte(testUtils.RandStringRunes(100))
+
+	if _, err := s.Get(ctx, key); err != storage.ErrNotFound {
+		t.Fatalf("Expected ErrNotFound before put, got %v", err)
+	}
+
+	if err := s.Put(ctx, key, bytes.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatalf("Error putting object: %s", err)
+	}
+
+	body, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Error getting object: %s", err)
+	}
+	got, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		t.Fatalf("Error reading object: %s", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("Expected %s, got %s", content, got)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Error deleting object: %s", err)
+	}
+	if _, err := s.Get(ctx, key); err != storage.ErrNotFound {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("Expected deleting a missing object to succeed, got %s", err)
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	s, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Error creating local storage: %s", err)
+	}
+
+	runStorageTest(t, s)
+
+	if err := s.Put(context.Background(), "../escape", bytes.NewReader(nil), 0, "text/plain"); err == nil {
+		t.Errorf("Expected keys outside the root to be rejected")
+	}
+}
+
+func TestS3Storage(t *testing.T) {
+	ctx := context.Background()
+
+	container, s, err := testUtils.SetUpMinioForTesting(ctx)
+	if err != nil {
+		t.Fatalf("Error setting up minio for testing: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	runStorageTest(t, s)
+}
@@ -3,10 +3,17 @@ package testUtils
 import (
 	"database/sql"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
-	amqpConfig "github.com/nihal-ramaswamy/GoChat/internal/amqp"
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/mailer"
+	"github.com/nihal-ramaswamy/GoChat/internal/outbox"
+	"github.com/nihal-ramaswamy/GoChat/internal/sso"
+	"github.com/nihal-ramaswamy/GoChat/internal/storage"
 	rdb "github.com/redis/go-redis/v9"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 	"github.com/testcontainers/testcontainers-go/modules/rabbitmq"
@@ -23,18 +30,30 @@ type ErrorDto struct {
 }
 
 type TokenDto struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
 }
 
 type TestConfig struct {
 	PostgresContainer *postgres.PostgresContainer
 	Db                *sql.DB
+	Users             db.UserRepository
+	Chats             db.ChatRepository
 	RabbitmqContainer *rabbitmq.RabbitMQContainer
-	AmqpConfig        *amqpConfig.AmqpConfig
-	RedisContainer    *redis.RedisContainer
-	Rdb               *rdb.Client
-	Server            *gin.Engine
-	Log               *zap.Logger
-	Upgrader          *websocket.Upgrader
-	WebsocketMap      *dto.WebsocketConnectionMap
+	Broker            broker.Broker
+	// Relay is not running, tests publish saved chats with RelayPending
+	Relay          *outbox.Relay
+	RedisContainer *redis.RedisContainer
+	// MiniRedis serves Rdb instead of RedisContainer in SetUpMemoryRouter
+	MiniRedis    *miniredis.Miniredis
+	Rdb          *rdb.Client
+	Server       *gin.Engine
+	Log          *zap.Logger
+	Upgrader     *websocket.Upgrader
+	WebsocketMap *dto.WebsocketConnectionMap
+	Storage      storage.Storage
+	Mailer       *mailer.FileMailer
+	// OidcProvider is nil, so single sign-on is disabled unless a test sets it
+	OidcProvider *sso.OidcProvider
+	Keys         *jwtkeys.KeySet
 }
@@ -0,0 +1,99 @@
+package testUtils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/mailer"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// MailpitContainer runs Mailpit, which accepts mail over SMTP and serves it
+// back over its HTTP API
+type MailpitContainer struct {
+	testcontainers.Container
+	ApiUrl string
+}
+
+// MailpitMessage is a mail read back from Mailpit
+type MailpitMessage struct {
+	ID      string
+	Subject string
+	From    struct{ Address string }
+	To      []struct{ Address string }
+	Text    string
+}
+
+func SetUpMailpitForTesting(ctx context.Context, from string) (*MailpitContainer, *mailer.SmtpMailer, error) {
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "axllent/mailpit:v1.20",
+			ExposedPorts: []string{"1025/tcp", "8025/tcp"},
+			WaitingFor: wait.ForAll(
+				wait.ForListeningPort("1025/tcp"),
+				wait.ForHTTP("/api/v1/messages").WithPort("8025/tcp"),
+			),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get mailpit container: %s", err)
+	}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get mailpit host: %s", err)
+	}
+	smtpPort, err := container.MappedPort(ctx, "1025/tcp")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get smtp port: %s", err)
+	}
+	apiPort, err := container.MappedPort(ctx, "8025/tcp")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get api port: %s", err)
+	}
+
+	mailpit := &MailpitContainer{
+		Container: container,
+		ApiUrl:    fmt.Sprintf("http://%s:%s/api/v1", host, apiPort.Port()),
+	}
+	return mailpit, mailer.NewSmtpMailer(host, smtpPort.Port(), "", "", from), nil
+}
+
+// Messages returns every mail Mailpit received, newest first, with its text
+func (m *MailpitContainer) Messages(ctx context.Context) ([]MailpitMessage, error) {
+	var list struct{ Messages []MailpitMessage }
+	if err := m.get(ctx, "/messages", &list); err != nil {
+		return nil, err
+	}
+
+	messages := []MailpitMessage{}
+	for _, summary := range list.Messages {
+		var message MailpitMessage
+		if err := m.get(ctx, "/message/"+summary.ID, &message); err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func (m *MailpitContainer) get(ctx context.Context, path string, result any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.ApiUrl+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Unexpected status %d from %s", resp.StatusCode, path)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
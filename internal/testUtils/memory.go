@@ -0,0 +1,88 @@
+package testUtils
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/fx_utils"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/mailer"
+	"github.com/nihal-ramaswamy/GoChat/internal/outbox"
+	"github.com/nihal-ramaswamy/GoChat/internal/storage"
+	"github.com/nihal-ramaswamy/GoChat/internal/utils"
+	rdb "github.com/redis/go-redis/v9"
+)
+
+// SetUpMemoryRouter is SetUpRouter without containers. Users and chats are
+// kept in a MemoryRepository and Redis is served by an in-process server, so
+// tests run in milliseconds. Events go through a MemoryBroker. Db is nil, so
+// only routes that go through the repositories can be served.
+func SetUpMemoryRouter() (*TestConfig, error) {
+	redisServer, err := miniredis.Run()
+	if err != nil {
+		return nil, fmt.Errorf("Redis server error: %s", err)
+	}
+
+	storageDir, err := os.MkdirTemp("", "attachments")
+	if err != nil {
+		return nil, fmt.Errorf("Storage directory error: %s", err)
+	}
+	localStorage, err := storage.NewLocalStorage(storageDir)
+	if err != nil {
+		return nil, fmt.Errorf("Storage error: %s", err)
+	}
+
+	mailDir, err := os.MkdirTemp("", "mails")
+	if err != nil {
+		return nil, fmt.Errorf("Mail directory error: %s", err)
+	}
+
+	os.Setenv(constants.ENV, "test")
+	// .env.test is optional without containers, variables it would set fall
+	// back to these
+	for key, value := range map[string]string{
+		constants.JWT_ISSUER: "http://localhost:8080",
+		"SECRET_KEY":         "secret",
+	} {
+		if _, ok := os.LookupEnv(key); !ok {
+			os.Setenv(key, value)
+		}
+	}
+	log := utils.NewZapLogger()
+
+	fileMailer, err := mailer.NewFileMailer(mailDir, "test@gochat.local", log)
+	if err != nil {
+		return nil, fmt.Errorf("Mailer error: %s", err)
+	}
+
+	keys, err := jwtkeys.DefaultKeySet(log)
+	if err != nil {
+		return nil, fmt.Errorf("JWT keys error: %s", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	repository := db.NewMemoryRepository()
+	broker := broker.NewMemoryBroker()
+
+	return &TestConfig{
+		Users:        repository,
+		Chats:        repository,
+		Broker:       broker,
+		Relay:        outbox.NewRelay(repository, broker, log),
+		MiniRedis:    redisServer,
+		Rdb:          rdb.NewClient(&rdb.Options{Addr: redisServer.Addr()}),
+		Server:       gin.New(),
+		Log:          log,
+		Upgrader:     fx_utils.NewWebsocketUpgrader(),
+		WebsocketMap: dto.NewWebsocketConnectionMap(),
+		Storage:      localStorage,
+		Mailer:       fileMailer,
+		Keys:         keys,
+	}, nil
+}
@@ -0,0 +1,39 @@
+package testUtils
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/storage"
+	"github.com/testcontainers/testcontainers-go/modules/minio"
+)
+
+func SetUpMinioForTesting(ctx context.Context) (*minio.MinioContainer, *storage.S3Storage, error) {
+	container, err := minio.Run(ctx,
+		"minio/minio:RELEASE.2024-01-16T16-07-38Z",
+		minio.WithUsername("minioadmin"),
+		minio.WithPassword("minioadmin"),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get minio container: %s", err)
+	}
+
+	endpoint, err := container.ConnectionString(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get connection string: %s", err)
+	}
+
+	s3Storage, err := storage.NewS3Storage(ctx, &storage.StorageConfig{
+		Backend:   constants.STORAGE_BACKEND_S3,
+		Endpoint:  endpoint,
+		AccessKey: container.Username,
+		SecretKey: container.Password,
+		Bucket:    "go-chat-test",
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get s3 storage: %s", err)
+	}
+
+	return container, s3Storage, nil
+}
@@ -10,9 +10,15 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
 	"github.com/nihal-ramaswamy/GoChat/internal/constants"
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
 	"github.com/nihal-ramaswamy/GoChat/internal/fx_utils"
+	"github.com/nihal-ramaswamy/GoChat/internal/jwtkeys"
+	"github.com/nihal-ramaswamy/GoChat/internal/mailer"
+	"github.com/nihal-ramaswamy/GoChat/internal/outbox"
+	"github.com/nihal-ramaswamy/GoChat/internal/storage"
 	"github.com/nihal-ramaswamy/GoChat/internal/utils"
 )
 
@@ -36,8 +42,8 @@ func FilterChatsByUserId(chats []*dto.Chat, userId string) int {
 	return count
 }
 
-func SetUpRouter(rootDir string, ctx context.Context) (*TestConfig, error) {
-	postgresContainer, db, err := SetUpPostgresForTesting(ctx, rootDir)
+func SetUpRouter(ctx context.Context) (*TestConfig, error) {
+	postgresContainer, pdb, err := SetUpPostgresForTesting(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("PostgresContainer error: %s", err)
 	}
@@ -48,26 +54,59 @@ func SetUpRouter(rootDir string, ctx context.Context) (*TestConfig, error) {
 	}
 
 	redisContainer, rdb, err := SetUpRedisForTesting(ctx)
+	storageDir, err := os.MkdirTemp("", "attachments")
+	if err != nil {
+		return nil, fmt.Errorf("Storage directory error: %s", err)
+	}
+	localStorage, err := storage.NewLocalStorage(storageDir)
+	if err != nil {
+		return nil, fmt.Errorf("Storage error: %s", err)
+	}
+
+	mailDir, err := os.MkdirTemp("", "mails")
+	if err != nil {
+		return nil, fmt.Errorf("Mail directory error: %s", err)
+	}
+
 	upgrader := fx_utils.NewWebsocketUpgrader()
 	webscoketMap := dto.NewWebsocketConnectionMap()
 
 	os.Setenv(constants.ENV, "test")
 	log := utils.NewZapLogger()
 
+	fileMailer, err := mailer.NewFileMailer(mailDir, "test@gochat.local", log)
+	if err != nil {
+		return nil, fmt.Errorf("Mailer error: %s", err)
+	}
+
+	keys, err := jwtkeys.DefaultKeySet(log)
+	if err != nil {
+		return nil, fmt.Errorf("JWT keys error: %s", err)
+	}
+
 	gin.SetMode(gin.TestMode)
 	server := gin.Default()
 
+	repository := db.NewPostgresRepository(pdb, log)
+	broker := broker.NewRabbitMqBroker(amqpConfig, log)
+
 	return &TestConfig{
 		PostgresContainer: postgresContainer,
-		Db:                db,
+		Db:                pdb,
+		Users:             repository,
+		Chats:             repository,
 		RabbitmqContainer: rabbitmqContainer,
-		AmqpConfig:        amqpConfig,
+		Broker:            broker,
+		Relay:             outbox.NewRelay(repository, broker, log),
 		RedisContainer:    redisContainer,
 		Rdb:               rdb,
 		Server:            server,
 		Log:               log,
 		Upgrader:          upgrader,
 		WebsocketMap:      webscoketMap,
+		Storage:           localStorage,
+		Mailer:            fileMailer,
+		Keys:              keys,
 	}, nil
 }
 
@@ -4,13 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"path/filepath"
 	"time"
 
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
+	"github.com/nihal-ramaswamy/GoChat/internal/migrations"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 	"github.com/testcontainers/testcontainers-go/wait"
+	"go.uber.org/zap"
 )
 
 func GetDbConfig() *dto.TestConfigDto {
@@ -23,13 +24,11 @@ func GetDbConfig() *dto.TestConfigDto {
 
 func GetPostgresContainer(
 	testConfig *dto.TestConfigDto,
-	rootDir string,
 	ctx context.Context,
 ) (*postgres.PostgresContainer, error) {
 	container, err := postgres.Run(
 		ctx,
 		"docker.io/postgres:16-alpine",
-		postgres.WithInitScripts(filepath.Join(rootDir, "db", "init.sql")),
 		postgres.WithUsername(testConfig.Username),
 		postgres.WithPassword(testConfig.Password),
 		postgres.WithDatabase(testConfig.DatabaseName),
@@ -42,10 +41,12 @@ func GetPostgresContainer(
 	return container, err
 }
 
-func SetUpPostgresForTesting(ctx context.Context, rootDir string) (*postgres.PostgresContainer, *sql.DB, error) {
+// SetUpPostgresForTesting starts Postgres and creates the schema with the
+// same migrations the server runs
+func SetUpPostgresForTesting(ctx context.Context) (*postgres.PostgresContainer, *sql.DB, error) {
 	testConfig := GetDbConfig()
 
-	container, err := GetPostgresContainer(testConfig, rootDir, ctx)
+	container, err := GetPostgresContainer(testConfig, ctx)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get postgres container: %s", err)
 	}
@@ -65,6 +66,10 @@ func SetUpPostgresForTesting(ctx context.Context, rootDir string) (*postgres.Pos
 		return nil, nil, fmt.Errorf("failed to ping db: %s", err)
 	}
 
+	if _, err := migrations.Up(ctx, db, zap.NewNop()); err != nil {
+		return nil, nil, fmt.Errorf("failed to migrate db: %s", err)
+	}
+
 	return container, db, nil
 }
 
@@ -3,10 +3,12 @@ package testUtils
 import (
 	"context"
 	"fmt"
+	"time"
 
 	amqpConfig "github.com/nihal-ramaswamy/GoChat/internal/amqp"
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
 	"github.com/testcontainers/testcontainers-go/modules/rabbitmq"
+	"go.uber.org/zap"
 )
 
 func GetRabbitMqConfig() *dto.TestConfigDto {
@@ -42,9 +44,12 @@ func SetUpRabbitMqForTesting(ctx context.Context) (*rabbitmq.RabbitMQContainer,
 		return nil, nil, fmt.Errorf("failed to get amqp url: %s", err)
 	}
 
-	amqpConfigDto, err := amqpConfig.NewAmqpConfig(amqpURL)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get amqp config: %s", err)
+	amqpConfigDto := amqpConfig.NewAmqpConfig(amqpURL, zap.NewNop())
+	select {
+	case <-amqpConfigDto.Ready():
+	case <-time.After(30 * time.Second):
+		amqpConfigDto.Close()
+		return nil, nil, fmt.Errorf("failed to connect to rabbitmq: %s", amqpConfigDto.State())
 	}
 
 	return container, amqpConfigDto, nil
@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
@@ -17,9 +19,10 @@ func GetDotEnvVariable(key string) string {
 		envFile = dir(".env." + envFile)
 	}
 
+	// Without the file the variables are read from the environment alone
 	err := godotenv.Load(envFile)
 
-	if nil != err {
+	if nil != err && !errors.Is(err, fs.ErrNotExist) {
 		log.Fatalf("Error loading .env file: %s", err)
 	}
 
@@ -6,20 +6,19 @@ import (
 	"github.com/nihal-ramaswamy/GoChat/internal/dto"
 )
 
+// CreateNewConnection upgrades the request to a websocket connection and adds
+// it to the map under connectionId
 func CreateNewConnection(
 	websocketConnectionMap *dto.WebsocketConnectionMap,
 	upgrader *websocket.Upgrader,
 	c *gin.Context,
-	id string,
-) error {
-	data, exists := websocketConnectionMap.Get(id)
-	if exists && data.Active {
-		return nil
-	}
+	connectionId string,
+) (*dto.WebsocketConnection, error) {
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	websocketConnectionMap.Add(id, dto.NewWebsocketConnection(conn))
-	return nil
+	connection := dto.NewWebsocketConnection(conn)
+	websocketConnectionMap.Add(connectionId, connection)
+	return connection, nil
 }
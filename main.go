@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
+	"os"
+	"sync"
+
 	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq"
-	amqpConfig "github.com/nihal-ramaswamy/GoChat/internal/amqp"
+	"github.com/nihal-ramaswamy/GoChat/internal/broker"
 	"github.com/nihal-ramaswamy/GoChat/internal/fx_utils"
+	"github.com/nihal-ramaswamy/GoChat/internal/outbox"
+	"github.com/nihal-ramaswamy/GoChat/internal/presence"
 	"github.com/nihal-ramaswamy/GoChat/internal/server"
 	"github.com/nihal-ramaswamy/GoChat/internal/utils"
 	"go.uber.org/fx"
@@ -12,6 +18,21 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		log := utils.NewZapLogger()
+		if err := migrate(os.Args[2:], log); err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		log := utils.NewZapLogger()
+		if err := admin(os.Args[2:], log); err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
+
 	fx.New(
 		fx.Provide(utils.NewZapLogger),
 		utils.FxLogger(),
@@ -28,13 +49,34 @@ func main() {
 func Invoke(
 	server *gin.Engine,
 	config *server.Config,
-	amqpConfig *amqpConfig.AmqpConfig,
+	broker broker.Broker,
+	relay *outbox.Relay,
+	sweeper *presence.Sweeper,
 	log *zap.Logger,
 ) {
 	defer func() {
-		log.Info("Closing amqp connection")
-		amqpConfig.Channel.Close()
-		amqpConfig.Conn.Close()
+		log.Info("Closing broker")
+		if err := broker.Close(); err != nil {
+			log.Error(err.Error())
+		}
+	}()
+
+	// Deferred after closing the broker, so the relay and the sweeper stop
+	// before it
+	ctx, cancel := context.WithCancel(context.Background())
+	var stopped sync.WaitGroup
+	stopped.Add(2)
+	go func() {
+		defer stopped.Done()
+		relay.Run(ctx)
+	}()
+	go func() {
+		defer stopped.Done()
+		sweeper.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		stopped.Wait()
 	}()
 
 	err := server.Run(config.Port)
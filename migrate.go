@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/nihal-ramaswamy/GoChat/internal/db"
+	"github.com/nihal-ramaswamy/GoChat/internal/migrations"
+	postgresConfig "github.com/nihal-ramaswamy/GoChat/internal/postgres"
+	"go.uber.org/zap"
+)
+
+// migrate runs the migrate subcommand:
+//
+//	go run . migrate up
+//	go run . migrate down [steps]
+//	go run . migrate version
+func migrate(args []string, log *zap.Logger) error {
+	pdb := db.GetPostgresDbInstanceWithConfig(postgresConfig.GetPsqlInfoDefault(), log)
+	defer pdb.Close()
+
+	ctx := context.Background()
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		count, err := migrations.Up(ctx, pdb, log)
+		if err != nil {
+			return err
+		}
+		log.Info("Applied migrations", zap.Int("count", count))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("Invalid number of steps %s", args[1])
+			}
+		}
+		count, err := migrations.Down(ctx, pdb, log, steps)
+		if err != nil {
+			return err
+		}
+		log.Info("Reverted migrations", zap.Int("count", count))
+	case "version":
+		version, err := migrations.Version(ctx, pdb)
+		if err != nil {
+			return err
+		}
+		log.Info("Current schema version", zap.Int64("version", version))
+	default:
+		return fmt.Errorf("Unknown migrate command %s, expected up, down or version", command)
+	}
+	return nil
+}